@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net"
+	"os"
 
+	"github.com/johnroshan2255/auth-service/internal/authz"
 	"github.com/johnroshan2255/auth-service/internal/config"
 	"github.com/johnroshan2255/auth-service/internal/database"
 	"github.com/johnroshan2255/auth-service/internal/middleware"
@@ -14,6 +18,7 @@ import (
 	authv1 "github.com/johnroshan2255/auth-service/proto/auth/v1"
 	"github.com/joho/godotenv"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
@@ -36,10 +41,150 @@ func main() {
 	}
 	defer database.CloseDB(db)
 
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
 	userRepo := repository.NewPostgresUserRepo(db)
 	authService := service.NewAuthService(userRepo)
 
-	// Set service key for backend-to-backend gRPC authentication
+	// Every user belongs to a tenant; adopt users created before tenants existed
+	authService.SetTenantRepo(repository.NewPostgresTenantRepo(db), cfg.DefaultTenantSlug)
+	if err := authService.AdoptUsersWithoutTenant(context.Background()); err != nil {
+		log.Fatalf("failed to assign users to a tenant: %v", err)
+	}
+	authService.SetMembershipRepo(repository.NewPostgresMembershipRepo(db))
+	if err := authService.BackfillMemberships(context.Background()); err != nil {
+		log.Fatalf("failed to backfill tenant memberships: %v", err)
+	}
+
+	// Roles map to permission sets checked by RequirePermission
+	authService.SetRoleRepo(repository.NewPostgresRoleRepo(db))
+	if err := authService.EnsureBuiltinRoles(context.Background()); err != nil {
+		log.Fatalf("failed to create built-in roles: %v", err)
+	}
+	middleware.SetPermissionChecker(authService)
+	authService.SetAccessPolicyRepo(repository.NewPostgresAccessPolicyRepo(db))
+
+	// Track logins as revocable sessions
+	authService.SetSessionRepo(repository.NewPostgresSessionRepo(db, repository.SessionPolicy{
+		MaxActive:        cfg.SessionMaxPerUser,
+		EvictOldest:      cfg.SessionLimitPolicy != "reject_newest",
+		IdleTimeout:      cfg.SessionIdleTimeout,
+		AbsoluteLifetime: cfg.SessionAbsoluteLifetime,
+	}))
+	middleware.SetSessionChecker(authService)
+	authService.SetLoginHistoryRepo(repository.NewPostgresLoginHistoryRepo(db))
+
+	// Audit impersonation, token exchange and everything done with the resulting tokens
+	authService.SetAuditRepo(repository.NewPostgresAuditRepo(db))
+	middleware.SetAuditRecorder(authService)
+	if len(cfg.ImpersonationScopes) > 0 {
+		if err := authService.SetImpersonationScopes(cfg.ImpersonationScopes); err != nil {
+			log.Fatalf("invalid IMPERSONATION_SCOPES: %v", err)
+		}
+	}
+
+	// Enable WebAuthn / passkeys when a relying party is configured
+	webAuthn, err := service.NewWebAuthn(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize webauthn: %v", err)
+	}
+	if webAuthn != nil {
+		authService.SetWebAuthn(webAuthn, repository.NewPostgresWebAuthnRepo(db))
+	} else {
+		log.Println("Warning: WEBAUTHN_RP_ID not set. Passkey login will be disabled.")
+	}
+
+	// Transactional email carries single-use links, so features that send it stay
+	// disabled until a real relay is configured
+	if cfg.SMTPHost != "" {
+		emailSender, err := service.NewSMTPEmailSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.EmailFrom)
+		if err != nil {
+			log.Fatalf("invalid SMTP configuration: %v", err)
+		}
+		authService.SetEmailSender(emailSender)
+	} else {
+		log.Println("Warning: SMTP_HOST not set. Magic links, invitations and new-device alerts will be disabled.")
+	}
+
+	// Phone verification and OTP login need a real SMS provider
+	if cfg.TwilioAccountSID != "" {
+		smsSender, err := service.NewTwilioSMSSender(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.TwilioFrom)
+		if err != nil {
+			log.Fatalf("invalid SMS configuration: %v", err)
+		}
+		authService.SetSMSSender(smsSender, repository.NewPostgresPhoneOTPRepo(db), cfg.PhoneOTPLoginEnabled)
+	} else if cfg.PhoneOTPLoginEnabled {
+		log.Fatal("PHONE_OTP_LOGIN_ENABLED requires an SMS provider (TWILIO_ACCOUNT_SID)")
+	} else {
+		log.Println("Warning: TWILIO_ACCOUNT_SID not set. Phone verification will be disabled.")
+	}
+
+	authService.SetTenantDomains(repository.NewPostgresTenantDomainRepo(db), repository.NewPostgresJoinRequestRepo(db))
+
+	if cfg.InvitationURL != "" {
+		authService.SetInvitations(repository.NewPostgresInvitationRepo(db), cfg.InvitationURL)
+	} else {
+		log.Println("Warning: INVITATION_URL not set. Tenant invitations will be disabled.")
+	}
+
+	// The client registry and the token endpoint do not need the consent page,
+	// so machine clients can use client credentials without it
+	authService.SetOAuth(repository.NewPostgresOAuthClientRepo(db), repository.NewPostgresOAuthGrantRepo(db), cfg.OAuthConsentURL)
+	// Dynamic client registration with admin-issued initial access tokens
+	authService.SetInitialAccessTokenRepo(repository.NewPostgresInitialAccessTokenRepo(db))
+	// Backends authenticate gRPC calls with client credentials tokens
+	middleware.SetServiceTokenVerifier(authService)
+
+	if cfg.OAuthConsentURL != "" {
+		// Device authorization grant for CLIs and other input-constrained devices
+		if cfg.OAuthDeviceVerificationURL != "" {
+			authService.SetDeviceAuthorization(cfg.OAuthDeviceVerificationURL)
+		}
+
+		// OpenID Connect: ID tokens, discovery, userinfo and logout
+		if cfg.OIDCIssuer != "" {
+			if cfg.OIDCSigningKeyFile == "" {
+				log.Println("Warning: OIDC_SIGNING_KEY_FILE not set. ID tokens will not verify after a restart.")
+			}
+			signingKey, err := service.LoadOIDCSigningKey(cfg.OIDCSigningKeyFile)
+			if err != nil {
+				log.Fatalf("failed to load OIDC signing key: %v", err)
+			}
+			authService.SetOIDC(cfg.OIDCIssuer, signingKey)
+		}
+	} else {
+		log.Println("Warning: OAUTH_CONSENT_URL not set. The authorization code, device and OpenID Connect flows will be disabled.")
+	}
+
+	if cfg.MagicLinkURL != "" {
+		authService.SetMagicLink(repository.NewPostgresMagicLinkRepo(db), cfg.MagicLinkURL)
+	} else {
+		log.Println("Warning: MAGIC_LINK_URL not set. Magic-link login will be disabled.")
+	}
+
+	// Federated login through upstream OpenID Connect providers
+	if cfg.OIDCProvidersFile != "" && cfg.FederatedLoginRedirectURL != "" {
+		data, err := os.ReadFile(cfg.OIDCProvidersFile)
+		if err != nil {
+			log.Fatalf("failed to read OIDC providers: %v", err)
+		}
+		var providers []service.FederatedProvider
+		if err := json.Unmarshal(data, &providers); err != nil {
+			log.Fatalf("invalid OIDC providers: %v", err)
+		}
+		if err := authService.SetFederation(repository.NewPostgresFederationRepo(db), cfg.FederatedLoginRedirectURL, providers); err != nil {
+			log.Fatalf("invalid OIDC providers: %v", err)
+		}
+	} else if cfg.OIDCProvidersFile != "" {
+		log.Println("Warning: FEDERATED_LOGIN_REDIRECT_URL not set. Federated login will be disabled.")
+	}
+
+	// Backends authenticate gRPC calls with their own service keys; the legacy
+	// shared key is still accepted when set
+	authService.SetServiceKeyRepo(repository.NewPostgresServiceKeyRepo(db))
+	middleware.SetServiceKeyVerifier(authService)
 	if cfg.ServiceKey != "" {
 		middleware.SetServiceKey(cfg.ServiceKey)
 	}
@@ -53,6 +198,49 @@ func main() {
 		defer notificationService.Close()
 	}
 
+	// Relationship-based authorization for backends (document sharing and the like)
+	var relationEngine *authz.Engine
+	if cfg.RelationSchemaFile != "" {
+		source, err := os.ReadFile(cfg.RelationSchemaFile)
+		if err != nil {
+			log.Fatalf("failed to read relation schema: %v", err)
+		}
+		schema, err := authz.ParseSchema(string(source))
+		if err != nil {
+			log.Fatalf("invalid relation schema: %v", err)
+		}
+		relationEngine = authz.NewEngine(schema, repository.NewPostgresRelationTupleRepo(db))
+	}
+
+	// Serve gRPC over TLS when a certificate is configured, optionally requiring client certificates
+	serverOptions := []grpc.ServerOption{grpc.UnaryInterceptor(middleware.BackendAuthInterceptor)}
+	if cfg.TLSEnabled && cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		tlsConfig, err := grpchandler.ServerTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile, cfg.TLSRequireClientCert)
+		if err != nil {
+			log.Fatalf("failed to configure gRPC TLS: %v", err)
+		}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else {
+		log.Println("Warning: TLS_ENABLED, TLS_CERT_FILE and TLS_KEY_FILE not all set. gRPC will be served without TLS.")
+	}
+	if cfg.GRPCClientIdentitiesFile != "" {
+		// Identities are matched against verified client certificates, which need TLS and a CA to verify them
+		if !cfg.TLSEnabled || cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" || cfg.TLSClientCAFile == "" {
+			log.Fatal("GRPC_CLIENT_IDENTITIES_FILE requires TLS (TLS_ENABLED, TLS_CERT_FILE, TLS_KEY_FILE) and TLS_CLIENT_CA_FILE")
+		}
+		data, err := os.ReadFile(cfg.GRPCClientIdentitiesFile)
+		if err != nil {
+			log.Fatalf("failed to read gRPC client identities: %v", err)
+		}
+		var identities []middleware.ClientIdentity
+		if err := json.Unmarshal(data, &identities); err != nil {
+			log.Fatalf("invalid gRPC client identities: %v", err)
+		}
+		if err := middleware.SetClientIdentities(identities); err != nil {
+			log.Fatalf("invalid gRPC client identities: %v", err)
+		}
+	}
+
 	// Start gRPC server in a goroutine
 	go func() {
 		grpcPort := cfg.GRPCPort
@@ -65,12 +253,13 @@ func main() {
 			log.Fatalf("failed to listen on gRPC port: %v", err)
 		}
 
-		grpcServer := grpc.NewServer(
-			grpc.UnaryInterceptor(middleware.BackendAuthInterceptor),
-		)
+		grpcServer := grpc.NewServer(serverOptions...)
 
 		handler := grpchandler.NewAuthHandler(authService)
 		authv1.RegisterAuthServiceServer(grpcServer, handler)
+		if relationEngine != nil {
+			authv1.RegisterRelationServiceServer(grpcServer, grpchandler.NewRelationHandler(relationEngine))
+		}
 
 		log.Printf("gRPC server (backend-to-backend) running on %s", grpcPort)
 		if err := grpcServer.Serve(lis); err != nil {
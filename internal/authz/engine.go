@@ -0,0 +1,448 @@
+// Package authz implements relationship-based authorization in the style of
+// Zanzibar: relation tuples (object#relation@subject) are evaluated against a
+// schema of relation rewrites to answer Check, Expand and ListObjects.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	// maxDepth bounds how many relations a single evaluation may follow
+	maxDepth = 25
+	// maxTupleUpdates bounds a single Write
+	maxTupleUpdates = 100
+	// maxListObjects bounds ListObjects results; maxListObjectsScan bounds the
+	// candidate objects it checks
+	maxListObjects     = 1000
+	maxListObjectsScan = 10000
+	listObjectsPage    = 200
+	// checkCacheTTL is how long Check results are reused for requests that do
+	// not ask for a fresher revision
+	checkCacheTTL = 5 * time.Second
+)
+
+var (
+	ErrInvalidRequest   = errors.New("invalid request")
+	ErrDepthExceeded    = errors.New("relation graph too deep")
+	ErrTokenUnavailable = errors.New("consistency token is newer than the relation store")
+)
+
+// Engine evaluates relation tuples of every tenant against one schema
+type Engine struct {
+	schema *Schema
+	repo   repository.RelationTupleRepository
+
+	mu     sync.Mutex
+	checks map[string]cachedCheck
+}
+
+type cachedCheck struct {
+	allowed   bool
+	revision  int64
+	expiresAt time.Time
+}
+
+// ExpandTree is the userset tree of an object's relation. Leaves list the
+// subjects stored for a relation; other nodes combine their children.
+type ExpandTree struct {
+	Operation string // "leaf", "union", "intersection" or "exclusion"
+	Object    Object
+	Relation  string
+	Subjects  []Subject
+	Children  []*ExpandTree
+}
+
+func NewEngine(schema *Schema, repo repository.RelationTupleRepository) *Engine {
+	return &Engine{schema: schema, repo: repo, checks: make(map[string]cachedCheck)}
+}
+
+// Write applies tuple deletions and then writes for a tenant atomically and
+// returns a consistency token for the write
+func (e *Engine) Write(ctx context.Context, tenantUUID string, writes, deletes []Tuple) (string, error) {
+	if err := validateTenant(tenantUUID); err != nil {
+		return "", err
+	}
+	if len(writes)+len(deletes) == 0 {
+		return "", fmt.Errorf("%w: no updates", ErrInvalidRequest)
+	}
+	if len(writes)+len(deletes) > maxTupleUpdates {
+		return "", fmt.Errorf("%w: at most %d updates per write", ErrInvalidRequest, maxTupleUpdates)
+	}
+
+	writeModels := make([]model.RelationTuple, 0, len(writes))
+	for _, tuple := range writes {
+		if !e.schema.isRelation(tuple.Object.Type, tuple.Relation) {
+			return "", fmt.Errorf("%w: %s#%s is not a relation", ErrInvalidRequest, tuple.Object.Type, tuple.Relation)
+		}
+		if !e.schema.allows(tuple.Object.Type, tuple.Relation, tuple.Subject) {
+			return "", fmt.Errorf("%w: %s#%s does not accept %s", ErrInvalidRequest, tuple.Object.Type, tuple.Relation, tuple.Subject)
+		}
+		writeModels = append(writeModels, tuple.model())
+	}
+	deleteModels := make([]model.RelationTuple, 0, len(deletes))
+	for _, tuple := range deletes {
+		deleteModels = append(deleteModels, tuple.model())
+	}
+
+	revision, err := e.repo.WriteTuples(ctx, tenantUUID, writeModels, deleteModels)
+	if err != nil {
+		return "", err
+	}
+	return encodeToken(revision), nil
+}
+
+// Check reports whether subject has relation (or permission) to object. The
+// answer reflects every write up to consistencyToken, or may be a few seconds
+// stale when the token is empty. It returns the token the answer was made at.
+func (e *Engine) Check(ctx context.Context, tenantUUID string, object Object, relation string, subject Subject, consistencyToken string) (bool, string, error) {
+	if err := e.validateQuery(tenantUUID, object.Type, relation, subject); err != nil {
+		return false, "", err
+	}
+	minRevision, revision, err := e.revisions(ctx, consistencyToken)
+	if err != nil {
+		return false, "", err
+	}
+
+	key := tenantUUID + "|" + object.String() + "#" + relation + "@" + subject.String()
+	e.mu.Lock()
+	cached, ok := e.checks[key]
+	e.mu.Unlock()
+	if ok && cached.revision >= minRevision && time.Now().Before(cached.expiresAt) {
+		return cached.allowed, encodeToken(cached.revision), nil
+	}
+
+	allowed, err := e.newEvaluation(ctx, tenantUUID).check(object, relation, subject, 0)
+	if err != nil {
+		return false, "", err
+	}
+
+	e.mu.Lock()
+	e.pruneChecks()
+	e.checks[key] = cachedCheck{allowed: allowed, revision: revision, expiresAt: time.Now().Add(checkCacheTTL)}
+	e.mu.Unlock()
+	return allowed, encodeToken(revision), nil
+}
+
+// Expand returns the userset tree of an object's relation or permission
+func (e *Engine) Expand(ctx context.Context, tenantUUID string, object Object, relation, consistencyToken string) (*ExpandTree, string, error) {
+	if err := validateTenant(tenantUUID); err != nil {
+		return nil, "", err
+	}
+	if !e.schema.hasRelation(object.Type, relation) {
+		return nil, "", fmt.Errorf("%w: unknown relation %s#%s", ErrInvalidRequest, object.Type, relation)
+	}
+	_, revision, err := e.revisions(ctx, consistencyToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tree, err := e.newEvaluation(ctx, tenantUUID).expand(object, relation, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	return tree, encodeToken(revision), nil
+}
+
+// ListObjects returns the IDs of objects of objectType that subject has
+// relation to, in ID order. Candidates are the objects appearing in tuples;
+// at most maxListObjectsScan of them are checked.
+func (e *Engine) ListObjects(ctx context.Context, tenantUUID, objectType, relation string, subject Subject, limit int, consistencyToken string) ([]string, string, error) {
+	if err := e.validateQuery(tenantUUID, objectType, relation, subject); err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 || limit > maxListObjects {
+		limit = maxListObjects
+	}
+	_, revision, err := e.revisions(ctx, consistencyToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	eval := e.newEvaluation(ctx, tenantUUID)
+	ids := []string{}
+	afterID := ""
+	for scanned := 0; scanned < maxListObjectsScan; {
+		candidates, err := e.repo.ListObjectIDs(ctx, tenantUUID, objectType, afterID, listObjectsPage)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, id := range candidates {
+			allowed, err := eval.check(Object{Type: objectType, ID: id}, relation, subject, 0)
+			if err != nil {
+				return nil, "", err
+			}
+			if allowed {
+				ids = append(ids, id)
+				if len(ids) == limit {
+					return ids, encodeToken(revision), nil
+				}
+			}
+		}
+		if len(candidates) < listObjectsPage {
+			break
+		}
+		scanned += len(candidates)
+		afterID = candidates[len(candidates)-1]
+	}
+	return ids, encodeToken(revision), nil
+}
+
+func (e *Engine) validateQuery(tenantUUID, objectType, relation string, subject Subject) error {
+	if err := validateTenant(tenantUUID); err != nil {
+		return err
+	}
+	if !e.schema.hasRelation(objectType, relation) {
+		return fmt.Errorf("%w: unknown relation %s#%s", ErrInvalidRequest, objectType, relation)
+	}
+	if _, ok := e.schema.types[subject.Type]; !ok {
+		return fmt.Errorf("%w: unknown subject type %q", ErrInvalidRequest, subject.Type)
+	}
+	if subject.Relation != "" && !e.schema.hasRelation(subject.Type, subject.Relation) {
+		return fmt.Errorf("%w: unknown relation %s#%s", ErrInvalidRequest, subject.Type, subject.Relation)
+	}
+	return nil
+}
+
+// revisions returns the revision a consistency token requires (0 without a
+// token) and the current revision, which reads are made at
+func (e *Engine) revisions(ctx context.Context, consistencyToken string) (int64, int64, error) {
+	var minRevision int64
+	if consistencyToken != "" {
+		var err error
+		if minRevision, err = decodeToken(consistencyToken); err != nil {
+			return 0, 0, err
+		}
+	}
+	revision, err := e.repo.CurrentRevision(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if minRevision > revision {
+		return 0, 0, ErrTokenUnavailable
+	}
+	return minRevision, revision, nil
+}
+
+// pruneChecks drops expired cached checks once the cache grows; e.mu must be held
+func (e *Engine) pruneChecks() {
+	if len(e.checks) < 10000 {
+		return
+	}
+	now := time.Now()
+	for key, cached := range e.checks {
+		if now.After(cached.expiresAt) {
+			delete(e.checks, key)
+		}
+	}
+}
+
+func validateTenant(tenantUUID string) error {
+	if _, err := uuid.Parse(tenantUUID); err != nil {
+		return fmt.Errorf("%w: invalid tenant", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// evaluation answers the questions of one request, remembering positive
+// results and ending cycles
+type evaluation struct {
+	ctx        context.Context
+	engine     *Engine
+	tenantUUID string
+	results    map[string]bool
+	inProgress map[string]bool
+}
+
+func (e *Engine) newEvaluation(ctx context.Context, tenantUUID string) *evaluation {
+	return &evaluation{
+		ctx:        ctx,
+		engine:     e,
+		tenantUUID: tenantUUID,
+		results:    make(map[string]bool),
+		inProgress: make(map[string]bool),
+	}
+}
+
+func (ev *evaluation) check(object Object, relation string, subject Subject, depth int) (bool, error) {
+	if depth > maxDepth {
+		return false, ErrDepthExceeded
+	}
+	// A userset always contains itself
+	if subject.Relation == relation && subject.Type == object.Type && subject.ID == object.ID {
+		return true, nil
+	}
+
+	key := object.String() + "#" + relation + "@" + subject.String()
+	if result, ok := ev.results[key]; ok {
+		return result, nil
+	}
+	// Following a cycle cannot add subjects that are not reachable otherwise
+	if ev.inProgress[key] {
+		return false, nil
+	}
+	ev.inProgress[key] = true
+	defer delete(ev.inProgress, key)
+
+	var result bool
+	var err error
+	if rw, ok := ev.engine.schema.types[object.Type].permissions[relation]; ok {
+		result, err = ev.checkRewrite(object, rw, subject, depth)
+	} else {
+		result, err = ev.checkRelation(object, relation, subject, depth)
+	}
+	if err != nil {
+		return false, err
+	}
+	// A negative answer may have been cut short by a cycle and is not remembered
+	if result {
+		ev.results[key] = true
+	}
+	return result, nil
+}
+
+// checkRelation looks for subject among the stored tuples of a relation,
+// following usersets
+func (ev *evaluation) checkRelation(object Object, relation string, subject Subject, depth int) (bool, error) {
+	tuples, err := ev.engine.repo.ListTuples(ev.ctx, ev.tenantUUID, object.Type, object.ID, relation)
+	if err != nil {
+		return false, err
+	}
+
+	var usersets []Subject
+	for _, tuple := range tuples {
+		stored := subjectOf(tuple)
+		if stored.Type == subject.Type && stored.Relation == subject.Relation &&
+			(stored.ID == subject.ID || stored.ID == "*" && subject.Relation == "") {
+			return true, nil
+		}
+		if stored.Relation != "" {
+			usersets = append(usersets, stored)
+		}
+	}
+	for _, userset := range usersets {
+		ok, err := ev.check(Object{Type: userset.Type, ID: userset.ID}, userset.Relation, subject, depth+1)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func (ev *evaluation) checkRewrite(object Object, rw *rewrite, subject Subject, depth int) (bool, error) {
+	switch rw.op {
+	case opComputed:
+		return ev.check(object, rw.name, subject, depth+1)
+	case opArrow:
+		tuples, err := ev.engine.repo.ListTuples(ev.ctx, ev.tenantUUID, object.Type, object.ID, rw.tupleset)
+		if err != nil {
+			return false, err
+		}
+		for _, tuple := range tuples {
+			if tuple.SubjectRelation != "" || tuple.SubjectID == "*" || !ev.engine.schema.hasRelation(tuple.SubjectType, rw.name) {
+				continue
+			}
+			ok, err := ev.check(Object{Type: tuple.SubjectType, ID: tuple.SubjectID}, rw.name, subject, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case opUnion:
+		for _, child := range rw.children {
+			ok, err := ev.checkRewrite(object, child, subject, depth)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case opIntersection:
+		for _, child := range rw.children {
+			ok, err := ev.checkRewrite(object, child, subject, depth)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case opExclusion:
+		ok, err := ev.checkRewrite(object, rw.children[0], subject, depth)
+		if err != nil || !ok {
+			return false, err
+		}
+		excluded, err := ev.checkRewrite(object, rw.children[1], subject, depth)
+		if err != nil {
+			return false, err
+		}
+		return !excluded, nil
+	}
+	return false, fmt.Errorf("unknown rewrite %q", rw.op)
+}
+
+func (ev *evaluation) expand(object Object, relation string, depth int) (*ExpandTree, error) {
+	if depth > maxDepth {
+		return nil, ErrDepthExceeded
+	}
+
+	rw, ok := ev.engine.schema.types[object.Type].permissions[relation]
+	if !ok {
+		tuples, err := ev.engine.repo.ListTuples(ev.ctx, ev.tenantUUID, object.Type, object.ID, relation)
+		if err != nil {
+			return nil, err
+		}
+		subjects := make([]Subject, 0, len(tuples))
+		for _, tuple := range tuples {
+			subjects = append(subjects, subjectOf(tuple))
+		}
+		return &ExpandTree{Operation: "leaf", Object: object, Relation: relation, Subjects: subjects}, nil
+	}
+
+	tree, err := ev.expandRewrite(object, rw, depth)
+	if err != nil {
+		return nil, err
+	}
+	tree.Object = object
+	tree.Relation = relation
+	return tree, nil
+}
+
+func (ev *evaluation) expandRewrite(object Object, rw *rewrite, depth int) (*ExpandTree, error) {
+	switch rw.op {
+	case opComputed:
+		return ev.expand(object, rw.name, depth+1)
+	case opArrow:
+		tuples, err := ev.engine.repo.ListTuples(ev.ctx, ev.tenantUUID, object.Type, object.ID, rw.tupleset)
+		if err != nil {
+			return nil, err
+		}
+		tree := &ExpandTree{Operation: opUnion, Object: object, Relation: rw.tupleset}
+		for _, tuple := range tuples {
+			if tuple.SubjectRelation != "" || tuple.SubjectID == "*" || !ev.engine.schema.hasRelation(tuple.SubjectType, rw.name) {
+				continue
+			}
+			child, err := ev.expand(Object{Type: tuple.SubjectType, ID: tuple.SubjectID}, rw.name, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			tree.Children = append(tree.Children, child)
+		}
+		return tree, nil
+	}
+
+	tree := &ExpandTree{Operation: rw.op, Object: object}
+	for _, child := range rw.children {
+		childTree, err := ev.expandRewrite(object, child, depth)
+		if err != nil {
+			return nil, err
+		}
+		tree.Children = append(tree.Children, childTree)
+	}
+	return tree, nil
+}
@@ -0,0 +1,405 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	testTenant  = "00000000-0000-0000-0000-0000000000aa"
+	otherTenant = "00000000-0000-0000-0000-0000000000bb"
+)
+
+const testSchema = `
+	// people and teams
+	definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition folder {
+		relation viewer: user | group#member
+		permission view = viewer
+	}
+
+	definition document {
+		relation parent: folder
+		relation owner: user
+		relation editor: user | group#member
+		relation viewer: user | user:* | group#member
+		relation banned: user
+		permission edit = owner + editor
+		permission view = (edit + viewer + parent->view) - banned
+		permission review = editor & viewer
+	}
+`
+
+var testTuples = []string{
+	"group:eng#member@user:alice",
+	"group:all#member@group:eng#member",
+	"group:all#member@user:bob",
+	"document:readme#owner@user:carol",
+	"document:readme#editor@group:eng#member",
+	"document:readme#viewer@user:alice",
+	"document:readme#viewer@user:dave",
+	"document:readme#viewer@user:erin",
+	"document:readme#banned@user:erin",
+	"document:readme#parent@folder:docs",
+	"folder:docs#viewer@group:all#member",
+	"document:public#viewer@user:*",
+	"document:draft#owner@user:carol",
+}
+
+// fakeTupleStore is an in-memory RelationTupleRepository with a global revision
+type fakeTupleStore struct {
+	mu       sync.Mutex
+	revision int64
+	tuples   []model.RelationTuple
+}
+
+var _ repository.RelationTupleRepository = (*fakeTupleStore)(nil)
+
+func (f *fakeTupleStore) WriteTuples(ctx context.Context, tenantUUID string, writes, deletes []model.RelationTuple) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revision++
+	sameTuple := func(a, b model.RelationTuple) bool {
+		a.ID, a.Revision, b.ID, b.Revision = 0, 0, 0, 0
+		return a == b
+	}
+	for _, tuple := range deletes {
+		tuple.TenantUUID = tenantUUID
+		kept := f.tuples[:0]
+		for _, existing := range f.tuples {
+			if !sameTuple(existing, tuple) {
+				kept = append(kept, existing)
+			}
+		}
+		f.tuples = kept
+	}
+	for _, tuple := range writes {
+		tuple.TenantUUID = tenantUUID
+		tuple.Revision = f.revision
+		exists := false
+		for _, existing := range f.tuples {
+			exists = exists || sameTuple(existing, tuple)
+		}
+		if !exists {
+			f.tuples = append(f.tuples, tuple)
+		}
+	}
+	return f.revision, nil
+}
+
+func (f *fakeTupleStore) CurrentRevision(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.revision, nil
+}
+
+func (f *fakeTupleStore) ListTuples(ctx context.Context, tenantUUID, objectType, objectID, relation string) ([]model.RelationTuple, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tuples []model.RelationTuple
+	for _, tuple := range f.tuples {
+		if tuple.TenantUUID == tenantUUID && tuple.ObjectType == objectType && tuple.ObjectID == objectID && tuple.Relation == relation {
+			tuples = append(tuples, tuple)
+		}
+	}
+	return tuples, nil
+}
+
+func (f *fakeTupleStore) ListObjectIDs(ctx context.Context, tenantUUID, objectType, afterID string, limit int) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	seen := make(map[string]bool)
+	var ids []string
+	for _, tuple := range f.tuples {
+		if tuple.TenantUUID == tenantUUID && tuple.ObjectType == objectType && tuple.ObjectID > afterID && !seen[tuple.ObjectID] {
+			seen[tuple.ObjectID] = true
+			ids = append(ids, tuple.ObjectID)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func newTestEngine(t *testing.T) (*Engine, *fakeTupleStore, string) {
+	t.Helper()
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema: %v", err)
+	}
+	store := &fakeTupleStore{}
+	engine := NewEngine(schema, store)
+
+	token, err := engine.Write(context.Background(), testTenant, parseTuples(t, testTuples...), nil)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return engine, store, token
+}
+
+func parseTuples(t *testing.T, values ...string) []Tuple {
+	t.Helper()
+	tuples := make([]Tuple, 0, len(values))
+	for _, value := range values {
+		tuple, err := ParseTuple(value)
+		if err != nil {
+			t.Fatalf("ParseTuple(%q): %v", value, err)
+		}
+		tuples = append(tuples, tuple)
+	}
+	return tuples
+}
+
+func TestCheck(t *testing.T) {
+	engine, _, token := newTestEngine(t)
+
+	tests := []struct {
+		name     string
+		tenant   string
+		object   string
+		relation string
+		subject  string
+		want     bool
+	}{
+		{"direct relation", testTenant, "document:readme", "owner", "user:carol", true},
+		{"direct relation, other subject", testTenant, "document:readme", "owner", "user:dave", false},
+		{"union through owner", testTenant, "document:readme", "edit", "user:carol", true},
+		{"union through a group userset", testTenant, "document:readme", "edit", "user:alice", true},
+		{"union, no branch holds", testTenant, "document:readme", "edit", "user:dave", false},
+		{"userset subject", testTenant, "document:readme", "editor", "group:eng#member", true},
+		{"nested group", testTenant, "group:all", "member", "user:alice", true},
+		{"intersection holds", testTenant, "document:readme", "review", "user:alice", true},
+		{"intersection, one side only", testTenant, "document:readme", "review", "user:dave", false},
+		{"exclusion, not banned", testTenant, "document:readme", "view", "user:dave", true},
+		{"exclusion removes a viewer", testTenant, "document:readme", "view", "user:erin", false},
+		{"tuple to userset", testTenant, "document:readme", "view", "user:bob", true},
+		{"wildcard", testTenant, "document:public", "view", "user:zed", true},
+		{"wildcard does not leak", testTenant, "document:draft", "view", "user:zed", false},
+		{"tenants are separate", otherTenant, "document:readme", "edit", "user:carol", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, err := ParseObject(tt.object)
+			if err != nil {
+				t.Fatalf("ParseObject: %v", err)
+			}
+			subject, err := ParseSubject(tt.subject)
+			if err != nil {
+				t.Fatalf("ParseSubject: %v", err)
+			}
+			allowed, _, err := engine.Check(context.Background(), tt.tenant, object, tt.relation, subject, token)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if allowed != tt.want {
+				t.Fatalf("Check(%s#%s@%s) = %v, want %v", tt.object, tt.relation, tt.subject, allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCyclicGroups(t *testing.T) {
+	engine, _, _ := newTestEngine(t)
+	ctx := context.Background()
+	token, err := engine.Write(ctx, testTenant, parseTuples(t,
+		"group:a#member@group:b#member",
+		"group:b#member@group:a#member",
+		"group:b#member@user:alice",
+	), nil)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	for id, want := range map[string]bool{"alice": true, "bob": false} {
+		allowed, _, err := engine.Check(ctx, testTenant, Object{Type: "group", ID: "a"}, "member", Subject{Type: "user", ID: id}, token)
+		if err != nil {
+			t.Fatalf("Check %s: %v", id, err)
+		}
+		if allowed != want {
+			t.Fatalf("group:a#member@user:%s = %v, want %v", id, allowed, want)
+		}
+	}
+}
+
+func TestWriteValidation(t *testing.T) {
+	engine, _, _ := newTestEngine(t)
+
+	tests := []struct {
+		name   string
+		tenant string
+		tuple  string
+	}{
+		{"permission", testTenant, "document:readme#edit@user:alice"},
+		{"unknown relation", testTenant, "document:readme#admin@user:alice"},
+		{"subject type not accepted", testTenant, "document:readme#owner@group:eng#member"},
+		{"wildcard not accepted", testTenant, "document:readme#owner@user:*"},
+		{"invalid tenant", "acme", "document:readme#owner@user:alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.Write(context.Background(), tt.tenant, parseTuples(t, tt.tuple), nil)
+			if !errors.Is(err, ErrInvalidRequest) {
+				t.Fatalf("error = %v, want ErrInvalidRequest", err)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	engine, _, token := newTestEngine(t)
+
+	tree, _, err := engine.Expand(context.Background(), testTenant, Object{Type: "document", ID: "readme"}, "edit", token)
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if tree.Operation != opUnion || tree.Relation != "edit" || len(tree.Children) != 2 {
+		t.Fatalf("unexpected tree %+v", tree)
+	}
+	leaves := map[string][]Subject{}
+	for _, child := range tree.Children {
+		if child.Operation != "leaf" {
+			t.Fatalf("child %s is %q, want a leaf", child.Relation, child.Operation)
+		}
+		leaves[child.Relation] = child.Subjects
+	}
+	want := map[string][]Subject{
+		"owner":  {{Type: "user", ID: "carol"}},
+		"editor": {{Type: "group", ID: "eng", Relation: "member"}},
+	}
+	if !reflect.DeepEqual(leaves, want) {
+		t.Fatalf("leaves = %v, want %v", leaves, want)
+	}
+
+	// view = (edit + viewer + parent->view) - banned
+	tree, _, err = engine.Expand(context.Background(), testTenant, Object{Type: "document", ID: "readme"}, "view", token)
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if tree.Operation != opExclusion || len(tree.Children) != 2 {
+		t.Fatalf("view expands to %q with %d children, want an exclusion of 2", tree.Operation, len(tree.Children))
+	}
+	included, excluded := tree.Children[0], tree.Children[1]
+	if included.Operation != opUnion || len(included.Children) != 3 {
+		t.Fatalf("included set is %q with %d children, want a union of 3", included.Operation, len(included.Children))
+	}
+	if arrow := included.Children[2]; arrow.Relation != "parent" || len(arrow.Children) != 1 || arrow.Children[0].Object.String() != "folder:docs" {
+		t.Fatalf("parent->view expands to %+v", arrow)
+	}
+	if excluded.Relation != "banned" || !reflect.DeepEqual(excluded.Subjects, []Subject{{Type: "user", ID: "erin"}}) {
+		t.Fatalf("excluded set = %+v", excluded)
+	}
+
+	if _, _, err := engine.Expand(context.Background(), testTenant, Object{Type: "document", ID: "readme"}, "admin", token); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("unknown relation: error = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	engine, _, token := newTestEngine(t)
+
+	tests := []struct {
+		subject string
+		limit   int
+		want    []string
+	}{
+		{"user:carol", 0, []string{"draft", "public", "readme"}},
+		{"user:alice", 0, []string{"public", "readme"}},
+		{"user:alice", 1, []string{"public"}},
+		{"user:bob", 0, []string{"public", "readme"}},
+		{"user:erin", 0, []string{"public"}},
+	}
+	for _, tt := range tests {
+		subject, err := ParseSubject(tt.subject)
+		if err != nil {
+			t.Fatalf("ParseSubject: %v", err)
+		}
+		ids, _, err := engine.ListObjects(context.Background(), testTenant, "document", "view", subject, tt.limit, token)
+		if err != nil {
+			t.Fatalf("ListObjects(%s): %v", tt.subject, err)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("ListObjects(%s, limit %d) = %v, want %v", tt.subject, tt.limit, ids, tt.want)
+		}
+	}
+
+	ids, _, err := engine.ListObjects(context.Background(), otherTenant, "document", "view", Subject{Type: "user", ID: "carol"}, 0, "")
+	if err != nil {
+		t.Fatalf("ListObjects in another tenant: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("another tenant lists %v", ids)
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	engine, store, _ := newTestEngine(t)
+	ctx := context.Background()
+	readme := Object{Type: "document", ID: "readme"}
+	carol := Subject{Type: "user", ID: "carol"}
+
+	allowed, token, err := engine.Check(ctx, testTenant, readme, "edit", carol, "")
+	if err != nil || !allowed {
+		t.Fatalf("Check = %v, %v; want allowed", allowed, err)
+	}
+	if revision, err := decodeToken(token); err != nil || revision != store.revision {
+		t.Fatalf("token revision = %d, %v; want %d", revision, err, store.revision)
+	}
+
+	writeToken, err := engine.Write(ctx, testTenant, nil, parseTuples(t, "document:readme#owner@user:carol"))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Without a token the cached answer may be served for a few seconds
+	allowed, stale, err := engine.Check(ctx, testTenant, readme, "edit", carol, "")
+	if err != nil || !allowed {
+		t.Fatalf("cached Check = %v, %v; want the cached allowed answer", allowed, err)
+	}
+	if stale != token {
+		t.Fatalf("cached answer reports token %q, want %q", stale, token)
+	}
+
+	// The write's token forces an answer that includes the write
+	allowed, fresh, err := engine.Check(ctx, testTenant, readme, "edit", carol, writeToken)
+	if err != nil || allowed {
+		t.Fatalf("Check at write token = %v, %v; want denied", allowed, err)
+	}
+	if fresh != writeToken {
+		t.Fatalf("fresh answer reports token %q, want %q", fresh, writeToken)
+	}
+
+	if _, _, err := engine.Check(ctx, testTenant, readme, "edit", carol, encodeToken(store.revision+1)); !errors.Is(err, ErrTokenUnavailable) {
+		t.Fatalf("future token: error = %v, want ErrTokenUnavailable", err)
+	}
+	if _, _, err := engine.Check(ctx, testTenant, readme, "edit", carol, "not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("garbage token: error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestConsistencyToken(t *testing.T) {
+	for _, revision := range []int64{0, 1, 1 << 40} {
+		decoded, err := decodeToken(encodeToken(revision))
+		if err != nil || decoded != revision {
+			t.Fatalf("round trip of %d = %d, %v", revision, decoded, err)
+		}
+	}
+	for _, token := range []string{"", "v1.5", "djIuNQ", "djEuLTE", "djEueA"} {
+		if _, err := decodeToken(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("decodeToken(%q) error = %v, want ErrInvalidToken", token, err)
+		}
+	}
+}
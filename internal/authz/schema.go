@@ -0,0 +1,462 @@
+package authz
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Schema defines object types, the relations tuples may record and the
+// permissions computed from them. The language looks like:
+//
+//	// comments run to the end of the line
+//	definition user {}
+//
+//	definition group {
+//		relation member: user | group#member
+//	}
+//
+//	definition document {
+//		relation parent: folder
+//		relation owner: user
+//		relation editor: user | group#member
+//		relation viewer: user | user:* | group#member
+//		permission edit = owner + editor
+//		permission view = edit + viewer + parent->view
+//	}
+//
+// A relation lists the subjects it accepts: objects of a type, every object of
+// a type (type:*) or usersets (type#relation). A permission rewrites other
+// relations and permissions of the same type with union (+), intersection (&)
+// and exclusion (-), evaluated left to right unless parenthesized.
+// relation->name follows the relation to its objects and evaluates name there.
+type Schema struct {
+	types map[string]*typeDefinition
+}
+
+type typeDefinition struct {
+	name        string
+	relations   map[string][]allowedSubject
+	permissions map[string]*rewrite
+}
+
+type allowedSubject struct {
+	typeName string
+	relation string // userset relation, empty for direct subjects
+	wildcard bool
+}
+
+// Rewrite operations
+const (
+	opUnion        = "union"
+	opIntersection = "intersection"
+	opExclusion    = "exclusion"
+	opComputed     = "computed" // another relation or permission of the object
+	opArrow        = "arrow"    // name evaluated on the objects of tupleset
+)
+
+type rewrite struct {
+	op       string
+	name     string
+	tupleset string
+	children []*rewrite
+}
+
+// ParseSchema parses and validates a schema
+func ParseSchema(source string) (*Schema, error) {
+	tokens, err := tokenizeSchema(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &schemaParser{tokens: tokens}
+	schema := &Schema{types: make(map[string]*typeDefinition)}
+	for !p.done() {
+		def, err := p.definition()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := schema.types[def.name]; exists {
+			return nil, fmt.Errorf("schema: duplicate definition %q", def.name)
+		}
+		schema.types[def.name] = def
+	}
+	if err := schema.validate(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// isRelation reports whether name is a stored relation of objectType
+func (s *Schema) isRelation(objectType, name string) bool {
+	def, ok := s.types[objectType]
+	if !ok {
+		return false
+	}
+	_, ok = def.relations[name]
+	return ok
+}
+
+// hasRelation reports whether name is a relation or permission of objectType
+func (s *Schema) hasRelation(objectType, name string) bool {
+	def, ok := s.types[objectType]
+	if !ok {
+		return false
+	}
+	if _, ok := def.relations[name]; ok {
+		return true
+	}
+	_, ok = def.permissions[name]
+	return ok
+}
+
+// allows reports whether a relation accepts subject
+func (s *Schema) allows(objectType, relation string, subject Subject) bool {
+	for _, allowed := range s.types[objectType].relations[relation] {
+		if allowed.typeName != subject.Type {
+			continue
+		}
+		switch {
+		case subject.ID == "*":
+			if allowed.wildcard {
+				return true
+			}
+		case subject.Relation != "":
+			if allowed.relation == subject.Relation {
+				return true
+			}
+		case !allowed.wildcard && allowed.relation == "":
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) validate() error {
+	for _, def := range s.types {
+		for relation, allowedSubjects := range def.relations {
+			for _, allowed := range allowedSubjects {
+				if _, ok := s.types[allowed.typeName]; !ok {
+					return fmt.Errorf("schema: %s#%s refers to unknown type %q", def.name, relation, allowed.typeName)
+				}
+				if allowed.relation != "" && !s.hasRelation(allowed.typeName, allowed.relation) {
+					return fmt.Errorf("schema: %s#%s refers to unknown relation %s#%s", def.name, relation, allowed.typeName, allowed.relation)
+				}
+			}
+		}
+		for permission, rw := range def.permissions {
+			if err := s.validateRewrite(def, permission, rw); err != nil {
+				return err
+			}
+		}
+		if err := def.checkCycles(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCycles rejects permissions defined in terms of themselves through other
+// permissions of the same type, e.g. p = q with q = p. Arrows are not followed:
+// parent->view recurses over tuples, which evaluation bounds.
+func (def *typeDefinition) checkCycles() error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(def.permissions))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		rw, ok := def.permissions[name]
+		if !ok || state[name] == visited {
+			return nil
+		}
+		path = append(path, name)
+		if state[name] == visiting {
+			cycle := path[slices.Index(path, name):]
+			return fmt.Errorf("schema: %s#%s is defined in terms of itself (%s)", def.name, name, strings.Join(cycle, " -> "))
+		}
+		state[name] = visiting
+		for _, ref := range rw.computedNames(nil) {
+			if err := visit(ref, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	names := make([]string, 0, len(def.permissions))
+	for name := range def.permissions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// computedNames appends the relations and permissions a rewrite refers to on
+// its own object
+func (rw *rewrite) computedNames(names []string) []string {
+	switch rw.op {
+	case opComputed:
+		return append(names, rw.name)
+	case opArrow:
+		return names
+	}
+	for _, child := range rw.children {
+		names = child.computedNames(names)
+	}
+	return names
+}
+
+func (s *Schema) validateRewrite(def *typeDefinition, permission string, rw *rewrite) error {
+	switch rw.op {
+	case opComputed:
+		if rw.name == permission {
+			return fmt.Errorf("schema: %s#%s refers to itself", def.name, permission)
+		}
+		if !s.hasRelation(def.name, rw.name) {
+			return fmt.Errorf("schema: %s#%s refers to unknown relation %q", def.name, permission, rw.name)
+		}
+	case opArrow:
+		allowedSubjects, ok := def.relations[rw.tupleset]
+		if !ok {
+			return fmt.Errorf("schema: %s#%s follows %q, which is not a relation", def.name, permission, rw.tupleset)
+		}
+		found := false
+		for _, allowed := range allowedSubjects {
+			if s.hasRelation(allowed.typeName, rw.name) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("schema: %s#%s: no type of %s has %q", def.name, permission, rw.tupleset, rw.name)
+		}
+	default:
+		for _, child := range rw.children {
+			if err := s.validateRewrite(def, permission, child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tokenizeSchema splits a schema into names and punctuation, dropping comments
+func tokenizeSchema(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.HasPrefix(source[i:], "//"):
+			end := strings.IndexByte(source[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end
+		case strings.HasPrefix(source[i:], "->"):
+			tokens = append(tokens, "->")
+			i += 2
+		case strings.ContainsRune("{}:|#+&-()=*", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c >= 'a' && c <= 'z':
+			start := i
+			for i < len(source) && (source[i] >= 'a' && source[i] <= 'z' || source[i] >= '0' && source[i] <= '9' || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		default:
+			return nil, fmt.Errorf("schema: unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+type schemaParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *schemaParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *schemaParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *schemaParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *schemaParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("schema: expected %q, found %q", token, got)
+	}
+	return nil
+}
+
+func (p *schemaParser) name() (string, error) {
+	token := p.next()
+	if !namePattern.MatchString(token) {
+		return "", fmt.Errorf("schema: expected a name, found %q", token)
+	}
+	return token, nil
+}
+
+func (p *schemaParser) definition() (*typeDefinition, error) {
+	if err := p.expect("definition"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	def := &typeDefinition{
+		name:        name,
+		relations:   make(map[string][]allowedSubject),
+		permissions: make(map[string]*rewrite),
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for p.peek() != "}" {
+		keyword := p.next()
+		member, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if def.relations[member] != nil || def.permissions[member] != nil {
+			return nil, fmt.Errorf("schema: duplicate relation %s#%s", name, member)
+		}
+		switch keyword {
+		case "relation":
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			allowed, err := p.allowedSubjects()
+			if err != nil {
+				return nil, err
+			}
+			def.relations[member] = allowed
+		case "permission":
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			rw, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			def.permissions[member] = rw
+		default:
+			return nil, fmt.Errorf("schema: expected relation or permission in %s, found %q", name, keyword)
+		}
+	}
+	p.next()
+	return def, nil
+}
+
+func (p *schemaParser) allowedSubjects() ([]allowedSubject, error) {
+	var allowed []allowedSubject
+	for {
+		typeName, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		subject := allowedSubject{typeName: typeName}
+		switch p.peek() {
+		case ":":
+			p.next()
+			if err := p.expect("*"); err != nil {
+				return nil, err
+			}
+			subject.wildcard = true
+		case "#":
+			p.next()
+			if subject.relation, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		allowed = append(allowed, subject)
+
+		if p.peek() != "|" {
+			return allowed, nil
+		}
+		p.next()
+	}
+}
+
+// expression parses operands joined by +, & and -, left to right
+func (p *schemaParser) expression() (*rewrite, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch p.peek() {
+		case "+":
+			op = opUnion
+		case "&":
+			op = opIntersection
+		case "-":
+			op = opExclusion
+		default:
+			return left, nil
+		}
+		p.next()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		// Flatten chains of the same commutative operation
+		if left.op == op && op != opExclusion {
+			left.children = append(left.children, right)
+		} else {
+			left = &rewrite{op: op, children: []*rewrite{left, right}}
+		}
+	}
+}
+
+func (p *schemaParser) operand() (*rewrite, error) {
+	if p.peek() == "(" {
+		p.next()
+		rw, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return rw, nil
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "->" {
+		return &rewrite{op: opComputed, name: name}, nil
+	}
+	p.next()
+	target, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	return &rewrite{op: opArrow, tupleset: name, name: target}, nil
+}
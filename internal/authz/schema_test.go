@@ -0,0 +1,129 @@
+package authz
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string // substring of the error, empty when the schema is valid
+	}{
+		{"valid", testSchema, ""},
+		{"recursion through an arrow", `
+			definition user {}
+			definition folder {
+				relation parent: folder
+				relation viewer: user
+				permission view = viewer + parent->view
+			}`, ""},
+		{"comment at end of input", "definition user {} // trailing", ""},
+		{"duplicate definition", `definition user {} definition user {}`, `duplicate definition "user"`},
+		{"duplicate relation", `
+			definition user {}
+			definition doc {
+				relation owner: user
+				permission owner = owner
+			}`, "duplicate relation doc#owner"},
+		{"unknown subject type", `definition doc { relation owner: person }`, `unknown type "person"`},
+		{"unknown userset relation", `
+			definition user {}
+			definition group {}
+			definition doc { relation viewer: group#member }`, "unknown relation group#member"},
+		{"unknown computed relation", `
+			definition user {}
+			definition doc {
+				relation owner: user
+				permission edit = owner + editor
+			}`, `unknown relation "editor"`},
+		{"arrow over a permission", `
+			definition user {}
+			definition doc {
+				relation owner: user
+				permission edit = owner
+				permission view = edit->view
+			}`, "is not a relation"},
+		{"arrow to a missing relation", `
+			definition user {}
+			definition folder { relation viewer: user }
+			definition doc {
+				relation parent: folder
+				permission view = parent->view
+			}`, `no type of parent has "view"`},
+		{"self reference", `
+			definition user {}
+			definition doc {
+				relation owner: user
+				permission p = owner + p
+			}`, "doc#p refers to itself"},
+		{"two permission cycle", `
+			definition user {}
+			definition doc {
+				permission p = q
+				permission q = p
+			}`, "is defined in terms of itself (p -> q -> p)"},
+		{"cycle through operators", `
+			definition user {}
+			definition doc {
+				relation owner: user
+				permission a = owner + (b & owner)
+				permission b = owner - c
+				permission c = a
+			}`, "is defined in terms of itself (a -> b -> c -> a)"},
+		{"unexpected character", `definition Doc {}`, "unexpected character"},
+		{"missing name", `definition {}`, "expected a name"},
+		{"unknown keyword", `definition doc { attribute owner: user }`, "expected relation or permission"},
+		{"unterminated expression", `definition user {} definition doc { relation owner: user permission p = (owner }`, `expected ")"`},
+		{"wildcard needs a star", `definition user {} definition doc { relation viewer: user:alice }`, `expected "*"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := ParseSchema(tt.source)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParseSchema: %v", err)
+				}
+				if schema == nil {
+					t.Fatal("no schema returned")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchemaAllows(t *testing.T) {
+	schema, err := ParseSchema(testSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema: %v", err)
+	}
+
+	tests := []struct {
+		relation string
+		subject  string
+		want     bool
+	}{
+		{"owner", "user:alice", true},
+		{"owner", "user:*", false},
+		{"owner", "group:eng#member", false},
+		{"viewer", "user:*", true},
+		{"viewer", "group:eng#member", true},
+		{"viewer", "group:eng", false},
+		{"parent", "folder:docs", true},
+		{"parent", "user:alice", false},
+	}
+	for _, tt := range tests {
+		subject, err := ParseSubject(tt.subject)
+		if err != nil {
+			t.Fatalf("ParseSubject(%q): %v", tt.subject, err)
+		}
+		if got := schema.allows("document", tt.relation, subject); got != tt.want {
+			t.Errorf("document#%s allows %s = %v, want %v", tt.relation, tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package authz
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const tokenPrefix = "v1."
+
+var ErrInvalidToken = errors.New("invalid consistency token")
+
+// encodeToken turns a store revision into an opaque consistency token
+func encodeToken(revision int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(tokenPrefix + strconv.FormatInt(revision, 10)))
+}
+
+func decodeToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), tokenPrefix) {
+		return 0, ErrInvalidToken
+	}
+	revision, err := strconv.ParseInt(strings.TrimPrefix(string(raw), tokenPrefix), 10, 64)
+	if err != nil || revision < 0 {
+		return 0, ErrInvalidToken
+	}
+	return revision, nil
+}
@@ -0,0 +1,111 @@
+package authz
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+var (
+	namePattern     = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	objectIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-/|=+]{1,128}$`)
+)
+
+// Object identifies an object as type:id
+type Object struct {
+	Type string
+	ID   string
+}
+
+func (o Object) String() string {
+	return o.Type + ":" + o.ID
+}
+
+// Subject is an object (user:alice), every object of a type (user:*) or a
+// userset: everyone with a relation to an object (group:eng#member)
+type Subject struct {
+	Type     string
+	ID       string
+	Relation string
+}
+
+func (s Subject) String() string {
+	if s.Relation != "" {
+		return s.Type + ":" + s.ID + "#" + s.Relation
+	}
+	return s.Type + ":" + s.ID
+}
+
+// Tuple is a relationship object#relation@subject
+type Tuple struct {
+	Object   Object
+	Relation string
+	Subject  Subject
+}
+
+func (t Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// ParseObject parses "type:id"
+func ParseObject(value string) (Object, error) {
+	objectType, id, ok := strings.Cut(value, ":")
+	if !ok || !namePattern.MatchString(objectType) || !objectIDPattern.MatchString(id) {
+		return Object{}, fmt.Errorf("%w: invalid object %q", ErrInvalidRequest, value)
+	}
+	return Object{Type: objectType, ID: id}, nil
+}
+
+// ParseSubject parses "type:id", "type:*" or "type:id#relation"
+func ParseSubject(value string) (Subject, error) {
+	objectRef, relation, hasRelation := strings.Cut(value, "#")
+	objectType, id, ok := strings.Cut(objectRef, ":")
+	if !ok || !namePattern.MatchString(objectType) {
+		return Subject{}, fmt.Errorf("%w: invalid subject %q", ErrInvalidRequest, value)
+	}
+	if hasRelation && (id == "*" || !namePattern.MatchString(relation)) {
+		return Subject{}, fmt.Errorf("%w: invalid subject %q", ErrInvalidRequest, value)
+	}
+	if id != "*" && !objectIDPattern.MatchString(id) {
+		return Subject{}, fmt.Errorf("%w: invalid subject %q", ErrInvalidRequest, value)
+	}
+	return Subject{Type: objectType, ID: id, Relation: relation}, nil
+}
+
+// ParseTuple parses "type:id#relation@subject"
+func ParseTuple(value string) (Tuple, error) {
+	objectPart, subjectPart, ok := strings.Cut(value, "@")
+	if !ok {
+		return Tuple{}, fmt.Errorf("%w: invalid tuple %q", ErrInvalidRequest, value)
+	}
+	objectRef, relation, ok := strings.Cut(objectPart, "#")
+	if !ok || !namePattern.MatchString(relation) {
+		return Tuple{}, fmt.Errorf("%w: invalid tuple %q", ErrInvalidRequest, value)
+	}
+	object, err := ParseObject(objectRef)
+	if err != nil {
+		return Tuple{}, err
+	}
+	subject, err := ParseSubject(subjectPart)
+	if err != nil {
+		return Tuple{}, err
+	}
+	return Tuple{Object: object, Relation: relation, Subject: subject}, nil
+}
+
+func (t Tuple) model() model.RelationTuple {
+	return model.RelationTuple{
+		ObjectType:      t.Object.Type,
+		ObjectID:        t.Object.ID,
+		Relation:        t.Relation,
+		SubjectType:     t.Subject.Type,
+		SubjectID:       t.Subject.ID,
+		SubjectRelation: t.Subject.Relation,
+	}
+}
+
+func subjectOf(tuple model.RelationTuple) Subject {
+	return Subject{Type: tuple.SubjectType, ID: tuple.SubjectID, Relation: tuple.SubjectRelation}
+}
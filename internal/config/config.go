@@ -1,7 +1,11 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -9,12 +13,64 @@ type Config struct {
 	Port     string
 	GRPCPort string
 	JWTKey   string
-	ServiceKey string
+	ServiceKey string // legacy shared key for backend gRPC calls
+	ServiceName string // identity this service presents in service tokens it issues for itself
 	CoreNotificationServiceAddr string
 	// TLS configuration for secure gRPC connections
 	TLSCertFile string // Path to TLS certificate file (optional)
 	TLSKeyFile  string // Path to TLS key file (optional)
 	TLSEnabled  bool   // Enable TLS for gRPC connections
+	TLSClientCAFile      string // CA that signs client certificates accepted by the gRPC server (optional)
+	TLSRequireClientCert bool   // Reject gRPC connections without a client certificate (mutual TLS)
+	// JSON list mapping client certificate SPIFFE IDs or CNs to services (see middleware.ClientIdentity); requires TLS and TLSClientCAFile
+	GRPCClientIdentitiesFile string
+	// WebAuthn relying party configuration (WebAuthn is disabled when RP ID is empty)
+	WebAuthnRPID          string   // Relying party ID, usually the site's domain (e.g. example.com)
+	WebAuthnRPDisplayName string   // Human readable relying party name shown by authenticators
+	WebAuthnRPOrigins     []string // Allowed origins (e.g. https://app.example.com)
+	// Frontend page that receives magic-link tokens (magic-link login is disabled when empty)
+	MagicLinkURL string
+	// SMTP relay for transactional email. Magic links, invitations and new-device
+	// alerts are disabled when no relay is configured.
+	SMTPHost     string
+	SMTPPort     string // defaults to 587
+	SMTPUsername string
+	SMTPPassword string
+	EmailFrom    string // e.g. "Example <no-reply@example.com>"
+	// Allow login with a one-time code sent to a verified phone number (requires an SMS provider)
+	PhoneOTPLoginEnabled bool
+	// Twilio credentials for SMS delivery (phone verification and OTP login are disabled when empty)
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string // E.164 sender number or messaging service SID
+	// Session limits (0 disables a limit)
+	SessionMaxPerUser       int           // Max concurrently active sessions per user
+	SessionLimitPolicy      string        // "evict_oldest" (default) or "reject_newest" when the max is reached
+	SessionIdleTimeout      time.Duration // Sessions expire after this long without activity
+	SessionAbsoluteLifetime time.Duration // Sessions expire this long after login regardless of activity
+	// Tenant that self-service signups join when no tenant name is given
+	// (each signup gets a personal tenant when empty)
+	DefaultTenantSlug string
+	// Frontend page that receives tenant invitation tokens (invitations are disabled when empty)
+	InvitationURL string
+	// Permissions impersonation tokens are limited to (read-only tenant permissions when empty)
+	ImpersonationScopes []string
+	// Schema file for relationship-based authorization (the RelationService is disabled when empty)
+	RelationSchemaFile string
+	// Frontend page that signs users in and asks for consent to OAuth authorization
+	// requests (the authorization code, device and OpenID Connect flows are disabled when empty)
+	OAuthConsentURL string
+	// Frontend page where users enter the code shown by a CLI or TV (the device
+	// authorization grant is disabled when empty)
+	OAuthDeviceVerificationURL string
+	// Public base URL of this service used as the OpenID Connect issuer (OIDC is disabled when empty)
+	OIDCIssuer string
+	// PEM RSA key that signs ID tokens (a key is generated at startup when empty)
+	OIDCSigningKeyFile string
+	// JSON list of upstream OpenID Connect providers users may sign in with (see service.FederatedProvider)
+	OIDCProvidersFile string
+	// Frontend page registered with the providers as redirect URI (federated login is disabled when empty)
+	FederatedLoginRedirectURL string
 }
 
 func LoadConfig() *Config {
@@ -24,9 +80,87 @@ func LoadConfig() *Config {
 		GRPCPort:  os.Getenv("GRPC_PORT"),
 		JWTKey:    os.Getenv("JWT_KEY"),
 		ServiceKey: os.Getenv("SERVICE_KEY"),
+		ServiceName: stringEnv("SERVICE_NAME", "auth-service"),
 		CoreNotificationServiceAddr: os.Getenv("CORE_NOTIFICATION_SERVICE_ADDR"),
 		TLSCertFile: os.Getenv("TLS_CERT_FILE"),
 		TLSKeyFile:  os.Getenv("TLS_KEY_FILE"),
 		TLSEnabled:  os.Getenv("TLS_ENABLED") == "true",
+		TLSClientCAFile:      os.Getenv("TLS_CLIENT_CA_FILE"),
+		TLSRequireClientCert: os.Getenv("TLS_REQUIRE_CLIENT_CERT") == "true",
+		GRPCClientIdentitiesFile: os.Getenv("GRPC_CLIENT_IDENTITIES_FILE"),
+		WebAuthnRPID:          os.Getenv("WEBAUTHN_RP_ID"),
+		WebAuthnRPDisplayName: os.Getenv("WEBAUTHN_RP_DISPLAY_NAME"),
+		WebAuthnRPOrigins:     splitList(os.Getenv("WEBAUTHN_RP_ORIGINS")),
+		MagicLinkURL:          os.Getenv("MAGIC_LINK_URL"),
+		SMTPHost:              os.Getenv("SMTP_HOST"),
+		SMTPPort:              os.Getenv("SMTP_PORT"),
+		SMTPUsername:          os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:          os.Getenv("SMTP_PASSWORD"),
+		EmailFrom:             os.Getenv("EMAIL_FROM"),
+		PhoneOTPLoginEnabled:  os.Getenv("PHONE_OTP_LOGIN_ENABLED") == "true",
+		TwilioAccountSID:      os.Getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:       os.Getenv("TWILIO_AUTH_TOKEN"),
+		TwilioFrom:            os.Getenv("TWILIO_FROM"),
+		SessionMaxPerUser:       intEnv("SESSION_MAX_PER_USER", 0),
+		SessionLimitPolicy:      os.Getenv("SESSION_LIMIT_POLICY"),
+		SessionIdleTimeout:      durationEnv("SESSION_IDLE_TIMEOUT", 0),
+		SessionAbsoluteLifetime: durationEnv("SESSION_ABSOLUTE_LIFETIME", 24*time.Hour),
+		DefaultTenantSlug:       os.Getenv("DEFAULT_TENANT_SLUG"),
+		InvitationURL:           os.Getenv("INVITATION_URL"),
+		ImpersonationScopes:     splitList(os.Getenv("IMPERSONATION_SCOPES")),
+		RelationSchemaFile:      os.Getenv("RELATION_SCHEMA_FILE"),
+		OAuthConsentURL:         os.Getenv("OAUTH_CONSENT_URL"),
+		OAuthDeviceVerificationURL: os.Getenv("OAUTH_DEVICE_VERIFICATION_URL"),
+		OIDCIssuer:              os.Getenv("OIDC_ISSUER"),
+		OIDCSigningKeyFile:      os.Getenv("OIDC_SIGNING_KEY_FILE"),
+		OIDCProvidersFile:       os.Getenv("OIDC_PROVIDERS_FILE"),
+		FederatedLoginRedirectURL: os.Getenv("FEDERATED_LOGIN_REDIRECT_URL"),
 	}
 }
+
+// stringEnv reads an environment value, falling back to def when unset
+func stringEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// intEnv reads an integer environment value, falling back to def when unset or invalid
+func intEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// durationEnv reads a duration (e.g. "30m", "12h"), falling back to def when unset or invalid
+func durationEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %s", key, value, def)
+		return def
+	}
+	return d
+}
+
+// splitList parses a comma separated environment value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
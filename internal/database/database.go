@@ -5,12 +5,14 @@ import (
 	"log"
 
 	"github.com/johnroshan2255/auth-service/internal/config"
+	"github.com/johnroshan2255/auth-service/internal/model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 func InitDB(cfg *config.Config) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(cfg.DBUrl), &gorm.Config{})
+	// TranslateError maps constraint violations to gorm errors such as gorm.ErrDuplicatedKey
+	db, err := gorm.Open(postgres.Open(cfg.DBUrl), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +30,40 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Migrate creates or updates the tables managed by this service
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&model.Tenant{},
+		&model.User{},
+		&model.TenantMembership{},
+		&model.Role{},
+		&model.AccessPolicy{},
+		&model.RelationTuple{},
+		&model.RelationRevision{},
+		&model.TenantInvitation{},
+		&model.TenantDomain{},
+		&model.TenantJoinRequest{},
+		&model.WebAuthnCredential{},
+		&model.WebAuthnChallenge{},
+		&model.MagicLinkToken{},
+		&model.LinkedIdentity{},
+		&model.FederatedLoginState{},
+		&model.PhoneOTP{},
+		&model.Session{},
+		&model.LoginEvent{},
+		&model.KnownDevice{},
+		&model.OAuthClient{},
+		&model.OAuthConsent{},
+		&model.OAuthInitialAccessToken{},
+		&model.OAuthAuthorizationRequest{},
+		&model.OAuthAuthorizationCode{},
+		&model.OAuthRefreshToken{},
+		&model.OAuthDeviceCode{},
+		&model.ServiceKey{},
+		&model.AuditEvent{},
+	)
+}
+
 func CloseDB(db *gorm.DB) error {
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -14,6 +15,18 @@ func SetJWTKey(key string) {
 	jwtKey = []byte(key)
 }
 
+// SessionChecker reports whether the server-side session behind a token is still active
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, sessionID string) bool
+}
+
+var sessionChecker SessionChecker
+
+// SetSessionChecker makes AuthMiddleware reject tokens whose session was revoked or expired
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
 // AuthMiddleware validates JWT tokens from Authorization header
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -56,6 +69,27 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Purpose-bound tokens (e.g. MFA tokens) cannot be used as access tokens
+		if _, ok := claims["token_use"]; ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			c.Abort()
+			return
+		}
+
+		// Tokens issued to third-party OAuth clients are meant for the client's own APIs
+		if _, ok := claims["aud"]; ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token audience"})
+			c.Abort()
+			return
+		}
+
+		sessionID, _ := claims["sid"].(string)
+		if sessionChecker != nil && !sessionChecker.IsSessionActive(c.Request.Context(), sessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session revoked or expired"})
+			c.Abort()
+			return
+		}
+
 		// Set user info in context for use in handlers
 		// Support both user_uuid (new) and user_id (old) for backward compatibility
 		userUUID, ok := claims["user_uuid"].(string)
@@ -64,8 +98,45 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 		c.Set("user_id", userUUID)
 		c.Set("tenant_id", claims["tenant_id"])
+		c.Set("tenant_slug", claims["tenant_slug"])
 		c.Set("role", claims["role"])
+		c.Set("session_id", sessionID)
+
+		// Authentication context for step-up checks (RequireRecentAuth)
+		if authTime, ok := claims["auth_time"].(float64); ok {
+			c.Set("auth_time", int64(authTime))
+		}
+		amrValues, _ := claims["amr"].([]interface{})
+		amr := make([]string, 0, len(amrValues))
+		for _, value := range amrValues {
+			if method, ok := value.(string); ok {
+				amr = append(amr, method)
+			}
+		}
+		c.Set("amr", amr)
+
+		// Tokens issued through OAuth only grant what their scope names (see RequirePermission)
+		if scope, ok := claims["scope"].(string); ok {
+			c.Set("scope", strings.Fields(scope))
+		}
+
+		// Impersonation and exchanged tokens name who is acting for the user
+		act, delegated := claims["act"].(map[string]interface{})
+		if delegated {
+			actorClientID, _ := act["client_id"].(string)
+			if actorClientID != "" {
+				c.Set("act_client_id", actorClientID)
+			} else {
+				actorUUID, _ := act["sub"].(string)
+				c.Set("act_user_id", actorUUID)
+			}
+		}
 
 		c.Next()
+
+		if delegated && auditRecorder != nil {
+			auditRecorder.RecordDelegatedRequest(c.Request.Context(), c.GetString("act_user_id"), c.GetString("act_client_id"),
+				userUUID, c.GetString("tenant_id"), c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+		}
 	}
 }
@@ -2,21 +2,102 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"strings"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
 var serviceKey string
 
+// SetServiceKey enables the legacy shared key sent as service-key metadata
 func SetServiceKey(key string) {
 	serviceKey = key
 }
 
+// ServiceTokenVerifier validates service tokens issued by the client credentials grant
+type ServiceTokenVerifier interface {
+	VerifyServiceToken(ctx context.Context, token string) (clientID string, scopes []string, err error)
+}
+
+var serviceTokenVerifier ServiceTokenVerifier
+
+// SetServiceTokenVerifier makes BackendAuthInterceptor accept "authorization: Bearer" service tokens
+func SetServiceTokenVerifier(verifier ServiceTokenVerifier) {
+	serviceTokenVerifier = verifier
+}
+
+// ServiceKeyVerifier resolves per-service keys sent as service-key metadata
+type ServiceKeyVerifier interface {
+	VerifyServiceKey(ctx context.Context, key string) (serviceName string, allowedMethods []string, err error)
+}
+
+var serviceKeyVerifier ServiceKeyVerifier
+
+// SetServiceKeyVerifier makes BackendAuthInterceptor accept keys from the service key store
+func SetServiceKeyVerifier(verifier ServiceKeyVerifier) {
+	serviceKeyVerifier = verifier
+}
+
+// ClientIdentity maps a verified client certificate to a service. Match is the
+// certificate's SPIFFE ID (spiffe://...) or its subject common name as "CN=name".
+type ClientIdentity struct {
+	Match          string   `json:"match"`
+	Service        string   `json:"service"`
+	AllowedMethods []string `json:"allowed_methods"`
+}
+
+var clientIdentities map[string]ClientIdentity
+
+// SetClientIdentities makes BackendAuthInterceptor authenticate callers by their TLS client certificate
+func SetClientIdentities(identities []ClientIdentity) error {
+	mapped := make(map[string]ClientIdentity, len(identities))
+	for _, identity := range identities {
+		if !strings.HasPrefix(identity.Match, "spiffe://") && !strings.HasPrefix(identity.Match, "CN=") {
+			return fmt.Errorf("client identity %q must be a SPIFFE ID or CN=name", identity.Match)
+		}
+		if identity.Service == "" {
+			return fmt.Errorf("client identity %q has no service", identity.Match)
+		}
+		mapped[identity.Match] = identity
+	}
+	clientIdentities = mapped
+	return nil
+}
+
+// ServiceIdentity is the backend service behind a gRPC call
+type ServiceIdentity struct {
+	Name   string   // OAuth client ID or service key owner
+	Scopes []string // token scopes or key's allowed methods
+	Legacy bool     // authenticated with the shared SERVICE_KEY
+	Peer   string   // SPIFFE ID or CN of the verified client certificate, if any
+}
+
+type serviceIdentityKey struct{}
+
+// ServiceIdentityFromContext returns the caller identified by BackendAuthInterceptor
+func ServiceIdentityFromContext(ctx context.Context) (ServiceIdentity, bool) {
+	identity, ok := ctx.Value(serviceIdentityKey{}).(ServiceIdentity)
+	return identity, ok
+}
+
+// BackendAuthInterceptor authenticates backend services by mapped client
+// certificate, service token, per-service key or, as a fallback, the legacy
+// shared key, and puts the caller's ServiceIdentity in the context. Calls are
+// unauthenticated only when none of these is configured.
 func BackendAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if serviceKey == "" {
+	peerID := peerIdentity(ctx)
+	if identity, ok := clientIdentities[peerID]; ok {
+		return callAs(ctx, req, info, handler, ServiceIdentity{Name: identity.Service, Scopes: identity.AllowedMethods, Peer: peerID})
+	}
+	if serviceKey == "" && serviceTokenVerifier == nil && serviceKeyVerifier == nil && len(clientIdentities) == 0 {
 		return handler(ctx, req)
 	}
 
@@ -25,10 +106,79 @@ func BackendAuthInterceptor(ctx context.Context, req interface{}, info *grpc.Una
 		return nil, status.Errorf(codes.Unauthenticated, "missing metadata")
 	}
 
+	if values := md.Get("authorization"); len(values) > 0 && serviceTokenVerifier != nil {
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid authorization metadata")
+		}
+		clientID, scopes, err := serviceTokenVerifier.VerifyServiceToken(ctx, token)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid service token")
+		}
+		return callAs(ctx, req, info, handler, ServiceIdentity{Name: clientID, Scopes: scopes, Peer: peerID})
+	}
+
 	keys := md.Get("service-key")
-	if len(keys) == 0 || keys[0] != serviceKey {
+	if len(keys) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "missing service credentials")
+	}
+	if serviceKeyVerifier != nil {
+		if name, methods, err := serviceKeyVerifier.VerifyServiceKey(ctx, keys[0]); err == nil {
+			return callAs(ctx, req, info, handler, ServiceIdentity{Name: name, Scopes: methods, Peer: peerID})
+		}
+	}
+	if serviceKey == "" || subtle.ConstantTimeCompare([]byte(keys[0]), []byte(serviceKey)) != 1 {
 		return nil, status.Errorf(codes.Unauthenticated, "invalid service key")
 	}
+	if serviceTokenVerifier != nil || serviceKeyVerifier != nil {
+		log.Printf("Warning: %s called with the legacy SERVICE_KEY; issue the caller its own key or client", info.FullMethod)
+	}
+	identity := ServiceIdentity{Name: "legacy-service-key", Legacy: true, Peer: peerID}
+	return handler(context.WithValue(ctx, serviceIdentityKey{}, identity), req)
+}
 
-	return handler(ctx, req)
+// callAs runs handler for an authenticated service after checking its scopes
+// allow the method
+func callAs(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler, identity ServiceIdentity) (interface{}, error) {
+	if !scopeAllowsMethod(identity.Scopes, info.FullMethod) {
+		log.Printf("Denied %s to service %s", info.FullMethod, identity.Name)
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", identity.Name, info.FullMethod)
+	}
+	return handler(context.WithValue(ctx, serviceIdentityKey{}, identity), req)
+}
+
+// scopeAllowsMethod reports whether a scope names the gRPC service
+// ("auth.v1.AuthService") or the method ("auth.v1.AuthService/CheckPermission")
+func scopeAllowsMethod(scopes []string, fullMethod string) bool {
+	method := strings.TrimPrefix(fullMethod, "/")
+	serviceName, _, _ := strings.Cut(method, "/")
+	for _, scope := range scopes {
+		if scope == method || scope == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIdentity returns the SPIFFE ID, or else "CN=" and the common name, of a
+// verified TLS client certificate; "" without one
+func peerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	if cert.Subject.CommonName != "" {
+		return "CN=" + cert.Subject.CommonName
+	}
+	return ""
 }
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// resetBackendAuth clears the interceptor's configuration when the test ends
+func resetBackendAuth(t *testing.T) {
+	t.Cleanup(func() {
+		serviceKey = ""
+		serviceTokenVerifier = nil
+		serviceKeyVerifier = nil
+		clientIdentities = nil
+	})
+}
+
+// callBackend runs BackendAuthInterceptor for method with the given metadata
+// and returns the identity the handler saw
+func callBackend(method string, md metadata.MD) (ServiceIdentity, error) {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	var identity ServiceIdentity
+	_, err := BackendAuthInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		identity, _ = ServiceIdentityFromContext(ctx)
+		return nil, nil
+	})
+	return identity, err
+}
+
+func TestBackendAuthServiceTokenScopes(t *testing.T) {
+	resetBackendAuth(t)
+	service.SetJWTKey("test-secret")
+	SetServiceTokenVerifier(service.NewAuthService(nil))
+
+	token, _, err := service.IssueServiceToken("billing", []string{"auth.v1.AuthService/CheckPermission", "auth.v1.RelationService"})
+	if err != nil {
+		t.Fatalf("IssueServiceToken: %v", err)
+	}
+	bearer := metadata.Pairs("authorization", "Bearer "+token)
+
+	tests := []struct {
+		name   string
+		method string
+		md     metadata.MD
+		want   codes.Code
+	}{
+		{"method in scope", "/auth.v1.AuthService/CheckPermission", bearer, codes.OK},
+		{"service in scope", "/auth.v1.RelationService/Check", bearer, codes.OK},
+		{"other method of a scoped service", "/auth.v1.AuthService/ValidateToken", bearer, codes.PermissionDenied},
+		{"method named only by prefix", "/auth.v1.AuthService/CheckPermissions", bearer, codes.PermissionDenied},
+		{"service named only by prefix", "/auth.v1.RelationServiceAdmin/Write", bearer, codes.PermissionDenied},
+		{"tampered token", "/auth.v1.RelationService/Check", metadata.Pairs("authorization", "Bearer "+token+"x"), codes.Unauthenticated},
+		{"not a bearer token", "/auth.v1.RelationService/Check", metadata.Pairs("authorization", token), codes.Unauthenticated},
+		{"no credentials", "/auth.v1.RelationService/Check", metadata.MD{}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := callBackend(tt.method, tt.md)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("code = %v (%v), want %v", got, err, tt.want)
+			}
+			if tt.want == codes.OK && (identity.Name != "billing" || identity.Legacy) {
+				t.Fatalf("handler saw identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestBackendAuthRejectsUserTokens(t *testing.T) {
+	resetBackendAuth(t)
+	service.SetJWTKey("test-secret")
+	SetServiceTokenVerifier(service.NewAuthService(nil))
+
+	// A user's access token must not pass as a service token even with a scope naming the service
+	userToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_uuid": "00000000-0000-0000-0000-000000000001",
+		"client_id": "billing",
+		"scope":     "auth.v1.AuthService",
+		"exp":       time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	_, err = callBackend("/auth.v1.AuthService/CheckPermission", metadata.Pairs("authorization", "Bearer "+userToken))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("code = %v, want Unauthenticated", status.Code(err))
+	}
+}
+
+// fakeKeyVerifier maps raw service keys to a service and its allowed methods
+type fakeKeyVerifier map[string][]string
+
+func (f fakeKeyVerifier) VerifyServiceKey(ctx context.Context, key string) (string, []string, error) {
+	methods, ok := f[key]
+	if !ok {
+		return "", nil, service.ErrInvalidServiceKey
+	}
+	return "billing", methods, nil
+}
+
+func TestBackendAuthServiceKeyMethods(t *testing.T) {
+	resetBackendAuth(t)
+	SetServiceKeyVerifier(fakeKeyVerifier{
+		"sk_billing": {"auth.v1.AuthService/CheckPermission", "auth.v1.RelationService"},
+		"sk_nothing": nil,
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		want   codes.Code
+	}{
+		{"allowed method", "/auth.v1.AuthService/CheckPermission", "sk_billing", codes.OK},
+		{"method of an allowed service", "/auth.v1.RelationService/Write", "sk_billing", codes.OK},
+		{"method not allowed", "/auth.v1.AuthService/ValidateToken", "sk_billing", codes.PermissionDenied},
+		{"key without methods", "/auth.v1.AuthService/CheckPermission", "sk_nothing", codes.PermissionDenied},
+		{"unknown key", "/auth.v1.AuthService/CheckPermission", "sk_unknown", codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := callBackend(tt.method, metadata.Pairs("service-key", tt.key))
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("code = %v (%v), want %v", got, err, tt.want)
+			}
+			if tt.want == codes.OK && identity.Name != "billing" {
+				t.Fatalf("handler saw identity %+v", identity)
+			}
+		})
+	}
+
+	// The legacy shared key is the fallback for keys the store does not know
+	SetServiceKey("legacy-secret")
+	identity, err := callBackend("/auth.v1.AuthService/ValidateToken", metadata.Pairs("service-key", "legacy-secret"))
+	if err != nil || !identity.Legacy {
+		t.Fatalf("legacy key: identity %+v, error %v", identity, err)
+	}
+}
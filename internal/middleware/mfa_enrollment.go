@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenUseMFAEnrollment marks the tokens the auth service issues when a tenant
+// requires MFA that the user has not enrolled yet
+const tokenUseMFAEnrollment = "mfa_enrollment"
+
+// MFAEnrollmentAuthMiddleware is AuthMiddleware for the routes that enroll a second
+// factor. It also accepts an MFA enrollment token, which is good for nothing else.
+func MFAEnrollmentAuthMiddleware() gin.HandlerFunc {
+	authenticate := AuthMiddleware()
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			authenticate(c)
+			return
+		}
+
+		token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
+			return jwtKey, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil || !token.Valid {
+			authenticate(c)
+			return
+		}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || claims["token_use"] != tokenUseMFAEnrollment {
+			authenticate(c)
+			return
+		}
+
+		userUUID, _ := claims["user_uuid"].(string)
+		if userUUID == "" {
+			authenticate(c)
+			return
+		}
+		c.Set("user_id", userUUID)
+		c.Set("tenant_id", claims["tenant_id"])
+		c.Set("amr", []string{})
+		c.Set("mfa_enrollment", true)
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionChecker reports whether a user holds a permission in a tenant
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userUUID, tenantUUID, role, permission string) bool
+}
+
+var permissionChecker PermissionChecker
+
+// SetPermissionChecker enables RequirePermission; without a checker every request is denied
+func SetPermissionChecker(checker PermissionChecker) {
+	permissionChecker = checker
+}
+
+// RequirePermission only lets through users holding every one of permissions in
+// the tenant their token is scoped to. Tokens limited by an OAuth scope must also
+// name each permission in it. It must run after AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if !scopeAllows(c, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "insufficient scope"})
+				c.Abort()
+				return
+			}
+			if permissionChecker == nil || !permissionChecker.HasPermission(c.Request.Context(), c.GetString("user_id"), c.GetString("tenant_id"), c.GetString("role"), permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// scopeAllows reports whether the token's scope covers permission. Sessions carry
+// no scope and are only limited by the user's role.
+func scopeAllows(c *gin.Context, permission string) bool {
+	value, limited := c.Get("scope")
+	if !limited {
+		return true
+	}
+	scopes, _ := value.([]string)
+	for _, scope := range scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// AccessPolicy is a tenant's attribute-based rule: when Condition (a CEL
+// expression) holds for a check of one of Permissions, the policy allows or
+// denies it. Deny policies override role grants and allow policies.
+type AccessPolicy struct {
+	ID          uint     `gorm:"primaryKey;autoIncrement"`
+	UUID        string   `gorm:"type:uuid;uniqueIndex;not null"`
+	TenantUUID  string   `gorm:"type:uuid;not null;uniqueIndex:idx_access_policy_tenant_name;column:tenant_uuid"`
+	Name        string   `gorm:"type:varchar(100);not null;uniqueIndex:idx_access_policy_tenant_name"`
+	Description string   `gorm:"type:varchar(255)"`
+	Effect      string   `gorm:"type:varchar(10);not null"`
+	Permissions []string `gorm:"type:jsonb;not null;serializer:json"` // permission patterns, "*" wildcards allowed
+	Condition   string   `gorm:"type:text;not null"`
+	Enabled     bool     `gorm:"not null;default:true"`
+	CreatedBy   string   `gorm:"type:uuid;column:created_by"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (AccessPolicy) TableName() string {
+	return "access_policies"
+}
+
+func (p *AccessPolicy) BeforeCreate(tx *gorm.DB) error {
+	if p.UUID == "" {
+		p.UUID = uuid.New().String()
+	}
+	return nil
+}
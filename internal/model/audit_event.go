@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// AuditEvent records a security-relevant action, such as one user acting as
+// another. Details holds action specific values like the stated reason.
+type AuditEvent struct {
+	ID            uint              `gorm:"primaryKey;autoIncrement"`
+	Action        string            `gorm:"type:varchar(100);index;not null"`
+	ActorUUID     string            `gorm:"type:varchar(36);index;column:actor_uuid"`   // user who acted, empty for clients
+	ActorClientID string            `gorm:"type:varchar(64);column:actor_client_id"`    // OAuth client that acted
+	SubjectUUID   string            `gorm:"type:varchar(36);index;column:subject_uuid"` // user acted upon or on behalf of
+	TenantUUID    string            `gorm:"type:varchar(36);index;column:tenant_uuid"`
+	Details       map[string]string `gorm:"type:jsonb;not null;default:'{}';serializer:json"`
+	IPAddress     string            `gorm:"type:varchar(45);column:ip_address"`
+	UserAgent     string            `gorm:"type:varchar(512);column:user_agent"`
+	CreatedAt     time.Time         `gorm:"index"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
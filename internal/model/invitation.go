@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TenantInvitation invites an email address to join a tenant with a role.
+// Only the SHA-256 hash of the single-use token is stored.
+type TenantInvitation struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement"`
+	UUID       string     `gorm:"type:uuid;uniqueIndex;not null"`
+	TenantUUID string     `gorm:"type:uuid;index;not null;column:tenant_uuid"`
+	Email      string     `gorm:"type:varchar(255);index;not null"`
+	Role       string     `gorm:"type:varchar(50);not null"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null;column:token_hash"`
+	InvitedBy  string     `gorm:"type:uuid;not null;column:invited_by"`
+	ExpiresAt  time.Time  `gorm:"not null;column:expires_at"`
+	AcceptedAt *time.Time `gorm:"column:accepted_at"`
+	AcceptedBy *string    `gorm:"type:uuid;column:accepted_by"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	CreatedAt  time.Time
+}
+
+func (TenantInvitation) TableName() string {
+	return "tenant_invitations"
+}
+
+func (i *TenantInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.UUID == "" {
+		i.UUID = uuid.New().String()
+	}
+	return nil
+}
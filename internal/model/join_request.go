@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// TenantJoinRequest asks a tenant's admins to let a user in
+type TenantJoinRequest struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement"`
+	UUID       string     `gorm:"type:uuid;uniqueIndex;not null"`
+	TenantUUID string     `gorm:"type:uuid;index;not null;column:tenant_uuid"`
+	UserUUID   string     `gorm:"type:uuid;index;not null;column:user_uuid"`
+	Status     string     `gorm:"type:varchar(20);not null;default:'pending'"`
+	DecidedBy  *string    `gorm:"type:uuid;column:decided_by"`
+	DecidedAt  *time.Time `gorm:"column:decided_at"`
+	CreatedAt  time.Time
+}
+
+func (TenantJoinRequest) TableName() string {
+	return "tenant_join_requests"
+}
+
+func (r *TenantJoinRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.UUID == "" {
+		r.UUID = uuid.New().String()
+	}
+	return nil
+}
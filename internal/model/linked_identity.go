@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LinkedIdentity maps an account at an upstream OpenID Connect provider to a user
+type LinkedIdentity struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement"`
+	UUID        string     `gorm:"type:uuid;uniqueIndex;not null"`
+	UserUUID    string     `gorm:"type:uuid;index;not null;column:user_uuid"`
+	Provider    string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_linked_identities_subject"`  // provider id from the providers file
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_linked_identities_subject"` // sub claim, unique per issuer
+	Email       string     `gorm:"type:varchar(255)"`                                                    // email the provider reported at the last login
+	LastLoginAt *time.Time `gorm:"column:last_login_at"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (LinkedIdentity) TableName() string {
+	return "linked_identities"
+}
+
+func (i *LinkedIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.UUID == "" {
+		i.UUID = uuid.New().String()
+	}
+	return nil
+}
+
+// FederatedLoginState tracks a redirect to an upstream provider until it returns.
+// Only the SHA-256 hash of the state parameter is stored.
+type FederatedLoginState struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement"`
+	StateHash    string    `gorm:"type:varchar(64);uniqueIndex;not null;column:state_hash"`
+	Provider     string    `gorm:"type:varchar(50);not null"`
+	Nonce        string    `gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null;column:code_verifier"` // PKCE verifier for the upstream token request
+	LinkUserUUID string    `gorm:"type:varchar(36);column:link_user_uuid"`          // set when a signed-in user links the identity
+	ExpiresAt    time.Time `gorm:"index;not null;column:expires_at"`
+	CreatedAt    time.Time
+}
+
+func (FederatedLoginState) TableName() string {
+	return "federated_login_states"
+}
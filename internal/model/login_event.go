@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// LoginEvent is an audit record of one login attempt
+type LoginEvent struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement"`
+	UserUUID          string    `gorm:"type:varchar(36);index;column:user_uuid"` // empty when the email matched no account
+	Email             string    `gorm:"type:varchar(255);index"`
+	Method            string    `gorm:"type:varchar(100)"` // comma separated amr values
+	Success           bool      `gorm:"not null"`
+	Reason            string    `gorm:"type:varchar(100)"` // failure reason
+	IPAddress         string    `gorm:"type:varchar(45);column:ip_address"`
+	UserAgent         string    `gorm:"type:varchar(512);column:user_agent"`
+	DeviceFingerprint string    `gorm:"type:varchar(64);column:device_fingerprint"`
+	CreatedAt         time.Time `gorm:"index"`
+}
+
+func (LoginEvent) TableName() string {
+	return "login_events"
+}
+
+// KnownDevice is a device fingerprint a user has successfully signed in from
+type KnownDevice struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	UserUUID    string    `gorm:"type:uuid;not null;uniqueIndex:idx_known_devices_user_fingerprint;column:user_uuid"`
+	Fingerprint string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_known_devices_user_fingerprint"`
+	UserAgent   string    `gorm:"type:varchar(512);column:user_agent"`
+	FirstSeenAt time.Time `gorm:"not null;column:first_seen_at"`
+	LastSeenAt  time.Time `gorm:"not null;column:last_seen_at"`
+}
+
+func (KnownDevice) TableName() string {
+	return "known_devices"
+}
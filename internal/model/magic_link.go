@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// MagicLinkToken is a single-use passwordless login link. Only the SHA-256 hash of the token is stored.
+type MagicLinkToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement"`
+	UserUUID  string     `gorm:"type:uuid;index;not null;column:user_uuid"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null;column:token_hash"`
+	ExpiresAt time.Time  `gorm:"index;not null;column:expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time
+}
+
+func (MagicLinkToken) TableName() string {
+	return "magic_link_tokens"
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	MembershipStatusActive    = "active"
+	MembershipStatusSuspended = "suspended"
+)
+
+// TenantMembership grants a user a role in a tenant. A user can belong to several
+// tenants; User.TenantID is the tenant they sign in to by default.
+type TenantMembership struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	UserUUID   string `gorm:"type:uuid;not null;uniqueIndex:idx_membership_user_tenant;column:user_uuid"`
+	TenantUUID string `gorm:"type:uuid;not null;uniqueIndex:idx_membership_user_tenant;index;column:tenant_uuid"`
+	Role       string `gorm:"type:varchar(50);not null;default:'user'"`
+	Status     string `gorm:"type:varchar(20);not null;default:'active'"`
+	// Attributes describe the member inside the tenant (e.g. department) for access policies
+	Attributes map[string]string `gorm:"type:jsonb;serializer:json"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (TenantMembership) TableName() string {
+	return "tenant_memberships"
+}
+
+func (m *TenantMembership) IsActive() bool {
+	return m.Status == MembershipStatusActive
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	OAuthClientPublic       = "public"       // native and browser apps; cannot keep a secret, must use PKCE
+	OAuthClientConfidential = "confidential" // servers that authenticate with a client secret
+)
+
+// OAuthClient is an application registered to obtain tokens through the OAuth 2.0
+// authorization server. Only the SHA-256 hash of a confidential client's secret is stored.
+type OAuthClient struct {
+	ID                     uint       `gorm:"primaryKey;autoIncrement"`
+	ClientID               string     `gorm:"type:varchar(64);uniqueIndex;not null;column:client_id"`
+	SecretHash             string     `gorm:"type:varchar(64);column:secret_hash"`
+	Name                   string     `gorm:"type:varchar(100);not null"`
+	Type                   string     `gorm:"type:varchar(20);not null"`
+	RedirectURIs           []string   `gorm:"type:jsonb;not null;serializer:json;column:redirect_uris"`
+	PostLogoutRedirectURIs []string   `gorm:"type:jsonb;not null;default:'[]';serializer:json;column:post_logout_redirect_uris"`
+	Scopes                 []string   `gorm:"type:jsonb;not null;serializer:json"` // scopes the client may request
+	GrantTypes             []string   `gorm:"type:jsonb;not null;serializer:json;column:grant_types"`
+	LogoURI                string     `gorm:"type:varchar(2048);column:logo_uri"`        // shown on the consent screen
+	FirstParty             bool       `gorm:"not null;default:false;column:first_party"` // trusted app: no consent screen, full API access
+	DisabledAt             *time.Time `gorm:"column:disabled_at"`
+	CreatedBy              string     `gorm:"type:uuid;column:created_by"`
+	RegistrationTokenUUID  string     `gorm:"type:varchar(36);index;column:registration_token_uuid"` // initial access token of a dynamically registered client
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func (c *OAuthClient) IsPublic() bool {
+	return c.Type == OAuthClientPublic
+}
+
+// OAuthConsent records the scopes a user granted a client in one tenant
+type OAuthConsent struct {
+	ID         uint     `gorm:"primaryKey;autoIncrement"`
+	UserUUID   string   `gorm:"type:uuid;not null;uniqueIndex:idx_oauth_consent;column:user_uuid"`
+	TenantUUID string   `gorm:"type:uuid;not null;uniqueIndex:idx_oauth_consent;column:tenant_uuid"`
+	ClientID   string   `gorm:"type:varchar(64);not null;uniqueIndex:idx_oauth_consent;column:client_id"`
+	Scopes     []string `gorm:"type:jsonb;not null;serializer:json"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// OAuthInitialAccessToken authorizes dynamic client registration (RFC 7591
+// section 3). Only the SHA-256 hash of the token is stored.
+type OAuthInitialAccessToken struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement"`
+	UUID        string     `gorm:"type:uuid;uniqueIndex;not null"`
+	TokenHash   string     `gorm:"type:varchar(64);uniqueIndex;not null;column:token_hash"`
+	Description string     `gorm:"type:varchar(255)"`
+	Scopes      []string   `gorm:"type:jsonb;not null;serializer:json"` // scopes registered clients may request
+	MaxUses     int        `gorm:"not null;default:0;column:max_uses"`  // 0 for unlimited
+	UseCount    int        `gorm:"not null;default:0;column:use_count"`
+	ExpiresAt   time.Time  `gorm:"not null;column:expires_at"`
+	RevokedAt   *time.Time `gorm:"column:revoked_at"`
+	CreatedBy   string     `gorm:"type:uuid;column:created_by"`
+	CreatedAt   time.Time
+}
+
+func (OAuthInitialAccessToken) TableName() string {
+	return "oauth_initial_access_tokens"
+}
+
+func (t *OAuthInitialAccessToken) BeforeCreate(tx *gorm.DB) error {
+	if t.UUID == "" {
+		t.UUID = uuid.New().String()
+	}
+	return nil
+}
+
+// IsActive reports whether the token can still register clients
+func (t *OAuthInitialAccessToken) IsActive(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt) && (t.MaxUses == 0 || t.UseCount < t.MaxUses)
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthAuthorizationRequest is a validated /oauth2/authorize request waiting for
+// the user to sign in and approve it on the consent page
+type OAuthAuthorizationRequest struct {
+	ID                  uint       `gorm:"primaryKey;autoIncrement"`
+	UUID                string     `gorm:"type:uuid;uniqueIndex;not null"`
+	ClientID            string     `gorm:"type:varchar(64);not null;column:client_id"`
+	RedirectURI         string     `gorm:"type:text;not null;column:redirect_uri"`
+	Scope               string     `gorm:"type:text;not null"` // space separated
+	State               string     `gorm:"type:text"`
+	CodeChallenge       string     `gorm:"type:varchar(128);not null;column:code_challenge"`
+	CodeChallengeMethod string     `gorm:"type:varchar(10);not null;column:code_challenge_method"`
+	Nonce               string     `gorm:"type:varchar(255)"` // OpenID Connect nonce echoed in the ID token
+	ExpiresAt           time.Time  `gorm:"index;not null;column:expires_at"`
+	CompletedAt         *time.Time `gorm:"column:completed_at"`
+	CreatedAt           time.Time
+}
+
+func (OAuthAuthorizationRequest) TableName() string {
+	return "oauth_authorization_requests"
+}
+
+func (r *OAuthAuthorizationRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.UUID == "" {
+		r.UUID = uuid.New().String()
+	}
+	return nil
+}
+
+// OAuthAuthorizationCode is a single-use code exchanged at the token endpoint.
+// Only the SHA-256 hash of the code is stored.
+type OAuthAuthorizationCode struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement"`
+	CodeHash      string     `gorm:"type:varchar(64);uniqueIndex;not null;column:code_hash"`
+	ClientID      string     `gorm:"type:varchar(64);not null;column:client_id"`
+	UserUUID      string     `gorm:"type:uuid;not null;column:user_uuid"`
+	TenantUUID    string     `gorm:"type:uuid;not null;column:tenant_uuid"`
+	RedirectURI   string     `gorm:"type:text;not null;column:redirect_uri"`
+	Scope         string     `gorm:"type:text;not null"`
+	CodeChallenge string     `gorm:"type:varchar(128);not null;column:code_challenge"`
+	Nonce         string     `gorm:"type:varchar(255)"`
+	AMR           string     `gorm:"type:varchar(100);column:amr"` // comma separated methods of the approving login
+	AuthTime      time.Time  `gorm:"not null;column:auth_time"`
+	SessionUUID   string     `gorm:"type:varchar(36);column:session_uuid"` // session created by the exchange
+	ExpiresAt     time.Time  `gorm:"index;not null;column:expires_at"`
+	UsedAt        *time.Time `gorm:"column:used_at"`
+	CreatedAt     time.Time
+}
+
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// OAuthRefreshToken lets a client renew its access token while its session is
+// active. Refresh tokens rotate on every use; presenting a used one revokes the session.
+type OAuthRefreshToken struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement"`
+	TokenHash   string     `gorm:"type:varchar(64);uniqueIndex;not null;column:token_hash"`
+	ClientID    string     `gorm:"type:varchar(64);not null;column:client_id"`
+	SessionUUID string     `gorm:"type:uuid;index;not null;column:session_uuid"`
+	UserUUID    string     `gorm:"type:uuid;not null;column:user_uuid"`
+	TenantUUID  string     `gorm:"type:uuid;not null;column:tenant_uuid"`
+	Scope       string     `gorm:"type:text;not null"`
+	AMR         string     `gorm:"type:varchar(100);column:amr"`
+	AuthTime    time.Time  `gorm:"not null;column:auth_time"`
+	ExpiresAt   time.Time  `gorm:"index;not null;column:expires_at"`
+	UsedAt      *time.Time `gorm:"column:used_at"`
+	CreatedAt   time.Time
+}
+
+func (OAuthRefreshToken) TableName() string {
+	return "oauth_refresh_tokens"
+}
+
+// Device code states
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+	DeviceCodeConsumed = "consumed"
+)
+
+// OAuthDeviceCode is a device authorization request (RFC 8628). The device polls
+// with the device code, only the hash of which is stored, while the user
+// approves the short user code on the verification page.
+type OAuthDeviceCode struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement"`
+	DeviceCodeHash string     `gorm:"type:varchar(64);uniqueIndex;not null;column:device_code_hash"`
+	UserCode       string     `gorm:"type:varchar(16);uniqueIndex;not null;column:user_code"` // normalized, without the dash
+	ClientID       string     `gorm:"type:varchar(64);not null;column:client_id"`
+	Scope          string     `gorm:"type:text;not null"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'pending'"`
+	Interval       int        `gorm:"not null"` // seconds the device must wait between polls
+	LastPolledAt   *time.Time `gorm:"column:last_polled_at"`
+	UserUUID       string     `gorm:"type:varchar(36);column:user_uuid"` // set once answered
+	TenantUUID     string     `gorm:"type:varchar(36);column:tenant_uuid"`
+	AMR            string     `gorm:"type:varchar(100);column:amr"`
+	AuthTime       *time.Time `gorm:"column:auth_time"`
+	ExpiresAt      time.Time  `gorm:"index;not null;column:expires_at"`
+	CreatedAt      time.Time
+}
+
+func (OAuthDeviceCode) TableName() string {
+	return "oauth_device_codes"
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Purposes for phone one-time codes
+const (
+	PhoneOTPPurposeVerify = "verify"
+	PhoneOTPPurposeLogin  = "login"
+)
+
+// PhoneOTP is a one-time code sent by SMS. Only an HMAC of the code is stored.
+type PhoneOTP struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement"`
+	UserUUID    string     `gorm:"type:uuid;index;not null;column:user_uuid"`
+	PhoneNumber string     `gorm:"type:varchar(20);not null;column:phone_number"`
+	Purpose     string     `gorm:"type:varchar(20);not null"`
+	CodeHash    string     `gorm:"type:varchar(64);not null;column:code_hash"`
+	Attempts    int        `gorm:"not null;default:0"`
+	ExpiresAt   time.Time  `gorm:"index;not null;column:expires_at"`
+	ConsumedAt  *time.Time `gorm:"column:consumed_at"`
+	CreatedAt   time.Time
+}
+
+func (PhoneOTP) TableName() string {
+	return "phone_otps"
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// RelationTuple states that a subject has a relation to an object, written
+// object_type:object_id#relation@subject. When SubjectRelation is set the subject
+// is a userset: everyone holding that relation to the subject object
+// (e.g. document:readme#viewer@group:eng#member). SubjectID "*" means every
+// subject of SubjectType.
+type RelationTuple struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement"`
+	TenantUUID      string `gorm:"type:uuid;not null;uniqueIndex:idx_relation_tuple;column:tenant_uuid"`
+	ObjectType      string `gorm:"type:varchar(64);not null;uniqueIndex:idx_relation_tuple"`
+	ObjectID        string `gorm:"type:varchar(128);not null;uniqueIndex:idx_relation_tuple"`
+	Relation        string `gorm:"type:varchar(64);not null;uniqueIndex:idx_relation_tuple"`
+	SubjectType     string `gorm:"type:varchar(64);not null;uniqueIndex:idx_relation_tuple"`
+	SubjectID       string `gorm:"type:varchar(128);not null;uniqueIndex:idx_relation_tuple"`
+	SubjectRelation string `gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_relation_tuple"`
+	Revision        int64  `gorm:"not null"` // revision of the write that created the tuple
+	CreatedAt       time.Time
+}
+
+func (RelationTuple) TableName() string {
+	return "relation_tuples"
+}
+
+// RelationRevision numbers writes to the relation tuple store. Consistency
+// tokens carry a revision so reads can be made at least as fresh as a write.
+type RelationRevision struct {
+	ID        int64 `gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time
+}
+
+func (RelationRevision) TableName() string {
+	return "relation_revisions"
+}
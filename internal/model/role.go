@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Role is a named set of permissions ("resource:action", "resource:*" or "*").
+// Global roles have an empty TenantUUID; a tenant role with the same name
+// overrides the global one inside that tenant. Memberships refer to roles by name.
+type Role struct {
+	ID          uint     `gorm:"primaryKey;autoIncrement"`
+	UUID        string   `gorm:"type:uuid;uniqueIndex;not null"`
+	TenantUUID  string   `gorm:"type:varchar(36);not null;default:'';uniqueIndex:idx_role_tenant_name;column:tenant_uuid"`
+	Name        string   `gorm:"type:varchar(50);not null;uniqueIndex:idx_role_tenant_name"`
+	Description string   `gorm:"type:varchar(255)"`
+	Permissions []string `gorm:"type:jsonb;not null;serializer:json"`
+	BuiltIn     bool     `gorm:"not null;default:false;column:built_in"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) error {
+	if r.UUID == "" {
+		r.UUID = uuid.New().String()
+	}
+	return nil
+}
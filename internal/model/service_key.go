@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ServiceKey authenticates a backend service calling the gRPC API with
+// service-key metadata. A service may hold several keys so they can be rotated
+// without downtime. Only the SHA-256 hash of the key is stored.
+type ServiceKey struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement"`
+	UUID           string     `gorm:"type:uuid;uniqueIndex;not null"`
+	ServiceName    string     `gorm:"type:varchar(100);index;not null;column:service_name"`
+	Description    string     `gorm:"type:varchar(255)"`
+	KeyHash        string     `gorm:"type:varchar(64);uniqueIndex;not null;column:key_hash"`
+	KeyPrefix      string     `gorm:"type:varchar(16);not null;column:key_prefix"` // shown to tell keys apart
+	AllowedMethods []string   `gorm:"type:jsonb;not null;serializer:json;column:allowed_methods"`
+	ExpiresAt      *time.Time `gorm:"column:expires_at"`
+	LastUsedAt     *time.Time `gorm:"column:last_used_at"`
+	RevokedAt      *time.Time `gorm:"column:revoked_at"`
+	CreatedBy      string     `gorm:"type:uuid;column:created_by"`
+	CreatedAt      time.Time
+}
+
+func (ServiceKey) TableName() string {
+	return "service_keys"
+}
+
+func (k *ServiceKey) BeforeCreate(tx *gorm.DB) error {
+	if k.UUID == "" {
+		k.UUID = uuid.New().String()
+	}
+	return nil
+}
+
+// IsActive reports whether the key may authenticate calls at now
+func (k *ServiceKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
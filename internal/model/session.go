@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is one login of a user on a device. Access tokens carry its UUID in the sid claim.
+type Session struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement"`
+	UUID       string     `gorm:"type:uuid;uniqueIndex;not null"`
+	UserUUID   string     `gorm:"type:uuid;index;not null;column:user_uuid"`
+	UserAgent  string     `gorm:"type:varchar(512);column:user_agent"`
+	IPAddress  string     `gorm:"type:varchar(45);column:ip_address"`
+	AMR        string     `gorm:"type:varchar(100);column:amr"` // comma separated authentication methods
+	MFA        bool       `gorm:"not null;default:false;column:mfa"`
+	ClientID   string     `gorm:"type:varchar(64);column:client_id"` // OAuth client the session was created for
+	LastSeenAt time.Time  `gorm:"not null;column:last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"index;not null;column:expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	CreatedAt  time.Time
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.UUID == "" {
+		s.UUID = uuid.New().String()
+	}
+	return nil
+}
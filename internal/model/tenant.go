@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	TenantStatusActive    = "active"
+	TenantStatusSuspended = "suspended"
+)
+
+// Tenant is an organization users belong to. User.TenantID holds the tenant's UUID.
+type Tenant struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	UUID      string `gorm:"type:uuid;uniqueIndex;not null"`
+	Slug      string `gorm:"type:varchar(63);uniqueIndex;not null"`
+	Name      string `gorm:"type:varchar(255);not null"`
+	Status    string `gorm:"type:varchar(20);not null;default:'active'"`
+	Settings  string `gorm:"type:jsonb;not null;default:'{}'"` // JSON object
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+func (t *Tenant) BeforeCreate(tx *gorm.DB) error {
+	if t.UUID == "" {
+		t.UUID = uuid.New().String()
+	}
+	if t.Settings == "" {
+		t.Settings = "{}"
+	}
+	return nil
+}
+
+func (t *Tenant) IsActive() bool {
+	return t.Status == TenantStatusActive
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// What happens when someone signs up with an email in a verified tenant domain
+const (
+	DomainJoinNone    = "none"      // discovery only
+	DomainJoinAuto    = "auto_join" // join after confirming the email address
+	DomainJoinRequest = "request"   // a tenant admin approves a join request
+)
+
+// TenantDomain is an email domain claimed by a tenant. It is only used for
+// discovery and auto-join once ownership was proven with a DNS TXT record.
+// A domain can be verified by one tenant at a time.
+type TenantDomain struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement"`
+	UUID              string     `gorm:"type:uuid;uniqueIndex;not null"`
+	TenantUUID        string     `gorm:"type:uuid;index;not null;column:tenant_uuid"`
+	Domain            string     `gorm:"type:varchar(255);uniqueIndex:idx_verified_domain,where:verified_at IS NOT NULL;not null"`
+	JoinPolicy        string     `gorm:"type:varchar(20);not null;default:'none';column:join_policy"`
+	VerificationToken string     `gorm:"type:varchar(64);not null;column:verification_token"`
+	VerifiedAt        *time.Time `gorm:"column:verified_at"`
+	CreatedAt         time.Time
+}
+
+func (TenantDomain) TableName() string {
+	return "tenant_domains"
+}
+
+func (d *TenantDomain) BeforeCreate(tx *gorm.DB) error {
+	if d.UUID == "" {
+		d.UUID = uuid.New().String()
+	}
+	return nil
+}
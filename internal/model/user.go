@@ -11,12 +11,14 @@ type User struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement"`
 	UUID         string    `gorm:"type:uuid;uniqueIndex;not null"`
 	Email        string    `gorm:"type:varchar(255);uniqueIndex;not null"`
+	EmailVerifiedAt *time.Time `gorm:"column:email_verified_at"` // set once the user proved they receive mail at Email
 	Username     string    `gorm:"type:varchar(50);uniqueIndex;not null"`
 	PasswordHash string    `gorm:"type:varchar(255);not null;column:password"`
-	PhoneNumber  string    `gorm:"type:varchar(20);column:phone_number"`
+	PhoneNumber  string    `gorm:"type:varchar(20);column:phone_number;uniqueIndex:idx_users_verified_phone,where:phone_verified_at IS NOT NULL"` // E.164, unique once verified
+	PhoneVerifiedAt *time.Time `gorm:"column:phone_verified_at"`
 	FirstName    string    `gorm:"type:varchar(100);column:first_name"`
 	LastName     string    `gorm:"type:varchar(100);column:last_name"`
-	TenantID     string    `gorm:"type:varchar(255);column:tenant_id"`
+	TenantID     string    `gorm:"type:varchar(255);index;column:tenant_id"` // tenants.uuid
 	Role         string    `gorm:"type:varchar(50);default:'user'"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
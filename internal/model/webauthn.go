@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredential is a passkey / security key registered by a user
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey;autoIncrement"`
+	UUID            string     `gorm:"type:uuid;uniqueIndex;not null"`
+	UserUUID        string     `gorm:"type:uuid;index;not null;column:user_uuid"`
+	CredentialID    string     `gorm:"type:varchar(1024);uniqueIndex;not null;column:credential_id"` // base64url encoded
+	PublicKey       []byte     `gorm:"type:bytea;not null;column:public_key"`                        // COSE encoded
+	AttestationType string     `gorm:"type:varchar(50);column:attestation_type"`
+	AAGUID          []byte     `gorm:"type:bytea;column:aaguid"`
+	SignCount       uint32     `gorm:"not null;default:0;column:sign_count"`
+	CloneWarning    bool       `gorm:"not null;default:false;column:clone_warning"`
+	Transports      string     `gorm:"type:varchar(255);column:transports"` // comma separated
+	BackupEligible  bool       `gorm:"not null;default:false;column:backup_eligible"`
+	BackupState     bool       `gorm:"not null;default:false;column:backup_state"`
+	Name            string     `gorm:"type:varchar(100)"`
+	LastUsedAt      *time.Time `gorm:"column:last_used_at"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+func (c *WebAuthnCredential) BeforeCreate(tx *gorm.DB) error {
+	if c.UUID == "" {
+		c.UUID = uuid.New().String()
+	}
+	return nil
+}
+
+// WebAuthn ceremony types stored with a challenge
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+// WebAuthnChallenge holds the server side state of an in-flight registration or login ceremony
+type WebAuthnChallenge struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	UUID        string    `gorm:"type:uuid;uniqueIndex;not null"`
+	UserUUID    string    `gorm:"type:varchar(36);index;column:user_uuid"` // empty for discoverable (passwordless) login
+	AMR         string    `gorm:"type:varchar(100);column:amr"`            // factors already passed before this ceremony (MFA step)
+	Ceremony    string    `gorm:"type:varchar(20);not null"`
+	SessionData string    `gorm:"type:text;not null;column:session_data"` // JSON encoded webauthn.SessionData
+	ExpiresAt   time.Time `gorm:"index;not null;column:expires_at"`
+	CreatedAt   time.Time
+}
+
+func (WebAuthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
+
+func (c *WebAuthnChallenge) BeforeCreate(tx *gorm.DB) error {
+	if c.UUID == "" {
+		c.UUID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AccessPolicyRepository interface {
+	CreatePolicy(ctx context.Context, policy *model.AccessPolicy) error
+	GetPolicy(ctx context.Context, tenantUUID, policyUUID string) (*model.AccessPolicy, error)
+	ListPolicies(ctx context.Context, tenantUUID string) ([]model.AccessPolicy, error)
+	ListEnabledPolicies(ctx context.Context, tenantUUID string) ([]model.AccessPolicy, error)
+	UpdatePolicy(ctx context.Context, policy *model.AccessPolicy) error
+	DeletePolicy(ctx context.Context, tenantUUID, policyUUID string) error
+}
+
+type PostgresAccessPolicyRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresAccessPolicyRepo(db *gorm.DB) *PostgresAccessPolicyRepo {
+	return &PostgresAccessPolicyRepo{db: db}
+}
+
+func (r *PostgresAccessPolicyRepo) CreatePolicy(ctx context.Context, policy *model.AccessPolicy) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(policy)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("policy already exists")
+	}
+	return nil
+}
+
+func (r *PostgresAccessPolicyRepo) GetPolicy(ctx context.Context, tenantUUID, policyUUID string) (*model.AccessPolicy, error) {
+	policy := &model.AccessPolicy{}
+	err := r.db.WithContext(ctx).Where("tenant_uuid = ? AND uuid = ?", tenantUUID, policyUUID).First(policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("policy not found")
+		}
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+	return policy, nil
+}
+
+func (r *PostgresAccessPolicyRepo) ListPolicies(ctx context.Context, tenantUUID string) ([]model.AccessPolicy, error) {
+	var policies []model.AccessPolicy
+	err := r.db.WithContext(ctx).Where("tenant_uuid = ?", tenantUUID).Order("name").Find(&policies).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policies: %w", err)
+	}
+	return policies, nil
+}
+
+func (r *PostgresAccessPolicyRepo) ListEnabledPolicies(ctx context.Context, tenantUUID string) ([]model.AccessPolicy, error) {
+	var policies []model.AccessPolicy
+	err := r.db.WithContext(ctx).Where("tenant_uuid = ? AND enabled", tenantUUID).Order("name").Find(&policies).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policies: %w", err)
+	}
+	return policies, nil
+}
+
+func (r *PostgresAccessPolicyRepo) UpdatePolicy(ctx context.Context, policy *model.AccessPolicy) error {
+	result := r.db.WithContext(ctx).Model(policy).
+		Where("tenant_uuid = ?", policy.TenantUUID).
+		Select("description", "effect", "permissions", "condition", "enabled").
+		Updates(policy)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("policy not found")
+	}
+	return nil
+}
+
+func (r *PostgresAccessPolicyRepo) DeletePolicy(ctx context.Context, tenantUUID, policyUUID string) error {
+	result := r.db.WithContext(ctx).Where("tenant_uuid = ? AND uuid = ?", tenantUUID, policyUUID).Delete(&model.AccessPolicy{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("policy not found")
+	}
+	return nil
+}
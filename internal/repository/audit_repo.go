@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+// AuditFilter narrows ListEvents; empty fields match everything
+type AuditFilter struct {
+	Action      string
+	ActorUUID   string
+	SubjectUUID string
+	TenantUUID  string
+}
+
+type AuditRepository interface {
+	CreateEvent(ctx context.Context, event *model.AuditEvent) error
+	ListEvents(ctx context.Context, filter AuditFilter, limit int) ([]model.AuditEvent, error)
+}
+
+type PostgresAuditRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresAuditRepo(db *gorm.DB) *PostgresAuditRepo {
+	return &PostgresAuditRepo{db: db}
+}
+
+func (r *PostgresAuditRepo) CreateEvent(ctx context.Context, event *model.AuditEvent) error {
+	if err := r.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresAuditRepo) ListEvents(ctx context.Context, filter AuditFilter, limit int) ([]model.AuditEvent, error) {
+	query := r.db.WithContext(ctx)
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ActorUUID != "" {
+		query = query.Where("actor_uuid = ?", filter.ActorUUID)
+	}
+	if filter.SubjectUUID != "" {
+		query = query.Where("subject_uuid = ?", filter.SubjectUUID)
+	}
+	if filter.TenantUUID != "" {
+		query = query.Where("tenant_uuid = ?", filter.TenantUUID)
+	}
+
+	var events []model.AuditEvent
+	if err := query.Order("created_at DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, nil
+}
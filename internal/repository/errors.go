@@ -0,0 +1,21 @@
+package repository
+
+import "errors"
+
+// Errors callers act on. Match them with errors.Is; the messages are returned to API clients.
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionLimitReached = errors.New("session limit reached")
+	ErrTenantNotFound      = errors.New("tenant not found")
+	ErrMembershipNotFound  = errors.New("membership not found")
+	ErrRoleNotFound        = errors.New("role not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrServiceKeyNotFound  = errors.New("service key not found")
+	ErrDeviceCodeNotFound  = errors.New("device code not found")
+	ErrInvalidDeviceCode   = errors.New("invalid device code")
+	ErrDeviceCodeUsed      = errors.New("device code already used")
+	// ErrInvalidInitialAccessToken is returned for unknown, used up, revoked and
+	// expired initial access tokens alike
+	ErrInvalidInitialAccessToken  = errors.New("invalid initial access token")
+	ErrInitialAccessTokenNotFound = errors.New("initial access token not found")
+)
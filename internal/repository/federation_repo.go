@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type FederationRepository interface {
+	SaveState(ctx context.Context, state *model.FederatedLoginState) error
+	ConsumeState(ctx context.Context, stateHash string) (*model.FederatedLoginState, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*model.LinkedIdentity, error)
+	ListIdentities(ctx context.Context, userUUID string) ([]model.LinkedIdentity, error)
+	CreateIdentity(ctx context.Context, identity *model.LinkedIdentity) error
+	RecordIdentityLogin(ctx context.Context, identityID uint, email string, at time.Time) error
+	DeleteIdentity(ctx context.Context, userUUID, identityUUID string) error
+}
+
+type PostgresFederationRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresFederationRepo(db *gorm.DB) *PostgresFederationRepo {
+	return &PostgresFederationRepo{db: db}
+}
+
+func (r *PostgresFederationRepo) SaveState(ctx context.Context, state *model.FederatedLoginState) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Opportunistically clean up abandoned logins
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.FederatedLoginState{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired login states: %w", err)
+		}
+		if err := tx.Create(state).Error; err != nil {
+			return fmt.Errorf("failed to save login state: %w", err)
+		}
+		return nil
+	})
+}
+
+// ConsumeState loads and deletes a login state so that a provider response can only be used once
+func (r *PostgresFederationRepo) ConsumeState(ctx context.Context, stateHash string) (*model.FederatedLoginState, error) {
+	state := &model.FederatedLoginState{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ?", stateHash).First(state).Error; err != nil {
+			return err
+		}
+		return tx.Delete(state).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired login state")
+		}
+		return nil, fmt.Errorf("failed to consume login state: %w", err)
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, errors.New("invalid or expired login state")
+	}
+	return state, nil
+}
+
+func (r *PostgresFederationRepo) GetIdentity(ctx context.Context, provider, subject string) (*model.LinkedIdentity, error) {
+	identity := &model.LinkedIdentity{}
+	if err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("linked identity not found")
+		}
+		return nil, fmt.Errorf("failed to get linked identity: %w", err)
+	}
+	return identity, nil
+}
+
+func (r *PostgresFederationRepo) ListIdentities(ctx context.Context, userUUID string) ([]model.LinkedIdentity, error) {
+	var identities []model.LinkedIdentity
+	if err := r.db.WithContext(ctx).Where("user_uuid = ?", userUUID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list linked identities: %w", err)
+	}
+	return identities, nil
+}
+
+func (r *PostgresFederationRepo) CreateIdentity(ctx context.Context, identity *model.LinkedIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.LinkedIdentity{}).Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check linked identity: %w", err)
+		}
+		if count > 0 {
+			return errors.New("identity already linked")
+		}
+		if err := tx.Create(identity).Error; err != nil {
+			return fmt.Errorf("failed to create linked identity: %w", err)
+		}
+		return nil
+	})
+}
+
+// RecordIdentityLogin stores the time of a login and the email the provider reported with it
+func (r *PostgresFederationRepo) RecordIdentityLogin(ctx context.Context, identityID uint, email string, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&model.LinkedIdentity{}).
+		Where("id = ?", identityID).
+		Updates(map[string]interface{}{"email": email, "last_login_at": at}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record identity login: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresFederationRepo) DeleteIdentity(ctx context.Context, userUUID, identityUUID string) error {
+	result := r.db.WithContext(ctx).Where("uuid = ? AND user_uuid = ?", identityUUID, userUUID).Delete(&model.LinkedIdentity{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete linked identity: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("linked identity not found")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type InitialAccessTokenRepository interface {
+	CreateToken(ctx context.Context, token *model.OAuthInitialAccessToken) error
+	GetActiveToken(ctx context.Context, tokenHash string) (*model.OAuthInitialAccessToken, error)
+	ListTokens(ctx context.Context) ([]model.OAuthInitialAccessToken, error)
+	RevokeToken(ctx context.Context, tokenUUID string) error
+	// RegisterClient counts one registration against the token and creates the
+	// client in the same transaction
+	RegisterClient(ctx context.Context, id uint, client *model.OAuthClient) error
+}
+
+type PostgresInitialAccessTokenRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresInitialAccessTokenRepo(db *gorm.DB) *PostgresInitialAccessTokenRepo {
+	return &PostgresInitialAccessTokenRepo{db: db}
+}
+
+func (r *PostgresInitialAccessTokenRepo) CreateToken(ctx context.Context, token *model.OAuthInitialAccessToken) error {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("failed to create initial access token: %w", err)
+	}
+	return nil
+}
+
+// GetActiveToken returns an unrevoked, unexpired token that has uses left
+func (r *PostgresInitialAccessTokenRepo) GetActiveToken(ctx context.Context, tokenHash string) (*model.OAuthInitialAccessToken, error) {
+	token := &model.OAuthInitialAccessToken{}
+	err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		Where("max_uses = 0 OR use_count < max_uses").
+		First(token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInitialAccessToken
+		}
+		return nil, fmt.Errorf("failed to get initial access token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *PostgresInitialAccessTokenRepo) ListTokens(ctx context.Context) ([]model.OAuthInitialAccessToken, error) {
+	var tokens []model.OAuthInitialAccessToken
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list initial access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (r *PostgresInitialAccessTokenRepo) RevokeToken(ctx context.Context, tokenUUID string) error {
+	result := r.db.WithContext(ctx).Model(&model.OAuthInitialAccessToken{}).
+		Where("uuid = ? AND revoked_at IS NULL", tokenUUID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke initial access token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInitialAccessTokenNotFound
+	}
+	return nil
+}
+
+// RegisterClient fails when the token has been used up, revoked or has expired
+// in the meantime. A use is only counted when the client is created.
+func (r *PostgresInitialAccessTokenRepo) RegisterClient(ctx context.Context, id uint, client *model.OAuthClient) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.OAuthInitialAccessToken{}).
+			Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+			Where("max_uses = 0 OR use_count < max_uses").
+			Update("use_count", gorm.Expr("use_count + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("failed to update initial access token: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidInitialAccessToken
+		}
+
+		if err := tx.Create(client).Error; err != nil {
+			return fmt.Errorf("failed to create oauth client: %w", err)
+		}
+		return nil
+	})
+}
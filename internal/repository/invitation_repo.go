@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type InvitationRepository interface {
+	// CreateInvitation stores an invitation, revoking earlier pending invitations
+	// of the same email to the same tenant
+	CreateInvitation(ctx context.Context, invitation *model.TenantInvitation) error
+	GetPendingByTokenHash(ctx context.Context, tokenHash string, now time.Time) (*model.TenantInvitation, error)
+	ListPendingInvitations(ctx context.Context, tenantUUID string, now time.Time) ([]model.TenantInvitation, error)
+	RevokeInvitation(ctx context.Context, tenantUUID, invitationUUID string) error
+	// AcceptForUser consumes the invitation and adds an existing user to its tenant
+	AcceptForUser(ctx context.Context, tokenHash, userUUID string) (*model.TenantInvitation, error)
+	// AcceptWithNewUser consumes the invitation and creates the user as a member of its tenant
+	AcceptWithNewUser(ctx context.Context, tokenHash string, user *model.User) (*model.TenantInvitation, error)
+}
+
+type PostgresInvitationRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresInvitationRepo(db *gorm.DB) *PostgresInvitationRepo {
+	return &PostgresInvitationRepo{db: db}
+}
+
+// pendingScope matches invitations that can still be accepted
+func pendingScope(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+}
+
+func (r *PostgresInvitationRepo) CreateInvitation(ctx context.Context, invitation *model.TenantInvitation) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := pendingScope(tx.Model(&model.TenantInvitation{}), now).
+			Where("tenant_uuid = ? AND email = ?", invitation.TenantUUID, invitation.Email).
+			Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke previous invitations: %w", err)
+		}
+		if err := tx.Create(invitation).Error; err != nil {
+			return fmt.Errorf("failed to create invitation: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresInvitationRepo) GetPendingByTokenHash(ctx context.Context, tokenHash string, now time.Time) (*model.TenantInvitation, error) {
+	invitation := &model.TenantInvitation{}
+	err := pendingScope(r.db.WithContext(ctx), now).Where("token_hash = ?", tokenHash).First(invitation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired invitation")
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+func (r *PostgresInvitationRepo) ListPendingInvitations(ctx context.Context, tenantUUID string, now time.Time) ([]model.TenantInvitation, error) {
+	var invitations []model.TenantInvitation
+	err := pendingScope(r.db.WithContext(ctx), now).
+		Where("tenant_uuid = ?", tenantUUID).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+func (r *PostgresInvitationRepo) RevokeInvitation(ctx context.Context, tenantUUID, invitationUUID string) error {
+	result := pendingScope(r.db.WithContext(ctx).Model(&model.TenantInvitation{}), time.Now()).
+		Where("tenant_uuid = ? AND uuid = ?", tenantUUID, invitationUUID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("invitation not found")
+	}
+	return nil
+}
+
+func (r *PostgresInvitationRepo) AcceptForUser(ctx context.Context, tokenHash, userUUID string) (*model.TenantInvitation, error) {
+	var invitation *model.TenantInvitation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		invitation, err = consumeInvitation(tx, tokenHash, userUUID)
+		if err != nil {
+			return err
+		}
+		return createMembership(tx, &model.TenantMembership{
+			UserUUID:   userUUID,
+			TenantUUID: invitation.TenantUUID,
+			Role:       invitation.Role,
+			Status:     model.MembershipStatusActive,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (r *PostgresInvitationRepo) AcceptWithNewUser(ctx context.Context, tokenHash string, user *model.User) (*model.TenantInvitation, error) {
+	var invitation *model.TenantInvitation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		invitation, err = consumeInvitation(tx, tokenHash, "")
+		if err != nil {
+			return err
+		}
+		user.TenantID = invitation.TenantUUID
+		user.Role = invitation.Role
+		if err := createUser(tx, user); err != nil {
+			return err
+		}
+		return tx.Model(&model.TenantInvitation{}).Where("id = ?", invitation.ID).
+			Update("accepted_by", user.UUID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// consumeInvitation marks a pending invitation as accepted. The conditional
+// update guarantees an invitation can only be used once.
+func consumeInvitation(tx *gorm.DB, tokenHash, userUUID string) (*model.TenantInvitation, error) {
+	now := time.Now()
+	updates := map[string]interface{}{"accepted_at": now}
+	if userUUID != "" {
+		updates["accepted_by"] = userUUID
+	}
+	result := pendingScope(tx.Model(&model.TenantInvitation{}), now).
+		Where("token_hash = ?", tokenHash).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("invalid or expired invitation")
+	}
+
+	invitation := &model.TenantInvitation{}
+	if err := tx.Where("token_hash = ?", tokenHash).First(invitation).Error; err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return invitation, nil
+}
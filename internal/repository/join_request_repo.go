@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type JoinRequestRepository interface {
+	// CreateJoinRequest stores a request unless the user already has a pending one for the tenant
+	CreateJoinRequest(ctx context.Context, request *model.TenantJoinRequest) error
+	ListPendingJoinRequests(ctx context.Context, tenantUUID string) ([]model.TenantJoinRequest, error)
+	// DecideJoinRequest approves (adding the user as a member with role) or rejects a pending request
+	DecideJoinRequest(ctx context.Context, tenantUUID, requestUUID, deciderUUID string, approve bool, role string) (*model.TenantJoinRequest, error)
+}
+
+type PostgresJoinRequestRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresJoinRequestRepo(db *gorm.DB) *PostgresJoinRequestRepo {
+	return &PostgresJoinRequestRepo{db: db}
+}
+
+func (r *PostgresJoinRequestRepo) CreateJoinRequest(ctx context.Context, request *model.TenantJoinRequest) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.TenantJoinRequest{}).
+			Where("tenant_uuid = ? AND user_uuid = ? AND status = ?", request.TenantUUID, request.UserUUID, model.JoinRequestPending).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check join requests: %w", err)
+		}
+		if count > 0 {
+			return errors.New("join request already pending")
+		}
+		if err := tx.Create(request).Error; err != nil {
+			return fmt.Errorf("failed to create join request: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresJoinRequestRepo) ListPendingJoinRequests(ctx context.Context, tenantUUID string) ([]model.TenantJoinRequest, error) {
+	var requests []model.TenantJoinRequest
+	err := r.db.WithContext(ctx).
+		Where("tenant_uuid = ? AND status = ?", tenantUUID, model.JoinRequestPending).
+		Order("created_at").
+		Find(&requests).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list join requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (r *PostgresJoinRequestRepo) DecideJoinRequest(ctx context.Context, tenantUUID, requestUUID, deciderUUID string, approve bool, role string) (*model.TenantJoinRequest, error) {
+	status := model.JoinRequestRejected
+	if approve {
+		status = model.JoinRequestApproved
+	}
+
+	request := &model.TenantJoinRequest{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.TenantJoinRequest{}).
+			Where("tenant_uuid = ? AND uuid = ? AND status = ?", tenantUUID, requestUUID, model.JoinRequestPending).
+			Updates(map[string]interface{}{
+				"status":     status,
+				"decided_by": deciderUUID,
+				"decided_at": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update join request: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("join request not found")
+		}
+		if err := tx.Where("uuid = ?", requestUUID).First(request).Error; err != nil {
+			return fmt.Errorf("failed to get join request: %w", err)
+		}
+
+		if !approve {
+			return nil
+		}
+		return createMembership(tx, &model.TenantMembership{
+			UserUUID:   request.UserUUID,
+			TenantUUID: request.TenantUUID,
+			Role:       role,
+			Status:     model.MembershipStatusActive,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
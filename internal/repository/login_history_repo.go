@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginHistoryRepository interface {
+	CreateEvent(ctx context.Context, event *model.LoginEvent) error
+	ListEvents(ctx context.Context, userUUID string, limit int) ([]model.LoginEvent, error)
+	// TouchDevice records a successful sign-in from a device. isNew is true the first
+	// time the fingerprint is seen; hadDevices reports whether the user had any known device before.
+	TouchDevice(ctx context.Context, userUUID, fingerprint, userAgent string, seenAt time.Time) (isNew bool, hadDevices bool, err error)
+}
+
+type PostgresLoginHistoryRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresLoginHistoryRepo(db *gorm.DB) *PostgresLoginHistoryRepo {
+	return &PostgresLoginHistoryRepo{db: db}
+}
+
+func (r *PostgresLoginHistoryRepo) CreateEvent(ctx context.Context, event *model.LoginEvent) error {
+	if err := r.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record login event: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresLoginHistoryRepo) ListEvents(ctx context.Context, userUUID string, limit int) ([]model.LoginEvent, error) {
+	var events []model.LoginEvent
+	err := r.db.WithContext(ctx).Where("user_uuid = ?", userUUID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *PostgresLoginHistoryRepo) TouchDevice(ctx context.Context, userUUID, fingerprint, userAgent string, seenAt time.Time) (bool, bool, error) {
+	var isNew, hadDevices bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.KnownDevice{}).Where("user_uuid = ?", userUUID).Count(&count).Error; err != nil {
+			return err
+		}
+		hadDevices = count > 0
+
+		device := &model.KnownDevice{
+			UserUUID:    userUUID,
+			Fingerprint: fingerprint,
+			UserAgent:   userAgent,
+			FirstSeenAt: seenAt,
+			LastSeenAt:  seenAt,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(device)
+		if result.Error != nil {
+			return result.Error
+		}
+		isNew = result.RowsAffected > 0
+		if isNew {
+			return nil
+		}
+
+		return tx.Model(&model.KnownDevice{}).
+			Where("user_uuid = ? AND fingerprint = ?", userUUID, fingerprint).
+			Update("last_seen_at", seenAt).Error
+	})
+	if err != nil {
+		return false, false, fmt.Errorf("failed to record device: %w", err)
+	}
+	return isNew, hadDevices, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type MagicLinkRepository interface {
+	CreateToken(ctx context.Context, token *model.MagicLinkToken) error
+	ConsumeToken(ctx context.Context, tokenHash string) (*model.MagicLinkToken, error)
+}
+
+type PostgresMagicLinkRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresMagicLinkRepo(db *gorm.DB) *PostgresMagicLinkRepo {
+	return &PostgresMagicLinkRepo{db: db}
+}
+
+func (r *PostgresMagicLinkRepo) CreateToken(ctx context.Context, token *model.MagicLinkToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Only the most recent link for a user stays valid
+		if err := tx.Where("user_uuid = ? AND used_at IS NULL", token.UserUUID).Delete(&model.MagicLinkToken{}).Error; err != nil {
+			return fmt.Errorf("failed to revoke previous magic links: %w", err)
+		}
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.MagicLinkToken{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired magic links: %w", err)
+		}
+		if err := tx.Create(token).Error; err != nil {
+			return fmt.Errorf("failed to create magic link: %w", err)
+		}
+		return nil
+	})
+}
+
+// ConsumeToken marks an unused, unexpired token as used and returns it.
+// The conditional update guarantees a link can only be exchanged once.
+func (r *PostgresMagicLinkRepo) ConsumeToken(ctx context.Context, tokenHash string) (*model.MagicLinkToken, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&model.MagicLinkToken{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume magic link: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("invalid or expired magic link")
+	}
+
+	token := &model.MagicLinkToken{}
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to get magic link: %w", err)
+	}
+	return token, nil
+}
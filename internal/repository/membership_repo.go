@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MembershipRepository interface {
+	CreateMembership(ctx context.Context, membership *model.TenantMembership) error
+	GetMembership(ctx context.Context, userUUID, tenantUUID string) (*model.TenantMembership, error)
+	ListUserMemberships(ctx context.Context, userUUID string) ([]model.TenantMembership, error)
+	ListTenantMembers(ctx context.Context, tenantUUID string) ([]model.TenantMembership, error)
+	UpdateMembershipRole(ctx context.Context, userUUID, tenantUUID, role string) error
+	UpdateMembershipAttributes(ctx context.Context, userUUID, tenantUUID string, attributes map[string]string) error
+	// BackfillFromUsers creates the membership implied by users.tenant_id/role where missing
+	BackfillFromUsers(ctx context.Context) (int64, error)
+}
+
+type PostgresMembershipRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresMembershipRepo(db *gorm.DB) *PostgresMembershipRepo {
+	return &PostgresMembershipRepo{db: db}
+}
+
+func (r *PostgresMembershipRepo) CreateMembership(ctx context.Context, membership *model.TenantMembership) error {
+	return createMembership(r.db.WithContext(ctx), membership)
+}
+
+// createMembership inserts a membership, failing when the user already belongs to the tenant
+func createMembership(tx *gorm.DB, membership *model.TenantMembership) error {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(membership)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create membership: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("membership already exists")
+	}
+	return nil
+}
+
+func (r *PostgresMembershipRepo) GetMembership(ctx context.Context, userUUID, tenantUUID string) (*model.TenantMembership, error) {
+	membership := &model.TenantMembership{}
+	err := r.db.WithContext(ctx).
+		Where("user_uuid = ? AND tenant_uuid = ?", userUUID, tenantUUID).
+		First(membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMembershipNotFound
+		}
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+	return membership, nil
+}
+
+func (r *PostgresMembershipRepo) ListUserMemberships(ctx context.Context, userUUID string) ([]model.TenantMembership, error) {
+	var memberships []model.TenantMembership
+	err := r.db.WithContext(ctx).Where("user_uuid = ?", userUUID).Order("created_at").Find(&memberships).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+func (r *PostgresMembershipRepo) ListTenantMembers(ctx context.Context, tenantUUID string) ([]model.TenantMembership, error) {
+	var memberships []model.TenantMembership
+	err := r.db.WithContext(ctx).Where("tenant_uuid = ?", tenantUUID).Order("created_at").Find(&memberships).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	return memberships, nil
+}
+
+func (r *PostgresMembershipRepo) UpdateMembershipRole(ctx context.Context, userUUID, tenantUUID, role string) error {
+	result := r.db.WithContext(ctx).Model(&model.TenantMembership{}).
+		Where("user_uuid = ? AND tenant_uuid = ?", userUUID, tenantUUID).
+		Update("role", role)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update membership: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMembershipNotFound
+	}
+	return nil
+}
+
+func (r *PostgresMembershipRepo) UpdateMembershipAttributes(ctx context.Context, userUUID, tenantUUID string, attributes map[string]string) error {
+	result := r.db.WithContext(ctx).Model(&model.TenantMembership{}).
+		Where("user_uuid = ? AND tenant_uuid = ?", userUUID, tenantUUID).
+		Select("attributes").
+		Updates(&model.TenantMembership{Attributes: attributes})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update membership: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMembershipNotFound
+	}
+	return nil
+}
+
+func (r *PostgresMembershipRepo) BackfillFromUsers(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Exec(`
+		INSERT INTO tenant_memberships (user_uuid, tenant_uuid, role, status, created_at, updated_at)
+		SELECT u.uuid, t.uuid, COALESCE(NULLIF(u.role, ''), 'user'), ?, NOW(), NOW()
+		FROM users u
+		JOIN tenants t ON t.uuid::text = u.tenant_id
+		ON CONFLICT (user_uuid, tenant_uuid) DO NOTHING`, model.MembershipStatusActive)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to backfill memberships: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OAuthClientRepository interface {
+	CreateClient(ctx context.Context, client *model.OAuthClient) error
+	GetClient(ctx context.Context, clientID string) (*model.OAuthClient, error)
+	ListClients(ctx context.Context) ([]model.OAuthClient, error)
+	UpdateClient(ctx context.Context, client *model.OAuthClient) error
+	DeleteClient(ctx context.Context, clientID string) error
+	GetConsent(ctx context.Context, userUUID, tenantUUID, clientID string) (*model.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent *model.OAuthConsent) error
+}
+
+type PostgresOAuthClientRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresOAuthClientRepo(db *gorm.DB) *PostgresOAuthClientRepo {
+	return &PostgresOAuthClientRepo{db: db}
+}
+
+func (r *PostgresOAuthClientRepo) CreateClient(ctx context.Context, client *model.OAuthClient) error {
+	if err := r.db.WithContext(ctx).Create(client).Error; err != nil {
+		return fmt.Errorf("failed to create oauth client: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresOAuthClientRepo) GetClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	client := &model.OAuthClient{}
+	err := r.db.WithContext(ctx).Where("client_id = ?", clientID).First(client).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("oauth client not found")
+		}
+		return nil, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+	return client, nil
+}
+
+func (r *PostgresOAuthClientRepo) ListClients(ctx context.Context) ([]model.OAuthClient, error) {
+	var clients []model.OAuthClient
+	if err := r.db.WithContext(ctx).Order("created_at").Find(&clients).Error; err != nil {
+		return nil, fmt.Errorf("failed to list oauth clients: %w", err)
+	}
+	return clients, nil
+}
+
+func (r *PostgresOAuthClientRepo) UpdateClient(ctx context.Context, client *model.OAuthClient) error {
+	result := r.db.WithContext(ctx).Model(client).
+		Select("secret_hash", "name", "redirect_uris", "post_logout_redirect_uris", "scopes", "grant_types", "logo_uri", "first_party", "disabled_at").
+		Updates(client)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update oauth client: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("oauth client not found")
+	}
+	return nil
+}
+
+// DeleteClient removes a client with its consents and refresh tokens
+func (r *PostgresOAuthClientRepo) DeleteClient(ctx context.Context, clientID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("client_id = ?", clientID).Delete(&model.OAuthClient{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete oauth client: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("oauth client not found")
+		}
+		if err := tx.Where("client_id = ?", clientID).Delete(&model.OAuthConsent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete consents: %w", err)
+		}
+		if err := tx.Where("client_id = ?", clientID).Delete(&model.OAuthRefreshToken{}).Error; err != nil {
+			return fmt.Errorf("failed to delete refresh tokens: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresOAuthClientRepo) GetConsent(ctx context.Context, userUUID, tenantUUID, clientID string) (*model.OAuthConsent, error) {
+	consent := &model.OAuthConsent{}
+	err := r.db.WithContext(ctx).
+		Where("user_uuid = ? AND tenant_uuid = ? AND client_id = ?", userUUID, tenantUUID, clientID).
+		First(consent).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("consent not found")
+		}
+		return nil, fmt.Errorf("failed to get consent: %w", err)
+	}
+	return consent, nil
+}
+
+// SaveConsent creates the consent or replaces the scopes of an existing one
+func (r *PostgresOAuthClientRepo) SaveConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_uuid"}, {Name: "tenant_uuid"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(consent).Error
+	if err != nil {
+		return fmt.Errorf("failed to save consent: %w", err)
+	}
+	return nil
+}
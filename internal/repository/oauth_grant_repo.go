@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type OAuthGrantRepository interface {
+	CreateAuthorizationRequest(ctx context.Context, request *model.OAuthAuthorizationRequest) error
+	GetAuthorizationRequest(ctx context.Context, requestUUID string) (*model.OAuthAuthorizationRequest, error)
+	CompleteAuthorizationRequest(ctx context.Context, requestUUID string) error
+	CreateCode(ctx context.Context, code *model.OAuthAuthorizationCode) error
+	ConsumeCode(ctx context.Context, codeHash string) (*model.OAuthAuthorizationCode, error)
+	SetCodeSession(ctx context.Context, codeHash, sessionUUID string) error
+	CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error
+	ConsumeRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error)
+	CreateDeviceCode(ctx context.Context, code *model.OAuthDeviceCode) error
+	GetDeviceCode(ctx context.Context, deviceCodeHash string) (*model.OAuthDeviceCode, error)
+	GetPendingDeviceCode(ctx context.Context, userCode string) (*model.OAuthDeviceCode, error)
+	DecideDeviceCode(ctx context.Context, code *model.OAuthDeviceCode) error
+	RecordDevicePoll(ctx context.Context, id uint, polledAt time.Time, interval int) error
+	ConsumeDeviceCode(ctx context.Context, id uint) error
+}
+
+type PostgresOAuthGrantRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresOAuthGrantRepo(db *gorm.DB) *PostgresOAuthGrantRepo {
+	return &PostgresOAuthGrantRepo{db: db}
+}
+
+func (r *PostgresOAuthGrantRepo) CreateAuthorizationRequest(ctx context.Context, request *model.OAuthAuthorizationRequest) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.OAuthAuthorizationRequest{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired authorization requests: %w", err)
+		}
+		if err := tx.Create(request).Error; err != nil {
+			return fmt.Errorf("failed to create authorization request: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetAuthorizationRequest returns a pending, unexpired authorization request
+func (r *PostgresOAuthGrantRepo) GetAuthorizationRequest(ctx context.Context, requestUUID string) (*model.OAuthAuthorizationRequest, error) {
+	request := &model.OAuthAuthorizationRequest{}
+	err := r.db.WithContext(ctx).
+		Where("uuid = ? AND completed_at IS NULL AND expires_at > ?", requestUUID, time.Now()).
+		First(request).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("authorization request not found")
+		}
+		return nil, fmt.Errorf("failed to get authorization request: %w", err)
+	}
+	return request, nil
+}
+
+// CompleteAuthorizationRequest marks a pending request as answered so it cannot be answered twice
+func (r *PostgresOAuthGrantRepo) CompleteAuthorizationRequest(ctx context.Context, requestUUID string) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&model.OAuthAuthorizationRequest{}).
+		Where("uuid = ? AND completed_at IS NULL AND expires_at > ?", requestUUID, now).
+		Update("completed_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to complete authorization request: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("authorization request not found")
+	}
+	return nil
+}
+
+func (r *PostgresOAuthGrantRepo) CreateCode(ctx context.Context, code *model.OAuthAuthorizationCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Used codes are kept until they expire so replays can be detected
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.OAuthAuthorizationCode{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired authorization codes: %w", err)
+		}
+		if err := tx.Create(code).Error; err != nil {
+			return fmt.Errorf("failed to create authorization code: %w", err)
+		}
+		return nil
+	})
+}
+
+// ConsumeCode marks an unused, unexpired code as used and returns it. A code
+// that was already used is returned together with an "authorization code
+// already used" error so the caller can revoke what it was exchanged for.
+func (r *PostgresOAuthGrantRepo) ConsumeCode(ctx context.Context, codeHash string) (*model.OAuthAuthorizationCode, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&model.OAuthAuthorizationCode{}).
+		Where("code_hash = ? AND used_at IS NULL AND expires_at > ?", codeHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume authorization code: %w", result.Error)
+	}
+
+	code := &model.OAuthAuthorizationCode{}
+	if err := r.db.WithContext(ctx).Where("code_hash = ?", codeHash).First(code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired authorization code")
+		}
+		return nil, fmt.Errorf("failed to get authorization code: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		if code.UsedAt != nil {
+			return code, errors.New("authorization code already used")
+		}
+		return nil, errors.New("invalid or expired authorization code")
+	}
+	return code, nil
+}
+
+// SetCodeSession records the session a code was exchanged for
+func (r *PostgresOAuthGrantRepo) SetCodeSession(ctx context.Context, codeHash, sessionUUID string) error {
+	err := r.db.WithContext(ctx).Model(&model.OAuthAuthorizationCode{}).
+		Where("code_hash = ?", codeHash).
+		Update("session_uuid", sessionUUID).Error
+	if err != nil {
+		return fmt.Errorf("failed to update authorization code: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresOAuthGrantRepo) CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.OAuthRefreshToken{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired refresh tokens: %w", err)
+		}
+		if err := tx.Create(token).Error; err != nil {
+			return fmt.Errorf("failed to create refresh token: %w", err)
+		}
+		return nil
+	})
+}
+
+// ConsumeRefreshToken marks an unused, unexpired refresh token as used and
+// returns it. Like ConsumeCode, a replayed token is returned with a "refresh
+// token already used" error.
+func (r *PostgresOAuthGrantRepo) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&model.OAuthRefreshToken{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume refresh token: %w", result.Error)
+	}
+
+	token := &model.OAuthRefreshToken{}
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired refresh token")
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		if token.UsedAt != nil {
+			return token, errors.New("refresh token already used")
+		}
+		return nil, errors.New("invalid or expired refresh token")
+	}
+	return token, nil
+}
+
+func (r *PostgresOAuthGrantRepo) CreateDeviceCode(ctx context.Context, code *model.OAuthDeviceCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Expired codes are purged first so their user codes can be reused
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.OAuthDeviceCode{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired device codes: %w", err)
+		}
+		if err := tx.Create(code).Error; err != nil {
+			return fmt.Errorf("failed to create device code: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetDeviceCode returns the device code with the given hash in any state
+func (r *PostgresOAuthGrantRepo) GetDeviceCode(ctx context.Context, deviceCodeHash string) (*model.OAuthDeviceCode, error) {
+	code := &model.OAuthDeviceCode{}
+	if err := r.db.WithContext(ctx).Where("device_code_hash = ?", deviceCodeHash).First(code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidDeviceCode
+		}
+		return nil, fmt.Errorf("failed to get device code: %w", err)
+	}
+	return code, nil
+}
+
+// GetPendingDeviceCode returns an unanswered, unexpired device code by its user code
+func (r *PostgresOAuthGrantRepo) GetPendingDeviceCode(ctx context.Context, userCode string) (*model.OAuthDeviceCode, error) {
+	code := &model.OAuthDeviceCode{}
+	err := r.db.WithContext(ctx).
+		Where("user_code = ? AND status = ? AND expires_at > ?", userCode, model.DeviceCodePending, time.Now()).
+		First(code).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceCodeNotFound
+		}
+		return nil, fmt.Errorf("failed to get device code: %w", err)
+	}
+	return code, nil
+}
+
+// DecideDeviceCode stores the user's answer to a pending device code. It fails
+// when the code was answered in the meantime.
+func (r *PostgresOAuthGrantRepo) DecideDeviceCode(ctx context.Context, code *model.OAuthDeviceCode) error {
+	result := r.db.WithContext(ctx).Model(&model.OAuthDeviceCode{}).
+		Where("id = ? AND status = ? AND expires_at > ?", code.ID, model.DeviceCodePending, time.Now()).
+		Updates(map[string]interface{}{
+			"status":      code.Status,
+			"user_uuid":   code.UserUUID,
+			"tenant_uuid": code.TenantUUID,
+			"amr":         code.AMR,
+			"auth_time":   code.AuthTime,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update device code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceCodeNotFound
+	}
+	return nil
+}
+
+// RecordDevicePoll stores when the device last polled and the interval it must now keep
+func (r *PostgresOAuthGrantRepo) RecordDevicePoll(ctx context.Context, id uint, polledAt time.Time, interval int) error {
+	err := r.db.WithContext(ctx).Model(&model.OAuthDeviceCode{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_polled_at": polledAt, "interval": interval}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update device code: %w", err)
+	}
+	return nil
+}
+
+// ConsumeDeviceCode marks an approved device code as exchanged so only one poll gets tokens
+func (r *PostgresOAuthGrantRepo) ConsumeDeviceCode(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&model.OAuthDeviceCode{}).
+		Where("id = ? AND status = ?", id, model.DeviceCodeApproved).
+		Update("status", model.DeviceCodeConsumed)
+	if result.Error != nil {
+		return fmt.Errorf("failed to consume device code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceCodeUsed
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type PhoneOTPRepository interface {
+	CreateOTP(ctx context.Context, otp *model.PhoneOTP) error
+	GetActiveOTP(ctx context.Context, userUUID, purpose string) (*model.PhoneOTP, error)
+	// ClaimAttempt counts a guess against the code, reporting false once maxAttempts were used
+	ClaimAttempt(ctx context.Context, id uint, maxAttempts int) (bool, error)
+	ConsumeOTP(ctx context.Context, id uint) error
+}
+
+type PostgresPhoneOTPRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresPhoneOTPRepo(db *gorm.DB) *PostgresPhoneOTPRepo {
+	return &PostgresPhoneOTPRepo{db: db}
+}
+
+func (r *PostgresPhoneOTPRepo) CreateOTP(ctx context.Context, otp *model.PhoneOTP) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A new code replaces any outstanding code for the same purpose
+		if err := tx.Where("user_uuid = ? AND purpose = ? AND consumed_at IS NULL", otp.UserUUID, otp.Purpose).Delete(&model.PhoneOTP{}).Error; err != nil {
+			return fmt.Errorf("failed to revoke previous codes: %w", err)
+		}
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.PhoneOTP{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired codes: %w", err)
+		}
+		if err := tx.Create(otp).Error; err != nil {
+			return fmt.Errorf("failed to create code: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresPhoneOTPRepo) GetActiveOTP(ctx context.Context, userUUID, purpose string) (*model.PhoneOTP, error) {
+	otp := &model.PhoneOTP{}
+	err := r.db.WithContext(ctx).
+		Where("user_uuid = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", userUUID, purpose, time.Now()).
+		Order("created_at DESC").
+		First(otp).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired code")
+		}
+		return nil, fmt.Errorf("failed to get code: %w", err)
+	}
+	return otp, nil
+}
+
+// ClaimAttempt increments the attempt counter only while it is below the limit.
+// The conditional update lets parallel guesses race without exceeding it.
+func (r *PostgresPhoneOTPRepo) ClaimAttempt(ctx context.Context, id uint, maxAttempts int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.PhoneOTP{}).
+		Where("id = ? AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record attempt: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ConsumeOTP marks a code as used; the conditional update makes it single-use
+func (r *PostgresPhoneOTPRepo) ConsumeOTP(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&model.PhoneOTP{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to consume code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("invalid or expired code")
+	}
+	return nil
+}
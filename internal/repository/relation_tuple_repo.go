@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// relationWriteLockKey serializes relation writes so revisions commit in order
+const relationWriteLockKey = 0x72656c74 // "relt"
+
+type RelationTupleRepository interface {
+	// WriteTuples deletes and then inserts tuples of a tenant in one transaction
+	// and returns the revision of the write. Writing an existing tuple is a no-op.
+	WriteTuples(ctx context.Context, tenantUUID string, writes, deletes []model.RelationTuple) (int64, error)
+	// CurrentRevision returns the revision of the last committed write
+	CurrentRevision(ctx context.Context) (int64, error)
+	ListTuples(ctx context.Context, tenantUUID, objectType, objectID, relation string) ([]model.RelationTuple, error)
+	// ListObjectIDs pages through the IDs of objects of a type that appear in any tuple
+	ListObjectIDs(ctx context.Context, tenantUUID, objectType, afterID string, limit int) ([]string, error)
+}
+
+type PostgresRelationTupleRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresRelationTupleRepo(db *gorm.DB) *PostgresRelationTupleRepo {
+	return &PostgresRelationTupleRepo{db: db}
+}
+
+func (r *PostgresRelationTupleRepo) WriteTuples(ctx context.Context, tenantUUID string, writes, deletes []model.RelationTuple) (int64, error) {
+	var revision model.RelationRevision
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", relationWriteLockKey).Error; err != nil {
+			return fmt.Errorf("failed to lock relation tuples: %w", err)
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return fmt.Errorf("failed to create relation revision: %w", err)
+		}
+
+		for _, tuple := range deletes {
+			err := tx.Where("tenant_uuid = ? AND object_type = ? AND object_id = ? AND relation = ? AND subject_type = ? AND subject_id = ? AND subject_relation = ?",
+				tenantUUID, tuple.ObjectType, tuple.ObjectID, tuple.Relation, tuple.SubjectType, tuple.SubjectID, tuple.SubjectRelation).
+				Delete(&model.RelationTuple{}).Error
+			if err != nil {
+				return fmt.Errorf("failed to delete relation tuple: %w", err)
+			}
+		}
+		for i := range writes {
+			writes[i].ID = 0
+			writes[i].TenantUUID = tenantUUID
+			writes[i].Revision = revision.ID
+		}
+		if len(writes) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&writes).Error; err != nil {
+				return fmt.Errorf("failed to write relation tuples: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revision.ID, nil
+}
+
+func (r *PostgresRelationTupleRepo) CurrentRevision(ctx context.Context) (int64, error) {
+	var revision int64
+	err := r.db.WithContext(ctx).Model(&model.RelationRevision{}).Select("COALESCE(MAX(id), 0)").Scan(&revision).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to read relation revision: %w", err)
+	}
+	return revision, nil
+}
+
+func (r *PostgresRelationTupleRepo) ListTuples(ctx context.Context, tenantUUID, objectType, objectID, relation string) ([]model.RelationTuple, error) {
+	var tuples []model.RelationTuple
+	err := r.db.WithContext(ctx).
+		Where("tenant_uuid = ? AND object_type = ? AND object_id = ? AND relation = ?", tenantUUID, objectType, objectID, relation).
+		Order("subject_type, subject_id, subject_relation").
+		Find(&tuples).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list relation tuples: %w", err)
+	}
+	return tuples, nil
+}
+
+func (r *PostgresRelationTupleRepo) ListObjectIDs(ctx context.Context, tenantUUID, objectType, afterID string, limit int) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).Model(&model.RelationTuple{}).
+		Distinct("object_id").
+		Where("tenant_uuid = ? AND object_type = ? AND object_id > ?", tenantUUID, objectType, afterID).
+		Order("object_id").
+		Limit(limit).
+		Pluck("object_id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list relation objects: %w", err)
+	}
+	return ids, nil
+}
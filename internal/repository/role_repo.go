@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepository interface {
+	CreateRole(ctx context.Context, role *model.Role) error
+	// EnsureRole creates the role unless one with the same tenant and name exists
+	EnsureRole(ctx context.Context, role *model.Role) error
+	GetRole(ctx context.Context, tenantUUID, roleUUID string) (*model.Role, error)
+	// FindRole resolves a role name inside a tenant, preferring the tenant's own role over a global one
+	FindRole(ctx context.Context, tenantUUID, name string) (*model.Role, error)
+	// ListRoles lists global roles plus the tenant's own roles (only global ones when tenantUUID is empty)
+	ListRoles(ctx context.Context, tenantUUID string) ([]model.Role, error)
+	UpdateRole(ctx context.Context, role *model.Role) error
+	DeleteRole(ctx context.Context, tenantUUID, roleUUID string) error
+}
+
+type PostgresRoleRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresRoleRepo(db *gorm.DB) *PostgresRoleRepo {
+	return &PostgresRoleRepo{db: db}
+}
+
+func (r *PostgresRoleRepo) CreateRole(ctx context.Context, role *model.Role) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(role)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create role: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("role already exists")
+	}
+	return nil
+}
+
+func (r *PostgresRoleRepo) EnsureRole(ctx context.Context, role *model.Role) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(role).Error
+	if err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRoleRepo) GetRole(ctx context.Context, tenantUUID, roleUUID string) (*model.Role, error) {
+	role := &model.Role{}
+	err := r.db.WithContext(ctx).Where("tenant_uuid = ? AND uuid = ?", tenantUUID, roleUUID).First(role).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+func (r *PostgresRoleRepo) FindRole(ctx context.Context, tenantUUID, name string) (*model.Role, error) {
+	role := &model.Role{}
+	// Tenant roles sort before global ones (tenant_uuid '')
+	err := r.db.WithContext(ctx).
+		Where("name = ? AND tenant_uuid IN ?", name, []string{tenantUUID, ""}).
+		Order("tenant_uuid DESC").
+		First(role).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+func (r *PostgresRoleRepo) ListRoles(ctx context.Context, tenantUUID string) ([]model.Role, error) {
+	var roles []model.Role
+	err := r.db.WithContext(ctx).
+		Where("tenant_uuid IN ?", []string{tenantUUID, ""}).
+		Order("tenant_uuid, name").
+		Find(&roles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *PostgresRoleRepo) UpdateRole(ctx context.Context, role *model.Role) error {
+	err := r.db.WithContext(ctx).Model(role).
+		Select("description", "permissions").
+		Updates(role).Error
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+// DeleteRole removes a custom role that no membership in its scope still uses
+func (r *PostgresRoleRepo) DeleteRole(ctx context.Context, tenantUUID, roleUUID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		role := &model.Role{}
+		err := tx.Where("tenant_uuid = ? AND uuid = ?", tenantUUID, roleUUID).First(role).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return fmt.Errorf("failed to get role: %w", err)
+		}
+		if role.BuiltIn {
+			return errors.New("built-in roles cannot be deleted")
+		}
+
+		inUse := tx.Model(&model.TenantMembership{}).Where("role = ?", role.Name)
+		if role.TenantUUID != "" {
+			inUse = inUse.Where("tenant_uuid = ?", role.TenantUUID)
+		}
+		var count int64
+		if err := inUse.Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check role usage: %w", err)
+		}
+		if count > 0 {
+			return errors.New("role is in use")
+		}
+
+		if err := tx.Delete(role).Error; err != nil {
+			return fmt.Errorf("failed to delete role: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type ServiceKeyRepository interface {
+	CreateKey(ctx context.Context, key *model.ServiceKey) error
+	GetActiveKey(ctx context.Context, keyHash string, now time.Time) (*model.ServiceKey, error)
+	// ListKeys lists the keys of a service, or of every service when serviceName is empty
+	ListKeys(ctx context.Context, serviceName string) ([]model.ServiceKey, error)
+	RevokeKey(ctx context.Context, keyUUID string) error
+	MarkUsed(ctx context.Context, keyID uint, usedAt time.Time) error
+}
+
+type PostgresServiceKeyRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresServiceKeyRepo(db *gorm.DB) *PostgresServiceKeyRepo {
+	return &PostgresServiceKeyRepo{db: db}
+}
+
+func (r *PostgresServiceKeyRepo) CreateKey(ctx context.Context, key *model.ServiceKey) error {
+	if err := r.db.WithContext(ctx).Create(key).Error; err != nil {
+		return fmt.Errorf("failed to create service key: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresServiceKeyRepo) GetActiveKey(ctx context.Context, keyHash string, now time.Time) (*model.ServiceKey, error) {
+	key := &model.ServiceKey{}
+	err := r.db.WithContext(ctx).
+		Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", keyHash, now).
+		First(key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServiceKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get service key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *PostgresServiceKeyRepo) ListKeys(ctx context.Context, serviceName string) ([]model.ServiceKey, error) {
+	query := r.db.WithContext(ctx).Order("service_name, created_at")
+	if serviceName != "" {
+		query = query.Where("service_name = ?", serviceName)
+	}
+	var keys []model.ServiceKey
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list service keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *PostgresServiceKeyRepo) RevokeKey(ctx context.Context, keyUUID string) error {
+	result := r.db.WithContext(ctx).Model(&model.ServiceKey{}).
+		Where("uuid = ? AND revoked_at IS NULL", keyUUID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke service key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrServiceKeyNotFound
+	}
+	return nil
+}
+
+// MarkUsed records when a key last authenticated a call. Writes are skipped
+// while the stored time is less than a minute old.
+func (r *PostgresServiceKeyRepo) MarkUsed(ctx context.Context, keyID uint, usedAt time.Time) error {
+	err := r.db.WithContext(ctx).Model(&model.ServiceKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", keyID, usedAt.Add(-time.Minute)).
+		Update("last_used_at", usedAt).Error
+	if err != nil {
+		return fmt.Errorf("failed to update service key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+// SessionPolicy holds the limits enforced by the session store. Zero values disable a limit.
+type SessionPolicy struct {
+	MaxActive        int           // concurrently active sessions per user
+	EvictOldest      bool          // revoke the oldest session at the limit instead of rejecting the new one
+	IdleTimeout      time.Duration // inactivity after which a session is no longer valid
+	AbsoluteLifetime time.Duration // lifetime of a session from login, regardless of activity
+}
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *model.Session) error
+	ValidateSession(ctx context.Context, sessionUUID string, now time.Time) (*model.Session, error)
+	ListActiveSessions(ctx context.Context, userUUID string) ([]model.Session, error)
+	RevokeSession(ctx context.Context, userUUID, sessionUUID string) error
+}
+
+type PostgresSessionRepo struct {
+	db     *gorm.DB
+	policy SessionPolicy
+}
+
+func NewPostgresSessionRepo(db *gorm.DB, policy SessionPolicy) *PostgresSessionRepo {
+	return &PostgresSessionRepo{db: db, policy: policy}
+}
+
+// defaultSessionLifetime applies when neither the caller nor the policy sets a lifetime
+const defaultSessionLifetime = 24 * time.Hour
+
+// CreateSession stores a new session, applying the absolute lifetime and the
+// per-user limit atomically so concurrent logins cannot exceed it. A caller-set
+// ExpiresAt (e.g. a stricter tenant policy) is kept when it is earlier.
+func (r *PostgresSessionRepo) CreateSession(ctx context.Context, session *model.Session) error {
+	now := time.Now()
+	if session.LastSeenAt.IsZero() {
+		session.LastSeenAt = now
+	}
+	session.ExpiresAt = r.policy.expiresAt(now, session.ExpiresAt)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if r.policy.MaxActive > 0 {
+			// Serialize logins of the same user while counting
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", session.UserUUID).Error; err != nil {
+				return fmt.Errorf("failed to lock sessions: %w", err)
+			}
+
+			var active []model.Session
+			if err := r.activeScope(tx, now).Where("user_uuid = ?", session.UserUUID).
+				Order("created_at").Find(&active).Error; err != nil {
+				return fmt.Errorf("failed to count sessions: %w", err)
+			}
+
+			evict, err := r.policy.evictions(active)
+			if err != nil {
+				return err
+			}
+			if len(evict) > 0 {
+				if err := tx.Model(&model.Session{}).Where("id IN ?", evict).Update("revoked_at", now).Error; err != nil {
+					return fmt.Errorf("failed to evict sessions: %w", err)
+				}
+			}
+		}
+
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		return nil
+	})
+}
+
+// ValidateSession returns the session if it is still active and records activity.
+// Activity writes are skipped if the session was seen in the last minute.
+func (r *PostgresSessionRepo) ValidateSession(ctx context.Context, sessionUUID string, now time.Time) (*model.Session, error) {
+	session := &model.Session{}
+	err := r.db.WithContext(ctx).Where("uuid = ?", sessionUUID).First(session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !r.policy.active(session, now) {
+		return nil, ErrSessionNotFound
+	}
+
+	if now.Sub(session.LastSeenAt) > time.Minute {
+		err := r.db.WithContext(ctx).Model(&model.Session{}).
+			Where("id = ?", session.ID).
+			Update("last_seen_at", now).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to update session: %w", err)
+		}
+		session.LastSeenAt = now
+	}
+	return session, nil
+}
+
+func (r *PostgresSessionRepo) ListActiveSessions(ctx context.Context, userUUID string) ([]model.Session, error) {
+	var sessions []model.Session
+	err := r.activeScope(r.db.WithContext(ctx), time.Now()).
+		Where("user_uuid = ?", userUUID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *PostgresSessionRepo) RevokeSession(ctx context.Context, userUUID, sessionUUID string) error {
+	result := r.db.WithContext(ctx).Model(&model.Session{}).
+		Where("uuid = ? AND user_uuid = ? AND revoked_at IS NULL", sessionUUID, userUUID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// expiresAt is when a session created at now ends. A requested end is kept when it
+// comes before the absolute lifetime.
+func (p SessionPolicy) expiresAt(now, requested time.Time) time.Time {
+	if p.AbsoluteLifetime > 0 {
+		if expiresAt := now.Add(p.AbsoluteLifetime); requested.IsZero() || expiresAt.Before(requested) {
+			return expiresAt
+		}
+		return requested
+	}
+	if requested.IsZero() {
+		return now.Add(defaultSessionLifetime)
+	}
+	return requested
+}
+
+// evictions returns the IDs of the sessions to revoke so that one more fits within
+// MaxActive, or ErrSessionLimitReached when the oldest may not be evicted. active
+// must be ordered oldest first.
+func (p SessionPolicy) evictions(active []model.Session) ([]uint, error) {
+	excess := len(active) - p.MaxActive + 1
+	if p.MaxActive <= 0 || excess <= 0 {
+		return nil, nil
+	}
+	if !p.EvictOldest {
+		return nil, ErrSessionLimitReached
+	}
+	ids := make([]uint, 0, excess)
+	for _, s := range active[:excess] {
+		ids = append(ids, s.ID)
+	}
+	return ids, nil
+}
+
+// active reports whether a session can still be used at now. It matches activeScope.
+func (p SessionPolicy) active(session *model.Session, now time.Time) bool {
+	if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return false
+	}
+	return p.IdleTimeout <= 0 || session.LastSeenAt.After(now.Add(-p.IdleTimeout))
+}
+
+// activeScope filters out revoked, expired and idle sessions
+func (r *PostgresSessionRepo) activeScope(tx *gorm.DB, now time.Time) *gorm.DB {
+	tx = tx.Where("revoked_at IS NULL AND expires_at > ?", now)
+	if r.policy.IdleTimeout > 0 {
+		tx = tx.Where("last_seen_at > ?", now.Add(-r.policy.IdleTimeout))
+	}
+	return tx
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func TestSessionPolicyEvictions(t *testing.T) {
+	active := []model.Session{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	tests := map[string]struct {
+		policy  SessionPolicy
+		active  []model.Session
+		evict   []uint
+		limited bool
+	}{
+		"no limit":                 {policy: SessionPolicy{}, active: active},
+		"below the limit":          {policy: SessionPolicy{MaxActive: 4}, active: active},
+		"at the limit":             {policy: SessionPolicy{MaxActive: 3}, active: active, limited: true},
+		"at the limit, evicting":   {policy: SessionPolicy{MaxActive: 3, EvictOldest: true}, active: active, evict: []uint{1}},
+		"over the limit, evicting": {policy: SessionPolicy{MaxActive: 2, EvictOldest: true}, active: active, evict: []uint{1, 2}},
+		"over the limit":           {policy: SessionPolicy{MaxActive: 1}, active: active, limited: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			evict, err := tt.policy.evictions(tt.active)
+			if tt.limited {
+				if !errors.Is(err, ErrSessionLimitReached) {
+					t.Fatalf("error = %v, want ErrSessionLimitReached", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evictions: %v", err)
+			}
+			if !reflect.DeepEqual(evict, tt.evict) {
+				t.Fatalf("evict = %v, want %v", evict, tt.evict)
+			}
+		})
+	}
+}
+
+func TestSessionPolicyActive(t *testing.T) {
+	now := time.Now()
+	revokedAt := now.Add(-time.Minute)
+
+	tests := map[string]struct {
+		policy  SessionPolicy
+		session model.Session
+		active  bool
+	}{
+		"fresh": {
+			session: model.Session{LastSeenAt: now, ExpiresAt: now.Add(time.Hour)},
+			active:  true,
+		},
+		"revoked": {
+			session: model.Session{LastSeenAt: now, ExpiresAt: now.Add(time.Hour), RevokedAt: &revokedAt},
+		},
+		"past its lifetime": {
+			session: model.Session{LastSeenAt: now, ExpiresAt: now.Add(-time.Second)},
+		},
+		"idle without an idle timeout": {
+			session: model.Session{LastSeenAt: now.Add(-12 * time.Hour), ExpiresAt: now.Add(time.Hour)},
+			active:  true,
+		},
+		"used within the idle timeout": {
+			policy:  SessionPolicy{IdleTimeout: 30 * time.Minute},
+			session: model.Session{LastSeenAt: now.Add(-29 * time.Minute), ExpiresAt: now.Add(time.Hour)},
+			active:  true,
+		},
+		"idle past the timeout": {
+			policy:  SessionPolicy{IdleTimeout: 30 * time.Minute},
+			session: model.Session{LastSeenAt: now.Add(-31 * time.Minute), ExpiresAt: now.Add(time.Hour)},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if active := tt.policy.active(&tt.session, now); active != tt.active {
+				t.Fatalf("active = %v, want %v", active, tt.active)
+			}
+		})
+	}
+}
+
+func TestSessionPolicyExpiresAt(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		policy    SessionPolicy
+		requested time.Time
+		expiresAt time.Time
+	}{
+		"default lifetime":              {expiresAt: now.Add(defaultSessionLifetime)},
+		"requested lifetime":            {requested: now.Add(time.Hour), expiresAt: now.Add(time.Hour)},
+		"absolute lifetime":             {policy: SessionPolicy{AbsoluteLifetime: 8 * time.Hour}, expiresAt: now.Add(8 * time.Hour)},
+		"absolute lifetime caps longer": {policy: SessionPolicy{AbsoluteLifetime: 8 * time.Hour}, requested: now.Add(48 * time.Hour), expiresAt: now.Add(8 * time.Hour)},
+		"shorter request is kept":       {policy: SessionPolicy{AbsoluteLifetime: 8 * time.Hour}, requested: now.Add(time.Hour), expiresAt: now.Add(time.Hour)},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if expiresAt := tt.policy.expiresAt(now, tt.requested); !expiresAt.Equal(tt.expiresAt) {
+				t.Fatalf("expiresAt = %v, want %v", expiresAt, tt.expiresAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type TenantDomainRepository interface {
+	CreateDomain(ctx context.Context, domain *model.TenantDomain) error
+	ListDomains(ctx context.Context, tenantUUID string) ([]model.TenantDomain, error)
+	GetDomain(ctx context.Context, tenantUUID, domainUUID string) (*model.TenantDomain, error)
+	// MarkVerified fails when another tenant already verified the same domain
+	MarkVerified(ctx context.Context, domain *model.TenantDomain, verifiedAt time.Time) error
+	UpdateJoinPolicy(ctx context.Context, tenantUUID, domainUUID, joinPolicy string) error
+	DeleteDomain(ctx context.Context, tenantUUID, domainUUID string) error
+	GetVerifiedDomain(ctx context.Context, domain string) (*model.TenantDomain, error)
+}
+
+type PostgresTenantDomainRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresTenantDomainRepo(db *gorm.DB) *PostgresTenantDomainRepo {
+	return &PostgresTenantDomainRepo{db: db}
+}
+
+func (r *PostgresTenantDomainRepo) CreateDomain(ctx context.Context, domain *model.TenantDomain) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.TenantDomain{}).
+			Where("tenant_uuid = ? AND domain = ?", domain.TenantUUID, domain.Domain).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check domain: %w", err)
+		}
+		if count > 0 {
+			return errors.New("domain already exists")
+		}
+		if err := tx.Create(domain).Error; err != nil {
+			return fmt.Errorf("failed to create domain: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresTenantDomainRepo) ListDomains(ctx context.Context, tenantUUID string) ([]model.TenantDomain, error) {
+	var domains []model.TenantDomain
+	err := r.db.WithContext(ctx).Where("tenant_uuid = ?", tenantUUID).Order("domain").Find(&domains).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	return domains, nil
+}
+
+func (r *PostgresTenantDomainRepo) GetDomain(ctx context.Context, tenantUUID, domainUUID string) (*model.TenantDomain, error) {
+	domain := &model.TenantDomain{}
+	err := r.db.WithContext(ctx).Where("tenant_uuid = ? AND uuid = ?", tenantUUID, domainUUID).First(domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("domain not found")
+		}
+		return nil, fmt.Errorf("failed to get domain: %w", err)
+	}
+	return domain, nil
+}
+
+func (r *PostgresTenantDomainRepo) MarkVerified(ctx context.Context, domain *model.TenantDomain, verifiedAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.TenantDomain{}).
+			Where("domain = ? AND verified_at IS NOT NULL AND id <> ?", domain.Domain, domain.ID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check domain: %w", err)
+		}
+		if count > 0 {
+			return errors.New("domain is verified by another tenant")
+		}
+		if err := tx.Model(&model.TenantDomain{}).Where("id = ?", domain.ID).
+			Update("verified_at", verifiedAt).Error; err != nil {
+			return fmt.Errorf("failed to verify domain: %w", err)
+		}
+		domain.VerifiedAt = &verifiedAt
+		return nil
+	})
+}
+
+func (r *PostgresTenantDomainRepo) UpdateJoinPolicy(ctx context.Context, tenantUUID, domainUUID, joinPolicy string) error {
+	result := r.db.WithContext(ctx).Model(&model.TenantDomain{}).
+		Where("tenant_uuid = ? AND uuid = ?", tenantUUID, domainUUID).
+		Update("join_policy", joinPolicy)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update domain: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("domain not found")
+	}
+	return nil
+}
+
+func (r *PostgresTenantDomainRepo) DeleteDomain(ctx context.Context, tenantUUID, domainUUID string) error {
+	result := r.db.WithContext(ctx).Where("tenant_uuid = ? AND uuid = ?", tenantUUID, domainUUID).Delete(&model.TenantDomain{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete domain: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("domain not found")
+	}
+	return nil
+}
+
+func (r *PostgresTenantDomainRepo) GetVerifiedDomain(ctx context.Context, domain string) (*model.TenantDomain, error) {
+	record := &model.TenantDomain{}
+	err := r.db.WithContext(ctx).Where("domain = ? AND verified_at IS NOT NULL", domain).First(record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("domain not found")
+		}
+		return nil, fmt.Errorf("failed to get domain: %w", err)
+	}
+	return record, nil
+}
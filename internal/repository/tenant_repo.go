@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type TenantRepository interface {
+	CreateTenant(ctx context.Context, tenant *model.Tenant) error
+	GetByUUID(ctx context.Context, tenantUUID string) (*model.Tenant, error)
+	GetBySlug(ctx context.Context, slug string) (*model.Tenant, error)
+	GetByUUIDs(ctx context.Context, tenantUUIDs []string) ([]model.Tenant, error)
+	SlugExists(ctx context.Context, slug string) (bool, error)
+	ListTenants(ctx context.Context, limit, offset int) ([]model.Tenant, error)
+	UpdateTenant(ctx context.Context, tenant *model.Tenant) error
+	CountUsersWithoutTenant(ctx context.Context) (int64, error)
+	// AdoptUsersWithoutTenant moves users with an empty tenant_id into the given tenant
+	AdoptUsersWithoutTenant(ctx context.Context, tenantUUID string) (int64, error)
+}
+
+type PostgresTenantRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresTenantRepo(db *gorm.DB) *PostgresTenantRepo {
+	return &PostgresTenantRepo{db: db}
+}
+
+func (r *PostgresTenantRepo) CreateTenant(ctx context.Context, tenant *model.Tenant) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createTenant(tx, tenant)
+	})
+}
+
+// createTenant inserts a tenant inside an existing transaction
+func createTenant(tx *gorm.DB, tenant *model.Tenant) error {
+	var count int64
+	if err := tx.Model(&model.Tenant{}).Where("slug = ?", tenant.Slug).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check slug: %w", err)
+	}
+	if count > 0 {
+		return errors.New("tenant slug already exists")
+	}
+
+	if err := tx.Create(tenant).Error; err != nil {
+		return fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresTenantRepo) GetByUUID(ctx context.Context, tenantUUID string) (*model.Tenant, error) {
+	tenant := &model.Tenant{}
+	err := r.db.WithContext(ctx).Where("uuid = ?", tenantUUID).First(tenant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return tenant, nil
+}
+
+func (r *PostgresTenantRepo) GetBySlug(ctx context.Context, slug string) (*model.Tenant, error) {
+	tenant := &model.Tenant{}
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(tenant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return tenant, nil
+}
+
+func (r *PostgresTenantRepo) GetByUUIDs(ctx context.Context, tenantUUIDs []string) ([]model.Tenant, error) {
+	var tenants []model.Tenant
+	if len(tenantUUIDs) == 0 {
+		return tenants, nil
+	}
+	if err := r.db.WithContext(ctx).Where("uuid IN ?", tenantUUIDs).Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+func (r *PostgresTenantRepo) SlugExists(ctx context.Context, slug string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.Tenant{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *PostgresTenantRepo) ListTenants(ctx context.Context, limit, offset int) ([]model.Tenant, error) {
+	var tenants []model.Tenant
+	err := r.db.WithContext(ctx).Order("created_at").Limit(limit).Offset(offset).Find(&tenants).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+func (r *PostgresTenantRepo) UpdateTenant(ctx context.Context, tenant *model.Tenant) error {
+	err := r.db.WithContext(ctx).Model(&model.Tenant{}).
+		Where("id = ?", tenant.ID).
+		Updates(map[string]interface{}{
+			"name":     tenant.Name,
+			"status":   tenant.Status,
+			"settings": tenant.Settings,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update tenant: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresTenantRepo) CountUsersWithoutTenant(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("tenant_id = '' OR tenant_id IS NULL").
+		Count(&count).Error
+	return count, err
+}
+
+func (r *PostgresTenantRepo) AdoptUsersWithoutTenant(ctx context.Context, tenantUUID string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("tenant_id = '' OR tenant_id IS NULL").
+		Update("tenant_id", tenantUUID)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to assign users to tenant: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 	"github.com/johnroshan2255/auth-service/internal/model"
@@ -16,6 +17,10 @@ type UserRepository interface {
 	EmailExists(ctx context.Context, email string) (bool, error)
 	UsernameExists(ctx context.Context, username string) (bool, error)
 	CreateUser(ctx context.Context, user *model.User) error
+	CreateUserWithTenant(ctx context.Context, user *model.User, tenant *model.Tenant) error
+	GetByVerifiedPhone(ctx context.Context, phoneNumber string) (*model.User, error)
+	MarkPhoneVerified(ctx context.Context, userUUID, phoneNumber string, verifiedAt time.Time) error
+	MarkEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error
 }
 
 type PostgresUserRepo struct {
@@ -31,7 +36,7 @@ func (r *PostgresUserRepo) GetByID(ctx context.Context, userUUID string) (*model
 	err := r.db.WithContext(ctx).Where("uuid = ?", userUUID).First(user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -43,7 +48,7 @@ func (r *PostgresUserRepo) GetByEmail(ctx context.Context, email string) (*model
 	err := r.db.WithContext(ctx).Where("email = ?", email).First(user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -55,7 +60,7 @@ func (r *PostgresUserRepo) GetByUsername(ctx context.Context, username string) (
 	err := r.db.WithContext(ctx).Where("username = ?", username).First(user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -76,29 +81,116 @@ func (r *PostgresUserRepo) UsernameExists(ctx context.Context, username string)
 
 func (r *PostgresUserRepo) CreateUser(ctx context.Context, user *model.User) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Check if email already exists
-		var emailCount int64
-		if err := tx.Model(&model.User{}).Where("email = ?", user.Email).Count(&emailCount).Error; err != nil {
-			return fmt.Errorf("failed to check email: %w", err)
-		}
-		if emailCount > 0 {
-			return errors.New("email already exists")
+		return createUser(tx, user)
+	})
+}
+
+// CreateUserWithTenant creates a new tenant and its first user atomically
+func (r *PostgresUserRepo) CreateUserWithTenant(ctx context.Context, user *model.User, tenant *model.Tenant) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createTenant(tx, tenant); err != nil {
+			return err
 		}
+		user.TenantID = tenant.UUID
+		return createUser(tx, user)
+	})
+}
 
-		// Check if username already exists
-		var usernameCount int64
-		if err := tx.Model(&model.User{}).Where("username = ?", user.Username).Count(&usernameCount).Error; err != nil {
-			return fmt.Errorf("failed to check username: %w", err)
+// createUser validates and inserts a user inside an existing transaction
+func createUser(tx *gorm.DB, user *model.User) error {
+	// Every user must belong to an existing, active tenant
+	var tenant model.Tenant
+	if err := tx.Where("uuid = ?", user.TenantID).First(&tenant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTenantNotFound
 		}
-		if usernameCount > 0 {
-			return errors.New("username already exists")
+		return fmt.Errorf("failed to check tenant: %w", err)
+	}
+	if !tenant.IsActive() {
+		return errors.New("tenant is not active")
+	}
+
+	// Check if email already exists
+	var emailCount int64
+	if err := tx.Model(&model.User{}).Where("email = ?", user.Email).Count(&emailCount).Error; err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if emailCount > 0 {
+		return errors.New("email already exists")
+	}
+
+	// Check if username already exists
+	var usernameCount int64
+	if err := tx.Model(&model.User{}).Where("username = ?", user.Username).Count(&usernameCount).Error; err != nil {
+		return fmt.Errorf("failed to check username: %w", err)
+	}
+	if usernameCount > 0 {
+		return errors.New("username already exists")
+	}
+
+	// Create user (UUID and timestamps are handled by GORM hooks/defaults)
+	if err := tx.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// The user's default tenant is also their first membership
+	return createMembership(tx, &model.TenantMembership{
+		UserUUID:   user.UUID,
+		TenantUUID: user.TenantID,
+		Role:       user.Role,
+		Status:     model.MembershipStatusActive,
+	})
+}
+
+func (r *PostgresUserRepo) GetByVerifiedPhone(ctx context.Context, phoneNumber string) (*model.User, error) {
+	user := &model.User{}
+	err := r.db.WithContext(ctx).Where("phone_number = ? AND phone_verified_at IS NOT NULL", phoneNumber).First(user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
 		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
 
-		// Create user (UUID and timestamps are handled by GORM hooks/defaults)
-		if err := tx.Create(user).Error; err != nil {
-			return fmt.Errorf("failed to create user: %w", err)
+func (r *PostgresUserRepo) MarkPhoneVerified(ctx context.Context, userUUID, phoneNumber string, verifiedAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A verified number identifies exactly one account for phone login
+		var count int64
+		if err := tx.Model(&model.User{}).
+			Where("phone_number = ? AND phone_verified_at IS NOT NULL AND uuid <> ?", phoneNumber, userUUID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check phone number: %w", err)
+		}
+		if count > 0 {
+			return errors.New("phone number already in use")
 		}
 
+		result := tx.Model(&model.User{}).
+			Where("uuid = ? AND phone_number = ?", userUUID, phoneNumber).
+			Update("phone_verified_at", verifiedAt)
+		// The count above races under READ COMMITTED; the partial unique index decides
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return errors.New("phone number already in use")
+		}
+		if result.Error != nil {
+			return fmt.Errorf("failed to verify phone number: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("phone number has changed")
+		}
 		return nil
 	})
 }
+
+// MarkEmailVerified records when the user first proved ownership of their email address
+func (r *PostgresUserRepo) MarkEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error {
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("uuid = ? AND email_verified_at IS NULL", userUUID).
+		Update("email_verified_at", verifiedAt).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type WebAuthnRepository interface {
+	CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error
+	GetCredentialByCredentialID(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error)
+	ListCredentialsByUser(ctx context.Context, userUUID string) ([]model.WebAuthnCredential, error)
+	UpdateCredentialUsage(ctx context.Context, credential *model.WebAuthnCredential) error
+	DeleteCredential(ctx context.Context, userUUID, credentialUUID string) error
+	CountCredentialsByUser(ctx context.Context, userUUID string) (int64, error)
+	SaveChallenge(ctx context.Context, challenge *model.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, challengeUUID, ceremony string) (*model.WebAuthnChallenge, error)
+}
+
+type PostgresWebAuthnRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresWebAuthnRepo(db *gorm.DB) *PostgresWebAuthnRepo {
+	return &PostgresWebAuthnRepo{db: db}
+}
+
+func (r *PostgresWebAuthnRepo) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.WebAuthnCredential{}).Where("credential_id = ?", credential.CredentialID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check credential: %w", err)
+		}
+		if count > 0 {
+			return errors.New("credential already registered")
+		}
+
+		if err := tx.Create(credential).Error; err != nil {
+			return fmt.Errorf("failed to create credential: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresWebAuthnRepo) GetCredentialByCredentialID(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error) {
+	credential := &model.WebAuthnCredential{}
+	err := r.db.WithContext(ctx).Where("credential_id = ?", credentialID).First(credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("credential not found")
+		}
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return credential, nil
+}
+
+func (r *PostgresWebAuthnRepo) ListCredentialsByUser(ctx context.Context, userUUID string) ([]model.WebAuthnCredential, error) {
+	var credentials []model.WebAuthnCredential
+	err := r.db.WithContext(ctx).Where("user_uuid = ?", userUUID).Order("created_at").Find(&credentials).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// UpdateCredentialUsage persists the sign counter, clone warning, backup state and last use after an assertion
+func (r *PostgresWebAuthnRepo) UpdateCredentialUsage(ctx context.Context, credential *model.WebAuthnCredential) error {
+	err := r.db.WithContext(ctx).Model(&model.WebAuthnCredential{}).
+		Where("id = ?", credential.ID).
+		Updates(map[string]interface{}{
+			"sign_count":    credential.SignCount,
+			"clone_warning": credential.CloneWarning,
+			"backup_state":  credential.BackupState,
+			"last_used_at":  credential.LastUsedAt,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update credential: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresWebAuthnRepo) DeleteCredential(ctx context.Context, userUUID, credentialUUID string) error {
+	result := r.db.WithContext(ctx).Where("uuid = ? AND user_uuid = ?", credentialUUID, userUUID).Delete(&model.WebAuthnCredential{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete credential: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("credential not found")
+	}
+	return nil
+}
+
+func (r *PostgresWebAuthnRepo) CountCredentialsByUser(ctx context.Context, userUUID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.WebAuthnCredential{}).Where("user_uuid = ?", userUUID).Count(&count).Error
+	return count, err
+}
+
+func (r *PostgresWebAuthnRepo) SaveChallenge(ctx context.Context, challenge *model.WebAuthnChallenge) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Opportunistically clean up abandoned ceremonies
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&model.WebAuthnChallenge{}).Error; err != nil {
+			return fmt.Errorf("failed to purge expired challenges: %w", err)
+		}
+		if err := tx.Create(challenge).Error; err != nil {
+			return fmt.Errorf("failed to save challenge: %w", err)
+		}
+		return nil
+	})
+}
+
+// ConsumeChallenge loads and deletes a challenge so that it can only be used once
+func (r *PostgresWebAuthnRepo) ConsumeChallenge(ctx context.Context, challengeUUID, ceremony string) (*model.WebAuthnChallenge, error) {
+	challenge := &model.WebAuthnChallenge{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uuid = ? AND ceremony = ?", challengeUUID, ceremony).First(challenge).Error; err != nil {
+			return err
+		}
+		return tx.Delete(challenge).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("challenge not found")
+		}
+		return nil, fmt.Errorf("failed to consume challenge: %w", err)
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, errors.New("challenge expired")
+	}
+	return challenge, nil
+}
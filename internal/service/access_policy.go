@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cel.dev/cel-go/cel"
+	"cel.dev/cel-go/common/types"
+	"cel.dev/cel-go/common/types/ref"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	// policyCostLimit bounds the work a single condition evaluation may do
+	policyCostLimit      = 10000
+	maxPolicyCondition   = 4096
+	accessPolicyCacheTTL = 30 * time.Second
+)
+
+var (
+	ErrPoliciesDisabled = errors.New("access policies are not configured")
+	ErrInvalidPolicy    = errors.New("invalid policy")
+
+	policyNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,99}$`)
+)
+
+// RequestContext describes the request a permission check is made for
+type RequestContext struct {
+	IPAddress  string
+	Time       time.Time // now when zero
+	Attributes map[string]string
+}
+
+// PolicyResult explains how one access policy took part in a decision
+type PolicyResult struct {
+	Name      string
+	Effect    string
+	Applies   bool   // the policy covers the checked permission
+	Matched   bool   // its condition held
+	Error     string // condition failed to evaluate; deny policies then deny
+	Draft     bool   // supplied by a dry run instead of stored
+	Condition string
+}
+
+// AccessPolicyInput creates or changes an access policy; nil fields are left untouched on update
+type AccessPolicyInput struct {
+	Name        string
+	Description *string
+	Effect      *string
+	Permissions []string
+	Condition   *string
+	Enabled     *bool
+}
+
+// DryRunInput is a permission check evaluated by DryRunAccessPolicies. Draft,
+// when set, is evaluated as if stored, replacing a stored policy with its name.
+type DryRunInput struct {
+	Subject string
+	Check   PermissionCheck
+	Request RequestContext
+	Draft   *AccessPolicyInput
+}
+
+// accessPolicyEnv declares what policy conditions can refer to:
+//
+//	subject    id, email, username, role, attributes (membership attributes)
+//	tenant     id, slug, name, status, settings (the tenant settings object)
+//	resource   id, attributes (supplied with the check)
+//	request    time (timestamp), ip, attributes (supplied with the check)
+//	permission the permission being checked
+//
+// ipInRange(ip, cidr) tests an IP address against a CIDR range, e.g.
+// ipInRange(request.ip, "10.0.0.0/8") && request.time.getHours("Europe/Berlin") < 18
+var accessPolicyEnv = mustAccessPolicyEnv()
+
+func mustAccessPolicyEnv() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("subject", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("tenant", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("resource", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("permission", cel.StringType),
+		cel.Function("ipInRange",
+			cel.Overload("ip_in_range_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(ipInRange))),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+func ipInRange(ipValue, cidrValue ref.Val) ref.Val {
+	ipString, ok := ipValue.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(ipValue)
+	}
+	cidrString, ok := cidrValue.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(cidrValue)
+	}
+	prefix, err := netip.ParsePrefix(string(cidrString))
+	if err != nil {
+		return types.NewErr("invalid CIDR range %q", string(cidrString))
+	}
+	addr, err := netip.ParseAddr(string(ipString))
+	if err != nil {
+		return types.False
+	}
+	return types.Bool(prefix.Contains(addr.Unmap()))
+}
+
+// accessPolicyCache keeps compiled conditions and each tenant's enabled policies
+type accessPolicyCache struct {
+	mu       sync.Mutex
+	programs map[string]cel.Program
+	tenants  map[string]cachedAccessPolicies
+}
+
+type cachedAccessPolicies struct {
+	policies  []model.AccessPolicy
+	expiresAt time.Time
+}
+
+// SetAccessPolicyRepo enables attribute-based access policies in permission checks
+func (s *AuthService) SetAccessPolicyRepo(repo repository.AccessPolicyRepository) {
+	s.accessPolicyRepo = repo
+	s.accessPolicies = &accessPolicyCache{
+		programs: make(map[string]cel.Program),
+		tenants:  make(map[string]cachedAccessPolicies),
+	}
+}
+
+// compilePolicyCondition type-checks a condition, which must be a boolean expression
+func (s *AuthService) compilePolicyCondition(condition string) (cel.Program, error) {
+	s.accessPolicies.mu.Lock()
+	program, ok := s.accessPolicies.programs[condition]
+	s.accessPolicies.mu.Unlock()
+	if ok {
+		return program, nil
+	}
+
+	if len(condition) > maxPolicyCondition {
+		return nil, fmt.Errorf("%w: condition is longer than %d characters", ErrInvalidPolicy, maxPolicyCondition)
+	}
+	ast, issues := accessPolicyEnv.Compile(condition)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("%w: condition must be a boolean expression", ErrInvalidPolicy)
+	}
+	program, err := accessPolicyEnv.Program(ast, cel.CostLimit(policyCostLimit))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+
+	s.accessPolicies.mu.Lock()
+	if len(s.accessPolicies.programs) > 1000 {
+		s.accessPolicies.programs = make(map[string]cel.Program)
+	}
+	s.accessPolicies.programs[condition] = program
+	s.accessPolicies.mu.Unlock()
+	return program, nil
+}
+
+// enabledAccessPolicies returns a tenant's enabled policies (none when policies are disabled)
+func (s *AuthService) enabledAccessPolicies(ctx context.Context, tenantUUID string) ([]model.AccessPolicy, error) {
+	if s.accessPolicyRepo == nil {
+		return nil, nil
+	}
+
+	s.accessPolicies.mu.Lock()
+	cached, ok := s.accessPolicies.tenants[tenantUUID]
+	s.accessPolicies.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.policies, nil
+	}
+
+	policies, err := s.accessPolicyRepo.ListEnabledPolicies(ctx, tenantUUID)
+	if err != nil {
+		return nil, err
+	}
+	s.accessPolicies.mu.Lock()
+	s.accessPolicies.tenants[tenantUUID] = cachedAccessPolicies{policies: policies, expiresAt: time.Now().Add(accessPolicyCacheTTL)}
+	s.accessPolicies.mu.Unlock()
+	return policies, nil
+}
+
+// policyActivation builds the condition variables of a check
+func policyActivation(user *model.User, membership *model.TenantMembership, tenant *model.Tenant, check PermissionCheck, request RequestContext) map[string]interface{} {
+	subject := map[string]interface{}{
+		"id":         user.UUID,
+		"email":      user.Email,
+		"username":   user.Username,
+		"role":       user.Role,
+		"attributes": stringMap(nil),
+	}
+	if membership != nil {
+		subject["attributes"] = stringMap(membership.Attributes)
+	}
+
+	tenantVars := map[string]interface{}{"id": user.TenantID, "settings": map[string]interface{}{}}
+	if tenant != nil {
+		tenantVars["slug"] = tenant.Slug
+		tenantVars["name"] = tenant.Name
+		tenantVars["status"] = tenant.Status
+		var settings map[string]interface{}
+		if tenant.Settings != "" && json.Unmarshal([]byte(tenant.Settings), &settings) == nil && settings != nil {
+			tenantVars["settings"] = settings
+		}
+	}
+
+	requestTime := request.Time
+	if requestTime.IsZero() {
+		requestTime = time.Now()
+	}
+	return map[string]interface{}{
+		"subject":    subject,
+		"tenant":     tenantVars,
+		"resource":   map[string]interface{}{"id": check.Resource, "attributes": stringMap(check.ResourceAttributes)},
+		"request":    map[string]interface{}{"time": requestTime, "ip": request.IPAddress, "attributes": stringMap(request.Attributes)},
+		"permission": check.Permission,
+	}
+}
+
+func stringMap(values map[string]string) map[string]string {
+	if values == nil {
+		return map[string]string{}
+	}
+	return values
+}
+
+// evaluateAccessPolicies applies the policies covering a check. It returns whether
+// an allow policy matched, the name of a deny policy that matched (or failed, which
+// also denies) and what each policy did.
+func (s *AuthService) evaluateAccessPolicies(policies []model.AccessPolicy, drafts map[string]bool, activation map[string]interface{}) (bool, string, []PolicyResult) {
+	permission, _ := activation["permission"].(string)
+	var allowed bool
+	var deniedBy string
+	results := make([]PolicyResult, 0, len(policies))
+	for _, policy := range policies {
+		result := PolicyResult{
+			Name:      policy.Name,
+			Effect:    policy.Effect,
+			Applies:   permissionGranted(policy.Permissions, permission),
+			Draft:     drafts[policy.Name],
+			Condition: policy.Condition,
+		}
+		if result.Applies {
+			matched, err := s.evaluatePolicyCondition(policy.Condition, activation)
+			if err != nil {
+				result.Error = err.Error()
+			}
+			result.Matched = matched
+			switch {
+			case policy.Effect == model.PolicyEffectDeny && (matched || err != nil):
+				if deniedBy == "" {
+					deniedBy = policy.Name
+				}
+			case policy.Effect == model.PolicyEffectAllow && matched:
+				allowed = true
+			}
+		}
+		results = append(results, result)
+	}
+	return allowed, deniedBy, results
+}
+
+func (s *AuthService) evaluatePolicyCondition(condition string, activation map[string]interface{}) (bool, error) {
+	program, err := s.compilePolicyCondition(condition)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := program.Eval(activation)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, errors.New("condition did not evaluate to a boolean")
+	}
+	return matched, nil
+}
+
+// DryRunAccessPolicies evaluates a permission check in the actor's tenant and
+// explains the decision, optionally with a draft policy that is not saved
+func (s *AuthService) DryRunAccessPolicies(ctx context.Context, actor Actor, input DryRunInput) (*PermissionDecision, error) {
+	if s.accessPolicyRepo == nil {
+		return nil, ErrPoliciesDisabled
+	}
+
+	var draft *model.AccessPolicy
+	if input.Draft != nil {
+		draft = &model.AccessPolicy{Name: input.Draft.Name, Enabled: true}
+		if err := s.applyAccessPolicyInput(draft, input.Draft); err != nil {
+			return nil, err
+		}
+	}
+
+	decisions, err := s.checkPermissions(ctx, input.Subject, actor.TenantUUID, []PermissionCheck{input.Check}, input.Request, draft)
+	if err != nil {
+		return nil, err
+	}
+	return &decisions[0], nil
+}
+
+// applyAccessPolicyInput validates input and copies it onto policy
+func (s *AuthService) applyAccessPolicyInput(policy *model.AccessPolicy, input *AccessPolicyInput) error {
+	if !policyNamePattern.MatchString(policy.Name) {
+		return fmt.Errorf("%w: invalid name", ErrInvalidPolicy)
+	}
+	if input.Description != nil {
+		policy.Description = *input.Description
+	}
+	if input.Effect != nil {
+		policy.Effect = *input.Effect
+	}
+	if policy.Effect != model.PolicyEffectAllow && policy.Effect != model.PolicyEffectDeny {
+		return fmt.Errorf("%w: effect must be allow or deny", ErrInvalidPolicy)
+	}
+	if input.Permissions != nil {
+		permissions, err := normalizePermissions(input.Permissions)
+		if err != nil {
+			return err
+		}
+		policy.Permissions = permissions
+	}
+	if len(policy.Permissions) == 0 {
+		return fmt.Errorf("%w: at least one permission is required", ErrInvalidPolicy)
+	}
+	if input.Condition != nil {
+		policy.Condition = strings.TrimSpace(*input.Condition)
+	}
+	if _, err := s.compilePolicyCondition(policy.Condition); err != nil {
+		return err
+	}
+	if input.Enabled != nil {
+		policy.Enabled = *input.Enabled
+	}
+	return nil
+}
+
+// ListAccessPolicies lists a tenant's access policies
+func (s *AuthService) ListAccessPolicies(ctx context.Context, tenantUUID string) ([]model.AccessPolicy, error) {
+	if s.accessPolicyRepo == nil {
+		return nil, ErrPoliciesDisabled
+	}
+	return s.accessPolicyRepo.ListPolicies(ctx, tenantUUID)
+}
+
+// CreateAccessPolicy adds a policy to the actor's tenant. Allow policies grant
+// permissions, so the actor must hold the permissions they cover.
+func (s *AuthService) CreateAccessPolicy(ctx context.Context, actor Actor, input AccessPolicyInput) (*model.AccessPolicy, error) {
+	if s.accessPolicyRepo == nil {
+		return nil, ErrPoliciesDisabled
+	}
+
+	policy := &model.AccessPolicy{
+		TenantUUID: actor.TenantUUID,
+		Name:       input.Name,
+		Enabled:    true,
+		CreatedBy:  actor.UserUUID,
+	}
+	if err := s.applyAccessPolicyInput(policy, &input); err != nil {
+		return nil, err
+	}
+	if policy.Effect == model.PolicyEffectAllow {
+		if err := s.checkGrant(ctx, actor, policy.Permissions); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.accessPolicyRepo.CreatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	s.resetAccessPolicies(actor.TenantUUID)
+	return policy, nil
+}
+
+// UpdateAccessPolicy changes a policy of the actor's tenant
+func (s *AuthService) UpdateAccessPolicy(ctx context.Context, actor Actor, policyUUID string, input AccessPolicyInput) (*model.AccessPolicy, error) {
+	if s.accessPolicyRepo == nil {
+		return nil, ErrPoliciesDisabled
+	}
+
+	policy, err := s.accessPolicyRepo.GetPolicy(ctx, actor.TenantUUID, policyUUID)
+	if err != nil {
+		return nil, err
+	}
+	// Any change can widen what the policy covered before; an allow policy also
+	// grants what it covers afterwards
+	granted := append([]string{}, policy.Permissions...)
+	if err := s.applyAccessPolicyInput(policy, &input); err != nil {
+		return nil, err
+	}
+	if policy.Effect == model.PolicyEffectAllow {
+		granted = append(granted, policy.Permissions...)
+	}
+	if err := s.checkGrant(ctx, actor, granted); err != nil {
+		return nil, err
+	}
+
+	if err := s.accessPolicyRepo.UpdatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	s.resetAccessPolicies(actor.TenantUUID)
+	return policy, nil
+}
+
+// DeleteAccessPolicy removes a policy of the actor's tenant
+func (s *AuthService) DeleteAccessPolicy(ctx context.Context, actor Actor, policyUUID string) error {
+	if s.accessPolicyRepo == nil {
+		return ErrPoliciesDisabled
+	}
+
+	policy, err := s.accessPolicyRepo.GetPolicy(ctx, actor.TenantUUID, policyUUID)
+	if err != nil {
+		return err
+	}
+	// Removing a deny policy widens access like adding an allow policy does
+	if policy.Effect == model.PolicyEffectDeny {
+		if err := s.checkGrant(ctx, actor, policy.Permissions); err != nil {
+			return err
+		}
+	}
+
+	if err := s.accessPolicyRepo.DeletePolicy(ctx, actor.TenantUUID, policyUUID); err != nil {
+		return err
+	}
+	s.resetAccessPolicies(actor.TenantUUID)
+	return nil
+}
+
+func (s *AuthService) resetAccessPolicies(tenantUUID string) {
+	s.accessPolicies.mu.Lock()
+	delete(s.accessPolicies.tenants, tenantUUID)
+	s.accessPolicies.mu.Unlock()
+}
+
+// SetMemberAttributes replaces the attributes access policies see for a member of the actor's tenant
+func (s *AuthService) SetMemberAttributes(ctx context.Context, actor Actor, userUUID string, attributes map[string]string) error {
+	if s.membershipRepo == nil {
+		return errors.New("tenants not configured")
+	}
+	if len(attributes) > 50 {
+		return errors.New("too many attributes")
+	}
+	for key, value := range attributes {
+		if !policyNamePattern.MatchString(key) || len(value) > 255 {
+			return fmt.Errorf("invalid attribute %q", key)
+		}
+	}
+	return s.membershipRepo.UpdateMembershipAttributes(ctx, userUUID, actor.TenantUUID, attributes)
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func policyActor(user *model.User) Actor {
+	return Actor{UserUUID: user.UUID, TenantUUID: user.TenantID, Role: "editor"}
+}
+
+func TestCreateAccessPolicyValidation(t *testing.T) {
+	s, policies, user := newAuthorizationTestService(t)
+	deny, allow := model.PolicyEffectDeny, model.PolicyEffectAllow
+
+	tests := []struct {
+		name    string
+		input   AccessPolicyInput
+		wantErr error
+	}{
+		{"syntax error", AccessPolicyInput{Name: "broken", Effect: &deny, Permissions: []string{"docs:read"}, Condition: stringPtr(`subject.id ==`)}, ErrInvalidPolicy},
+		{"unknown variable", AccessPolicyInput{Name: "unknown", Effect: &deny, Permissions: []string{"docs:read"}, Condition: stringPtr(`user.id == "x"`)}, ErrInvalidPolicy},
+		{"not a boolean", AccessPolicyInput{Name: "string", Effect: &deny, Permissions: []string{"docs:read"}, Condition: stringPtr(`subject.id`)}, ErrInvalidPolicy},
+		{"wrong argument types", AccessPolicyInput{Name: "args", Effect: &deny, Permissions: []string{"docs:read"}, Condition: stringPtr(`ipInRange(request.ip, 8)`)}, ErrInvalidPolicy},
+		{"too long", AccessPolicyInput{Name: "long", Effect: &deny, Permissions: []string{"docs:read"}, Condition: stringPtr(strings.Repeat("true && ", maxPolicyCondition/8) + "true")}, ErrInvalidPolicy},
+		{"empty condition", AccessPolicyInput{Name: "empty", Effect: &deny, Permissions: []string{"docs:read"}}, ErrInvalidPolicy},
+		{"invalid effect", AccessPolicyInput{Name: "effect", Effect: stringPtr("maybe"), Permissions: []string{"docs:read"}, Condition: stringPtr(`true`)}, ErrInvalidPolicy},
+		{"no permissions", AccessPolicyInput{Name: "none", Effect: &deny, Condition: stringPtr(`true`)}, ErrInvalidPolicy},
+		{"invalid name", AccessPolicyInput{Name: "Bad Name", Effect: &deny, Permissions: []string{"docs:read"}, Condition: stringPtr(`true`)}, ErrInvalidPolicy},
+		{"allow beyond own permissions", AccessPolicyInput{Name: "escalate", Effect: &allow, Permissions: []string{"docs:delete"}, Condition: stringPtr(`true`)}, ErrPermissionEscalate},
+		{"valid deny", AccessPolicyInput{Name: "office-hours", Effect: &deny, Permissions: []string{"docs:*"}, Condition: stringPtr(`request.time.getHours("UTC") < 6`)}, nil},
+		{"valid allow", AccessPolicyInput{Name: "office-network", Effect: &allow, Permissions: []string{"docs:read"}, Condition: stringPtr(`ipInRange(request.ip, "10.0.0.0/8")`)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := len(policies.policies)
+			policy, err := s.CreateAccessPolicy(context.Background(), policyActor(user), tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if len(policies.policies) != stored {
+					t.Fatal("an invalid policy was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateAccessPolicy: %v", err)
+			}
+			if policy.TenantUUID != user.TenantID || policy.CreatedBy != user.UUID || !policy.Enabled {
+				t.Fatalf("unexpected policy %+v", policy)
+			}
+		})
+	}
+}
+
+func TestIPInRange(t *testing.T) {
+	s, _, user := newAuthorizationTestService(t)
+
+	tests := []struct {
+		ip      string
+		cidr    string
+		want    bool
+		wantErr bool
+	}{
+		{"10.1.2.3", "10.0.0.0/8", true, false},
+		{"192.168.1.1", "10.0.0.0/8", false, false},
+		{"::ffff:10.0.0.1", "10.0.0.0/8", true, false},
+		{"2001:db8::1", "2001:db8::/32", true, false},
+		{"2001:db9::1", "2001:db8::/32", false, false},
+		{"not an ip", "10.0.0.0/8", false, false},
+		{"", "10.0.0.0/8", false, false},
+		{"10.1.2.3", "10.0.0.0", false, true},
+	}
+	for _, tt := range tests {
+		activation := policyActivation(user, nil, nil, PermissionCheck{Permission: "docs:read"}, RequestContext{IPAddress: tt.ip})
+		matched, err := s.evaluatePolicyCondition(fmt.Sprintf("ipInRange(request.ip, %q)", tt.cidr), activation)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ipInRange(%q, %q) error = %v, want error %v", tt.ip, tt.cidr, err, tt.wantErr)
+		}
+		if matched != tt.want {
+			t.Errorf("ipInRange(%q, %q) = %v, want %v", tt.ip, tt.cidr, matched, tt.want)
+		}
+	}
+}
+
+func TestAccessPolicyCostLimit(t *testing.T) {
+	s, policies, user := newAuthorizationTestService(t)
+
+	numbers := make([]string, 200)
+	for i := range numbers {
+		numbers[i] = fmt.Sprint(i)
+	}
+	list := "[" + strings.Join(numbers, ",") + "]"
+	expensive := fmt.Sprintf("%s.all(x, %s.all(y, x + y >= 0))", list, list)
+
+	activation := policyActivation(user, nil, nil, PermissionCheck{Permission: "docs:read"}, RequestContext{})
+	if _, err := s.evaluatePolicyCondition(expensive, activation); err == nil || !strings.Contains(err.Error(), "cost limit") {
+		t.Fatalf("error = %v, want the cost limit to be exceeded", err)
+	}
+
+	// A deny policy that cannot be evaluated denies, even over a role grant
+	policies.policies = append(policies.policies, &model.AccessPolicy{
+		UUID:        "00000000-0000-0000-0000-0000000000e4",
+		TenantUUID:  user.TenantID,
+		Name:        "expensive",
+		Effect:      model.PolicyEffectDeny,
+		Permissions: []string{"docs:comment"},
+		Condition:   expensive,
+		Enabled:     true,
+	})
+	decision, err := s.CheckPermission(context.Background(), user.UUID, "", PermissionCheck{Permission: "docs:comment"}, RequestContext{})
+	if err != nil {
+		t.Fatalf("CheckPermission: %v", err)
+	}
+	if decision.Allowed || decision.Reason != DecisionDeniedByPolicy || decision.Policy != "expensive" {
+		t.Fatalf("decision = %+v, want denied by expensive", decision)
+	}
+}
+
+func TestDenyPolicyOverridesAllow(t *testing.T) {
+	s, _, user := newAuthorizationTestService(t)
+	ctx := context.Background()
+	deny := model.PolicyEffectDeny
+	condition := `request.attributes["network"] == "public"`
+
+	_, err := s.CreateAccessPolicy(ctx, policyActor(user), AccessPolicyInput{
+		Name:        "no-public-writes",
+		Effect:      &deny,
+		Permissions: []string{"docs:write"},
+		Condition:   &condition,
+	})
+	if err != nil {
+		t.Fatalf("CreateAccessPolicy: %v", err)
+	}
+
+	check := PermissionCheck{Permission: "docs:write", Resource: "draft-1"}
+	decision, err := s.CheckPermission(ctx, user.UUID, "", check, RequestContext{Attributes: map[string]string{"network": "office"}})
+	if err != nil {
+		t.Fatalf("CheckPermission: %v", err)
+	}
+	if !decision.Allowed || decision.Policy != "own-drafts" {
+		t.Fatalf("office decision = %+v, want allowed by own-drafts", decision)
+	}
+
+	decision, err = s.CheckPermission(ctx, user.UUID, "", check, RequestContext{Attributes: map[string]string{"network": "public"}})
+	if err != nil {
+		t.Fatalf("CheckPermission: %v", err)
+	}
+	if decision.Allowed || decision.Reason != DecisionDeniedByPolicy || decision.Policy != "no-public-writes" {
+		t.Fatalf("public decision = %+v, want denied by no-public-writes", decision)
+	}
+}
+
+func TestDryRunAccessPolicies(t *testing.T) {
+	s, policies, user := newAuthorizationTestService(t)
+	ctx := context.Background()
+	actor := policyActor(user)
+	deny := model.PolicyEffectDeny
+	condition := `resource.id.startsWith("draft-")`
+	check := PermissionCheck{Permission: "docs:write", Resource: "draft-1"}
+
+	// Without a draft the stored policies decide
+	decision, err := s.DryRunAccessPolicies(ctx, actor, DryRunInput{Subject: user.UUID, Check: check})
+	if err != nil {
+		t.Fatalf("DryRunAccessPolicies: %v", err)
+	}
+	if !decision.Allowed || decision.Reason != DecisionAllowedByPolicy {
+		t.Fatalf("decision = %+v, want allowed by policy", decision)
+	}
+
+	// A draft deny policy overrides, is marked as a draft and is not saved
+	stored := len(policies.policies)
+	decision, err = s.DryRunAccessPolicies(ctx, actor, DryRunInput{
+		Subject: user.UUID,
+		Check:   check,
+		Draft:   &AccessPolicyInput{Name: "freeze-drafts", Effect: &deny, Permissions: []string{"docs:write"}, Condition: &condition},
+	})
+	if err != nil {
+		t.Fatalf("DryRunAccessPolicies: %v", err)
+	}
+	if decision.Allowed || decision.Policy != "freeze-drafts" {
+		t.Fatalf("decision = %+v, want denied by the draft", decision)
+	}
+	drafts := 0
+	for _, result := range decision.Policies {
+		if result.Draft {
+			drafts++
+			if result.Name != "freeze-drafts" || !result.Matched {
+				t.Fatalf("draft result = %+v", result)
+			}
+		}
+	}
+	if drafts != 1 {
+		t.Fatalf("%d draft results, want 1", drafts)
+	}
+	if len(policies.policies) != stored {
+		t.Fatal("dry run stored the draft")
+	}
+
+	// A draft replaces the stored policy of the same name
+	never := `false`
+	decision, err = s.DryRunAccessPolicies(ctx, actor, DryRunInput{
+		Subject: user.UUID,
+		Check:   check,
+		Draft:   &AccessPolicyInput{Name: "own-drafts", Effect: stringPtr(model.PolicyEffectAllow), Permissions: []string{"docs:write"}, Condition: &never},
+	})
+	if err != nil {
+		t.Fatalf("DryRunAccessPolicies: %v", err)
+	}
+	if decision.Allowed || decision.Reason != DecisionNotGranted {
+		t.Fatalf("decision = %+v, want not granted once own-drafts never matches", decision)
+	}
+	if len(decision.Policies) != 2 {
+		t.Fatalf("%d policy results, want the stored policy replaced by the draft", len(decision.Policies))
+	}
+
+	// Drafts are compiled like saved policies
+	broken := `resource.id.startsWith(`
+	_, err = s.DryRunAccessPolicies(ctx, actor, DryRunInput{
+		Subject: user.UUID,
+		Check:   check,
+		Draft:   &AccessPolicyInput{Name: "broken", Effect: &deny, Permissions: []string{"docs:write"}, Condition: &broken},
+	})
+	if !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("error = %v, want ErrInvalidPolicy", err)
+	}
+}
+
+func TestUpdateAccessPolicyTakesEffect(t *testing.T) {
+	s, _, user := newAuthorizationTestService(t)
+	ctx := context.Background()
+	const ownDrafts = "00000000-0000-0000-0000-0000000000e2"
+
+	allowed := func(resource string) bool {
+		t.Helper()
+		decision, err := s.CheckPermission(ctx, user.UUID, "", PermissionCheck{Permission: "docs:write", Resource: resource}, RequestContext{})
+		if err != nil {
+			t.Fatalf("CheckPermission: %v", err)
+		}
+		return decision.Allowed
+	}
+
+	// Prime the tenant's cached policies and compiled programs
+	if !allowed("draft-1") || allowed("mine-1") {
+		t.Fatal("own-drafts does not apply as stored")
+	}
+
+	condition := `resource.id.startsWith("mine-")`
+	if _, err := s.UpdateAccessPolicy(ctx, policyOwner, ownDrafts, AccessPolicyInput{Condition: &condition}); err != nil {
+		t.Fatalf("UpdateAccessPolicy: %v", err)
+	}
+	if allowed("draft-1") || !allowed("mine-1") {
+		t.Fatal("the updated condition is not used")
+	}
+
+	disabled := false
+	if _, err := s.UpdateAccessPolicy(ctx, policyOwner, ownDrafts, AccessPolicyInput{Enabled: &disabled}); err != nil {
+		t.Fatalf("UpdateAccessPolicy: %v", err)
+	}
+	if allowed("mine-1") {
+		t.Fatal("a disabled policy still allows")
+	}
+
+	broken := `resource.id.startsWith(`
+	if _, err := s.UpdateAccessPolicy(ctx, policyOwner, ownDrafts, AccessPolicyInput{Condition: &broken}); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("error = %v, want ErrInvalidPolicy", err)
+	}
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
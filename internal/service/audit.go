@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// Audited actions
+const (
+	AuditTokenExchange      = "token.exchange"
+	AuditImpersonationStart = "impersonation.start"
+	AuditDelegatedRequest   = "delegated.request" // API call made with a token carrying an act claim
+	AuditSessionRevoke      = "session.revoke"    // support staff signed a user out of a device
+
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+var ErrAuditDisabled = errors.New("audit log is not configured")
+
+// AuditFilter narrows ListAuditEvents; empty fields match everything
+type AuditFilter = repository.AuditFilter
+
+// SetAuditRepo enables the audit log. Impersonation and token exchange are
+// refused without it so they can never go unrecorded.
+func (s *AuthService) SetAuditRepo(repo repository.AuditRepository) {
+	s.auditRepo = repo
+}
+
+// ListAuditEvents returns the most recent audit events matching filter
+func (s *AuthService) ListAuditEvents(ctx context.Context, filter AuditFilter, limit int) ([]model.AuditEvent, error) {
+	if s.auditRepo == nil {
+		return nil, ErrAuditDisabled
+	}
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	return s.auditRepo.ListEvents(ctx, filter, limit)
+}
+
+// RecordDelegatedRequest audits an API call made by actor on behalf of subject
+func (s *AuthService) RecordDelegatedRequest(ctx context.Context, actor, actorClientID, subjectUUID, tenantUUID, method, path string, status int) {
+	err := s.recordAudit(ctx, &model.AuditEvent{
+		Action:        AuditDelegatedRequest,
+		ActorUUID:     actor,
+		ActorClientID: actorClientID,
+		SubjectUUID:   subjectUUID,
+		TenantUUID:    tenantUUID,
+		Details: map[string]string{
+			"method": method,
+			"path":   path,
+			"status": strconv.Itoa(status),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to audit delegated request: %v", err)
+	}
+}
+
+// recordAudit stores event along with the device the request came from
+func (s *AuthService) recordAudit(ctx context.Context, event *model.AuditEvent) error {
+	if s.auditRepo == nil {
+		return ErrAuditDisabled
+	}
+	info := clientInfoFrom(ctx)
+	event.IPAddress = truncate(info.IPAddress, 45)
+	event.UserAgent = truncate(info.UserAgent, 512)
+	if event.Details == nil {
+		event.Details = map[string]string{}
+	}
+	return s.auditRepo.CreateEvent(ctx, event)
+}
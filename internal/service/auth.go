@@ -2,12 +2,14 @@ package service
 
 import (
 	"context"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"log"
-	"time"
+	"net/http"
 
 	"golang.org/x/crypto/bcrypt"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/johnroshan2255/auth-service/internal/model"
 	"github.com/johnroshan2255/auth-service/internal/repository"
@@ -15,11 +17,64 @@ import (
 
 type AuthService struct {
 	repo                  repository.UserRepository
+	webauthnRepo          repository.WebAuthnRepository
+	webauthn              *webauthn.WebAuthn
+	magicLinkRepo         repository.MagicLinkRepository
+	magicLinkURL          string
+	emailSender           EmailSender
+	smsSender             SMSSender
+	phoneOTPRepo          repository.PhoneOTPRepository
+	phoneOTPLogin         bool
+	sessionRepo           repository.SessionRepository
+	loginHistoryRepo      repository.LoginHistoryRepository
+	tenantRepo            repository.TenantRepository
+	membershipRepo        repository.MembershipRepository
+	invitationRepo        repository.InvitationRepository
+	invitationURL         string
+	tenantDomainRepo      repository.TenantDomainRepository
+	joinRequestRepo       repository.JoinRequestRepository
+	lookupTXT             func(ctx context.Context, name string) ([]string, error)
+	roleRepo              repository.RoleRepository
+	permissions           *permissionCache
+	accessPolicyRepo      repository.AccessPolicyRepository
+	accessPolicies        *accessPolicyCache
+	oauthClientRepo       repository.OAuthClientRepository
+	oauthGrantRepo        repository.OAuthGrantRepository
+	oauthConsentURL       string
+	initialAccessTokenRepo repository.InitialAccessTokenRepository
+	deviceVerificationURL string
+	oidcIssuer            string
+	oidcKey               *rsa.PrivateKey
+	oidcKeyID             string
+	serviceKeyRepo        repository.ServiceKeyRepository
+	serviceKeys           *serviceKeyCache
+	auditRepo             repository.AuditRepository
+	impersonationScopes   []string
+	federationRepo        repository.FederationRepository
+	federationRedirectURL string
+	identityProviders     []*identityProvider
+	httpClient            *http.Client
+	defaultTenantSlug     string
 	coreNotificationClient *CoreNotificationClient
+	loginFailures         *attemptLimiter
+	magicLinkSends        *attemptLimiter
+	smsSends              *attemptLimiter
+	userCodeLookups       *attemptLimiter
 }
 
+// ErrMFARequired is returned by Login when the password was correct but the user
+// must complete a second factor. The returned token is then a short-lived MFA token.
+var ErrMFARequired = errors.New("mfa required")
+
 func NewAuthService(repo repository.UserRepository) *AuthService {
-	return &AuthService{repo: repo}
+	return &AuthService{
+		repo:                repo,
+		loginFailures:       newAttemptLimiter(maxFailedLogins, failedLoginWindow),
+		magicLinkSends:      newAttemptLimiter(maxMagicLinkSends, magicLinkSendWindow),
+		smsSends:            newAttemptLimiter(maxSMSSends, smsSendWindow),
+		userCodeLookups:     newAttemptLimiter(maxUserCodeFailures, userCodeFailureWindow),
+		impersonationScopes: defaultImpersonationScopes,
+	}
 }
 
 // SetCoreNotificationClient sets the gRPC client for calling core-service notification
@@ -35,23 +90,54 @@ func SetJWTKey(key string) {
 
 // Login authenticates the user and returns a JWT
 func (s *AuthService) Login(ctx context.Context, email, password string) (string, *model.User, error) {
+	amr := []string{AMRPassword}
+	if !s.loginFailures.Allowed(email) {
+		s.recordLoginFailure(ctx, nil, email, amr, loginFailureLockedOut)
+		return "", nil, ErrTooManyAttempts
+	}
+
 	user, err := s.repo.GetByEmail(ctx, email)
 	if err != nil {
+		s.loginFailures.Record(email)
+		s.recordLoginFailure(ctx, nil, email, amr, loginFailureUnknownUser)
 		return "", nil, fmt.Errorf("invalid credentials: %w", err)
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		s.loginFailures.Record(email)
+		s.recordLoginFailure(ctx, user, email, amr, loginFailureBadPassword)
 		return "", nil, errors.New("invalid credentials")
 	}
+	s.loginFailures.Reset(email)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_uuid":   user.UUID,
-		"tenant_id": user.TenantID,
-		"role":      user.Role,
-		"exp":       time.Now().Add(time.Hour * 24).Unix(),
-	})
+	return s.completeLogin(ctx, user, amr)
+}
 
-	tokenString, err := token.SignedString(jwtKey)
+// completeLogin finishes a successful first-factor login: it either asks for a
+// second factor (returning an MFA token with ErrMFARequired) or issues the access token
+func (s *AuthService) completeLogin(ctx context.Context, user *model.User, amr []string) (string, *model.User, error) {
+	// Reject methods the tenant disallows before asking for a second factor
+	policy, err := s.tenantPolicy(ctx, user.TenantID)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := policy.CheckMethods(amr); err != nil {
+		s.recordLoginFailure(ctx, user, user.Email, amr, loginFailurePolicy)
+		return "", nil, err
+	}
+
+	if s.hasSecondFactor(ctx, user) {
+		mfaToken, err := generateMFAToken(user, amr)
+		if err != nil {
+			return "", nil, err
+		}
+		return mfaToken, user, ErrMFARequired
+	}
+
+	tokenString, err := s.issueLoginToken(ctx, user, amr)
+	if errors.Is(err, ErrMFAEnrollmentRequired) {
+		return tokenString, user, err
+	}
 	if err != nil {
 		return "", nil, err
 	}
@@ -59,19 +145,48 @@ func (s *AuthService) Login(ctx context.Context, email, password string) (string
 	return tokenString, user, nil
 }
 
-// ValidateToken parses JWT and returns user info
-func (s *AuthService) ValidateToken(tokenStr string) (bool, *model.User) {
+// TokenInfo is what an access token says beyond its user
+type TokenInfo struct {
+	Scopes        []string // nil for sessions that are not limited by scope
+	ActorUserUUID string   // set when support staff impersonate the user
+	ActorClientID string   // set when a client exchanged the token to act for the user
+}
+
+// ValidateToken parses JWT and returns user info. Tokens whose session was revoked or expired are rejected.
+func (s *AuthService) ValidateToken(ctx context.Context, tokenStr string) (bool, *model.User) {
+	user, _, valid := s.InspectToken(ctx, tokenStr, "", nil)
+	return valid, user
+}
+
+// InspectToken is ValidateToken for callers that also need the token's scope and
+// actor. A token limited by scope must carry every one of requiredScopes. Tokens
+// issued to a third-party client carry its client ID as audience and are only
+// valid when audience names it.
+func (s *AuthService) InspectToken(ctx context.Context, tokenStr, audience string, requiredScopes []string) (*model.User, *TokenInfo, bool) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		return jwtKey, nil
 	})
 
 	if err != nil || !token.Valid {
-		return false, nil
+		return nil, nil, false
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return false, nil
+		return nil, nil, false
+	}
+
+	// Purpose-bound tokens (e.g. MFA tokens) are not access tokens
+	if _, ok := claims["token_use"]; ok {
+		return nil, nil, false
+	}
+
+	// Tokens issued to third-party OAuth clients are only valid for the audience they name
+	if _, ok := claims["aud"]; ok {
+		tokenAudience, err := claims.GetAudience()
+		if err != nil || audience == "" || !containsString(tokenAudience, audience) {
+			return nil, nil, false
+		}
 	}
 
 	// Support both user_uuid (new) and user_id (old) for backward compatibility
@@ -79,22 +194,58 @@ func (s *AuthService) ValidateToken(tokenStr string) (bool, *model.User) {
 	if !ok {
 		userUUID, ok = claims["user_id"].(string)
 		if !ok {
-			return false, nil
+			return nil, nil, false
 		}
 	}
 
+	info := &TokenInfo{}
+	// Sessions are not limited by scope; OAuth tokens only grant what their scope names
+	if scope, ok := claims["scope"].(string); ok {
+		info.Scopes = append([]string{}, splitScope(scope)...)
+		for _, required := range requiredScopes {
+			if !containsString(info.Scopes, required) {
+				return nil, nil, false
+			}
+		}
+	}
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		if clientID, _ := act["client_id"].(string); clientID != "" {
+			info.ActorClientID = clientID
+		} else {
+			info.ActorUserUUID, _ = act["sub"].(string)
+		}
+	}
+
+	sessionID, _ := claims["sid"].(string)
+	if !s.IsSessionActive(ctx, sessionID) {
+		return nil, nil, false
+	}
+
 	tenantID, _ := claims["tenant_id"].(string)
 	role, _ := claims["role"].(string)
 
-	return true, &model.User{
-		UUID:     userUUID,
-		TenantID: tenantID,
-		Role:     role,
+	// Report the role currently held in the token's tenant
+	user, _, err := s.scopeToTenant(ctx, &model.User{UUID: userUUID, TenantID: tenantID, Role: role}, tenantID)
+	if err != nil {
+		return nil, nil, false
 	}
+
+	return user, info, true
 }
 
-// Signup creates a new user account
-func (s *AuthService) Signup(ctx context.Context, email, username, password, phoneNumber, firstName, lastName string) (string, *model.User, error) {
+// Signup creates a new user account. When tenantName is set, or no default tenant
+// is configured, a new tenant is provisioned and the user becomes its admin; with
+// an invitationToken the user joins the inviting tenant instead.
+func (s *AuthService) Signup(ctx context.Context, email, username, password, phoneNumber, firstName, lastName, tenantName, invitationToken string) (string, *model.User, error) {
+	if tenantName != "" && invitationToken != "" {
+		return "", nil, errors.New("tenant name and invitation cannot be combined")
+	}
+
+	phoneNumber, err := NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -102,7 +253,7 @@ func (s *AuthService) Signup(ctx context.Context, email, username, password, pho
 	}
 
 	// Create user (ID will be generated by database)
-	// Uniqueness checks are handled within the transaction in CreateUser
+	// Uniqueness and tenant checks are handled within the transaction in CreateUser
 	user := &model.User{
 		Email:        email,
 		Username:     username,
@@ -110,28 +261,29 @@ func (s *AuthService) Signup(ctx context.Context, email, username, password, pho
 		PhoneNumber:   phoneNumber,
 		FirstName:    firstName,
 		LastName:     lastName,
-		TenantID:     "", // Set default tenant or generate one
 		Role:         "user", // Default role
 	}
 
-	err = s.repo.CreateUser(ctx, user)
+	if invitationToken != "" {
+		err = s.signupWithInvitation(ctx, user, password, invitationToken)
+	} else {
+		err = s.createSignupUser(ctx, user, password, tenantName)
+	}
 	if err != nil {
 		return "", nil, err
 	}
 
+	// People with an email in a tenant's verified domain are offered to join it
+	if invitationToken == "" && tenantName == "" {
+		s.joinTenantByDomain(ctx, user)
+	}
+
 	if s.coreNotificationClient != nil {
 		go s.sendNotification(user.UUID, user.Email, user.Username)
 	}
 
 	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_uuid":   user.UUID,
-		"tenant_id": user.TenantID,
-		"role":      user.Role,
-		"exp":       time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	tokenString, err := token.SignedString(jwtKey)
+	tokenString, err := s.issueSignupToken(ctx, user, []string{AMRPassword})
 	if err != nil {
 		return "", nil, errors.New("failed to generate token: " + err.Error())
 	}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func TestValidateTokenRejectsThirdPartyTokens(t *testing.T) {
+	s := NewAuthService(newFakeUsers())
+	user := &model.User{UUID: "00000000-0000-0000-0000-000000000001", TenantID: "00000000-0000-0000-0000-0000000000aa", Role: "user"}
+	ctx := context.Background()
+
+	claims := accessTokenClaims(user, nil, []string{AMRPassword}, time.Now(), time.Minute, "")
+	token, err := signAccessToken(claims)
+	if err != nil {
+		t.Fatalf("signAccessToken: %v", err)
+	}
+	if valid, validated := s.ValidateToken(ctx, token); !valid || validated.UUID != user.UUID {
+		t.Fatal("session token was rejected")
+	}
+
+	claims["aud"] = "third-party-client"
+	token, err = signAccessToken(claims)
+	if err != nil {
+		t.Fatalf("signAccessToken: %v", err)
+	}
+	if valid, _ := s.ValidateToken(ctx, token); valid {
+		t.Fatal("token issued to a third-party client was accepted")
+	}
+}
+
+func TestInspectTokenScopeAndActor(t *testing.T) {
+	s := NewAuthService(newFakeUsers())
+	user := &model.User{UUID: "00000000-0000-0000-0000-000000000001", TenantID: "00000000-0000-0000-0000-0000000000aa", Role: "user"}
+	ctx := context.Background()
+
+	session, err := issueAccessToken(user, nil, []string{AMRPassword}, time.Minute, "")
+	if err != nil {
+		t.Fatalf("issueAccessToken: %v", err)
+	}
+	_, info, valid := s.InspectToken(ctx, session, "", []string{"documents:read"})
+	if !valid || info.Scopes != nil {
+		t.Fatalf("session token: valid=%v scopes=%v, want valid without scope", valid, info)
+	}
+
+	claims := accessTokenClaims(user, nil, []string{AMRPassword}, time.Now(), time.Minute, "")
+	claims["client_id"] = "reports"
+	claims["scope"] = "documents:read openid"
+	claims["act"] = map[string]interface{}{"sub": "reports", "client_id": "reports"}
+	exchanged, err := signAccessToken(claims)
+	if err != nil {
+		t.Fatalf("signAccessToken: %v", err)
+	}
+
+	_, info, valid = s.InspectToken(ctx, exchanged, "", []string{"documents:read"})
+	if !valid {
+		t.Fatal("token with the required scope was rejected")
+	}
+	if len(info.Scopes) != 2 || info.ActorClientID != "reports" || info.ActorUserUUID != "" {
+		t.Fatalf("unexpected token info: %+v", info)
+	}
+
+	if _, _, valid := s.InspectToken(ctx, exchanged, "", []string{"documents:write"}); valid {
+		t.Fatal("token without the required scope was accepted")
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// maxPermissionChecks bounds a CheckPermissions batch
+const maxPermissionChecks = 100
+
+// Reasons reported with a permission decision
+const (
+	DecisionGranted            = "granted"
+	DecisionAllowedByPolicy    = "allowed_by_policy"
+	DecisionDeniedByPolicy     = "denied_by_policy"
+	DecisionNotGranted         = "permission_not_granted"
+	DecisionSubjectNotFound    = "subject_not_found"
+	DecisionNotMember          = "not_a_member"
+	DecisionMembershipInactive = "membership_inactive"
+	DecisionTenantInactive     = "tenant_inactive"
+)
+
+var (
+	ErrInvalidPermissionCheck = errors.New("invalid permission check")
+	ErrUserNotFound           = repository.ErrUserNotFound
+)
+
+// PermissionCheck asks whether a permission is held. Resource names the object
+// being accessed; role permissions apply to every resource, while access
+// policies can look at the resource and its attributes.
+type PermissionCheck struct {
+	Permission         string
+	Resource           string
+	ResourceAttributes map[string]string
+}
+
+// PermissionDecision is the answer to a PermissionCheck
+type PermissionDecision struct {
+	Allowed  bool
+	Reason   string
+	TenantID string // tenant the check was evaluated in
+	Role     string // subject's role in that tenant, empty when not a member
+	Policy   string // access policy that decided, if any
+	Policies []PolicyResult
+}
+
+// CheckPermission decides whether subject (a user UUID) holds permission in a
+// tenant (UUID or slug; the subject's default tenant when empty). The subject's
+// role grants permissions; the tenant's access policies can then allow more or
+// deny, with deny policies taking precedence.
+func (s *AuthService) CheckPermission(ctx context.Context, subject, tenantRef string, check PermissionCheck, request RequestContext) (*PermissionDecision, error) {
+	decisions, err := s.CheckPermissions(ctx, subject, tenantRef, []PermissionCheck{check}, request)
+	if err != nil {
+		return nil, err
+	}
+	return &decisions[0], nil
+}
+
+// CheckPermissions decides several checks for one subject and tenant, in order
+func (s *AuthService) CheckPermissions(ctx context.Context, subject, tenantRef string, checks []PermissionCheck, request RequestContext) ([]PermissionDecision, error) {
+	return s.checkPermissions(ctx, subject, tenantRef, checks, request, nil)
+}
+
+// checkPermissions evaluates checks, with draft (when set) replacing or adding
+// to the tenant's stored access policies
+func (s *AuthService) checkPermissions(ctx context.Context, subject, tenantRef string, checks []PermissionCheck, request RequestContext, draft *model.AccessPolicy) ([]PermissionDecision, error) {
+	if s.roleRepo == nil {
+		return nil, ErrRBACDisabled
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("%w: subject is required", ErrInvalidPermissionCheck)
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("%w: at least one check is required", ErrInvalidPermissionCheck)
+	}
+	if len(checks) > maxPermissionChecks {
+		return nil, fmt.Errorf("%w: at most %d checks per request", ErrInvalidPermissionCheck, maxPermissionChecks)
+	}
+	for _, check := range checks {
+		// Checks name one concrete permission; wildcards are only granted
+		if strings.Contains(check.Permission, "*") || !permissionPattern.MatchString(check.Permission) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPermission, check.Permission)
+		}
+	}
+
+	decide := func(decision PermissionDecision) []PermissionDecision {
+		decisions := make([]PermissionDecision, len(checks))
+		for i := range decisions {
+			decisions[i] = decision
+		}
+		return decisions
+	}
+
+	user, err := s.repo.GetByID(ctx, subject)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return decide(PermissionDecision{Reason: DecisionSubjectNotFound}), nil
+		}
+		return nil, err
+	}
+
+	tenantUUID := user.TenantID
+	if tenantRef != "" && s.tenantRepo != nil {
+		tenant, err := s.findTenant(ctx, tenantRef)
+		if err != nil {
+			if errors.Is(err, ErrTenantNotFound) {
+				return decide(PermissionDecision{Reason: DecisionNotMember}), nil
+			}
+			return nil, err
+		}
+		tenantUUID = tenant.UUID
+	} else if tenantRef != "" {
+		tenantUUID = tenantRef
+	}
+
+	scoped, tenant, err := s.scopeToTenant(ctx, user, tenantUUID)
+	if err != nil {
+		decision := PermissionDecision{TenantID: tenantUUID}
+		switch {
+		case errors.Is(err, ErrMembershipInactive):
+			decision.Reason = DecisionMembershipInactive
+		case errors.Is(err, ErrTenantInactive):
+			decision.Reason = DecisionTenantInactive
+		case errors.Is(err, ErrMembershipNotFound), errors.Is(err, ErrTenantNotFound):
+			decision.Reason = DecisionNotMember
+		default:
+			return nil, err
+		}
+		return decide(decision), nil
+	}
+
+	granted, err := s.ResolvePermissions(ctx, tenantUUID, scoped.Role)
+	if err != nil {
+		return nil, err
+	}
+	policies, drafts, err := s.accessPoliciesWithDraft(ctx, tenantUUID, draft)
+	if err != nil {
+		return nil, err
+	}
+	var membership *model.TenantMembership
+	if len(policies) > 0 && s.membershipRepo != nil {
+		if membership, err = s.membershipRepo.GetMembership(ctx, scoped.UUID, tenantUUID); err != nil {
+			return nil, err
+		}
+	}
+
+	decisions := make([]PermissionDecision, len(checks))
+	for i, check := range checks {
+		decision := PermissionDecision{Reason: DecisionNotGranted, TenantID: tenantUUID, Role: scoped.Role}
+		var allowedByPolicy bool
+		var deniedBy string
+		if len(policies) > 0 {
+			activation := policyActivation(scoped, membership, tenant, check, request)
+			allowedByPolicy, deniedBy, decision.Policies = s.evaluateAccessPolicies(policies, drafts, activation)
+		}
+		switch {
+		case deniedBy != "":
+			decision.Reason = DecisionDeniedByPolicy
+			decision.Policy = deniedBy
+		case permissionGranted(granted, check.Permission):
+			decision.Allowed = true
+			decision.Reason = DecisionGranted
+		case allowedByPolicy:
+			decision.Allowed = true
+			decision.Reason = DecisionAllowedByPolicy
+			for _, result := range decision.Policies {
+				if result.Effect == model.PolicyEffectAllow && result.Matched {
+					decision.Policy = result.Name
+					break
+				}
+			}
+		}
+		decisions[i] = decision
+	}
+	return decisions, nil
+}
+
+// accessPoliciesWithDraft returns a tenant's enabled policies with draft in place
+// of the stored policy of the same name, and the names of draft policies
+func (s *AuthService) accessPoliciesWithDraft(ctx context.Context, tenantUUID string, draft *model.AccessPolicy) ([]model.AccessPolicy, map[string]bool, error) {
+	policies, err := s.enabledAccessPolicies(ctx, tenantUUID)
+	if err != nil || draft == nil {
+		return policies, nil, err
+	}
+
+	merged := make([]model.AccessPolicy, 0, len(policies)+1)
+	for _, policy := range policies {
+		if policy.Name != draft.Name {
+			merged = append(merged, policy)
+		}
+	}
+	if draft.Enabled {
+		merged = append(merged, *draft)
+	}
+	return merged, map[string]bool{draft.Name: true}, nil
+}
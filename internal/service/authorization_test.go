@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+// newAuthorizationTestService returns a service where user is an editor and
+// policyOwner an owner of the home tenant, which has a deny policy "no-secrets",
+// an allow policy "own-drafts" and a disabled policy
+func newAuthorizationTestService(t *testing.T) (*AuthService, *fakeAccessPolicies, *model.User) {
+	t.Helper()
+	user := &model.User{
+		UUID:     "00000000-0000-0000-0000-000000000001",
+		Email:    "ada@example.com",
+		Role:     "user",
+		TenantID: "00000000-0000-0000-0000-0000000000aa",
+	}
+	home := &model.Tenant{UUID: user.TenantID, Slug: "home", Status: model.TenantStatusActive}
+	stranger := &model.Tenant{UUID: "00000000-0000-0000-0000-0000000000bb", Slug: "stranger", Status: model.TenantStatusActive}
+	banned := &model.Tenant{UUID: "00000000-0000-0000-0000-0000000000cc", Slug: "banned", Status: model.TenantStatusActive}
+	suspended := &model.Tenant{UUID: "00000000-0000-0000-0000-0000000000dd", Slug: "suspended", Status: model.TenantStatusSuspended}
+
+	s := NewAuthService(newFakeUsers(user))
+	s.SetTenantRepo(newFakeTenants(home, stranger, banned, suspended), "home")
+	s.SetMembershipRepo(newFakeMemberships(
+		&model.TenantMembership{UserUUID: user.UUID, TenantUUID: home.UUID, Role: "editor", Status: model.MembershipStatusActive},
+		&model.TenantMembership{UserUUID: user.UUID, TenantUUID: banned.UUID, Role: "editor", Status: model.MembershipStatusSuspended},
+		&model.TenantMembership{UserUUID: user.UUID, TenantUUID: suspended.UUID, Role: "editor", Status: model.MembershipStatusActive},
+		&model.TenantMembership{UserUUID: policyOwner.UserUUID, TenantUUID: home.UUID, Role: "owner", Status: model.MembershipStatusActive},
+	))
+	s.SetRoleRepo(newFakeRoles(
+		&model.Role{
+			UUID:        "00000000-0000-0000-0000-0000000000f1",
+			TenantUUID:  home.UUID,
+			Name:        "editor",
+			Permissions: []string{"docs:read", "docs:comment"},
+		},
+		&model.Role{
+			UUID:        "00000000-0000-0000-0000-0000000000f2",
+			TenantUUID:  home.UUID,
+			Name:        "owner",
+			Permissions: []string{"docs:*", PermPoliciesWrite},
+		},
+	))
+	policies := newFakeAccessPolicies(
+		&model.AccessPolicy{
+			UUID:        "00000000-0000-0000-0000-0000000000e1",
+			TenantUUID:  home.UUID,
+			Name:        "no-secrets",
+			Effect:      model.PolicyEffectDeny,
+			Permissions: []string{"docs:*"},
+			Condition:   `"classification" in resource.attributes && resource.attributes["classification"] == "secret"`,
+			Enabled:     true,
+		},
+		&model.AccessPolicy{
+			UUID:        "00000000-0000-0000-0000-0000000000e2",
+			TenantUUID:  home.UUID,
+			Name:        "own-drafts",
+			Effect:      model.PolicyEffectAllow,
+			Permissions: []string{"docs:write"},
+			Condition:   `resource.id.startsWith("draft-")`,
+			Enabled:     true,
+		},
+		&model.AccessPolicy{
+			UUID:        "00000000-0000-0000-0000-0000000000e3",
+			TenantUUID:  home.UUID,
+			Name:        "disabled",
+			Effect:      model.PolicyEffectAllow,
+			Permissions: []string{"docs:delete"},
+			Condition:   `true`,
+		},
+	)
+	s.SetAccessPolicyRepo(policies)
+	return s, policies, user
+}
+
+// policyOwner holds every docs permission in the home tenant of newAuthorizationTestService
+var policyOwner = Actor{UserUUID: "00000000-0000-0000-0000-000000000002", TenantUUID: "00000000-0000-0000-0000-0000000000aa", Role: "owner"}
+
+func TestCheckPermission(t *testing.T) {
+	s, _, user := newAuthorizationTestService(t)
+
+	tests := []struct {
+		name        string
+		subject     string
+		tenantRef   string
+		check       PermissionCheck
+		wantAllowed bool
+		wantReason  string
+		wantPolicy  string
+	}{
+		{"granted by role", user.UUID, "", PermissionCheck{Permission: "docs:read", Resource: "doc-1"}, true, DecisionGranted, ""},
+		{"granted in tenant by slug", user.UUID, "home", PermissionCheck{Permission: "docs:comment"}, true, DecisionGranted, ""},
+		{"deny policy overrides role grant", user.UUID, "", PermissionCheck{
+			Permission:         "docs:read",
+			Resource:           "doc-2",
+			ResourceAttributes: map[string]string{"classification": "secret"},
+		}, false, DecisionDeniedByPolicy, "no-secrets"},
+		{"deny policy overrides allow policy", user.UUID, "", PermissionCheck{
+			Permission:         "docs:write",
+			Resource:           "draft-2",
+			ResourceAttributes: map[string]string{"classification": "secret"},
+		}, false, DecisionDeniedByPolicy, "no-secrets"},
+		{"allowed by policy", user.UUID, "", PermissionCheck{Permission: "docs:write", Resource: "draft-1"}, true, DecisionAllowedByPolicy, "own-drafts"},
+		{"not granted", user.UUID, "", PermissionCheck{Permission: "docs:write", Resource: "doc-1"}, false, DecisionNotGranted, ""},
+		{"disabled policy is ignored", user.UUID, "", PermissionCheck{Permission: "docs:delete"}, false, DecisionNotGranted, ""},
+		{"unknown subject", "00000000-0000-0000-0000-000000000099", "", PermissionCheck{Permission: "docs:read"}, false, DecisionSubjectNotFound, ""},
+		{"not a member", user.UUID, "stranger", PermissionCheck{Permission: "docs:read"}, false, DecisionNotMember, ""},
+		{"unknown tenant", user.UUID, "nowhere", PermissionCheck{Permission: "docs:read"}, false, DecisionNotMember, ""},
+		{"suspended membership", user.UUID, "banned", PermissionCheck{Permission: "docs:read"}, false, DecisionMembershipInactive, ""},
+		{"suspended tenant", user.UUID, "suspended", PermissionCheck{Permission: "docs:read"}, false, DecisionTenantInactive, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := s.CheckPermission(context.Background(), tt.subject, tt.tenantRef, tt.check, RequestContext{})
+			if err != nil {
+				t.Fatalf("CheckPermission: %v", err)
+			}
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason || decision.Policy != tt.wantPolicy {
+				t.Fatalf("decision = allowed %v, reason %q, policy %q; want %v, %q, %q",
+					decision.Allowed, decision.Reason, decision.Policy, tt.wantAllowed, tt.wantReason, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	s, _, user := newAuthorizationTestService(t)
+
+	decisions, err := s.CheckPermissions(context.Background(), user.UUID, "", []PermissionCheck{
+		{Permission: "docs:read"},
+		{Permission: "docs:write"},
+		{Permission: "docs:read", ResourceAttributes: map[string]string{"classification": "secret"}},
+	}, RequestContext{})
+	if err != nil {
+		t.Fatalf("CheckPermissions: %v", err)
+	}
+	want := []string{DecisionGranted, DecisionNotGranted, DecisionDeniedByPolicy}
+	if len(decisions) != len(want) {
+		t.Fatalf("got %d decisions, want %d", len(decisions), len(want))
+	}
+	for i, decision := range decisions {
+		if decision.Reason != want[i] || decision.Role != "editor" || decision.TenantID != user.TenantID {
+			t.Fatalf("decision %d = %+v, want reason %q as editor in %s", i, decision, want[i], user.TenantID)
+		}
+	}
+
+	for _, permission := range []string{"docs:*", "*", "not a permission"} {
+		_, err := s.CheckPermissions(context.Background(), user.UUID, "", []PermissionCheck{{Permission: permission}}, RequestContext{})
+		if !errors.Is(err, ErrInvalidPermission) {
+			t.Fatalf("%q: error = %v, want ErrInvalidPermission", permission, err)
+		}
+	}
+	if _, err := s.CheckPermissions(context.Background(), user.UUID, "", nil, RequestContext{}); !errors.Is(err, ErrInvalidPermissionCheck) {
+		t.Fatalf("empty batch: error = %v, want ErrInvalidPermissionCheck", err)
+	}
+}
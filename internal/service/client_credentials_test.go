@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func TestServiceTokenRoundTrip(t *testing.T) {
+	s := NewAuthService(newFakeUsers())
+	ctx := context.Background()
+
+	token, expiresAt, err := IssueServiceToken("billing", []string{"auth.v1.AuthService/CheckPermission", "auth.v1.RelationService"})
+	if err != nil {
+		t.Fatalf("IssueServiceToken: %v", err)
+	}
+	if time.Until(expiresAt) > serviceTokenTTL || time.Until(expiresAt) < serviceTokenTTL-time.Minute {
+		t.Fatalf("token expires at %v, want in %v", expiresAt, serviceTokenTTL)
+	}
+	clientID, scopes, err := s.VerifyServiceToken(ctx, token)
+	if err != nil {
+		t.Fatalf("VerifyServiceToken: %v", err)
+	}
+	if clientID != "billing" || len(scopes) != 2 || scopes[1] != "auth.v1.RelationService" {
+		t.Fatalf("verified %q with %v", clientID, scopes)
+	}
+
+	// Service tokens are not access tokens
+	if valid, _ := s.ValidateToken(ctx, token); valid {
+		t.Fatal("service token was accepted as an access token")
+	}
+}
+
+func TestVerifyServiceTokenRejects(t *testing.T) {
+	s := NewAuthService(newFakeUsers())
+	user := &model.User{UUID: "00000000-0000-0000-0000-000000000001", TenantID: "00000000-0000-0000-0000-0000000000aa", Role: "user"}
+	now := time.Now()
+
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		return token
+	}
+	serviceClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": "billing", "client_id": "billing", "scope": "auth.v1.AuthService", "token_use": tokenUseService, "exp": now.Add(time.Minute).Unix()}
+	}
+	accessToken, err := signAccessToken(accessTokenClaims(user, nil, []string{AMRPassword}, now, time.Minute, ""))
+	if err != nil {
+		t.Fatalf("signAccessToken: %v", err)
+	}
+
+	expired := serviceClaims()
+	expired["exp"] = now.Add(-time.Minute).Unix()
+	noExpiry := serviceClaims()
+	delete(noExpiry, "exp")
+	noClient := serviceClaims()
+	delete(noClient, "client_id")
+	mfa := serviceClaims()
+	mfa["token_use"] = "mfa"
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"user access token", accessToken},
+		{"expired", sign(jwt.SigningMethodHS256, jwtKey, expired)},
+		{"without expiry", sign(jwt.SigningMethodHS256, jwtKey, noExpiry)},
+		{"without client", sign(jwt.SigningMethodHS256, jwtKey, noClient)},
+		{"other token use", sign(jwt.SigningMethodHS256, jwtKey, mfa)},
+		{"other key", sign(jwt.SigningMethodHS256, []byte("other-secret"), serviceClaims())},
+		{"other algorithm", sign(jwt.SigningMethodHS512, jwtKey, serviceClaims())},
+		{"not a token", "billing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := s.VerifyServiceToken(context.Background(), tt.token); !errors.Is(err, ErrInvalidServiceToken) {
+				t.Fatalf("error = %v, want ErrInvalidServiceToken", err)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	const secret = "billing-secret"
+	s := NewAuthService(newFakeUsers())
+	s.SetOAuth(&fakeOAuthClients{clients: map[string]*model.OAuthClient{
+		"billing": {
+			ClientID:   "billing",
+			Type:       model.OAuthClientConfidential,
+			SecretHash: hashOpaqueToken(secret),
+			GrantTypes: []string{GrantTypeClientCredentials},
+			Scopes:     []string{"auth.v1.AuthService/CheckPermission", "auth.v1.RelationService"},
+		},
+		"cli": {ClientID: "cli", Type: model.OAuthClientPublic, GrantTypes: []string{GrantTypeClientCredentials}},
+		"web": {
+			ClientID:   "web",
+			Type:       model.OAuthClientConfidential,
+			SecretHash: hashOpaqueToken(secret),
+			GrantTypes: []string{GrantTypeAuthorizationCode},
+		},
+	}}, newFakeOAuthGrants(), "")
+	ctx := context.Background()
+
+	response, err := s.ExchangeToken(ctx, TokenRequest{GrantType: GrantTypeClientCredentials, ClientID: "billing", ClientSecret: secret, Scope: "auth.v1.RelationService"})
+	if err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+	if response.RefreshToken != "" || response.Scope != "auth.v1.RelationService" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	clientID, scopes, err := s.VerifyServiceToken(ctx, response.AccessToken)
+	if err != nil || clientID != "billing" || len(scopes) != 1 {
+		t.Fatalf("VerifyServiceToken = %q, %v, %v", clientID, scopes, err)
+	}
+
+	tests := []struct {
+		name    string
+		request TokenRequest
+		wantErr string
+	}{
+		{"wrong secret", TokenRequest{ClientID: "billing", ClientSecret: "guess"}, OAuthInvalidClient},
+		{"scope the client lacks", TokenRequest{ClientID: "billing", ClientSecret: secret, Scope: "auth.v1.AuthService"}, OAuthInvalidScope},
+		{"public client", TokenRequest{ClientID: "cli"}, OAuthUnauthorizedClient},
+		{"grant not allowed", TokenRequest{ClientID: "web", ClientSecret: secret}, OAuthUnauthorizedClient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request.GrantType = GrantTypeClientCredentials
+			_, err := s.ExchangeToken(ctx, tt.request)
+			wantOAuthError(t, err, tt.wantErr)
+		})
+	}
+
+	// Authorization requests need the consent page
+	_, err = s.StartAuthorization(ctx, AuthorizationParams{ResponseType: "code", ClientID: "web"})
+	if !errors.Is(err, ErrOAuthDisabled) {
+		t.Fatalf("StartAuthorization error = %v, want ErrOAuthDisabled", err)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// Dynamic client registration error codes (RFC 7591 section 3.2.2)
+const (
+	OAuthInvalidRedirectURI    = "invalid_redirect_uri"
+	OAuthInvalidClientMetadata = "invalid_client_metadata"
+
+	AuditClientRegistered = "oauth_client.register"
+
+	defaultInitialAccessTokenTTL = 7 * 24 * time.Hour
+	maxInitialAccessTokenTTL     = 365 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidInitialAccessToken      = repository.ErrInvalidInitialAccessToken
+	ErrInitialAccessTokenNotFound     = repository.ErrInitialAccessTokenNotFound
+	ErrInvalidInitialAccessTokenInput = errors.New("invalid initial access token settings")
+)
+
+// dynamicGrantTypes are the grants a client may register for itself. Token
+// exchange lets a client act for users, so only administrators can grant it.
+var dynamicGrantTypes = map[string]bool{
+	GrantTypeAuthorizationCode: true,
+	GrantTypeRefreshToken:      true,
+	GrantTypeClientCredentials: true,
+	GrantTypeDeviceCode:        true,
+}
+
+// ClientMetadata is a dynamic client registration request (RFC 7591 section 2)
+type ClientMetadata struct {
+	RedirectURIs            []string
+	PostLogoutRedirectURIs  []string
+	TokenEndpointAuthMethod string // "none" registers a public client
+	GrantTypes              []string
+	ResponseTypes           []string
+	ClientName              string
+	LogoURI                 string
+	Scope                   string
+}
+
+// InitialAccessTokenInput configures a new initial access token
+type InitialAccessTokenInput struct {
+	Description string
+	Scopes      []string      // scopes clients registered with the token may request
+	MaxUses     int           // 0 for unlimited
+	ExpiresIn   time.Duration // defaults to a week
+}
+
+// SetInitialAccessTokenRepo enables dynamic client registration at /oauth2/register
+func (s *AuthService) SetInitialAccessTokenRepo(repo repository.InitialAccessTokenRepository) {
+	s.initialAccessTokenRepo = repo
+}
+
+// ClientRegistrationEnabled reports whether /oauth2/register is served
+func (s *AuthService) ClientRegistrationEnabled() bool {
+	return s.oauthClientRepo != nil && s.initialAccessTokenRepo != nil
+}
+
+// CreateInitialAccessToken issues a token that lets developers register
+// clients themselves. The token is returned only here.
+func (s *AuthService) CreateInitialAccessToken(ctx context.Context, createdBy string, input InitialAccessTokenInput) (*model.OAuthInitialAccessToken, string, error) {
+	if !s.ClientRegistrationEnabled() {
+		return nil, "", ErrOAuthDisabled
+	}
+	description := strings.TrimSpace(input.Description)
+	if len(description) > 255 {
+		return nil, "", fmt.Errorf("%w: description must be at most 255 characters", ErrInvalidInitialAccessTokenInput)
+	}
+	if len(input.Scopes) == 0 || len(input.Scopes) > maxClientScopes {
+		return nil, "", fmt.Errorf("%w: 1-%d scopes are required", ErrInvalidInitialAccessTokenInput, maxClientScopes)
+	}
+	for _, scope := range input.Scopes {
+		if !scopeTokenPattern.MatchString(scope) {
+			return nil, "", fmt.Errorf("%w: invalid scope %q", ErrInvalidInitialAccessTokenInput, scope)
+		}
+	}
+	if input.MaxUses < 0 {
+		return nil, "", fmt.Errorf("%w: max_uses cannot be negative", ErrInvalidInitialAccessTokenInput)
+	}
+	expiresIn := input.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = defaultInitialAccessTokenTTL
+	}
+	if expiresIn < 0 || expiresIn > maxInitialAccessTokenTTL {
+		return nil, "", fmt.Errorf("%w: tokens expire within a year", ErrInvalidInitialAccessTokenInput)
+	}
+
+	raw, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	token := &model.OAuthInitialAccessToken{
+		TokenHash:   hashOpaqueToken(raw),
+		Description: description,
+		Scopes:      dedupe(input.Scopes),
+		MaxUses:     input.MaxUses,
+		ExpiresAt:   time.Now().Add(expiresIn),
+		CreatedBy:   createdBy,
+	}
+	if err := s.initialAccessTokenRepo.CreateToken(ctx, token); err != nil {
+		return nil, "", err
+	}
+	return token, raw, nil
+}
+
+func (s *AuthService) ListInitialAccessTokens(ctx context.Context) ([]model.OAuthInitialAccessToken, error) {
+	if !s.ClientRegistrationEnabled() {
+		return nil, ErrOAuthDisabled
+	}
+	return s.initialAccessTokenRepo.ListTokens(ctx)
+}
+
+// RevokeInitialAccessToken stops a token from registering more clients; clients
+// it already registered keep working
+func (s *AuthService) RevokeInitialAccessToken(ctx context.Context, tokenUUID string) error {
+	if !s.ClientRegistrationEnabled() {
+		return ErrOAuthDisabled
+	}
+	return s.initialAccessTokenRepo.RevokeToken(ctx, tokenUUID)
+}
+
+// RegisterClient implements dynamic client registration (RFC 7591) for the
+// holder of an initial access token. Registered clients are never first party.
+// Invalid metadata is reported as *OAuthError.
+func (s *AuthService) RegisterClient(ctx context.Context, initialAccessToken string, metadata ClientMetadata) (*model.OAuthClient, string, error) {
+	if !s.ClientRegistrationEnabled() {
+		return nil, "", ErrOAuthDisabled
+	}
+	if initialAccessToken == "" {
+		return nil, "", ErrInvalidInitialAccessToken
+	}
+	token, err := s.initialAccessTokenRepo.GetActiveToken(ctx, hashOpaqueToken(initialAccessToken))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var clientType string
+	switch metadata.TokenEndpointAuthMethod {
+	case "", "client_secret_basic", "client_secret_post":
+		clientType = model.OAuthClientConfidential
+	case "none":
+		clientType = model.OAuthClientPublic
+	default:
+		return nil, "", oauthError(OAuthInvalidClientMetadata, "unsupported token_endpoint_auth_method")
+	}
+
+	grantTypes := metadata.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantTypeAuthorizationCode}
+	}
+	for _, grantType := range grantTypes {
+		if !dynamicGrantTypes[grantType] {
+			return nil, "", oauthError(OAuthInvalidClientMetadata, fmt.Sprintf("grant type %q cannot be registered", grantType))
+		}
+	}
+	for _, responseType := range metadata.ResponseTypes {
+		if responseType != "code" || !containsString(grantTypes, GrantTypeAuthorizationCode) {
+			return nil, "", oauthError(OAuthInvalidClientMetadata, fmt.Sprintf("response type %q does not match the grant types", responseType))
+		}
+	}
+
+	scopes := token.Scopes
+	if metadata.Scope != "" {
+		scopes = splitScope(metadata.Scope)
+		for _, scope := range scopes {
+			if !containsString(token.Scopes, scope) {
+				return nil, "", oauthError(OAuthInvalidClientMetadata, fmt.Sprintf("scope %q is not allowed", scope))
+			}
+		}
+	}
+
+	public := clientType == model.OAuthClientPublic
+	for _, redirectURI := range append(append([]string{}, metadata.RedirectURIs...), metadata.PostLogoutRedirectURIs...) {
+		if err := validateRedirectURI(redirectURI, public); err != nil {
+			return nil, "", oauthError(OAuthInvalidRedirectURI, clientErrorDescription(err))
+		}
+	}
+	if strings.TrimSpace(metadata.ClientName) == "" {
+		return nil, "", oauthError(OAuthInvalidClientMetadata, "client_name is required")
+	}
+
+	client := &model.OAuthClient{
+		ClientID:              uuid.New().String(),
+		Type:                  clientType,
+		CreatedBy:             token.CreatedBy,
+		RegistrationTokenUUID: token.UUID,
+	}
+	err = applyOAuthClientInput(client, OAuthClientInput{
+		Name:                   &metadata.ClientName,
+		RedirectURIs:           metadata.RedirectURIs,
+		PostLogoutRedirectURIs: metadata.PostLogoutRedirectURIs,
+		Scopes:                 scopes,
+		GrantTypes:             grantTypes,
+		LogoURI:                &metadata.LogoURI,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidOAuthClient) {
+			return nil, "", oauthError(OAuthInvalidClientMetadata, clientErrorDescription(err))
+		}
+		return nil, "", err
+	}
+
+	secret, err := setOAuthClientSecret(client)
+	if err != nil {
+		return nil, "", err
+	}
+	// The token's use is only counted when the client is created. It fails with
+	// ErrInvalidInitialAccessToken when a concurrent registration used it up.
+	if err := s.initialAccessTokenRepo.RegisterClient(ctx, token.ID, client); err != nil {
+		return nil, "", err
+	}
+
+	err = s.recordAudit(ctx, &model.AuditEvent{
+		Action:    AuditClientRegistered,
+		ActorUUID: token.CreatedBy,
+		Details: map[string]string{
+			"client_id":          client.ClientID,
+			"client_name":        client.Name,
+			"registration_token": token.UUID,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to audit client registration: %v", err)
+	}
+	return client, secret, nil
+}
+
+// clientErrorDescription drops the ErrInvalidOAuthClient prefix from a validation error
+func clientErrorDescription(err error) string {
+	return strings.TrimPrefix(err.Error(), ErrInvalidOAuthClient.Error()+": ")
+}
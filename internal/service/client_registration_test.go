@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+const registrationAdmin = "00000000-0000-0000-0000-000000000001"
+
+// newRegistrationTestService returns a service with dynamic client registration
+// enabled and an initial access token for scopes openid and documents:read
+func newRegistrationTestService(t *testing.T, maxUses int) (*AuthService, *fakeInitialAccessTokens, *fakeAudit, string) {
+	t.Helper()
+	clients := &fakeOAuthClients{clients: map[string]*model.OAuthClient{}}
+	tokens := &fakeInitialAccessTokens{clients: clients}
+	audit := &fakeAudit{}
+
+	s := NewAuthService(newFakeUsers())
+	s.SetOAuth(clients, newFakeOAuthGrants(), testConsentURL)
+	s.SetInitialAccessTokenRepo(tokens)
+	s.SetAuditRepo(audit)
+	_, raw, err := s.CreateInitialAccessToken(context.Background(), registrationAdmin, InitialAccessTokenInput{
+		Description: "partner onboarding",
+		Scopes:      []string{ScopeOpenID, "documents:read"},
+		MaxUses:     maxUses,
+	})
+	if err != nil {
+		t.Fatalf("CreateInitialAccessToken: %v", err)
+	}
+	return s, tokens, audit, raw
+}
+
+func validClientMetadata() ClientMetadata {
+	return ClientMetadata{
+		RedirectURIs: []string{"https://partner.example.com/callback"},
+		ClientName:   "Partner",
+		LogoURI:      "https://partner.example.com/logo.png",
+	}
+}
+
+func TestRegisterClientUseCount(t *testing.T) {
+	s, tokens, audit, raw := newRegistrationTestService(t, 2)
+	ctx := context.Background()
+
+	client, secret, err := s.RegisterClient(ctx, raw, validClientMetadata())
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	if client.FirstParty || client.IsPublic() || secret == "" || client.CreatedBy != registrationAdmin {
+		t.Fatalf("registered client = %+v, secret %q", client, secret)
+	}
+	if client.RegistrationTokenUUID != tokens.tokens[0].UUID || strings.Join(client.Scopes, " ") != "openid documents:read" {
+		t.Fatalf("registered client = %+v, want the token's UUID and scopes", client)
+	}
+	if len(audit.events) != 1 || audit.events[0].Action != AuditClientRegistered || audit.events[0].Details["client_id"] != client.ClientID {
+		t.Fatalf("audit events = %+v", audit.events)
+	}
+
+	// Rejected metadata does not use the token up
+	invalid := validClientMetadata()
+	invalid.ClientName = " "
+	_, _, err = s.RegisterClient(ctx, raw, invalid)
+	wantOAuthError(t, err, OAuthInvalidClientMetadata)
+	if tokens.tokens[0].UseCount != 1 {
+		t.Fatalf("use count = %d after a rejected registration, want 1", tokens.tokens[0].UseCount)
+	}
+
+	public := validClientMetadata()
+	public.TokenEndpointAuthMethod = "none"
+	client, secret, err = s.RegisterClient(ctx, raw, public)
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	if !client.IsPublic() || secret != "" {
+		t.Fatalf("public client = %+v, secret %q", client, secret)
+	}
+
+	if _, _, err := s.RegisterClient(ctx, raw, validClientMetadata()); !errors.Is(err, ErrInvalidInitialAccessToken) {
+		t.Fatalf("third registration: error = %v, want ErrInvalidInitialAccessToken", err)
+	}
+	if tokens.tokens[0].UseCount != 2 {
+		t.Fatalf("use count = %d, want 2", tokens.tokens[0].UseCount)
+	}
+}
+
+func TestRegisterClientInitialAccessToken(t *testing.T) {
+	s, tokens, _, raw := newRegistrationTestService(t, 0)
+	ctx := context.Background()
+
+	for _, token := range []string{"", "unknown", raw + "x"} {
+		if _, _, err := s.RegisterClient(ctx, token, validClientMetadata()); !errors.Is(err, ErrInvalidInitialAccessToken) {
+			t.Fatalf("%q: error = %v, want ErrInvalidInitialAccessToken", token, err)
+		}
+	}
+
+	if err := s.RevokeInitialAccessToken(ctx, tokens.tokens[0].UUID); err != nil {
+		t.Fatalf("RevokeInitialAccessToken: %v", err)
+	}
+	if _, _, err := s.RegisterClient(ctx, raw, validClientMetadata()); !errors.Is(err, ErrInvalidInitialAccessToken) {
+		t.Fatalf("revoked token: error = %v, want ErrInvalidInitialAccessToken", err)
+	}
+	if err := s.RevokeInitialAccessToken(ctx, tokens.tokens[0].UUID); !errors.Is(err, ErrInitialAccessTokenNotFound) {
+		t.Fatalf("second revocation: error = %v, want ErrInitialAccessTokenNotFound", err)
+	}
+}
+
+func TestRegisterClientMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(m *ClientMetadata)
+		wantErr string
+	}{
+		{"token exchange", func(m *ClientMetadata) {
+			m.GrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeTokenExchange}
+		}, OAuthInvalidClientMetadata},
+		{"unknown grant", func(m *ClientMetadata) { m.GrantTypes = []string{"password"} }, OAuthInvalidClientMetadata},
+		{"response type without the code grant", func(m *ClientMetadata) {
+			m.GrantTypes = []string{GrantTypeClientCredentials}
+			m.ResponseTypes = []string{"code"}
+		}, OAuthInvalidClientMetadata},
+		{"implicit response type", func(m *ClientMetadata) { m.ResponseTypes = []string{"token"} }, OAuthInvalidClientMetadata},
+		{"scope outside the token", func(m *ClientMetadata) { m.Scope = "openid documents:write" }, OAuthInvalidClientMetadata},
+		{"unsupported auth method", func(m *ClientMetadata) { m.TokenEndpointAuthMethod = "private_key_jwt" }, OAuthInvalidClientMetadata},
+		{"narrower scope", func(m *ClientMetadata) { m.Scope = "documents:read" }, ""},
+		{"device client", func(m *ClientMetadata) { m.GrantTypes = []string{GrantTypeDeviceCode} }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _, raw := newRegistrationTestService(t, 0)
+			metadata := validClientMetadata()
+			tt.change(&metadata)
+
+			client, _, err := s.RegisterClient(context.Background(), raw, metadata)
+			if tt.wantErr != "" {
+				wantOAuthError(t, err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("RegisterClient: %v", err)
+			}
+			if clientAllowsGrant(client, GrantTypeTokenExchange) {
+				t.Fatal("registered client may exchange tokens")
+			}
+		})
+	}
+}
+
+func TestRegisterClientURIValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		public  bool
+		change  func(m *ClientMetadata)
+		wantErr string
+	}{
+		{"loopback http", false, func(m *ClientMetadata) { m.RedirectURIs = []string{"http://127.0.0.1:8080/callback"} }, ""},
+		{"native app scheme", true, func(m *ClientMetadata) { m.RedirectURIs = []string{"com.example.partner:/callback"} }, ""},
+		{"plain http", false, func(m *ClientMetadata) { m.RedirectURIs = []string{"http://partner.example.com/callback"} }, OAuthInvalidRedirectURI},
+		{"fragment", false, func(m *ClientMetadata) { m.RedirectURIs = []string{"https://partner.example.com/callback#x"} }, OAuthInvalidRedirectURI},
+		{"relative", false, func(m *ClientMetadata) { m.RedirectURIs = []string{"/callback"} }, OAuthInvalidRedirectURI},
+		{"javascript", true, func(m *ClientMetadata) { m.RedirectURIs = []string{"javascript:alert(1)"} }, OAuthInvalidRedirectURI},
+		{"custom scheme for a confidential client", false, func(m *ClientMetadata) { m.RedirectURIs = []string{"com.example.partner:/callback"} }, OAuthInvalidRedirectURI},
+		{"post-logout redirect", false, func(m *ClientMetadata) {
+			m.PostLogoutRedirectURIs = []string{"http://partner.example.com/bye"}
+		}, OAuthInvalidRedirectURI},
+		{"http logo", false, func(m *ClientMetadata) { m.LogoURI = "http://partner.example.com/logo.png" }, OAuthInvalidClientMetadata},
+		{"data logo", false, func(m *ClientMetadata) { m.LogoURI = "data:image/png;base64,AAAA" }, OAuthInvalidClientMetadata},
+		{"overlong logo", false, func(m *ClientMetadata) {
+			m.LogoURI = "https://partner.example.com/" + strings.Repeat("a", maxLogoURIChars)
+		}, OAuthInvalidClientMetadata},
+		{"no logo", false, func(m *ClientMetadata) { m.LogoURI = "" }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tokens, _, raw := newRegistrationTestService(t, 0)
+			metadata := validClientMetadata()
+			if tt.public {
+				metadata.TokenEndpointAuthMethod = "none"
+			}
+			tt.change(&metadata)
+
+			_, _, err := s.RegisterClient(context.Background(), raw, metadata)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("RegisterClient: %v", err)
+				}
+				return
+			}
+			wantOAuthError(t, err, tt.wantErr)
+			if tokens.tokens[0].UseCount != 0 {
+				t.Fatal("rejected registration used the token")
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+	deviceCodeTTL         = 10 * time.Minute
+	devicePollInterval    = 5 // seconds
+	maxUserCodeFailures   = 10
+	userCodeFailureWindow = 15 * time.Minute
+
+	// RFC 8628 section 6.1: consonants only, so codes do not spell words and
+	// cannot be confused with digits
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// Device authorization error codes (RFC 8628 section 3.5)
+const (
+	OAuthAuthorizationPending = "authorization_pending"
+	OAuthSlowDown             = "slow_down"
+	OAuthExpiredToken         = "expired_token"
+)
+
+var (
+	ErrDeviceFlowDisabled = errors.New("device authorization is not configured")
+	ErrDeviceCodeNotFound = repository.ErrDeviceCodeNotFound
+	ErrInvalidDeviceCode  = repository.ErrInvalidDeviceCode
+	ErrDeviceCodeUsed     = repository.ErrDeviceCodeUsed
+)
+
+// DeviceAuthorization is the device authorization response (RFC 8628 section 3.2)
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               int64
+	Interval                int64
+}
+
+// SetDeviceAuthorization enables the device authorization grant. verificationURL
+// is the frontend page where a signed-in user enters the user code; it may
+// receive the code prefilled as ?user_code=.
+func (s *AuthService) SetDeviceAuthorization(verificationURL string) {
+	s.deviceVerificationURL = verificationURL
+}
+
+// DeviceAuthorizationEnabled reports whether /oauth2/device_authorization is served
+func (s *AuthService) DeviceAuthorizationEnabled() bool {
+	return s.oauthGrantRepo != nil && s.deviceVerificationURL != ""
+}
+
+// StartDeviceAuthorization issues a device code the client polls the token
+// endpoint with and a user code the user approves on the verification page.
+// Errors are *OAuthError except for unexpected failures.
+func (s *AuthService) StartDeviceAuthorization(ctx context.Context, clientID, clientSecret, scope string) (*DeviceAuthorization, error) {
+	if !s.DeviceAuthorizationEnabled() {
+		return nil, ErrDeviceFlowDisabled
+	}
+	client, err := s.authenticateOAuthClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !clientAllowsGrant(client, GrantTypeDeviceCode) {
+		return nil, oauthError(OAuthUnauthorizedClient, "client may not use the device authorization grant")
+	}
+	scopes, oauthErr := resolveScopes(client, scope)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	deviceCode, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, err
+	}
+	err = s.oauthGrantRepo.CreateDeviceCode(ctx, &model.OAuthDeviceCode{
+		DeviceCodeHash: hashOpaqueToken(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ClientID,
+		Scope:          strings.Join(scopes, " "),
+		Status:         model.DeviceCodePending,
+		Interval:       devicePollInterval,
+		ExpiresAt:      time.Now().Add(deviceCodeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	display := formatUserCode(userCode)
+	complete, err := withQuery(s.deviceVerificationURL, url.Values{"user_code": {display}})
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         s.deviceVerificationURL,
+		VerificationURIComplete: complete,
+		ExpiresIn:               int64(deviceCodeTTL.Seconds()),
+		Interval:                devicePollInterval,
+	}, nil
+}
+
+// GetDeviceAuthorization describes a pending device authorization to the
+// signed-in user on the verification page
+func (s *AuthService) GetDeviceAuthorization(ctx context.Context, actor Actor, userCode string) (*AuthorizationPrompt, error) {
+	code, client, err := s.pendingDeviceCode(ctx, actor, userCode)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := splitScope(code.Scope)
+	return &AuthorizationPrompt{
+		RequestID:       formatUserCode(code.UserCode),
+		Client:          client,
+		Scopes:          scopes,
+		ConsentRequired: !s.hasConsent(ctx, actor, client, scopes),
+	}, nil
+}
+
+// DecideDeviceAuthorization approves or denies a pending device authorization
+// for the signed-in user. The device picks up the answer on its next poll.
+func (s *AuthService) DecideDeviceAuthorization(ctx context.Context, actor Actor, userCode string, amr []string, authTime time.Time, approve bool) error {
+	code, client, err := s.pendingDeviceCode(ctx, actor, userCode)
+	if err != nil {
+		return err
+	}
+
+	code.Status = model.DeviceCodeDenied
+	if approve {
+		if _, _, _, err := s.oauthGrantUser(ctx, actor.UserUUID, actor.TenantUUID, amr); err != nil {
+			return err
+		}
+		if err := s.recordConsent(ctx, actor, client, splitScope(code.Scope)); err != nil {
+			return err
+		}
+		code.Status = model.DeviceCodeApproved
+		code.UserUUID = actor.UserUUID
+		code.TenantUUID = actor.TenantUUID
+		code.AMR = strings.Join(withMFA(amr), ",")
+		code.AuthTime = &authTime
+	}
+	return s.oauthGrantRepo.DecideDeviceCode(ctx, code)
+}
+
+// pendingDeviceCode looks up an unanswered user code. Failed lookups count
+// against the user so codes cannot be guessed.
+func (s *AuthService) pendingDeviceCode(ctx context.Context, actor Actor, userCode string) (*model.OAuthDeviceCode, *model.OAuthClient, error) {
+	if !s.DeviceAuthorizationEnabled() {
+		return nil, nil, ErrDeviceFlowDisabled
+	}
+	if !s.userCodeLookups.Allowed(actor.UserUUID) {
+		return nil, nil, ErrTooManyAttempts
+	}
+
+	code, err := s.oauthGrantRepo.GetPendingDeviceCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, ErrDeviceCodeNotFound) {
+			s.userCodeLookups.Record(actor.UserUUID)
+		}
+		return nil, nil, err
+	}
+	client, err := s.oauthClientRepo.GetClient(ctx, code.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, nil, errors.New("oauth client not found")
+	}
+	return code, client, nil
+}
+
+// exchangeDeviceCode answers a device's poll (RFC 8628 section 3.4). Polling
+// faster than the interval gets slow_down and a longer interval.
+func (s *AuthService) exchangeDeviceCode(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if req.DeviceCode == "" {
+		return nil, oauthError(OAuthInvalidRequest, "device_code is required")
+	}
+
+	code, err := s.oauthGrantRepo.GetDeviceCode(ctx, hashOpaqueToken(req.DeviceCode))
+	if err != nil {
+		if errors.Is(err, ErrInvalidDeviceCode) {
+			return nil, oauthError(OAuthInvalidGrant, err.Error())
+		}
+		return nil, err
+	}
+	if code.ClientID != client.ClientID {
+		return nil, oauthError(OAuthInvalidGrant, "device code was issued to another client")
+	}
+	now := time.Now()
+	if now.After(code.ExpiresAt) {
+		return nil, oauthError(OAuthExpiredToken, "device code expired")
+	}
+
+	switch code.Status {
+	case model.DeviceCodeDenied:
+		return nil, oauthError(OAuthAccessDenied, "the user denied the request")
+	case model.DeviceCodeConsumed:
+		return nil, oauthError(OAuthInvalidGrant, ErrDeviceCodeUsed.Error())
+	case model.DeviceCodePending:
+		interval := code.Interval
+		tooSoon := code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < time.Duration(interval)*time.Second
+		if tooSoon {
+			interval += devicePollInterval
+		}
+		if err := s.oauthGrantRepo.RecordDevicePoll(ctx, code.ID, now, interval); err != nil {
+			return nil, err
+		}
+		if tooSoon {
+			return nil, oauthError(OAuthSlowDown, "polling too frequently")
+		}
+		return nil, oauthError(OAuthAuthorizationPending, "the user has not answered yet")
+	}
+
+	if err := s.oauthGrantRepo.ConsumeDeviceCode(ctx, code.ID); err != nil {
+		if errors.Is(err, ErrDeviceCodeUsed) {
+			return nil, oauthError(OAuthInvalidGrant, err.Error())
+		}
+		return nil, err
+	}
+	amr := splitAMR(code.AMR)
+	user, tenant, policy, err := s.oauthGrantUser(ctx, code.UserUUID, code.TenantUUID, amr)
+	if err != nil {
+		return nil, oauthError(OAuthInvalidGrant, err.Error())
+	}
+	session, err := s.startSession(ctx, user, amr, policy.SessionLifetime(), client.ClientID)
+	if err != nil {
+		if errors.Is(err, ErrSessionLimitReached) {
+			return nil, oauthError(OAuthInvalidGrant, err.Error())
+		}
+		return nil, err
+	}
+
+	authTime := now
+	if code.AuthTime != nil {
+		authTime = *code.AuthTime
+	}
+	return s.issueOAuthTokens(ctx, client, oauthGrant{
+		user:         user,
+		tenant:       tenant,
+		policy:       policy,
+		session:      session,
+		scope:        code.Scope,
+		refreshScope: code.Scope,
+		amr:          amr,
+		authTime:     authTime,
+	})
+}
+
+// generateUserCode returns a random user code in normalized form
+func generateUserCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode makes typed user codes case-insensitive and drops the dash
+// and any other separators the user may have entered
+func normalizeUserCode(userCode string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(userCode) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// formatUserCode splits a normalized user code in two halves for display, e.g. WDJB-MJHT
+func formatUserCode(userCode string) string {
+	half := len(userCode) / 2
+	return userCode[:half] + "-" + userCode[half:]
+}
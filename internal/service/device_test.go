@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+const deviceTestVerificationURL = "https://app.example.com/device"
+
+// newDeviceTestService returns a service with a public device client "tv" and
+// the actor who approves its codes
+func newDeviceTestService(t *testing.T) (*AuthService, *fakeOAuthGrants, Actor) {
+	t.Helper()
+	user := &model.User{
+		UUID:     "00000000-0000-0000-0000-000000000001",
+		Email:    "ada@example.com",
+		Role:     "user",
+		TenantID: "00000000-0000-0000-0000-0000000000aa",
+	}
+	grants := newFakeOAuthGrants()
+
+	s := NewAuthService(newFakeUsers(user))
+	s.SetOAuth(&fakeOAuthClients{clients: map[string]*model.OAuthClient{
+		"tv": {
+			ClientID:   "tv",
+			Type:       model.OAuthClientPublic,
+			GrantTypes: []string{GrantTypeDeviceCode, GrantTypeRefreshToken},
+			Scopes:     []string{ScopeOfflineAccess, "documents:read"},
+		},
+		"other": {ClientID: "other", Type: model.OAuthClientPublic, GrantTypes: []string{GrantTypeDeviceCode}},
+	}}, grants, testConsentURL)
+	s.SetDeviceAuthorization(deviceTestVerificationURL)
+	s.SetSessionRepo(newFakeSessionStore())
+	return s, grants, Actor{UserUUID: user.UUID, TenantUUID: user.TenantID, Role: user.Role}
+}
+
+func pollDevice(s *AuthService, clientID, deviceCode string) (*TokenResponse, error) {
+	return s.ExchangeToken(context.Background(), TokenRequest{GrantType: GrantTypeDeviceCode, ClientID: clientID, DeviceCode: deviceCode})
+}
+
+func TestDeviceFlow(t *testing.T) {
+	s, grants, actor := newDeviceTestService(t)
+	ctx := context.Background()
+
+	authorization, err := s.StartDeviceAuthorization(ctx, "tv", "", "documents:read offline_access")
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", err)
+	}
+	if !regexp.MustCompile(`^[B-Z]{4}-[B-Z]{4}$`).MatchString(authorization.UserCode) || authorization.Interval != devicePollInterval {
+		t.Fatalf("unexpected authorization: %+v", authorization)
+	}
+	if authorization.VerificationURIComplete != deviceTestVerificationURL+"?user_code="+authorization.UserCode {
+		t.Fatalf("verification_uri_complete = %q", authorization.VerificationURIComplete)
+	}
+
+	_, err = pollDevice(s, "tv", authorization.DeviceCode)
+	wantOAuthError(t, err, OAuthAuthorizationPending)
+
+	// Polling again within the interval slows the device down for good
+	_, err = pollDevice(s, "tv", authorization.DeviceCode)
+	wantOAuthError(t, err, OAuthSlowDown)
+	stored := grants.deviceCode(authorization.UserCode)
+	if stored.Interval != 2*devicePollInterval {
+		t.Fatalf("interval = %d after slow_down, want %d", stored.Interval, 2*devicePollInterval)
+	}
+	waited := time.Now().Add(-time.Duration(stored.Interval) * time.Second)
+	stored.LastPolledAt = &waited
+	_, err = pollDevice(s, "tv", authorization.DeviceCode)
+	wantOAuthError(t, err, OAuthAuthorizationPending)
+
+	_, err = pollDevice(s, "other", authorization.DeviceCode)
+	wantOAuthError(t, err, OAuthInvalidGrant)
+	_, err = pollDevice(s, "tv", "not-a-device-code")
+	wantOAuthError(t, err, OAuthInvalidGrant)
+
+	// The user may type the code in lower case and without the dash
+	typed := strings.ToLower(strings.ReplaceAll(authorization.UserCode, "-", ""))
+	prompt, err := s.GetDeviceAuthorization(ctx, actor, typed)
+	if err != nil {
+		t.Fatalf("GetDeviceAuthorization: %v", err)
+	}
+	if prompt.Client.ClientID != "tv" || len(prompt.Scopes) != 2 {
+		t.Fatalf("unexpected prompt: %+v", prompt)
+	}
+	if err := s.DecideDeviceAuthorization(ctx, actor, typed, []string{AMRPassword}, time.Now(), true); err != nil {
+		t.Fatalf("DecideDeviceAuthorization: %v", err)
+	}
+
+	response, err := pollDevice(s, "tv", authorization.DeviceCode)
+	if err != nil {
+		t.Fatalf("poll after approval: %v", err)
+	}
+	if response.AccessToken == "" || response.RefreshToken == "" || response.Scope != "documents:read offline_access" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	// Only one poll gets tokens
+	_, err = pollDevice(s, "tv", authorization.DeviceCode)
+	wantOAuthError(t, err, OAuthInvalidGrant)
+	if err := s.DecideDeviceAuthorization(ctx, actor, typed, []string{AMRPassword}, time.Now(), true); !errors.Is(err, ErrDeviceCodeNotFound) {
+		t.Fatalf("answering a used code: error = %v, want ErrDeviceCodeNotFound", err)
+	}
+}
+
+func TestDeviceFlowDenied(t *testing.T) {
+	s, _, actor := newDeviceTestService(t)
+	ctx := context.Background()
+
+	authorization, err := s.StartDeviceAuthorization(ctx, "tv", "", "documents:read")
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", err)
+	}
+	if err := s.DecideDeviceAuthorization(ctx, actor, authorization.UserCode, []string{AMRPassword}, time.Now(), false); err != nil {
+		t.Fatalf("DecideDeviceAuthorization: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = pollDevice(s, "tv", authorization.DeviceCode)
+		wantOAuthError(t, err, OAuthAccessDenied)
+	}
+	if err := s.DecideDeviceAuthorization(ctx, actor, authorization.UserCode, []string{AMRPassword}, time.Now(), true); !errors.Is(err, ErrDeviceCodeNotFound) {
+		t.Fatalf("approving a denied code: error = %v, want ErrDeviceCodeNotFound", err)
+	}
+}
+
+func TestDeviceFlowExpired(t *testing.T) {
+	s, grants, actor := newDeviceTestService(t)
+	ctx := context.Background()
+
+	authorization, err := s.StartDeviceAuthorization(ctx, "tv", "", "documents:read")
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", err)
+	}
+	grants.deviceCode(authorization.UserCode).ExpiresAt = time.Now().Add(-time.Second)
+
+	_, err = pollDevice(s, "tv", authorization.DeviceCode)
+	wantOAuthError(t, err, OAuthExpiredToken)
+	if _, err := s.GetDeviceAuthorization(ctx, actor, authorization.UserCode); !errors.Is(err, ErrDeviceCodeNotFound) {
+		t.Fatalf("expired code: error = %v, want ErrDeviceCodeNotFound", err)
+	}
+}
+
+func TestDeviceUserCodeFailureLimit(t *testing.T) {
+	s, _, actor := newDeviceTestService(t)
+	ctx := context.Background()
+
+	authorization, err := s.StartDeviceAuthorization(ctx, "tv", "", "documents:read")
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", err)
+	}
+	for i := 0; i < maxUserCodeFailures; i++ {
+		if _, err := s.GetDeviceAuthorization(ctx, actor, "BBBB-BBBB"); !errors.Is(err, ErrDeviceCodeNotFound) {
+			t.Fatalf("guess %d: error = %v, want ErrDeviceCodeNotFound", i+1, err)
+		}
+	}
+
+	// Once over the limit even the right code is refused, but only for this user
+	if _, err := s.GetDeviceAuthorization(ctx, actor, authorization.UserCode); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("after %d failures: error = %v, want ErrTooManyAttempts", maxUserCodeFailures, err)
+	}
+	if err := s.DecideDeviceAuthorization(ctx, actor, authorization.UserCode, []string{AMRPassword}, time.Now(), true); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("after %d failures: error = %v, want ErrTooManyAttempts", maxUserCodeFailures, err)
+	}
+	other := Actor{UserUUID: "00000000-0000-0000-0000-000000000002", TenantUUID: actor.TenantUUID, Role: "user"}
+	if _, err := s.GetDeviceAuthorization(ctx, other, authorization.UserCode); err != nil {
+		t.Fatalf("another user: %v", err)
+	}
+}
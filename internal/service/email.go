@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailSender delivers transactional email (magic links, alerts, invitations).
+// The core notification service only exposes NotifyUserCreated today, so delivery
+// is pluggable until it grows a generic email RPC. Without a sender every feature
+// that emails a link is disabled; message bodies carry bearer tokens and must
+// never be logged.
+type EmailSender interface {
+	SendEmail(ctx context.Context, to, subject, body string) error
+}
+
+// SMTPEmailSender delivers email through an SMTP relay. The connection is
+// upgraded with STARTTLS when the server offers it, and credentials are only
+// sent over TLS or to localhost.
+type SMTPEmailSender struct {
+	addr     string
+	from     string // From header, e.g. "Example <no-reply@example.com>"
+	envelope string // bare address for MAIL FROM
+	auth     smtp.Auth
+}
+
+// NewSMTPEmailSender configures delivery through host:port as from. username may
+// be empty for relays that do not require authentication.
+func NewSMTPEmailSender(host, port, username, password, from string) (*SMTPEmailSender, error) {
+	if host == "" || from == "" {
+		return nil, errors.New("smtp host and sender address are required")
+	}
+	if port == "" {
+		port = "587"
+	}
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	sender := &SMTPEmailSender{addr: net.JoinHostPort(host, port), from: address.String(), envelope: address.Address}
+	if username != "" {
+		sender.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender, nil
+}
+
+func (s *SMTPEmailSender) SendEmail(ctx context.Context, to, subject, body string) error {
+	// Header values come from users (the address) and must not inject headers
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("invalid email header")
+	}
+	message := "From: " + s.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, s.auth, s.envelope, []string{to}, []byte(message))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("smtp delivery failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetEmailSender sets how transactional email is delivered
+func (s *AuthService) SetEmailSender(sender EmailSender) {
+	s.emailSender = sender
+}
@@ -0,0 +1,716 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+func init() {
+	SetJWTKey("test-secret")
+}
+
+// fakeUsers is an in-memory UserRepository. Methods a test does not need panic
+// through the embedded nil interface.
+type fakeUsers struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[string]*model.User
+}
+
+func newFakeUsers(users ...*model.User) *fakeUsers {
+	f := &fakeUsers{users: map[string]*model.User{}}
+	for _, user := range users {
+		f.users[user.UUID] = user
+	}
+	return f
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, userUUID string) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userUUID]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *fakeUsers) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (f *fakeUsers) UsernameExists(ctx context.Context, username string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeUsers) CreateUser(ctx context.Context, user *model.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.users {
+		if existing.Email == user.Email {
+			return errors.New("email already exists")
+		}
+	}
+	if user.UUID == "" {
+		user.UUID = uuid.New().String()
+	}
+	copied := *user
+	f.users[user.UUID] = &copied
+	return nil
+}
+
+func (f *fakeUsers) MarkEmailVerified(ctx context.Context, userUUID string, verifiedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userUUID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	user.EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
+func (f *fakeUsers) GetByVerifiedPhone(ctx context.Context, phoneNumber string) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.PhoneNumber == phoneNumber && user.PhoneVerifiedAt != nil {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (f *fakeUsers) MarkPhoneVerified(ctx context.Context, userUUID, phoneNumber string, verifiedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userUUID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	user.PhoneNumber = phoneNumber
+	user.PhoneVerifiedAt = &verifiedAt
+	return nil
+}
+
+// fakeTenants is an in-memory TenantRepository
+type fakeTenants struct {
+	repository.TenantRepository
+
+	mu      sync.Mutex
+	tenants map[string]*model.Tenant
+}
+
+func newFakeTenants(tenants ...*model.Tenant) *fakeTenants {
+	f := &fakeTenants{tenants: map[string]*model.Tenant{}}
+	for _, tenant := range tenants {
+		f.tenants[tenant.UUID] = tenant
+	}
+	return f
+}
+
+func (f *fakeTenants) GetByUUID(ctx context.Context, tenantUUID string) (*model.Tenant, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tenant, ok := f.tenants[tenantUUID]
+	if !ok {
+		return nil, repository.ErrTenantNotFound
+	}
+	copied := *tenant
+	return &copied, nil
+}
+
+func (f *fakeTenants) SlugExists(ctx context.Context, slug string) (bool, error) {
+	_, err := f.GetBySlug(ctx, slug)
+	return err == nil, nil
+}
+
+func (f *fakeTenants) GetBySlug(ctx context.Context, slug string) (*model.Tenant, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tenant := range f.tenants {
+		if tenant.Slug == slug {
+			copied := *tenant
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrTenantNotFound
+}
+
+// fakeMemberships is an in-memory MembershipRepository
+type fakeMemberships struct {
+	repository.MembershipRepository
+
+	mu          sync.Mutex
+	memberships []*model.TenantMembership
+}
+
+func newFakeMemberships(memberships ...*model.TenantMembership) *fakeMemberships {
+	return &fakeMemberships{memberships: memberships}
+}
+
+func (f *fakeMemberships) GetMembership(ctx context.Context, userUUID, tenantUUID string) (*model.TenantMembership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, membership := range f.memberships {
+		if membership.UserUUID == userUUID && membership.TenantUUID == tenantUUID {
+			copied := *membership
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrMembershipNotFound
+}
+
+func (f *fakeMemberships) UpdateMembershipRole(ctx context.Context, userUUID, tenantUUID, role string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, membership := range f.memberships {
+		if membership.UserUUID == userUUID && membership.TenantUUID == tenantUUID {
+			membership.Role = role
+			return nil
+		}
+	}
+	return repository.ErrMembershipNotFound
+}
+
+// fakeRoles is an in-memory RoleRepository
+type fakeRoles struct {
+	repository.RoleRepository
+
+	mu    sync.Mutex
+	roles []*model.Role
+}
+
+func newFakeRoles(roles ...*model.Role) *fakeRoles {
+	return &fakeRoles{roles: roles}
+}
+
+func (f *fakeRoles) CreateRole(ctx context.Context, role *model.Role) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.roles {
+		if existing.TenantUUID == role.TenantUUID && existing.Name == role.Name {
+			return errors.New("role already exists")
+		}
+	}
+	role.UUID = uuid.New().String()
+	stored := *role
+	f.roles = append(f.roles, &stored)
+	return nil
+}
+
+func (f *fakeRoles) EnsureRole(ctx context.Context, role *model.Role) error {
+	if err := f.CreateRole(ctx, role); err != nil && err.Error() != "role already exists" {
+		return err
+	}
+	return nil
+}
+
+// FindRole prefers a tenant role over a global one of the same name
+func (f *fakeRoles) FindRole(ctx context.Context, tenantUUID, name string) (*model.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found *model.Role
+	for _, role := range f.roles {
+		if role.Name != name {
+			continue
+		}
+		if role.TenantUUID == tenantUUID {
+			found = role
+			break
+		}
+		if role.TenantUUID == "" {
+			found = role
+		}
+	}
+	if found == nil {
+		return nil, repository.ErrRoleNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
+
+// fakeAccessPolicies is an in-memory AccessPolicyRepository
+type fakeAccessPolicies struct {
+	repository.AccessPolicyRepository
+
+	mu       sync.Mutex
+	policies []*model.AccessPolicy
+}
+
+func newFakeAccessPolicies(policies ...*model.AccessPolicy) *fakeAccessPolicies {
+	return &fakeAccessPolicies{policies: policies}
+}
+
+func (f *fakeAccessPolicies) CreatePolicy(ctx context.Context, policy *model.AccessPolicy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.policies {
+		if existing.TenantUUID == policy.TenantUUID && existing.Name == policy.Name {
+			return errors.New("policy already exists")
+		}
+	}
+	policy.UUID = uuid.New().String()
+	stored := *policy
+	f.policies = append(f.policies, &stored)
+	return nil
+}
+
+func (f *fakeAccessPolicies) GetPolicy(ctx context.Context, tenantUUID, policyUUID string) (*model.AccessPolicy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, policy := range f.policies {
+		if policy.TenantUUID == tenantUUID && policy.UUID == policyUUID {
+			copied := *policy
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("policy not found")
+}
+
+func (f *fakeAccessPolicies) UpdatePolicy(ctx context.Context, policy *model.AccessPolicy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, existing := range f.policies {
+		if existing.UUID == policy.UUID {
+			stored := *policy
+			f.policies[i] = &stored
+			return nil
+		}
+	}
+	return errors.New("policy not found")
+}
+
+func (f *fakeAccessPolicies) ListEnabledPolicies(ctx context.Context, tenantUUID string) ([]model.AccessPolicy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var policies []model.AccessPolicy
+	for _, policy := range f.policies {
+		if policy.TenantUUID == tenantUUID && policy.Enabled {
+			policies = append(policies, *policy)
+		}
+	}
+	return policies, nil
+}
+
+// fakeSessionStore is an in-memory SessionRepository. With max set it rejects
+// logins beyond max active sessions per user.
+type fakeSessionStore struct {
+	mu       sync.Mutex
+	max      int
+	sessions map[string]*model.Session
+}
+
+var _ repository.SessionRepository = (*fakeSessionStore)(nil)
+
+func newFakeSessionStore() *fakeSessionStore {
+	return &fakeSessionStore{sessions: map[string]*model.Session{}}
+}
+
+func (f *fakeSessionStore) CreateSession(ctx context.Context, session *model.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.max > 0 {
+		active := 0
+		for _, existing := range f.sessions {
+			if existing.UserUUID == session.UserUUID && existing.RevokedAt == nil && existing.ExpiresAt.After(time.Now()) {
+				active++
+			}
+		}
+		if active >= f.max {
+			return repository.ErrSessionLimitReached
+		}
+	}
+	session.UUID = uuid.New().String()
+	session.CreatedAt = time.Now()
+	if session.ExpiresAt.IsZero() {
+		session.ExpiresAt = session.CreatedAt.Add(24 * time.Hour)
+	}
+	stored := *session
+	f.sessions[session.UUID] = &stored
+	return nil
+}
+
+func (f *fakeSessionStore) ValidateSession(ctx context.Context, sessionUUID string, now time.Time) (*model.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session, ok := f.sessions[sessionUUID]
+	if !ok || session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return nil, repository.ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (f *fakeSessionStore) ListActiveSessions(ctx context.Context, userUUID string) ([]model.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sessions []model.Session
+	for _, session := range f.sessions {
+		if session.UserUUID == userUUID && session.RevokedAt == nil && session.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (f *fakeSessionStore) RevokeSession(ctx context.Context, userUUID, sessionUUID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session, ok := f.sessions[sessionUUID]
+	if !ok || session.UserUUID != userUUID || session.RevokedAt != nil {
+		return repository.ErrSessionNotFound
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return nil
+}
+
+// fakeAudit records audit events
+type fakeAudit struct {
+	repository.AuditRepository
+
+	mu     sync.Mutex
+	events []model.AuditEvent
+}
+
+func (f *fakeAudit) CreateEvent(ctx context.Context, event *model.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, *event)
+	return nil
+}
+
+// fakeOAuthGrants is an in-memory OAuthGrantRepository that, like the Postgres
+// one, returns a used code or refresh token together with its replay error
+type fakeOAuthGrants struct {
+	repository.OAuthGrantRepository
+
+	mu            sync.Mutex
+	requests      map[string]*model.OAuthAuthorizationRequest
+	codes         map[string]*model.OAuthAuthorizationCode
+	refreshTokens map[string]*model.OAuthRefreshToken
+	deviceCodes   []*model.OAuthDeviceCode
+}
+
+func newFakeOAuthGrants() *fakeOAuthGrants {
+	return &fakeOAuthGrants{
+		requests:      map[string]*model.OAuthAuthorizationRequest{},
+		codes:         map[string]*model.OAuthAuthorizationCode{},
+		refreshTokens: map[string]*model.OAuthRefreshToken{},
+	}
+}
+
+// deviceCode returns the stored device code for a user code so tests can move its clock
+func (f *fakeOAuthGrants) deviceCode(userCode string) *model.OAuthDeviceCode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, code := range f.deviceCodes {
+		if code.UserCode == normalizeUserCode(userCode) {
+			return code
+		}
+	}
+	return nil
+}
+
+func (f *fakeOAuthGrants) CreateAuthorizationRequest(ctx context.Context, request *model.OAuthAuthorizationRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	request.UUID = uuid.New().String()
+	stored := *request
+	f.requests[request.UUID] = &stored
+	return nil
+}
+
+func (f *fakeOAuthGrants) GetAuthorizationRequest(ctx context.Context, requestUUID string) (*model.OAuthAuthorizationRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	request, ok := f.requests[requestUUID]
+	if !ok || request.CompletedAt != nil || !request.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("authorization request not found")
+	}
+	copied := *request
+	return &copied, nil
+}
+
+func (f *fakeOAuthGrants) CompleteAuthorizationRequest(ctx context.Context, requestUUID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	request, ok := f.requests[requestUUID]
+	if !ok || request.CompletedAt != nil {
+		return errors.New("authorization request not found")
+	}
+	now := time.Now()
+	request.CompletedAt = &now
+	return nil
+}
+
+func (f *fakeOAuthGrants) CreateCode(ctx context.Context, code *model.OAuthAuthorizationCode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *code
+	f.codes[code.CodeHash] = &stored
+	return nil
+}
+
+func (f *fakeOAuthGrants) ConsumeCode(ctx context.Context, codeHash string) (*model.OAuthAuthorizationCode, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code, ok := f.codes[codeHash]
+	if !ok || !code.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("invalid or expired authorization code")
+	}
+	copied := *code
+	if code.UsedAt != nil {
+		return &copied, errors.New("authorization code already used")
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	return &copied, nil
+}
+
+func (f *fakeOAuthGrants) SetCodeSession(ctx context.Context, codeHash, sessionUUID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if code, ok := f.codes[codeHash]; ok {
+		code.SessionUUID = sessionUUID
+	}
+	return nil
+}
+
+func (f *fakeOAuthGrants) CreateRefreshToken(ctx context.Context, token *model.OAuthRefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *token
+	f.refreshTokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (f *fakeOAuthGrants) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*model.OAuthRefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.refreshTokens[tokenHash]
+	if !ok || !token.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+	copied := *token
+	if token.UsedAt != nil {
+		return &copied, errors.New("refresh token already used")
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return &copied, nil
+}
+
+// fakeServiceKeys is an in-memory ServiceKeyRepository that counts lookups so
+// tests can tell cached verifications apart
+type fakeServiceKeys struct {
+	repository.ServiceKeyRepository
+
+	mu      sync.Mutex
+	keys    []*model.ServiceKey
+	lookups int
+}
+
+func (f *fakeServiceKeys) CreateKey(ctx context.Context, key *model.ServiceKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key.ID = uint(len(f.keys) + 1)
+	key.UUID = uuid.New().String()
+	key.CreatedAt = time.Now()
+	stored := *key
+	f.keys = append(f.keys, &stored)
+	return nil
+}
+
+func (f *fakeServiceKeys) GetActiveKey(ctx context.Context, keyHash string, now time.Time) (*model.ServiceKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	for _, key := range f.keys {
+		if key.KeyHash == keyHash && key.IsActive(now) {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrServiceKeyNotFound
+}
+
+func (f *fakeServiceKeys) RevokeKey(ctx context.Context, keyUUID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range f.keys {
+		if key.UUID == keyUUID && key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			return nil
+		}
+	}
+	return repository.ErrServiceKeyNotFound
+}
+
+func (f *fakeServiceKeys) MarkUsed(ctx context.Context, keyID uint, usedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range f.keys {
+		if key.ID == keyID {
+			key.LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func (f *fakeOAuthGrants) CreateDeviceCode(ctx context.Context, code *model.OAuthDeviceCode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code.ID = uint(len(f.deviceCodes) + 1)
+	stored := *code
+	f.deviceCodes = append(f.deviceCodes, &stored)
+	return nil
+}
+
+func (f *fakeOAuthGrants) GetDeviceCode(ctx context.Context, deviceCodeHash string) (*model.OAuthDeviceCode, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, code := range f.deviceCodes {
+		if code.DeviceCodeHash == deviceCodeHash {
+			copied := *code
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrInvalidDeviceCode
+}
+
+func (f *fakeOAuthGrants) GetPendingDeviceCode(ctx context.Context, userCode string) (*model.OAuthDeviceCode, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, code := range f.deviceCodes {
+		if code.UserCode == userCode && code.Status == model.DeviceCodePending && code.ExpiresAt.After(time.Now()) {
+			copied := *code
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrDeviceCodeNotFound
+}
+
+func (f *fakeOAuthGrants) DecideDeviceCode(ctx context.Context, code *model.OAuthDeviceCode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, stored := range f.deviceCodes {
+		if stored.ID == code.ID && stored.Status == model.DeviceCodePending && stored.ExpiresAt.After(time.Now()) {
+			stored.Status = code.Status
+			stored.UserUUID = code.UserUUID
+			stored.TenantUUID = code.TenantUUID
+			stored.AMR = code.AMR
+			stored.AuthTime = code.AuthTime
+			return nil
+		}
+	}
+	return repository.ErrDeviceCodeNotFound
+}
+
+func (f *fakeOAuthGrants) RecordDevicePoll(ctx context.Context, id uint, polledAt time.Time, interval int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, code := range f.deviceCodes {
+		if code.ID == id {
+			code.LastPolledAt = &polledAt
+			code.Interval = interval
+		}
+	}
+	return nil
+}
+
+func (f *fakeOAuthGrants) ConsumeDeviceCode(ctx context.Context, id uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, code := range f.deviceCodes {
+		if code.ID == id && code.Status == model.DeviceCodeApproved {
+			code.Status = model.DeviceCodeConsumed
+			return nil
+		}
+	}
+	return repository.ErrDeviceCodeUsed
+}
+
+// fakeInitialAccessTokens is an in-memory InitialAccessTokenRepository that
+// adds registered clients to clients
+type fakeInitialAccessTokens struct {
+	repository.InitialAccessTokenRepository
+
+	mu      sync.Mutex
+	tokens  []*model.OAuthInitialAccessToken
+	clients *fakeOAuthClients
+}
+
+func (f *fakeInitialAccessTokens) CreateToken(ctx context.Context, token *model.OAuthInitialAccessToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token.ID = uint(len(f.tokens) + 1)
+	token.UUID = uuid.New().String()
+	stored := *token
+	f.tokens = append(f.tokens, &stored)
+	return nil
+}
+
+func (f *fakeInitialAccessTokens) GetActiveToken(ctx context.Context, tokenHash string) (*model.OAuthInitialAccessToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, token := range f.tokens {
+		if token.TokenHash == tokenHash && token.IsActive(time.Now()) {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrInvalidInitialAccessToken
+}
+
+func (f *fakeInitialAccessTokens) RevokeToken(ctx context.Context, tokenUUID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, token := range f.tokens {
+		if token.UUID == tokenUUID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return nil
+		}
+	}
+	return repository.ErrInitialAccessTokenNotFound
+}
+
+func (f *fakeInitialAccessTokens) RegisterClient(ctx context.Context, id uint, client *model.OAuthClient) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, token := range f.tokens {
+		if token.ID == id && token.IsActive(time.Now()) {
+			token.UseCount++
+			f.clients.clients[client.ClientID] = client
+			return nil
+		}
+	}
+	return repository.ErrInvalidInitialAccessToken
+}
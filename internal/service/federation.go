@@ -0,0 +1,721 @@
+package service
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	AuditIdentityLinked   = "identity.link"
+	AuditIdentityUnlinked = "identity.unlink"
+
+	federatedLoginTTL        = 10 * time.Minute
+	jwksRefetchInterval      = time.Minute // unknown key IDs trigger at most one JWKS fetch per interval
+	maxProviderResponseBytes = 1 << 20
+	maxFederatedUsernameLen  = 40
+)
+
+var (
+	ErrFederationDisabled          = errors.New("federated login is not configured")
+	ErrUnknownIdentityProvider     = errors.New("unknown identity provider")
+	ErrInvalidFederatedLogin       = errors.New("invalid or expired federated login")
+	ErrIdentityProviderUnavailable = errors.New("identity provider unavailable")
+	ErrFederatedAccountExists      = errors.New("an account with this email already exists; sign in and link the provider from your account")
+	ErrFederatedSignupDisabled     = errors.New("no account is linked to this identity")
+	ErrFederatedEmailRequired      = errors.New("identity provider did not share an email address")
+	ErrIdentityAlreadyLinked       = errors.New("identity already linked")
+	ErrLastIdentity                = errors.New("cannot unlink the only way to sign in")
+)
+
+var (
+	identityProviderIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+	nonUsernameChars          = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// FederatedProvider configures an upstream OpenID Connect provider users may sign in with
+type FederatedProvider struct {
+	ID                 string   `json:"id"`   // used in URLs and stored with linked identities; never change it
+	Name               string   `json:"name"` // shown on the login page
+	Issuer             string   `json:"issuer"`
+	ClientID           string   `json:"client_id"`
+	ClientSecret       string   `json:"client_secret"`
+	Scopes             []string `json:"scopes"`               // defaults to openid email profile
+	TenantSlug         string   `json:"tenant_slug"`          // tenant provisioned users join; the signup default when empty
+	AllowSignup        bool     `json:"allow_signup"`         // create an account on the first login
+	LinkExistingEmails bool     `json:"link_existing_emails"` // link a first login to the account with the same verified email
+}
+
+// identityProvider is a configured provider with its discovered endpoints and signing keys
+type identityProvider struct {
+	FederatedProvider
+	mu            sync.Mutex
+	metadata      *providerMetadata
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+// providerMetadata is the part of a provider's discovery document a login uses
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// federatedClaims are the ID token claims a federated login relies on
+type federatedClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Username      string // preferred_username
+}
+
+// SetFederation enables login through upstream OpenID Connect providers.
+// redirectURL is the frontend page registered with every provider as the
+// redirect URI; it posts the returned code and state to the callback endpoint.
+func (s *AuthService) SetFederation(repo repository.FederationRepository, redirectURL string, providers []FederatedProvider) error {
+	if _, err := url.Parse(redirectURL); err != nil || redirectURL == "" {
+		return fmt.Errorf("invalid federated login redirect url %q", redirectURL)
+	}
+	configured := make([]*identityProvider, 0, len(providers))
+	seen := map[string]bool{}
+	for _, provider := range providers {
+		if !identityProviderIDPattern.MatchString(provider.ID) {
+			return fmt.Errorf("invalid identity provider id %q", provider.ID)
+		}
+		if seen[provider.ID] {
+			return fmt.Errorf("duplicate identity provider id %q", provider.ID)
+		}
+		seen[provider.ID] = true
+
+		issuer, err := url.Parse(provider.Issuer)
+		if err != nil || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" ||
+			(issuer.Scheme != "https" && !(issuer.Scheme == "http" && isLoopbackHost(issuer.Hostname()))) {
+			return fmt.Errorf("identity provider %q: issuer must be an https url", provider.ID)
+		}
+		if provider.ClientID == "" {
+			return fmt.Errorf("identity provider %q: client_id is required", provider.ID)
+		}
+		if provider.Name == "" {
+			provider.Name = provider.ID
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{ScopeOpenID, ScopeEmail, ScopeProfile}
+		}
+		if !containsString(provider.Scopes, ScopeOpenID) {
+			provider.Scopes = append([]string{ScopeOpenID}, provider.Scopes...)
+		}
+		configured = append(configured, &identityProvider{FederatedProvider: provider})
+	}
+
+	s.federationRepo = repo
+	s.federationRedirectURL = redirectURL
+	s.identityProviders = configured
+	if s.httpClient == nil {
+		s.httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return nil
+}
+
+// FederationEnabled reports whether any upstream provider is configured
+func (s *AuthService) FederationEnabled() bool {
+	return s.federationRepo != nil && len(s.identityProviders) > 0
+}
+
+// ListIdentityProviders returns the providers offered on the login page, in configuration order
+func (s *AuthService) ListIdentityProviders() []FederatedProvider {
+	providers := make([]FederatedProvider, 0, len(s.identityProviders))
+	for _, provider := range s.identityProviders {
+		providers = append(providers, provider.FederatedProvider)
+	}
+	return providers
+}
+
+// BeginFederatedLogin returns the URL of the provider's login page
+func (s *AuthService) BeginFederatedLogin(ctx context.Context, providerID string) (string, error) {
+	return s.beginFederation(ctx, providerID, "")
+}
+
+// BeginIdentityLink starts a login at a provider whose identity is then linked to the signed-in user
+func (s *AuthService) BeginIdentityLink(ctx context.Context, userUUID, providerID string) (string, error) {
+	return s.beginFederation(ctx, providerID, userUUID)
+}
+
+// CompleteFederatedLogin finishes a login that returned from the provider with
+// code and state. The identity's linked user is signed in; a first login links
+// the account with the same verified email when the provider allows it, or
+// provisions a new user. The result matches Login.
+func (s *AuthService) CompleteFederatedLogin(ctx context.Context, state, code string) (string, *model.User, error) {
+	provider, claims, err := s.finishFederation(ctx, state, code, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	user, err := s.federatedUser(ctx, provider, claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// A locked-out account stays locked regardless of login method
+	if !s.loginFailures.Allowed(user.Email) {
+		return "", nil, ErrTooManyAttempts
+	}
+	return s.completeLogin(ctx, user, []string{AMRFederated})
+}
+
+// LinkFederatedIdentity finishes a link started with BeginIdentityLink
+func (s *AuthService) LinkFederatedIdentity(ctx context.Context, userUUID, state, code string) (*model.LinkedIdentity, error) {
+	provider, claims, err := s.finishFederation(ctx, state, code, userUUID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+	return s.linkIdentity(ctx, user, provider, claims, "user")
+}
+
+func (s *AuthService) ListLinkedIdentities(ctx context.Context, userUUID string) ([]model.LinkedIdentity, error) {
+	if s.federationRepo == nil {
+		return nil, ErrFederationDisabled
+	}
+	return s.federationRepo.ListIdentities(ctx, userUUID)
+}
+
+// UnlinkIdentity removes a linked identity. Users without a password must keep one.
+func (s *AuthService) UnlinkIdentity(ctx context.Context, userUUID, identityUUID string) error {
+	if s.federationRepo == nil {
+		return ErrFederationDisabled
+	}
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	identities, err := s.federationRepo.ListIdentities(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	var identity *model.LinkedIdentity
+	for i := range identities {
+		if identities[i].UUID == identityUUID {
+			identity = &identities[i]
+		}
+	}
+	if identity == nil {
+		return errors.New("linked identity not found")
+	}
+	if user.PasswordHash == "" && len(identities) == 1 {
+		return ErrLastIdentity
+	}
+
+	if err := s.federationRepo.DeleteIdentity(ctx, userUUID, identityUUID); err != nil {
+		return err
+	}
+	err = s.recordAudit(ctx, &model.AuditEvent{
+		Action:      AuditIdentityUnlinked,
+		ActorUUID:   userUUID,
+		SubjectUUID: userUUID,
+		TenantUUID:  user.TenantID,
+		Details:     map[string]string{"provider": identity.Provider, "subject": identity.Subject},
+	})
+	if err != nil && !errors.Is(err, ErrAuditDisabled) {
+		log.Printf("Failed to audit identity unlink: %v", err)
+	}
+	return nil
+}
+
+func (s *AuthService) identityProvider(providerID string) (*identityProvider, error) {
+	for _, provider := range s.identityProviders {
+		if provider.ID == providerID {
+			return provider, nil
+		}
+	}
+	return nil, ErrUnknownIdentityProvider
+}
+
+// beginFederation stores the state, nonce and PKCE verifier of a new
+// authorization request and returns the provider URL to redirect to
+func (s *AuthService) beginFederation(ctx context.Context, providerID, linkUserUUID string) (string, error) {
+	if !s.FederationEnabled() {
+		return "", ErrFederationDisabled
+	}
+	provider, err := s.identityProvider(providerID)
+	if err != nil {
+		return "", err
+	}
+	metadata, err := s.providerMetadata(ctx, provider)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.federationRepo.SaveState(ctx, &model.FederatedLoginState{
+		StateHash:    hashOpaqueToken(state),
+		Provider:     provider.ID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserUUID: linkUserUUID,
+		ExpiresAt:    time.Now().Add(federatedLoginTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	return withQuery(metadata.AuthorizationEndpoint, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {s.federationRedirectURL},
+		"scope":                 {strings.Join(provider.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	})
+}
+
+// finishFederation redeems the code of a returning authorization request and
+// validates the ID token. A login state cannot complete a link or vice versa.
+func (s *AuthService) finishFederation(ctx context.Context, stateValue, code, linkUserUUID string) (*identityProvider, *federatedClaims, error) {
+	if !s.FederationEnabled() {
+		return nil, nil, ErrFederationDisabled
+	}
+	if stateValue == "" || code == "" {
+		return nil, nil, ErrInvalidFederatedLogin
+	}
+	state, err := s.federationRepo.ConsumeState(ctx, hashOpaqueToken(stateValue))
+	if err != nil {
+		if err.Error() == "invalid or expired login state" {
+			return nil, nil, ErrInvalidFederatedLogin
+		}
+		return nil, nil, err
+	}
+	if state.LinkUserUUID != linkUserUUID {
+		return nil, nil, ErrInvalidFederatedLogin
+	}
+	provider, err := s.identityProvider(state.Provider)
+	if err != nil {
+		return nil, nil, ErrInvalidFederatedLogin
+	}
+
+	idToken, err := s.redeemFederatedCode(ctx, provider, code, state.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := s.verifyFederatedIDToken(ctx, provider, idToken, state.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return provider, claims, nil
+}
+
+// federatedUser finds or creates the user a provider identity signs in as
+func (s *AuthService) federatedUser(ctx context.Context, provider *identityProvider, claims *federatedClaims) (*model.User, error) {
+	identity, err := s.federationRepo.GetIdentity(ctx, provider.ID, claims.Subject)
+	if err == nil {
+		if err := s.federationRepo.RecordIdentityLogin(ctx, identity.ID, truncate(claims.Email, 255), time.Now()); err != nil {
+			log.Printf("Failed to record identity login: %v", err)
+		}
+		return s.repo.GetByID(ctx, identity.UserUUID)
+	}
+	if err.Error() != "linked identity not found" {
+		return nil, err
+	}
+
+	if claims.Email != "" {
+		if existing, err := s.repo.GetByEmail(ctx, claims.Email); err == nil {
+			// Both sides must have verified the address, otherwise whoever
+			// registered it first could take over the other's account
+			if !provider.LinkExistingEmails || !claims.EmailVerified || existing.EmailVerifiedAt == nil {
+				return nil, ErrFederatedAccountExists
+			}
+			if _, err := s.linkIdentity(ctx, existing, provider, claims, "email"); err != nil {
+				return nil, err
+			}
+			return existing, nil
+		}
+	}
+
+	if !provider.AllowSignup {
+		return nil, ErrFederatedSignupDisabled
+	}
+	return s.provisionFederatedUser(ctx, provider, claims)
+}
+
+// provisionFederatedUser creates the account of a first federated login in the
+// provider's tenant, the default tenant or a personal one. The account has no
+// password; an empty hash never matches one.
+func (s *AuthService) provisionFederatedUser(ctx context.Context, provider *identityProvider, claims *federatedClaims) (*model.User, error) {
+	if claims.Email == "" {
+		return nil, ErrFederatedEmailRequired
+	}
+	if s.tenantRepo == nil {
+		return nil, errors.New("tenants not configured")
+	}
+	username, err := s.federatedUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Email:     claims.Email,
+		Username:  username,
+		FirstName: truncate(claims.GivenName, 100),
+		LastName:  truncate(claims.FamilyName, 100),
+		Role:      "user",
+	}
+	if claims.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	tenantSlug := provider.TenantSlug
+	if tenantSlug == "" {
+		tenantSlug = s.defaultTenantSlug
+	}
+	if tenantSlug != "" {
+		tenant, err := s.tenantRepo.GetBySlug(ctx, tenantSlug)
+		if err != nil {
+			return nil, err
+		}
+		if err := tenantPolicyFromSettings(tenant.UUID, tenant.Settings).CheckEmail(user.Email); err != nil {
+			return nil, err
+		}
+		user.TenantID = tenant.UUID
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+	} else {
+		// Like a self-service signup, the user owns their personal tenant
+		user.Role = "admin"
+		slug, err := s.uniqueTenantSlug(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		tenant := &model.Tenant{
+			Slug:   slug,
+			Name:   username,
+			Status: model.TenantStatusActive,
+		}
+		if err := s.repo.CreateUserWithTenant(ctx, user, tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.linkIdentity(ctx, user, provider, claims, "signup"); err != nil {
+		return nil, err
+	}
+	if provider.TenantSlug == "" {
+		s.joinTenantByDomain(ctx, user)
+	}
+	if s.coreNotificationClient != nil {
+		go s.sendNotification(user.UUID, user.Email, user.Username)
+	}
+	return user, nil
+}
+
+// linkIdentity records that the provider identity signs in as user. method
+// ("user", "email" or "signup") says how the link came about for the audit log.
+func (s *AuthService) linkIdentity(ctx context.Context, user *model.User, provider *identityProvider, claims *federatedClaims, method string) (*model.LinkedIdentity, error) {
+	now := time.Now()
+	identity := &model.LinkedIdentity{
+		UserUUID:    user.UUID,
+		Provider:    provider.ID,
+		Subject:     claims.Subject,
+		Email:       truncate(claims.Email, 255),
+		LastLoginAt: &now,
+	}
+	if err := s.federationRepo.CreateIdentity(ctx, identity); err != nil {
+		if err.Error() == "identity already linked" {
+			return nil, ErrIdentityAlreadyLinked
+		}
+		return nil, err
+	}
+
+	err := s.recordAudit(ctx, &model.AuditEvent{
+		Action:      AuditIdentityLinked,
+		ActorUUID:   user.UUID,
+		SubjectUUID: user.UUID,
+		TenantUUID:  user.TenantID,
+		Details: map[string]string{
+			"provider": provider.ID,
+			"subject":  claims.Subject,
+			"method":   method,
+		},
+	})
+	if err != nil && !errors.Is(err, ErrAuditDisabled) {
+		log.Printf("Failed to audit identity link: %v", err)
+	}
+	return identity, nil
+}
+
+// federatedUsername derives an unused username from preferred_username or the email
+func (s *AuthService) federatedUsername(ctx context.Context, claims *federatedClaims) (string, error) {
+	name := claims.Username
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base := strings.Trim(nonUsernameChars.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+	base = truncate(base, maxFederatedUsernameLen)
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	username := base
+	for i := 2; ; i++ {
+		exists, err := s.repo.UsernameExists(ctx, username)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+		if i > 999 {
+			return "", errors.New("could not generate a unique username")
+		}
+		username = base + "-" + strconv.Itoa(i)
+	}
+}
+
+// providerMetadata returns the provider's discovery document, fetched once (OIDC Discovery section 4)
+func (s *AuthService) providerMetadata(ctx context.Context, provider *identityProvider) (*providerMetadata, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.metadata != nil {
+		return provider.metadata, nil
+	}
+
+	var metadata providerMetadata
+	if err := s.fetchProviderJSON(ctx, strings.TrimSuffix(provider.Issuer, "/")+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, err
+	}
+	// The document must be about the issuer it was fetched for (section 4.3)
+	if metadata.Issuer != provider.Issuer {
+		return nil, fmt.Errorf("%w: discovery document names issuer %q", ErrIdentityProviderUnavailable, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is missing endpoints", ErrIdentityProviderUnavailable)
+	}
+	provider.metadata = &metadata
+	return provider.metadata, nil
+}
+
+// providerSigningKey returns the provider key an ID token names in its kid
+// header. The JWKS is refetched for unknown key IDs so provider key rotation
+// needs no restart.
+func (s *AuthService) providerSigningKey(ctx context.Context, provider *identityProvider, kid string) (*rsa.PublicKey, error) {
+	metadata, err := s.providerMetadata(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if key := pickSigningKey(provider.keys, kid); key != nil {
+		return key, nil
+	}
+	if provider.keys != nil && time.Since(provider.keysFetchedAt) < jwksRefetchInterval {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			KeyType  string `json:"kty"`
+			Use      string `json:"use"`
+			KeyID    string `json:"kid"`
+			Modulus  string `json:"n"`
+			Exponent string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := s.fetchProviderJSON(ctx, metadata.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[jwk.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	provider.keys = keys
+	provider.keysFetchedAt = time.Now()
+
+	if key := pickSigningKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// pickSigningKey finds a key by ID; tokens without a kid may only use a sole key
+func pickSigningKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+// redeemFederatedCode exchanges an authorization code at the provider's token
+// endpoint and returns the ID token
+func (s *AuthService) redeemFederatedCode(ctx context.Context, provider *identityProvider, code, verifier string) (string, error) {
+	metadata, err := s.providerMetadata(ctx, provider)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {s.federationRedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIdentityProviderUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic form-encodes the credentials first (RFC 6749 section 2.3.1)
+	req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIdentityProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, maxProviderResponseBytes)).Decode(&body)
+	switch {
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized:
+		// A bad or reused code is the caller's problem, not an outage
+		log.Printf("Identity provider %s rejected an authorization code: %s %s", provider.ID, body.Error, body.ErrorDescription)
+		return "", ErrInvalidFederatedLogin
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%w: token endpoint returned %s", ErrIdentityProviderUnavailable, resp.Status)
+	case decodeErr != nil || body.IDToken == "":
+		return "", fmt.Errorf("%w: token response has no id_token", ErrIdentityProviderUnavailable)
+	}
+	return body.IDToken, nil
+}
+
+// verifyFederatedIDToken validates an ID token from the token endpoint (OIDC Core section 3.1.3.7)
+func (s *AuthService) verifyFederatedIDToken(ctx context.Context, provider *identityProvider, idToken, nonce string) (*federatedClaims, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.providerSigningKey(ctx, provider, kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(provider.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute))
+	if err != nil || !token.Valid {
+		if errors.Is(err, ErrIdentityProviderUnavailable) {
+			return nil, ErrIdentityProviderUnavailable
+		}
+		log.Printf("Rejected ID token from identity provider %s: %v", provider.ID, err)
+		return nil, ErrInvalidFederatedLogin
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidFederatedLogin
+	}
+
+	// With several audiences, or an azp at all, this client must be the authorized party
+	audience, _ := claims.GetAudience()
+	azp, hasAZP := claims["azp"].(string)
+	if (len(audience) > 1 || hasAZP) && azp != provider.ClientID {
+		return nil, ErrInvalidFederatedLogin
+	}
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, ErrInvalidFederatedLogin
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" || len(subject) > 255 {
+		return nil, ErrInvalidFederatedLogin
+	}
+
+	result := &federatedClaims{Subject: subject}
+	result.Email, _ = claims["email"].(string)
+	result.Email = strings.TrimSpace(result.Email)
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+	result.GivenName, _ = claims["given_name"].(string)
+	result.FamilyName, _ = claims["family_name"].(string)
+	result.Username, _ = claims["preferred_username"].(string)
+	return result, nil
+}
+
+// fetchProviderJSON GETs a provider document into v
+func (s *AuthService) fetchProviderJSON(ctx context.Context, documentURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIdentityProviderUnavailable, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIdentityProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %s", ErrIdentityProviderUnavailable, documentURL, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxProviderResponseBytes)).Decode(v); err != nil {
+		return fmt.Errorf("%w: invalid document at %s", ErrIdentityProviderUnavailable, documentURL)
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	testProviderID     = "acme"
+	testProviderClient = "auth-service"
+	testProviderSecret = "provider-secret"
+	testTenantUUID     = "00000000-0000-0000-0000-0000000000aa"
+)
+
+// fakeIssuer is an upstream OpenID Connect provider. The token endpoint checks
+// the client credentials and PKCE verifier, then returns an ID token built from
+// the claims of the pending login.
+type fakeIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	signer    *rsa.PrivateKey // signs ID tokens; key unless a test swaps it
+	claims    jwt.MapClaims
+	challenge string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	issuer := &fakeIssuer{key: key, signer: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.server.URL,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": "key-1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (f *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	clientID, secret, _ := r.BasicAuth()
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if clientID != testProviderClient || secret != testProviderSecret ||
+		r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != "code-1" ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != f.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, f.claims)
+	token.Header["kid"] = "key-1"
+	idToken, err := token.SignedString(f.signer)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+}
+
+// fakeFederation is an in-memory FederationRepository
+type fakeFederation struct {
+	mu         sync.Mutex
+	states     map[string]*model.FederatedLoginState
+	identities []*model.LinkedIdentity
+}
+
+var _ repository.FederationRepository = (*fakeFederation)(nil)
+
+func (f *fakeFederation) SaveState(ctx context.Context, state *model.FederatedLoginState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[state.StateHash] = state
+	return nil
+}
+
+func (f *fakeFederation) ConsumeState(ctx context.Context, stateHash string) (*model.FederatedLoginState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, ok := f.states[stateHash]
+	if !ok || state.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("invalid or expired login state")
+	}
+	delete(f.states, stateHash)
+	return state, nil
+}
+
+func (f *fakeFederation) GetIdentity(ctx context.Context, provider, subject string) (*model.LinkedIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, identity := range f.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, errors.New("linked identity not found")
+}
+
+func (f *fakeFederation) ListIdentities(ctx context.Context, userUUID string) ([]model.LinkedIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var identities []model.LinkedIdentity
+	for _, identity := range f.identities {
+		if identity.UserUUID == userUUID {
+			identities = append(identities, *identity)
+		}
+	}
+	return identities, nil
+}
+
+func (f *fakeFederation) CreateIdentity(ctx context.Context, identity *model.LinkedIdentity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return errors.New("identity already linked")
+		}
+	}
+	identity.ID = uint(len(f.identities) + 1)
+	f.identities = append(f.identities, identity)
+	return nil
+}
+
+func (f *fakeFederation) RecordIdentityLogin(ctx context.Context, identityID uint, email string, at time.Time) error {
+	return nil
+}
+
+func (f *fakeFederation) DeleteIdentity(ctx context.Context, userUUID, identityUUID string) error {
+	return errors.New("linked identity not found")
+}
+
+type federationTest struct {
+	service    *AuthService
+	issuer     *fakeIssuer
+	users      *fakeUsers
+	federation *fakeFederation
+}
+
+func newFederationTest(t *testing.T, provider FederatedProvider, users ...*model.User) *federationTest {
+	t.Helper()
+	issuer := newFakeIssuer(t)
+	test := &federationTest{
+		issuer:     issuer,
+		users:      newFakeUsers(users...),
+		federation: &fakeFederation{states: map[string]*model.FederatedLoginState{}},
+	}
+	test.service = NewAuthService(test.users)
+	test.service.SetTenantRepo(newFakeTenants(&model.Tenant{
+		UUID:   testTenantUUID,
+		Slug:   "acme",
+		Status: model.TenantStatusActive,
+	}), "acme")
+
+	provider.ID = testProviderID
+	provider.Issuer = issuer.server.URL
+	provider.ClientID = testProviderClient
+	provider.ClientSecret = testProviderSecret
+	if err := test.service.SetFederation(test.federation, "https://app.example.com/federated/callback", []FederatedProvider{provider}); err != nil {
+		t.Fatalf("SetFederation: %v", err)
+	}
+	return test
+}
+
+// login runs a federated login whose ID token has the provider's usual claims
+// for subject, changed by edit
+func (f *federationTest) login(t *testing.T, subject, email string, edit func(jwt.MapClaims)) (string, *model.User, error) {
+	t.Helper()
+	ctx := context.Background()
+	authorizationURL, err := f.service.BeginFederatedLogin(ctx, testProviderID)
+	if err != nil {
+		t.Fatalf("BeginFederatedLogin: %v", err)
+	}
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("authorization url: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != testProviderClient || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization url %q", authorizationURL)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            f.issuer.server.URL,
+		"aud":            testProviderClient,
+		"sub":            subject,
+		"email":          email,
+		"email_verified": true,
+		"nonce":          query.Get("nonce"),
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+	if edit != nil {
+		edit(claims)
+	}
+	f.issuer.mu.Lock()
+	f.issuer.claims = claims
+	f.issuer.challenge = query.Get("code_challenge")
+	f.issuer.mu.Unlock()
+
+	return f.service.CompleteFederatedLogin(ctx, query.Get("state"), "code-1")
+}
+
+func TestFederatedLoginProvisionsUser(t *testing.T) {
+	test := newFederationTest(t, FederatedProvider{AllowSignup: true})
+	ctx := context.Background()
+
+	token, user, err := test.login(t, "subject-1", "grace@example.com", func(claims jwt.MapClaims) {
+		claims["preferred_username"] = "Grace.Hopper"
+		claims["given_name"] = "Grace"
+	})
+	if err != nil {
+		t.Fatalf("CompleteFederatedLogin: %v", err)
+	}
+	if user.Email != "grace@example.com" || user.Username != "grace.hopper" || user.TenantID != testTenantUUID || user.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected provisioned user %+v", user)
+	}
+	if valid, validated := test.service.ValidateToken(ctx, token); !valid || validated.UUID != user.UUID {
+		t.Fatal("issued token does not validate for the provisioned user")
+	}
+	identities, _ := test.federation.ListIdentities(ctx, user.UUID)
+	if len(identities) != 1 || identities[0].Provider != testProviderID || identities[0].Subject != "subject-1" {
+		t.Fatalf("identities = %+v, want the provider identity", identities)
+	}
+
+	// The next login signs in as the same user
+	_, again, err := test.login(t, "subject-1", "grace@example.com", nil)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.UUID != user.UUID || len(test.users.users) != 1 {
+		t.Fatalf("second login created another user")
+	}
+}
+
+func TestFederatedLoginSignupDisabled(t *testing.T) {
+	test := newFederationTest(t, FederatedProvider{})
+
+	if _, _, err := test.login(t, "subject-1", "grace@example.com", nil); !errors.Is(err, ErrFederatedSignupDisabled) {
+		t.Fatalf("error = %v, want ErrFederatedSignupDisabled", err)
+	}
+	if len(test.users.users) != 0 {
+		t.Fatal("a user was created")
+	}
+}
+
+func TestFederatedLoginRejectsInvalidIDTokens(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tests := map[string]struct {
+		edit   func(jwt.MapClaims)
+		signer *rsa.PrivateKey
+	}{
+		"bad signature":  {signer: otherKey},
+		"wrong audience": {edit: func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		"nonce mismatch": {edit: func(claims jwt.MapClaims) { claims["nonce"] = "replayed" }},
+		"wrong issuer":   {edit: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }},
+		"expired":        {edit: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		"foreign azp": {edit: func(claims jwt.MapClaims) {
+			claims["aud"] = []string{testProviderClient, "another-client"}
+			claims["azp"] = "another-client"
+		}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			test := newFederationTest(t, FederatedProvider{AllowSignup: true})
+			if tt.signer != nil {
+				test.issuer.signer = tt.signer
+			}
+			if _, _, err := test.login(t, "subject-1", "grace@example.com", tt.edit); !errors.Is(err, ErrInvalidFederatedLogin) {
+				t.Fatalf("error = %v, want ErrInvalidFederatedLogin", err)
+			}
+			if len(test.users.users) != 0 {
+				t.Fatal("a user was created")
+			}
+		})
+	}
+}
+
+func TestFederatedLoginStateIsSingleUse(t *testing.T) {
+	test := newFederationTest(t, FederatedProvider{AllowSignup: true})
+	ctx := context.Background()
+
+	authorizationURL, err := test.service.BeginFederatedLogin(ctx, testProviderID)
+	if err != nil {
+		t.Fatalf("BeginFederatedLogin: %v", err)
+	}
+	parsed, _ := url.Parse(authorizationURL)
+	state := parsed.Query().Get("state")
+
+	// A code the provider rejects still uses up the state
+	if _, _, err := test.service.CompleteFederatedLogin(ctx, state, "wrong-code"); !errors.Is(err, ErrInvalidFederatedLogin) {
+		t.Fatalf("error = %v, want ErrInvalidFederatedLogin", err)
+	}
+	if _, _, err := test.service.CompleteFederatedLogin(ctx, state, "code-1"); !errors.Is(err, ErrInvalidFederatedLogin) {
+		t.Fatalf("reused state: error = %v, want ErrInvalidFederatedLogin", err)
+	}
+}
+
+func TestFederatedLoginLinksExistingEmails(t *testing.T) {
+	verifiedAt := time.Now().Add(-time.Hour)
+	existing := func() *model.User {
+		return &model.User{
+			UUID:            "00000000-0000-0000-0000-000000000001",
+			Email:           "grace@example.com",
+			Username:        "grace",
+			Role:            "user",
+			TenantID:        testTenantUUID,
+			EmailVerifiedAt: &verifiedAt,
+		}
+	}
+
+	t.Run("unverified provider email", func(t *testing.T) {
+		test := newFederationTest(t, FederatedProvider{AllowSignup: true, LinkExistingEmails: true}, existing())
+		_, _, err := test.login(t, "subject-1", "grace@example.com", func(claims jwt.MapClaims) {
+			claims["email_verified"] = false
+		})
+		if !errors.Is(err, ErrFederatedAccountExists) {
+			t.Fatalf("error = %v, want ErrFederatedAccountExists", err)
+		}
+		if len(test.federation.identities) != 0 {
+			t.Fatal("identity was linked to the existing account")
+		}
+	})
+
+	t.Run("linking not enabled", func(t *testing.T) {
+		test := newFederationTest(t, FederatedProvider{AllowSignup: true}, existing())
+		if _, _, err := test.login(t, "subject-1", "grace@example.com", nil); !errors.Is(err, ErrFederatedAccountExists) {
+			t.Fatalf("error = %v, want ErrFederatedAccountExists", err)
+		}
+	})
+
+	t.Run("unverified account email", func(t *testing.T) {
+		user := existing()
+		user.EmailVerifiedAt = nil
+		test := newFederationTest(t, FederatedProvider{AllowSignup: true, LinkExistingEmails: true}, user)
+		if _, _, err := test.login(t, "subject-1", "grace@example.com", nil); !errors.Is(err, ErrFederatedAccountExists) {
+			t.Fatalf("error = %v, want ErrFederatedAccountExists", err)
+		}
+	})
+
+	t.Run("both verified", func(t *testing.T) {
+		test := newFederationTest(t, FederatedProvider{AllowSignup: true, LinkExistingEmails: true}, existing())
+		_, user, err := test.login(t, "subject-1", "grace@example.com", nil)
+		if err != nil {
+			t.Fatalf("CompleteFederatedLogin: %v", err)
+		}
+		if user.UUID != existing().UUID || len(test.federation.identities) != 1 {
+			t.Fatalf("login did not link the existing account")
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/middleware"
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+// newImpersonationTestService returns a service where customer is an admin of
+// their tenant and staff a platform user who impersonates them
+func newImpersonationTestService(t *testing.T) (*AuthService, *fakeAudit, Actor, *model.User) {
+	t.Helper()
+	customer := &model.User{
+		UUID:     "00000000-0000-0000-0000-000000000001",
+		Email:    "ada@example.com",
+		Role:     "admin",
+		TenantID: "00000000-0000-0000-0000-0000000000aa",
+	}
+	staff := Actor{UserUUID: "00000000-0000-0000-0000-000000000002", TenantUUID: "00000000-0000-0000-0000-0000000000bb", Role: "superadmin"}
+
+	s := NewAuthService(newFakeUsers(customer))
+	s.SetRoleRepo(newFakeRoles())
+	if err := s.EnsureBuiltinRoles(context.Background()); err != nil {
+		t.Fatalf("EnsureBuiltinRoles: %v", err)
+	}
+	audit := &fakeAudit{}
+	s.SetAuditRepo(audit)
+	return s, audit, staff, customer
+}
+
+func impersonate(t *testing.T, s *AuthService, staff Actor, customer *model.User) string {
+	t.Helper()
+	token, _, err := s.Impersonate(context.Background(), staff, "", []string{AMRPassword}, time.Now(), ImpersonationInput{
+		UserUUID: customer.UUID,
+		Reason:   "ticket 4711: cannot see the members page",
+	})
+	if err != nil {
+		t.Fatalf("Impersonate: %v", err)
+	}
+	return token
+}
+
+func TestImpersonationTokenScope(t *testing.T) {
+	s, audit, staff, customer := newImpersonationTestService(t)
+	token := impersonate(t, s, staff, customer)
+
+	_, info, valid := s.InspectToken(context.Background(), token, "", nil)
+	if !valid {
+		t.Fatal("impersonation token was rejected")
+	}
+	if strings.Join(info.Scopes, " ") != strings.Join(defaultImpersonationScopes, " ") || info.ActorUserUUID != staff.UserUUID {
+		t.Fatalf("unexpected token info: %+v", info)
+	}
+	if len(audit.events) != 1 || audit.events[0].Details["scope"] != strings.Join(defaultImpersonationScopes, " ") {
+		t.Fatalf("audit events = %+v, want the start with its scope", audit.events)
+	}
+
+	// The customer is an admin, but the token only covers the impersonation scopes
+	gin.SetMode(gin.TestMode)
+	middleware.SetJWTKey("test-secret")
+	middleware.SetPermissionChecker(s)
+	t.Cleanup(func() { middleware.SetPermissionChecker(nil) })
+	router := gin.New()
+	router.Use(middleware.AuthMiddleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/members", middleware.RequirePermission(PermMembersRead), ok)
+	router.POST("/members", middleware.RequirePermission(PermMembersWrite), ok)
+
+	for _, tt := range []struct {
+		method   string
+		want     int
+		wantBody string
+	}{
+		{http.MethodGet, http.StatusNoContent, ""},
+		{http.MethodPost, http.StatusForbidden, "insufficient scope"},
+	} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(tt.method, "/members", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != tt.want || !strings.Contains(recorder.Body.String(), tt.wantBody) {
+			t.Fatalf("%s /members = %d %s, want %d", tt.method, recorder.Code, recorder.Body, tt.want)
+		}
+	}
+}
+
+func TestSetImpersonationScopes(t *testing.T) {
+	s, audit, staff, customer := newImpersonationTestService(t)
+
+	for _, scopes := range [][]string{nil, {"members:*"}, {"*"}, {PermUsersImpersonate}, {"Members:Read"}} {
+		if err := s.SetImpersonationScopes(scopes); !errors.Is(err, ErrInvalidPermission) {
+			t.Fatalf("%v: error = %v, want ErrInvalidPermission", scopes, err)
+		}
+	}
+	if err := s.SetImpersonationScopes([]string{PermMembersRead, PermMembersWrite, PermMembersRead}); err != nil {
+		t.Fatalf("SetImpersonationScopes: %v", err)
+	}
+
+	token := impersonate(t, s, staff, customer)
+	_, info, valid := s.InspectToken(context.Background(), token, "", []string{PermMembersWrite})
+	if !valid || len(info.Scopes) != 2 {
+		t.Fatalf("token info = %+v, valid %v; want members:read and members:write", info, valid)
+	}
+	if _, _, valid := s.InspectToken(context.Background(), token, "", []string{PermTenantRead}); valid {
+		t.Fatal("token covers a scope outside the configured list")
+	}
+	if audit.events[0].Details["scope"] != "members:read members:write" {
+		t.Fatalf("audited scope = %q", audit.events[0].Details["scope"])
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var ErrInvitationsDisabled = errors.New("invitations are not configured")
+
+// InvitationDetails describes a pending invitation to the person holding its token
+type InvitationDetails struct {
+	Invitation   *model.TenantInvitation
+	Tenant       *model.Tenant
+	ExistingUser bool // the invited email already has an account (accept after signing in)
+}
+
+// SetInvitations enables tenant invitations. baseURL is the page that receives
+// the invitation token (as ?token=...).
+func (s *AuthService) SetInvitations(repo repository.InvitationRepository, baseURL string) {
+	s.invitationRepo = repo
+	s.invitationURL = baseURL
+}
+
+// InviteToTenant emails a single-use invitation to join the actor's tenant with
+// role. The actor may only hand out roles within their own permissions.
+func (s *AuthService) InviteToTenant(ctx context.Context, actor Actor, email, role string) (*model.TenantInvitation, error) {
+	if !s.invitationsEnabled() {
+		return nil, ErrInvitationsDisabled
+	}
+
+	email = strings.TrimSpace(email)
+	if role == "" {
+		role = "user"
+	}
+	if s.roleRepo != nil {
+		if err := s.checkRoleGrant(ctx, actor, role); err != nil {
+			if errors.Is(err, ErrRoleNotFound) {
+				return nil, errors.New("invalid role")
+			}
+			return nil, err
+		}
+	} else if role != "user" && role != "admin" {
+		return nil, errors.New("invalid role")
+	}
+
+	tenant, err := s.tenantRepo.GetByUUID(ctx, actor.TenantUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !tenant.IsActive() {
+		return nil, ErrTenantInactive
+	}
+	if err := tenantPolicyFromSettings(tenant.UUID, tenant.Settings).CheckEmail(email); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.repo.GetByEmail(ctx, email); err == nil && s.membershipRepo != nil {
+		if _, err := s.membershipRepo.GetMembership(ctx, existing.UUID, tenant.UUID); err == nil {
+			return nil, errors.New("user is already a member")
+		}
+	}
+
+	return s.sendInvitation(ctx, tenant, actor.UserUUID, email, role,
+		"You're invited to join "+tenant.Name,
+		"You have been invited to join "+tenant.Name+".")
+}
+
+// sendInvitation stores an invitation and emails its link. intro is the first
+// sentence of the email.
+func (s *AuthService) sendInvitation(ctx context.Context, tenant *model.Tenant, inviterUUID, email, role, subject, intro string) (*model.TenantInvitation, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	invitation := &model.TenantInvitation{
+		TenantUUID: tenant.UUID,
+		Email:      email,
+		Role:       role,
+		TokenHash:  hashOpaqueToken(token),
+		InvitedBy:  inviterUUID,
+		ExpiresAt:  time.Now().Add(invitationTTL),
+	}
+	if err := s.invitationRepo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	link, err := buildTokenLink(s.invitationURL, token)
+	if err != nil {
+		return nil, err
+	}
+	body := fmt.Sprintf("%s The invitation expires in %d days and can only be used once.\n\n%s\n\nIf you were not expecting this, you can ignore this email.",
+		intro, int(invitationTTL.Hours()/24), link)
+	if err := s.emailSender.SendEmail(ctx, email, subject, body); err != nil {
+		log.Printf("Failed to send invitation email: %v", err)
+		// Nobody holds the token, so do not leave an undeliverable invitation pending
+		if err := s.invitationRepo.RevokeInvitation(ctx, tenant.UUID, invitation.UUID); err != nil {
+			log.Printf("Failed to revoke undelivered invitation: %v", err)
+		}
+		return nil, errors.New("failed to send invitation")
+	}
+	return invitation, nil
+}
+
+// invitationsEnabled reports whether invitations can be created and delivered
+func (s *AuthService) invitationsEnabled() bool {
+	return s.invitationRepo != nil && s.invitationURL != "" && s.emailSender != nil && s.tenantRepo != nil
+}
+
+// GetInvitation looks up a pending invitation by its token so the frontend can
+// show it and choose between signing in and signing up (pre-filled with the email)
+func (s *AuthService) GetInvitation(ctx context.Context, token string) (*InvitationDetails, error) {
+	if s.invitationRepo == nil || s.tenantRepo == nil {
+		return nil, ErrInvitationsDisabled
+	}
+
+	invitation, err := s.invitationRepo.GetPendingByTokenHash(ctx, hashOpaqueToken(token), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := s.tenantRepo.GetByUUID(ctx, invitation.TenantUUID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.repo.GetByEmail(ctx, invitation.Email)
+
+	return &InvitationDetails{
+		Invitation:   invitation,
+		Tenant:       tenant,
+		ExistingUser: err == nil,
+	}, nil
+}
+
+// AcceptInvitation adds the signed-in user to the invitation's tenant. The
+// invitation must have been sent to the user's email address.
+func (s *AuthService) AcceptInvitation(ctx context.Context, userUUID, token string) (*model.Tenant, error) {
+	if s.invitationRepo == nil || s.tenantRepo == nil {
+		return nil, ErrInvitationsDisabled
+	}
+
+	invitation, err := s.invitationRepo.GetPendingByTokenHash(ctx, hashOpaqueToken(token), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, errors.New("invitation was sent to a different email")
+	}
+
+	tenant, err := s.tenantRepo.GetByUUID(ctx, invitation.TenantUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !tenant.IsActive() {
+		return nil, ErrTenantInactive
+	}
+	if err := tenantPolicyFromSettings(tenant.UUID, tenant.Settings).CheckEmail(user.Email); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.invitationRepo.AcceptForUser(ctx, hashOpaqueToken(token), user.UUID); err != nil {
+		return nil, err
+	}
+	// The invitation link was mailed to this address
+	if user.EmailVerifiedAt == nil {
+		if err := s.repo.MarkEmailVerified(ctx, user.UUID, time.Now()); err != nil {
+			log.Printf("Failed to mark email verified: %v", err)
+		}
+	}
+	return tenant, nil
+}
+
+// signupWithInvitation creates a user who signed up from an invitation link as a
+// member of the inviting tenant, with the invited role
+func (s *AuthService) signupWithInvitation(ctx context.Context, user *model.User, password, token string) error {
+	if s.invitationRepo == nil || s.tenantRepo == nil {
+		return ErrInvitationsDisabled
+	}
+
+	invitation, err := s.invitationRepo.GetPendingByTokenHash(ctx, hashOpaqueToken(token), time.Now())
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return errors.New("invitation was sent to a different email")
+	}
+	policy, err := s.tenantPolicy(ctx, invitation.TenantUUID)
+	if err != nil {
+		return err
+	}
+	if err := policy.CheckEmail(user.Email); err != nil {
+		return err
+	}
+	if err := policy.CheckPassword(password); err != nil {
+		return err
+	}
+
+	// The invitation link was mailed to this address
+	verifiedAt := time.Now()
+	user.EmailVerifiedAt = &verifiedAt
+
+	_, err = s.invitationRepo.AcceptWithNewUser(ctx, hashOpaqueToken(token), user)
+	return err
+}
+
+// ListInvitations lists a tenant's pending invitations
+func (s *AuthService) ListInvitations(ctx context.Context, tenantUUID string) ([]model.TenantInvitation, error) {
+	if s.invitationRepo == nil {
+		return nil, ErrInvitationsDisabled
+	}
+	return s.invitationRepo.ListPendingInvitations(ctx, tenantUUID, time.Now())
+}
+
+// RevokeInvitation cancels one of a tenant's pending invitations
+func (s *AuthService) RevokeInvitation(ctx context.Context, tenantUUID, invitationUUID string) error {
+	if s.invitationRepo == nil {
+		return ErrInvitationsDisabled
+	}
+	return s.invitationRepo.RevokeInvitation(ctx, tenantUUID, invitationUUID)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// Failure reasons recorded in the login history
+const (
+	loginFailureUnknownUser  = "unknown_user"
+	loginFailureBadPassword  = "invalid_password"
+	loginFailureBadCode      = "invalid_code"
+	loginFailureLockedOut    = "locked_out"
+	loginFailureSessionLimit = "session_limit"
+	loginFailureTenantDenied = "tenant_access_denied"
+	loginFailurePolicy       = "tenant_policy"
+	defaultLoginHistoryLimit = 50
+	maxLoginHistoryLimit     = 200
+)
+
+// newDeviceAlertTimeout bounds delivery of an alert, which runs after the login returned
+const newDeviceAlertTimeout = 30 * time.Second
+
+// SetLoginHistoryRepo enables login auditing and new-device alerts
+func (s *AuthService) SetLoginHistoryRepo(repo repository.LoginHistoryRepository) {
+	s.loginHistoryRepo = repo
+}
+
+// ListLoginEvents returns the user's most recent login attempts
+func (s *AuthService) ListLoginEvents(ctx context.Context, userUUID string, limit int) ([]model.LoginEvent, error) {
+	if s.loginHistoryRepo == nil {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = defaultLoginHistoryLimit
+	}
+	if limit > maxLoginHistoryLimit {
+		limit = maxLoginHistoryLimit
+	}
+	return s.loginHistoryRepo.ListEvents(ctx, userUUID, limit)
+}
+
+// recordLoginFailure audits a failed attempt; user is nil when no account matched
+func (s *AuthService) recordLoginFailure(ctx context.Context, user *model.User, email string, amr []string, reason string) {
+	userUUID := ""
+	if user != nil {
+		userUUID = user.UUID
+		email = user.Email
+	}
+	s.recordLoginEvent(ctx, userUUID, email, amr, false, reason)
+}
+
+// recordLoginSuccess audits a completed login and alerts the user when it came from a new device
+func (s *AuthService) recordLoginSuccess(ctx context.Context, user *model.User, amr []string) {
+	if s.loginHistoryRepo == nil {
+		return
+	}
+	s.recordLoginEvent(ctx, user.UUID, user.Email, amr, true, "")
+
+	info := clientInfoFrom(ctx)
+	now := time.Now()
+	isNew, hadDevices, err := s.loginHistoryRepo.TouchDevice(ctx, user.UUID, deviceFingerprint(info), truncate(info.UserAgent, 512), now)
+	if err != nil {
+		log.Printf("Failed to record login device: %v", err)
+		return
+	}
+
+	// The first device on an account (e.g. at signup) is not worth an alert
+	if isNew && hadDevices && s.emailSender != nil {
+		go s.sendNewDeviceAlert(user.Email, info, now)
+	}
+}
+
+func (s *AuthService) recordLoginEvent(ctx context.Context, userUUID, email string, amr []string, success bool, reason string) {
+	if s.loginHistoryRepo == nil {
+		return
+	}
+
+	info := clientInfoFrom(ctx)
+	event := &model.LoginEvent{
+		UserUUID:          userUUID,
+		Email:             truncate(email, 255),
+		Method:            strings.Join(withMFA(amr), ","),
+		Success:           success,
+		Reason:            reason,
+		IPAddress:         info.IPAddress,
+		UserAgent:         truncate(info.UserAgent, 512),
+		DeviceFingerprint: deviceFingerprint(info),
+	}
+	if err := s.loginHistoryRepo.CreateEvent(ctx, event); err != nil {
+		log.Printf("Failed to record login event: %v", err)
+	}
+}
+
+func (s *AuthService) sendNewDeviceAlert(email string, info ClientInfo, at time.Time) {
+	body := fmt.Sprintf("Your account was just signed in to from a new device.\n\nTime: %s\nIP address: %s\nDevice: %s\n\nIf this was you, no action is needed. Otherwise, change your password and sign out of unknown sessions.",
+		at.UTC().Format(time.RFC1123), info.IPAddress, info.UserAgent)
+	ctx, cancel := context.WithTimeout(context.Background(), newDeviceAlertTimeout)
+	defer cancel()
+	if err := s.emailSender.SendEmail(ctx, email, "New sign-in to your account", body); err != nil {
+		log.Printf("Failed to send new sign-in alert: %v", err)
+	}
+}
+
+// deviceFingerprint identifies a device by its user agent and, when the client sends
+// one, a stable device identifier. IP addresses are left out as they change too often.
+func deviceFingerprint(info ClientInfo) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(info.UserAgent) + "|" + strings.TrimSpace(info.DeviceID)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const magicLinkTTL = 15 * time.Minute
+
+var ErrMagicLinkDisabled = errors.New("magic link login is not configured")
+
+// SetMagicLink enables passwordless magic-link login. baseURL is the page that
+// receives the token (as ?token=...) and posts it to the consume endpoint.
+func (s *AuthService) SetMagicLink(repo repository.MagicLinkRepository, baseURL string) {
+	s.magicLinkRepo = repo
+	s.magicLinkURL = baseURL
+}
+
+// RequestMagicLink emails a single-use login link. Unknown emails succeed silently
+// so the endpoint cannot be used to discover accounts.
+func (s *AuthService) RequestMagicLink(ctx context.Context, email string) error {
+	if s.magicLinkRepo == nil || s.magicLinkURL == "" || s.emailSender == nil {
+		return ErrMagicLinkDisabled
+	}
+
+	email = strings.TrimSpace(email)
+	if !s.magicLinkSends.Allowed(email) || !s.loginFailures.Allowed(email) {
+		return ErrTooManyAttempts
+	}
+	s.magicLinkSends.Record(email)
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.magicLinkRepo.CreateToken(ctx, &model.MagicLinkToken{
+		UserUUID:  user.UUID,
+		TokenHash: hashOpaqueToken(token),
+		ExpiresAt: time.Now().Add(magicLinkTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := buildTokenLink(s.magicLinkURL, token)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Use the link below to sign in. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not request this, you can ignore this email.",
+		int(magicLinkTTL.Minutes()), link)
+	if err := s.emailSender.SendEmail(ctx, user.Email, "Your sign-in link", body); err != nil {
+		log.Printf("Failed to send magic link email: %v", err)
+		return errors.New("failed to send magic link")
+	}
+	return nil
+}
+
+// ConsumeMagicLink exchanges a magic link token for a new session, issuing the same
+// session token (with its sid) as Login
+func (s *AuthService) ConsumeMagicLink(ctx context.Context, token string) (string, *model.User, error) {
+	if s.magicLinkRepo == nil {
+		return "", nil, ErrMagicLinkDisabled
+	}
+
+	record, err := s.magicLinkRepo.ConsumeToken(ctx, hashOpaqueToken(token))
+	if err != nil {
+		return "", nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, record.UserUUID)
+	if err != nil {
+		return "", nil, errors.New("invalid or expired magic link")
+	}
+
+	// A locked-out account stays locked regardless of login method
+	if !s.loginFailures.Allowed(user.Email) {
+		return "", nil, ErrTooManyAttempts
+	}
+
+	// The link was delivered to the user's email address
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := s.repo.MarkEmailVerified(ctx, user.UUID, now); err != nil {
+			log.Printf("Failed to mark email verified: %v", err)
+		} else {
+			user.EmailVerifiedAt = &now
+		}
+	}
+
+	return s.completeLogin(ctx, user, []string{AMROTP})
+}
+
+// buildTokenLink appends a single-use token to a frontend URL as ?token=...
+func buildTokenLink(baseURL, token string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid link url: %w", err)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
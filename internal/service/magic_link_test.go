@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeEmailSender records messages instead of sending them
+type fakeEmailSender struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+type sentEmail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func (f *fakeEmailSender) SendEmail(ctx context.Context, to, subject, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, sentEmail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+var emailLink = regexp.MustCompile(`https://\S+`)
+
+// lastToken returns the token of the link in the most recent message
+func (f *fakeEmailSender) lastToken(t *testing.T) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	link, err := url.Parse(emailLink.FindString(f.sent[len(f.sent)-1].Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("no link in message %q", f.sent[len(f.sent)-1].Body)
+	}
+	return link.Query().Get("token")
+}
+
+// fakeMagicLinks mirrors PostgresMagicLinkRepo: a new link replaces the user's
+// unused ones and a link is only returned once, before it expires
+type fakeMagicLinks struct {
+	mu     sync.Mutex
+	tokens []*model.MagicLinkToken
+}
+
+var _ repository.MagicLinkRepository = (*fakeMagicLinks)(nil)
+
+func (f *fakeMagicLinks) CreateToken(ctx context.Context, token *model.MagicLinkToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := f.tokens[:0]
+	for _, existing := range f.tokens {
+		if existing.UserUUID != token.UserUUID || existing.UsedAt != nil {
+			kept = append(kept, existing)
+		}
+	}
+	f.tokens = append(kept, token)
+	return nil
+}
+
+func (f *fakeMagicLinks) ConsumeToken(ctx context.Context, tokenHash string) (*model.MagicLinkToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for _, token := range f.tokens {
+		if token.TokenHash == tokenHash && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			token.UsedAt = &now
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("invalid or expired magic link")
+}
+
+func (f *fakeMagicLinks) expireAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, token := range f.tokens {
+		token.ExpiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+func newMagicLinkTestService(t *testing.T) (*AuthService, *fakeEmailSender, *fakeMagicLinks, *fakeSessionStore, *model.User) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	user := &model.User{
+		UUID:         "00000000-0000-0000-0000-000000000001",
+		Email:        "ada@example.com",
+		Username:     "ada",
+		PasswordHash: string(hash),
+		Role:         "user",
+		TenantID:     "00000000-0000-0000-0000-0000000000aa",
+	}
+
+	email := &fakeEmailSender{}
+	links := &fakeMagicLinks{}
+	sessions := newFakeSessionStore()
+	s := NewAuthService(newFakeUsers(user))
+	s.SetEmailSender(email)
+	s.SetMagicLink(links, "https://app.example.com/magic")
+	s.SetSessionRepo(sessions)
+	return s, email, links, sessions, user
+}
+
+// tokenClaims returns the claims of an access token issued by the service
+func tokenClaims(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return jwtKey, nil }); err != nil {
+		t.Fatalf("token does not parse: %v", err)
+	}
+	return claims
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	s, email, _, sessions, user := newMagicLinkTestService(t)
+	ctx := context.Background()
+
+	if err := s.RequestMagicLink(ctx, user.Email); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	link := email.lastToken(t)
+
+	token, loggedIn, err := s.ConsumeMagicLink(ctx, link)
+	if err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+	if loggedIn.UUID != user.UUID || loggedIn.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected user %+v", loggedIn)
+	}
+
+	// The link opens a server-side session like a password login
+	claims := tokenClaims(t, token)
+	sessionID, _ := claims["sid"].(string)
+	if _, ok := sessions.sessions[sessionID]; !ok {
+		t.Fatalf("token sid %q does not name a session", sessionID)
+	}
+	if amr := tokenAMR(t, token); len(amr) != 1 || amr[0] != AMROTP {
+		t.Fatalf("amr = %v, want [%s]", amr, AMROTP)
+	}
+	if valid, _ := s.ValidateToken(ctx, token); !valid {
+		t.Fatal("magic link token does not validate")
+	}
+	passwordToken, _, err := s.Login(ctx, user.Email, "correct horse")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, ok := tokenClaims(t, passwordToken)["sid"]; !ok {
+		t.Fatal("password login token has no sid")
+	}
+
+	// Signing the device out ends the magic link session
+	if err := s.RevokeSession(ctx, user.UUID, sessionID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if valid, _ := s.ValidateToken(ctx, token); valid {
+		t.Fatal("token validated after its session was revoked")
+	}
+
+	// A link works once
+	if _, _, err := s.ConsumeMagicLink(ctx, link); err == nil {
+		t.Fatal("magic link was used twice")
+	}
+}
+
+func TestMagicLinkExpiry(t *testing.T) {
+	s, email, links, sessions, user := newMagicLinkTestService(t)
+	ctx := context.Background()
+
+	if err := s.RequestMagicLink(ctx, user.Email); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	links.expireAll()
+	if _, _, err := s.ConsumeMagicLink(ctx, email.lastToken(t)); err == nil {
+		t.Fatal("expired magic link was accepted")
+	}
+	if len(sessions.sessions) != 0 {
+		t.Fatal("a session was started")
+	}
+}
+
+func TestMagicLinkOnlyLatestIsValid(t *testing.T) {
+	s, email, _, _, user := newMagicLinkTestService(t)
+	ctx := context.Background()
+
+	if err := s.RequestMagicLink(ctx, user.Email); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	first := email.lastToken(t)
+	if err := s.RequestMagicLink(ctx, user.Email); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+
+	if _, _, err := s.ConsumeMagicLink(ctx, first); err == nil {
+		t.Fatal("superseded magic link was accepted")
+	}
+	if _, _, err := s.ConsumeMagicLink(ctx, email.lastToken(t)); err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+}
+
+func TestMagicLinkSendLimit(t *testing.T) {
+	s, email, _, _, user := newMagicLinkTestService(t)
+	ctx := context.Background()
+
+	for i := 0; i < maxMagicLinkSends; i++ {
+		if err := s.RequestMagicLink(ctx, user.Email); err != nil {
+			t.Fatalf("RequestMagicLink %d: %v", i+1, err)
+		}
+	}
+	if err := s.RequestMagicLink(ctx, user.Email); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("error = %v, want ErrTooManyAttempts", err)
+	}
+	if len(email.sent) != maxMagicLinkSends {
+		t.Fatalf("sent %d emails, want %d", len(email.sent), maxMagicLinkSends)
+	}
+
+	// Unknown addresses are limited the same way and get no email
+	for i := 0; i < maxMagicLinkSends; i++ {
+		if err := s.RequestMagicLink(ctx, "nobody@example.com"); err != nil {
+			t.Fatalf("RequestMagicLink for unknown email: %v", err)
+		}
+	}
+	if err := s.RequestMagicLink(ctx, "nobody@example.com"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("unknown email: error = %v, want ErrTooManyAttempts", err)
+	}
+	if len(email.sent) != maxMagicLinkSends {
+		t.Fatal("an email was sent to an unknown address")
+	}
+}
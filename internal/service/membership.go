@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// UserTenant is one tenant the user belongs to, with their membership in it
+type UserTenant struct {
+	Tenant     model.Tenant
+	Membership model.TenantMembership
+}
+
+// SetMembershipRepo lets users belong to several tenants with a role in each
+func (s *AuthService) SetMembershipRepo(repo repository.MembershipRepository) {
+	s.membershipRepo = repo
+}
+
+// BackfillMemberships gives existing users the membership implied by their
+// tenant and role. It is safe to run on every startup.
+func (s *AuthService) BackfillMemberships(ctx context.Context) error {
+	if s.membershipRepo == nil {
+		return nil
+	}
+	created, err := s.membershipRepo.BackfillFromUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if created > 0 {
+		log.Printf("Created %d tenant memberships for existing users", created)
+	}
+	return nil
+}
+
+// ListUserTenants lists the tenants the user is a member of
+func (s *AuthService) ListUserTenants(ctx context.Context, userUUID string) ([]UserTenant, error) {
+	if s.membershipRepo == nil || s.tenantRepo == nil {
+		return nil, errors.New("tenants not configured")
+	}
+
+	memberships, err := s.membershipRepo.ListUserMemberships(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+	tenantUUIDs := make([]string, 0, len(memberships))
+	for _, membership := range memberships {
+		tenantUUIDs = append(tenantUUIDs, membership.TenantUUID)
+	}
+	tenants, err := s.tenantRepo.GetByUUIDs(ctx, tenantUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	byUUID := make(map[string]model.Tenant, len(tenants))
+	for _, tenant := range tenants {
+		byUUID[tenant.UUID] = tenant
+	}
+
+	result := make([]UserTenant, 0, len(memberships))
+	for _, membership := range memberships {
+		tenant, ok := byUUID[membership.TenantUUID]
+		if !ok {
+			continue
+		}
+		result = append(result, UserTenant{Tenant: tenant, Membership: membership})
+	}
+	return result, nil
+}
+
+// SwitchTenant mints an access token for the same login (session, auth_time and amr)
+// scoped to another tenant the user belongs to. tenantRef is a tenant UUID or slug.
+func (s *AuthService) SwitchTenant(ctx context.Context, userUUID, sessionID, tenantRef string, authTime time.Time, amr []string) (string, *model.User, *model.Tenant, error) {
+	if s.tenantRepo == nil {
+		return "", nil, nil, errors.New("tenants not configured")
+	}
+
+	tenant, err := s.findTenant(ctx, tenantRef)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	scoped, tenant, err := s.scopeToTenant(ctx, user, tenant.UUID)
+	if err != nil {
+		// Do not reveal whether a tenant the user cannot access exists
+		if errors.Is(err, ErrMembershipNotFound) {
+			return "", nil, nil, ErrTenantNotFound
+		}
+		return "", nil, nil, err
+	}
+
+	// The target tenant's policy must accept how the user signed in
+	policy := tenantPolicyFromSettings(tenant.UUID, tenant.Settings)
+	if err := policy.CheckEmail(user.Email); err != nil {
+		return "", nil, nil, err
+	}
+	if err := policy.CheckAuthentication(amr); err != nil {
+		return "", nil, nil, err
+	}
+
+	ttl := accessTokenTTL
+	if lifetime := policy.SessionLifetime(); lifetime > 0 {
+		remaining := time.Until(authTime.Add(lifetime))
+		if remaining <= 0 {
+			return "", nil, nil, errors.New("tenant session lifetime exceeded, sign in again")
+		}
+		if remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if s.sessionRepo != nil && sessionID != "" {
+		session, err := s.sessionRepo.ValidateSession(ctx, sessionID, time.Now())
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if remaining := time.Until(session.ExpiresAt); remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	tokenString, err := issueAccessTokenAt(scoped, tenant, amr, authTime, ttl, sessionID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return tokenString, scoped, tenant, nil
+}
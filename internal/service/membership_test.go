@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func TestSwitchTenant(t *testing.T) {
+	user := &model.User{
+		UUID:     "00000000-0000-0000-0000-000000000001",
+		Email:    "ada@example.com",
+		Role:     "user",
+		TenantID: "00000000-0000-0000-0000-0000000000aa",
+	}
+	home := &model.Tenant{UUID: user.TenantID, Slug: "home", Status: model.TenantStatusActive}
+	other := &model.Tenant{UUID: "00000000-0000-0000-0000-0000000000bb", Slug: "other", Status: model.TenantStatusActive}
+	suspended := &model.Tenant{UUID: "00000000-0000-0000-0000-0000000000cc", Slug: "suspended", Status: model.TenantStatusSuspended}
+	stranger := &model.Tenant{UUID: "00000000-0000-0000-0000-0000000000dd", Slug: "stranger", Status: model.TenantStatusActive}
+	banned := &model.Tenant{UUID: "00000000-0000-0000-0000-0000000000ee", Slug: "banned", Status: model.TenantStatusActive}
+
+	s := NewAuthService(newFakeUsers(user))
+	s.SetTenantRepo(newFakeTenants(home, other, suspended, stranger, banned), "home")
+	s.SetMembershipRepo(newFakeMemberships(
+		&model.TenantMembership{UserUUID: user.UUID, TenantUUID: home.UUID, Role: "user", Status: model.MembershipStatusActive},
+		&model.TenantMembership{UserUUID: user.UUID, TenantUUID: other.UUID, Role: "admin", Status: model.MembershipStatusActive},
+		&model.TenantMembership{UserUUID: user.UUID, TenantUUID: suspended.UUID, Role: "user", Status: model.MembershipStatusActive},
+		&model.TenantMembership{UserUUID: user.UUID, TenantUUID: banned.UUID, Role: "user", Status: model.MembershipStatusSuspended},
+	))
+
+	tests := []struct {
+		name      string
+		tenantRef string
+		wantErr   error
+	}{
+		{"member by slug", "other", nil},
+		{"member by id", other.UUID, nil},
+		{"not a member", stranger.UUID, ErrTenantNotFound},
+		{"not a member by slug", "stranger", ErrTenantNotFound},
+		{"unknown tenant", "nowhere", ErrTenantNotFound},
+		{"suspended tenant", "suspended", ErrTenantInactive},
+		{"suspended membership", "banned", ErrMembershipInactive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, scoped, tenant, err := s.SwitchTenant(context.Background(), user.UUID, "", tt.tenantRef, time.Now(), []string{AMRPassword})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if token != "" {
+					t.Fatal("a token was issued")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SwitchTenant: %v", err)
+			}
+			if tenant.UUID != other.UUID || scoped.Role != "admin" {
+				t.Fatalf("switched to %s as %q, want %s as admin", tenant.UUID, scoped.Role, other.UUID)
+			}
+			claims := tokenClaims(t, token)
+			if claims["tenant_id"] != other.UUID || claims["role"] != "admin" {
+				t.Fatalf("token claims tenant_id=%v role=%v", claims["tenant_id"], claims["role"])
+			}
+		})
+	}
+}
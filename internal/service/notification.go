@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/johnroshan2255/auth-service/internal/config"
 	notificationv1 "github.com/johnroshan2255/core-service/proto/notification/v1"
@@ -15,9 +17,13 @@ import (
 )
 
 type CoreNotificationClient struct {
-	conn       *grpc.ClientConn
-	addr       string
-	serviceKey string
+	conn        *grpc.ClientConn
+	addr        string
+	serviceKey  string
+	tokenSource func() (string, time.Time, error)
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
 }
 
 func NewCoreNotificationClient(addr, serviceKey string, useTLS bool) (*CoreNotificationClient, error) {
@@ -60,16 +66,45 @@ func (c *CoreNotificationClient) Close() error {
 	return nil
 }
 
+// SetTokenSource makes the client authenticate with service tokens; the legacy
+// service key, when configured, is still sent for peers that have not migrated
+func (c *CoreNotificationClient) SetTokenSource(source func() (string, time.Time, error)) {
+	c.tokenSource = source
+}
+
 func (c *CoreNotificationClient) createContextWithAuth(ctx context.Context) context.Context {
-	if c.serviceKey == "" {
+	md := metadata.MD{}
+	if c.serviceKey != "" {
+		md.Set("service-key", c.serviceKey)
+	}
+	if token, err := c.serviceToken(); err != nil {
+		log.Printf("Failed to issue service token: %v", err)
+	} else if token != "" {
+		md.Set("authorization", "Bearer "+token)
+	}
+	if md.Len() == 0 {
 		return ctx
 	}
-	md := metadata.New(map[string]string{
-		"service-key": c.serviceKey,
-	})
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
+// serviceToken returns a cached service token, renewing it shortly before it expires
+func (c *CoreNotificationClient) serviceToken() (string, error) {
+	if c.tokenSource == nil {
+		return "", nil
+	}
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.token == "" || time.Until(c.tokenExpiry) < time.Minute {
+		token, expiry, err := c.tokenSource()
+		if err != nil {
+			return "", err
+		}
+		c.token, c.tokenExpiry = token, expiry
+	}
+	return c.token, nil
+}
+
 func (c *CoreNotificationClient) NotifyUserCreated(ctx context.Context, userUUID, email, username string) error {
 	ctx = c.createContextWithAuth(ctx)
 	
@@ -103,6 +138,9 @@ func NewNotificationService(cfg *config.Config) (*NotificationService, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize notification service: %w", err)
 	}
+	client.SetTokenSource(func() (string, time.Time, error) {
+		return IssueServiceToken(cfg.ServiceName, nil)
+	})
 
 	return &NotificationService{
 		client: client,
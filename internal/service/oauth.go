@@ -0,0 +1,652 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+const (
+	ScopeOfflineAccess = "offline_access" // ask for a refresh token
+
+	authorizationRequestTTL = 10 * time.Minute
+	authorizationCodeTTL    = 5 * time.Minute
+)
+
+// OAuth 2.0 error codes (RFC 6749 sections 4.1.2.1 and 5.2)
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthAccessDenied            = "access_denied"
+	OAuthServerError             = "server_error"
+)
+
+var (
+	// RFC 7636 section 4: a 43-128 character verifier; S256 challenges are 43 characters
+	codeVerifierPattern  = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+	codeChallengePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+)
+
+// OAuthError is an error response defined by RFC 6749
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// AuthorizationParams are the parameters of an /oauth2/authorize request
+type AuthorizationParams struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string // OpenID Connect
+}
+
+// AuthorizationPrompt is what the consent page shows for a pending request
+type AuthorizationPrompt struct {
+	RequestID       string
+	Client          *model.OAuthClient
+	Scopes          []string
+	ConsentRequired bool // false when the client is first party or the user already granted the scopes
+}
+
+// TokenRequest is a request to the token endpoint. ClientSecret is empty for public clients.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	DeviceCode   string
+	// Token exchange (RFC 8693)
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+	Audience           string
+	Resource           string
+}
+
+// TokenResponse is a successful token endpoint response (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken  string
+	TokenType    string
+	ExpiresIn    int64
+	Scope        string
+	RefreshToken string
+	IDToken      string
+	// Set by token exchange (RFC 8693 section 2.2.1)
+	IssuedTokenType string
+}
+
+// StartAuthorization validates an authorization request and returns where to
+// send the browser: the consent page, or the client's redirect URI with an
+// error the client should handle. A returned error means the client or
+// redirect URI could not be trusted, so the user must be shown the error
+// instead of being redirected.
+func (s *AuthService) StartAuthorization(ctx context.Context, params AuthorizationParams) (string, error) {
+	if s.oauthGrantRepo == nil || s.oauthConsentURL == "" {
+		return "", ErrOAuthDisabled
+	}
+
+	client, err := s.oauthClientRepo.GetClient(ctx, params.ClientID)
+	if err != nil {
+		if err.Error() == "oauth client not found" {
+			return "", oauthError(OAuthInvalidClient, "unknown client")
+		}
+		return "", err
+	}
+	if client.DisabledAt != nil {
+		return "", oauthError(OAuthInvalidClient, "client is disabled")
+	}
+	redirectURI := params.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !containsString(client.RedirectURIs, redirectURI) {
+		return "", oauthError(OAuthInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	// From here on errors are reported to the client
+	fail := func(code, description string) (string, error) {
+		return authorizationRedirect(redirectURI, url.Values{
+			"error":             {code},
+			"error_description": {description},
+		}, params.State)
+	}
+	if params.ResponseType != "code" {
+		return fail(OAuthUnsupportedResponseType, "response_type must be code")
+	}
+	if !clientAllowsGrant(client, GrantTypeAuthorizationCode) {
+		return fail(OAuthUnauthorizedClient, "client may not use the authorization code grant")
+	}
+	if params.CodeChallengeMethod != "S256" || !codeChallengePattern.MatchString(params.CodeChallenge) {
+		return fail(OAuthInvalidRequest, "a PKCE code_challenge with code_challenge_method S256 is required")
+	}
+	scopes, oauthErr := resolveScopes(client, params.Scope)
+	if oauthErr != nil {
+		return fail(oauthErr.Code, oauthErr.Description)
+	}
+
+	request := &model.OAuthAuthorizationRequest{
+		ClientID:            client.ClientID,
+		RedirectURI:         redirectURI,
+		Scope:               strings.Join(scopes, " "),
+		State:               params.State,
+		CodeChallenge:       params.CodeChallenge,
+		CodeChallengeMethod: params.CodeChallengeMethod,
+		Nonce:               params.Nonce,
+		ExpiresAt:           time.Now().Add(authorizationRequestTTL),
+	}
+	if err := s.oauthGrantRepo.CreateAuthorizationRequest(ctx, request); err != nil {
+		log.Printf("Failed to store authorization request: %v", err)
+		return fail(OAuthServerError, "failed to start authorization")
+	}
+	return withQuery(s.oauthConsentURL, url.Values{"request_id": {request.UUID}})
+}
+
+// GetAuthorizationRequest describes a pending authorization request to the
+// signed-in user on the consent page
+func (s *AuthService) GetAuthorizationRequest(ctx context.Context, actor Actor, requestID string) (*AuthorizationPrompt, error) {
+	if s.oauthGrantRepo == nil || s.oauthConsentURL == "" {
+		return nil, ErrOAuthDisabled
+	}
+	request, client, err := s.pendingAuthorization(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := splitScope(request.Scope)
+	return &AuthorizationPrompt{
+		RequestID:       request.UUID,
+		Client:          client,
+		Scopes:          scopes,
+		ConsentRequired: !s.hasConsent(ctx, actor, client, scopes),
+	}, nil
+}
+
+// DecideAuthorization answers a pending authorization request for the
+// signed-in user and returns the client redirect carrying either an
+// authorization code or access_denied. amr and authTime describe the user's
+// login and are carried into the tokens issued for the code.
+func (s *AuthService) DecideAuthorization(ctx context.Context, actor Actor, requestID string, amr []string, authTime time.Time, approve bool) (string, error) {
+	if s.oauthGrantRepo == nil || s.oauthConsentURL == "" {
+		return "", ErrOAuthDisabled
+	}
+	request, client, err := s.pendingAuthorization(ctx, requestID)
+	if err != nil {
+		return "", err
+	}
+
+	if approve {
+		// Fail before answering so the consent page can ask for a stronger login
+		if _, _, _, err := s.oauthGrantUser(ctx, actor.UserUUID, actor.TenantUUID, amr); err != nil {
+			return "", err
+		}
+	}
+	if err := s.oauthGrantRepo.CompleteAuthorizationRequest(ctx, request.UUID); err != nil {
+		return "", err
+	}
+	if !approve {
+		return authorizationRedirect(request.RedirectURI, url.Values{
+			"error":             {OAuthAccessDenied},
+			"error_description": {"the user denied the request"},
+		}, request.State)
+	}
+
+	if err := s.recordConsent(ctx, actor, client, splitScope(request.Scope)); err != nil {
+		return "", err
+	}
+
+	code, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.oauthGrantRepo.CreateCode(ctx, &model.OAuthAuthorizationCode{
+		CodeHash:      hashOpaqueToken(code),
+		ClientID:      client.ClientID,
+		UserUUID:      actor.UserUUID,
+		TenantUUID:    actor.TenantUUID,
+		RedirectURI:   request.RedirectURI,
+		Scope:         request.Scope,
+		CodeChallenge: request.CodeChallenge,
+		Nonce:         request.Nonce,
+		AMR:           strings.Join(withMFA(amr), ","),
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return authorizationRedirect(request.RedirectURI, url.Values{"code": {code}}, request.State)
+}
+
+// ExchangeToken implements the token endpoint. Errors are *OAuthError except
+// for unexpected failures, which should be reported as server_error.
+func (s *AuthService) ExchangeToken(ctx context.Context, req TokenRequest) (*TokenResponse, error) {
+	if s.oauthGrantRepo == nil {
+		return nil, ErrOAuthDisabled
+	}
+	client, err := s.authenticateOAuthClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		if !clientAllowsGrant(client, GrantTypeAuthorizationCode) {
+			return nil, oauthError(OAuthUnauthorizedClient, "client may not use this grant type")
+		}
+		return s.exchangeAuthorizationCode(ctx, client, req)
+	case GrantTypeRefreshToken:
+		if !clientAllowsGrant(client, GrantTypeRefreshToken) {
+			return nil, oauthError(OAuthUnauthorizedClient, "client may not use this grant type")
+		}
+		return s.exchangeRefreshToken(ctx, client, req)
+	case GrantTypeClientCredentials:
+		if !clientAllowsGrant(client, GrantTypeClientCredentials) {
+			return nil, oauthError(OAuthUnauthorizedClient, "client may not use this grant type")
+		}
+		return s.exchangeClientCredentials(ctx, client, req)
+	case GrantTypeDeviceCode:
+		if !clientAllowsGrant(client, GrantTypeDeviceCode) {
+			return nil, oauthError(OAuthUnauthorizedClient, "client may not use this grant type")
+		}
+		return s.exchangeDeviceCode(ctx, client, req)
+	case GrantTypeTokenExchange:
+		if !clientAllowsGrant(client, GrantTypeTokenExchange) {
+			return nil, oauthError(OAuthUnauthorizedClient, "client may not use this grant type")
+		}
+		return s.exchangeToken(ctx, client, req)
+	case "":
+		return nil, oauthError(OAuthInvalidRequest, "grant_type is required")
+	}
+	return nil, oauthError(OAuthUnsupportedGrantType, "unsupported grant type")
+}
+
+// authenticateOAuthClient checks a confidential client's secret. Public clients
+// must not send one.
+func (s *AuthService) authenticateOAuthClient(ctx context.Context, clientID, secret string) (*model.OAuthClient, error) {
+	if clientID == "" {
+		return nil, oauthError(OAuthInvalidClient, "client authentication failed")
+	}
+	client, err := s.oauthClientRepo.GetClient(ctx, clientID)
+	if err != nil {
+		if err.Error() == "oauth client not found" {
+			return nil, oauthError(OAuthInvalidClient, "client authentication failed")
+		}
+		return nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, oauthError(OAuthInvalidClient, "client authentication failed")
+	}
+
+	if client.IsPublic() {
+		if secret != "" {
+			return nil, oauthError(OAuthInvalidClient, "public clients do not have a secret")
+		}
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(hashOpaqueToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError(OAuthInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+func (s *AuthService) exchangeAuthorizationCode(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if req.Code == "" || req.RedirectURI == "" || req.CodeVerifier == "" {
+		return nil, oauthError(OAuthInvalidRequest, "code, redirect_uri and code_verifier are required")
+	}
+
+	codeHash := hashOpaqueToken(req.Code)
+	code, err := s.oauthGrantRepo.ConsumeCode(ctx, codeHash)
+	if err != nil {
+		switch err.Error() {
+		case "authorization code already used":
+			// A replayed code may have been stolen: end what it was exchanged for
+			s.revokeOAuthSession(ctx, code.UserUUID, code.SessionUUID)
+			return nil, oauthError(OAuthInvalidGrant, "authorization code already used")
+		case "invalid or expired authorization code":
+			return nil, oauthError(OAuthInvalidGrant, "invalid or expired authorization code")
+		}
+		return nil, err
+	}
+	if code.ClientID != client.ClientID {
+		return nil, oauthError(OAuthInvalidGrant, "authorization code was issued to another client")
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, oauthError(OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, oauthError(OAuthInvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	amr := splitAMR(code.AMR)
+	user, tenant, policy, err := s.oauthGrantUser(ctx, code.UserUUID, code.TenantUUID, amr)
+	if err != nil {
+		return nil, oauthError(OAuthInvalidGrant, err.Error())
+	}
+
+	// Every client gets its own session so it can be listed and revoked separately
+	session, err := s.startSession(ctx, user, amr, policy.SessionLifetime(), client.ClientID)
+	if err != nil {
+		if errors.Is(err, ErrSessionLimitReached) {
+			return nil, oauthError(OAuthInvalidGrant, err.Error())
+		}
+		return nil, err
+	}
+	if session != nil {
+		if err := s.oauthGrantRepo.SetCodeSession(ctx, codeHash, session.UUID); err != nil {
+			log.Printf("Failed to link authorization code to its session: %v", err)
+		}
+	}
+
+	return s.issueOAuthTokens(ctx, client, oauthGrant{
+		user:         user,
+		tenant:       tenant,
+		policy:       policy,
+		session:      session,
+		scope:        code.Scope,
+		refreshScope: code.Scope,
+		amr:          amr,
+		authTime:     code.AuthTime,
+		nonce:        code.Nonce,
+	})
+}
+
+func (s *AuthService) exchangeRefreshToken(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, oauthError(OAuthInvalidRequest, "refresh_token is required")
+	}
+
+	token, err := s.oauthGrantRepo.ConsumeRefreshToken(ctx, hashOpaqueToken(req.RefreshToken))
+	if err != nil {
+		switch err.Error() {
+		case "refresh token already used":
+			// Rotated tokens are only replayed when one was stolen
+			s.revokeOAuthSession(ctx, token.UserUUID, token.SessionUUID)
+			return nil, oauthError(OAuthInvalidGrant, "refresh token already used")
+		case "invalid or expired refresh token":
+			return nil, oauthError(OAuthInvalidGrant, "invalid or expired refresh token")
+		}
+		return nil, err
+	}
+	if token.ClientID != client.ClientID {
+		return nil, oauthError(OAuthInvalidGrant, "refresh token was issued to another client")
+	}
+
+	// A narrower scope may be requested for the access token; the refresh token keeps the original
+	scope := token.Scope
+	if req.Scope != "" {
+		granted := splitScope(token.Scope)
+		for _, requested := range splitScope(req.Scope) {
+			if !containsString(granted, requested) {
+				return nil, oauthError(OAuthInvalidScope, fmt.Sprintf("scope %q was not granted", requested))
+			}
+		}
+		scope = strings.Join(splitScope(req.Scope), " ")
+	}
+
+	if s.sessionRepo == nil {
+		return nil, oauthError(OAuthInvalidGrant, "session revoked or expired")
+	}
+	session, err := s.sessionRepo.ValidateSession(ctx, token.SessionUUID, time.Now())
+	if err != nil {
+		return nil, oauthError(OAuthInvalidGrant, "session revoked or expired")
+	}
+	amr := splitAMR(token.AMR)
+	user, tenant, policy, err := s.oauthGrantUser(ctx, token.UserUUID, token.TenantUUID, amr)
+	if err != nil {
+		return nil, oauthError(OAuthInvalidGrant, err.Error())
+	}
+
+	return s.issueOAuthTokens(ctx, client, oauthGrant{
+		user:         user,
+		tenant:       tenant,
+		policy:       policy,
+		session:      session,
+		scope:        scope,
+		refreshScope: token.Scope,
+		amr:          amr,
+		authTime:     token.AuthTime,
+	})
+}
+
+// oauthGrantUser loads the user a grant is for, scoped to its tenant, and
+// checks the login still satisfies the tenant's policy
+func (s *AuthService) oauthGrantUser(ctx context.Context, userUUID, tenantUUID string, amr []string) (*model.User, *model.Tenant, TenantPolicy, error) {
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return nil, nil, TenantPolicy{}, err
+	}
+	scoped, tenant, err := s.scopeToTenant(ctx, user, tenantUUID)
+	if err != nil {
+		return nil, nil, TenantPolicy{}, err
+	}
+
+	var policy TenantPolicy
+	if tenant != nil {
+		policy = tenantPolicyFromSettings(tenant.UUID, tenant.Settings)
+	}
+	if err := policy.CheckEmail(scoped.Email); err != nil {
+		return nil, nil, TenantPolicy{}, err
+	}
+	if err := policy.CheckAuthentication(amr); err != nil {
+		return nil, nil, TenantPolicy{}, err
+	}
+	return scoped, tenant, policy, nil
+}
+
+// oauthGrant is what a client is issued tokens for
+type oauthGrant struct {
+	user         *model.User
+	tenant       *model.Tenant
+	policy       TenantPolicy
+	session      *model.Session
+	scope        string // scope of the access token
+	refreshScope string // scope of the refresh token, which may be wider
+	amr          []string
+	authTime     time.Time
+	nonce        string
+}
+
+// issueOAuthTokens signs an access token for the grant's scope, an ID token
+// when it includes openid and, when the client may refresh and refreshScope
+// includes offline_access, a refresh token bound to the session. Access tokens
+// of third-party clients carry the client as their audience and are not
+// accepted by this service's own API.
+func (s *AuthService) issueOAuthTokens(ctx context.Context, client *model.OAuthClient, grant oauthGrant) (*TokenResponse, error) {
+	user, session := grant.user, grant.session
+	ttl := sessionTokenTTL(grant.policy, session)
+	var sessionID string
+	if session != nil {
+		sessionID = session.UUID
+	}
+
+	claims := accessTokenClaims(user, grant.tenant, grant.amr, grant.authTime, ttl, sessionID)
+	claims["client_id"] = client.ClientID
+	claims["scope"] = grant.scope
+	if !client.FirstParty {
+		claims["aud"] = client.ClientID
+	}
+	accessToken, err := signAccessToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	response := &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       grant.scope,
+	}
+	if s.oidcKey != nil && containsString(splitScope(grant.scope), ScopeOpenID) {
+		if response.IDToken, err = s.issueIDToken(client, grant, ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	if session == nil || !clientAllowsGrant(client, GrantTypeRefreshToken) || !containsString(splitScope(grant.refreshScope), ScopeOfflineAccess) {
+		return response, nil
+	}
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	err = s.oauthGrantRepo.CreateRefreshToken(ctx, &model.OAuthRefreshToken{
+		TokenHash:   hashOpaqueToken(refreshToken),
+		ClientID:    client.ClientID,
+		SessionUUID: session.UUID,
+		UserUUID:    user.UUID,
+		TenantUUID:  user.TenantID,
+		Scope:       grant.refreshScope,
+		AMR:         strings.Join(grant.amr, ","),
+		AuthTime:    grant.authTime,
+		ExpiresAt:   session.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.RefreshToken = refreshToken
+	return response, nil
+}
+
+// pendingAuthorization loads an unanswered authorization request and its client
+func (s *AuthService) pendingAuthorization(ctx context.Context, requestID string) (*model.OAuthAuthorizationRequest, *model.OAuthClient, error) {
+	request, err := s.oauthGrantRepo.GetAuthorizationRequest(ctx, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := s.oauthClientRepo.GetClient(ctx, request.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, nil, errors.New("oauth client not found")
+	}
+	return request, client, nil
+}
+
+// hasConsent reports whether the user need not be asked before granting scopes to client
+func (s *AuthService) hasConsent(ctx context.Context, actor Actor, client *model.OAuthClient, scopes []string) bool {
+	if client.FirstParty {
+		return true
+	}
+	consent, err := s.oauthClientRepo.GetConsent(ctx, actor.UserUUID, actor.TenantUUID, client.ClientID)
+	if err != nil {
+		return false
+	}
+	for _, scope := range scopes {
+		if !containsString(consent.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordConsent remembers that the user granted scopes to a third-party client
+func (s *AuthService) recordConsent(ctx context.Context, actor Actor, client *model.OAuthClient, scopes []string) error {
+	if s.hasConsent(ctx, actor, client, scopes) {
+		return nil
+	}
+	consent := &model.OAuthConsent{
+		UserUUID:   actor.UserUUID,
+		TenantUUID: actor.TenantUUID,
+		ClientID:   client.ClientID,
+		Scopes:     scopes,
+	}
+	if existing, err := s.oauthClientRepo.GetConsent(ctx, actor.UserUUID, actor.TenantUUID, client.ClientID); err == nil {
+		consent.Scopes = dedupe(append(existing.Scopes, scopes...))
+	}
+	return s.oauthClientRepo.SaveConsent(ctx, consent)
+}
+
+func (s *AuthService) revokeOAuthSession(ctx context.Context, userUUID, sessionUUID string) {
+	if s.sessionRepo == nil || sessionUUID == "" {
+		return
+	}
+	if err := s.sessionRepo.RevokeSession(ctx, userUUID, sessionUUID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		log.Printf("Failed to revoke session after token replay: %v", err)
+	}
+}
+
+// resolveScopes checks requested scopes against the client's registration;
+// without a scope parameter the client gets all of its registered scopes
+func resolveScopes(client *model.OAuthClient, scope string) ([]string, *OAuthError) {
+	if strings.TrimSpace(scope) == "" {
+		return client.Scopes, nil
+	}
+	scopes := splitScope(scope)
+	for _, requested := range scopes {
+		if !containsString(client.Scopes, requested) {
+			return nil, oauthError(OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed for this client", requested))
+		}
+	}
+	return scopes, nil
+}
+
+// splitScope parses a space separated scope parameter, dropping duplicates
+func splitScope(scope string) []string {
+	return dedupe(strings.Fields(scope))
+}
+
+// verifyCodeChallenge checks a PKCE verifier against an S256 challenge (RFC 7636)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// authorizationRedirect adds response parameters and the client's state to its redirect URI
+func authorizationRedirect(redirectURI string, values url.Values, state string) (string, error) {
+	if state != "" {
+		values.Set("state", state)
+	}
+	return withQuery(redirectURI, values)
+}
+
+// withQuery adds values to the query of baseURL, keeping parameters it already has
+func withQuery(baseURL string, values url.Values) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+	q := u.Query()
+	for key, value := range values {
+		q[key] = value
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
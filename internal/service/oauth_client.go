@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+
+	maxRedirectURIs    = 10
+	maxClientScopes    = 50
+	maxClientNameChars = 100
+	maxLogoURIChars    = 2048
+)
+
+var (
+	ErrOAuthDisabled      = errors.New("oauth is not configured")
+	ErrInvalidOAuthClient = errors.New("invalid oauth client")
+
+	// scopeTokenPattern is the scope-token syntax of RFC 6749 section 3.3
+	scopeTokenPattern = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]{1,64}$`)
+)
+
+// supportedGrantTypes are the grants a client may be registered for
+var supportedGrantTypes = map[string]bool{
+	GrantTypeAuthorizationCode: true,
+	GrantTypeRefreshToken:      true,
+	GrantTypeClientCredentials: true,
+	GrantTypeDeviceCode:        true,
+	GrantTypeTokenExchange:     true,
+}
+
+// SupportedGrantTypes lists the grant types the token endpoint implements
+func SupportedGrantTypes() []string {
+	grantTypes := make([]string, 0, len(supportedGrantTypes))
+	for grantType := range supportedGrantTypes {
+		grantTypes = append(grantTypes, grantType)
+	}
+	sort.Strings(grantTypes)
+	return grantTypes
+}
+
+// OAuthClientInput registers or changes an OAuth client; nil and empty fields
+// are left untouched on update. Type cannot change after registration.
+type OAuthClientInput struct {
+	Name         *string
+	Type         string
+	RedirectURIs []string
+	// Where RP-initiated logout may send the browser afterwards
+	PostLogoutRedirectURIs []string
+	Scopes                 []string
+	GrantTypes             []string
+	LogoURI                *string // https image shown on the consent screen; "" removes it
+	FirstParty             *bool
+	Disabled               *bool
+}
+
+// SetOAuth enables the OAuth 2.0 authorization server. consentURL is the
+// frontend page that signs the user in and asks for consent; it receives the
+// pending authorization request as ?request_id=. Without it, authorization
+// requests are refused and only grants that need no user work.
+func (s *AuthService) SetOAuth(clientRepo repository.OAuthClientRepository, grantRepo repository.OAuthGrantRepository, consentURL string) {
+	s.oauthClientRepo = clientRepo
+	s.oauthGrantRepo = grantRepo
+	s.oauthConsentURL = consentURL
+}
+
+// RegisterOAuthClient adds a client to the registry. Confidential clients get a
+// secret that is returned only here.
+func (s *AuthService) RegisterOAuthClient(ctx context.Context, createdBy string, input OAuthClientInput) (*model.OAuthClient, string, error) {
+	if s.oauthClientRepo == nil {
+		return nil, "", ErrOAuthDisabled
+	}
+	if input.Type != model.OAuthClientPublic && input.Type != model.OAuthClientConfidential {
+		return nil, "", fmt.Errorf("%w: type must be %q or %q", ErrInvalidOAuthClient, model.OAuthClientPublic, model.OAuthClientConfidential)
+	}
+	if input.Name == nil {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidOAuthClient)
+	}
+	if len(input.GrantTypes) == 0 {
+		input.GrantTypes = []string{GrantTypeAuthorizationCode}
+	}
+
+	client := &model.OAuthClient{
+		ClientID:  uuid.New().String(),
+		Type:      input.Type,
+		CreatedBy: createdBy,
+	}
+	if err := applyOAuthClientInput(client, input); err != nil {
+		return nil, "", err
+	}
+	secret, err := s.createOAuthClient(ctx, client)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// createOAuthClient stores a validated client, generating the secret of a confidential one
+func (s *AuthService) createOAuthClient(ctx context.Context, client *model.OAuthClient) (string, error) {
+	secret, err := setOAuthClientSecret(client)
+	if err != nil {
+		return "", err
+	}
+	if err := s.oauthClientRepo.CreateClient(ctx, client); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// setOAuthClientSecret gives a confidential client a new secret, returning it in
+// plain text. Public clients have none.
+func setOAuthClientSecret(client *model.OAuthClient) (string, error) {
+	if client.IsPublic() {
+		return "", nil
+	}
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	client.SecretHash = hashOpaqueToken(secret)
+	return secret, nil
+}
+
+func (s *AuthService) ListOAuthClients(ctx context.Context) ([]model.OAuthClient, error) {
+	if s.oauthClientRepo == nil {
+		return nil, ErrOAuthDisabled
+	}
+	return s.oauthClientRepo.ListClients(ctx)
+}
+
+func (s *AuthService) GetOAuthClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	if s.oauthClientRepo == nil {
+		return nil, ErrOAuthDisabled
+	}
+	return s.oauthClientRepo.GetClient(ctx, clientID)
+}
+
+func (s *AuthService) UpdateOAuthClient(ctx context.Context, clientID string, input OAuthClientInput) (*model.OAuthClient, error) {
+	if s.oauthClientRepo == nil {
+		return nil, ErrOAuthDisabled
+	}
+	client, err := s.oauthClientRepo.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Type != "" && input.Type != client.Type {
+		return nil, fmt.Errorf("%w: type cannot change", ErrInvalidOAuthClient)
+	}
+	if err := applyOAuthClientInput(client, input); err != nil {
+		return nil, err
+	}
+	if err := s.oauthClientRepo.UpdateClient(ctx, client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// DeleteOAuthClient removes a client. Its refresh tokens and consents go with
+// it; access tokens already issued run out on their own.
+func (s *AuthService) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	if s.oauthClientRepo == nil {
+		return ErrOAuthDisabled
+	}
+	return s.oauthClientRepo.DeleteClient(ctx, clientID)
+}
+
+// RotateOAuthClientSecret replaces a confidential client's secret; the old one stops working immediately
+func (s *AuthService) RotateOAuthClientSecret(ctx context.Context, clientID string) (string, error) {
+	if s.oauthClientRepo == nil {
+		return "", ErrOAuthDisabled
+	}
+	client, err := s.oauthClientRepo.GetClient(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+	if client.IsPublic() {
+		return "", fmt.Errorf("%w: public clients have no secret", ErrInvalidOAuthClient)
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	client.SecretHash = hashOpaqueToken(secret)
+	if err := s.oauthClientRepo.UpdateClient(ctx, client); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// applyOAuthClientInput validates input and copies the fields it sets onto client
+func applyOAuthClientInput(client *model.OAuthClient, input OAuthClientInput) error {
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" || len(name) > maxClientNameChars {
+			return fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidOAuthClient, maxClientNameChars)
+		}
+		client.Name = name
+	}
+	if input.RedirectURIs != nil {
+		if len(input.RedirectURIs) > maxRedirectURIs {
+			return fmt.Errorf("%w: at most %d redirect URIs", ErrInvalidOAuthClient, maxRedirectURIs)
+		}
+		for _, redirectURI := range input.RedirectURIs {
+			if err := validateRedirectURI(redirectURI, client.IsPublic()); err != nil {
+				return err
+			}
+		}
+		client.RedirectURIs = dedupe(input.RedirectURIs)
+	}
+	if input.PostLogoutRedirectURIs != nil {
+		if len(input.PostLogoutRedirectURIs) > maxRedirectURIs {
+			return fmt.Errorf("%w: at most %d post-logout redirect URIs", ErrInvalidOAuthClient, maxRedirectURIs)
+		}
+		for _, redirectURI := range input.PostLogoutRedirectURIs {
+			if err := validateRedirectURI(redirectURI, client.IsPublic()); err != nil {
+				return err
+			}
+		}
+		client.PostLogoutRedirectURIs = dedupe(input.PostLogoutRedirectURIs)
+	}
+	if input.Scopes != nil {
+		if len(input.Scopes) > maxClientScopes {
+			return fmt.Errorf("%w: at most %d scopes", ErrInvalidOAuthClient, maxClientScopes)
+		}
+		for _, scope := range input.Scopes {
+			if !scopeTokenPattern.MatchString(scope) {
+				return fmt.Errorf("%w: invalid scope %q", ErrInvalidOAuthClient, scope)
+			}
+		}
+		client.Scopes = dedupe(input.Scopes)
+	}
+	if input.GrantTypes != nil {
+		for _, grantType := range input.GrantTypes {
+			if !supportedGrantTypes[grantType] {
+				return fmt.Errorf("%w: unsupported grant type %q", ErrInvalidOAuthClient, grantType)
+			}
+		}
+		client.GrantTypes = dedupe(input.GrantTypes)
+	}
+	if input.LogoURI != nil {
+		if err := validateLogoURI(*input.LogoURI); err != nil {
+			return err
+		}
+		client.LogoURI = *input.LogoURI
+	}
+	if input.FirstParty != nil {
+		client.FirstParty = *input.FirstParty
+	}
+	if input.Disabled != nil {
+		switch {
+		case *input.Disabled && client.DisabledAt == nil:
+			now := time.Now()
+			client.DisabledAt = &now
+		case !*input.Disabled:
+			client.DisabledAt = nil
+		}
+	}
+
+	if client.Scopes == nil {
+		client.Scopes = []string{}
+	}
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+	if client.PostLogoutRedirectURIs == nil {
+		client.PostLogoutRedirectURIs = []string{}
+	}
+	if client.IsPublic() && clientAllowsGrant(client, GrantTypeClientCredentials) {
+		return fmt.Errorf("%w: public clients cannot use client credentials", ErrInvalidOAuthClient)
+	}
+	if client.IsPublic() && clientAllowsGrant(client, GrantTypeTokenExchange) {
+		return fmt.Errorf("%w: public clients cannot exchange tokens", ErrInvalidOAuthClient)
+	}
+	if clientAllowsGrant(client, GrantTypeAuthorizationCode) && len(client.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: the authorization_code grant needs a redirect URI", ErrInvalidOAuthClient)
+	}
+	return nil
+}
+
+// validateLogoURI accepts an empty value or an absolute https URL
+func validateLogoURI(logoURI string) error {
+	if logoURI == "" {
+		return nil
+	}
+	u, err := url.Parse(logoURI)
+	if err != nil || u.Scheme != "https" || u.Host == "" || len(logoURI) > maxLogoURIChars {
+		return fmt.Errorf("%w: logo_uri must be an https URL of at most %d characters", ErrInvalidOAuthClient, maxLogoURIChars)
+	}
+	return nil
+}
+
+// validateRedirectURI accepts absolute URIs without a fragment: https anywhere,
+// http only on loopback, and for public (native) clients a private-use scheme
+// in reverse domain form such as com.example.app:/callback (RFC 8252)
+func validateRedirectURI(redirectURI string, public bool) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.Contains(redirectURI, "#") {
+		return fmt.Errorf("%w: invalid redirect URI %q", ErrInvalidOAuthClient, redirectURI)
+	}
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("%w: invalid redirect URI %q", ErrInvalidOAuthClient, redirectURI)
+		}
+	case "http":
+		if !isLoopbackHost(u.Hostname()) {
+			return fmt.Errorf("%w: http redirect URIs must use a loopback address", ErrInvalidOAuthClient)
+		}
+	default:
+		if !public || !strings.Contains(u.Scheme, ".") {
+			return fmt.Errorf("%w: unsupported redirect URI scheme %q", ErrInvalidOAuthClient, u.Scheme)
+		}
+	}
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func clientAllowsGrant(client *model.OAuthClient, grantType string) bool {
+	return containsString(client.GrantTypes, grantType)
+}
+
+// dedupe drops repeated values, keeping the first occurrence
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+const oauthTestRedirect = "https://spa.example.com/callback"
+
+// testVerifier is a PKCE code verifier of the minimum length
+var testVerifier = strings.Repeat("v", 43)
+
+func codeChallengeFor(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// newOAuthTestService returns a service with a third-party public client "spa"
+// and a first-party client "app", both allowed to refresh, and the actor who
+// authorizes them
+func newOAuthTestService(t *testing.T) (*AuthService, *fakeSessionStore, Actor) {
+	t.Helper()
+	user := &model.User{
+		UUID:     "00000000-0000-0000-0000-000000000001",
+		Email:    "ada@example.com",
+		Role:     "user",
+		TenantID: "00000000-0000-0000-0000-0000000000aa",
+	}
+	grantTypes := []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+	scopes := []string{ScopeOpenID, ScopeOfflineAccess, "documents:read", "documents:write"}
+
+	s := NewAuthService(newFakeUsers(user))
+	s.SetOAuth(&fakeOAuthClients{clients: map[string]*model.OAuthClient{
+		"spa": {ClientID: "spa", Type: model.OAuthClientPublic, RedirectURIs: []string{oauthTestRedirect}, GrantTypes: grantTypes, Scopes: scopes},
+		"app": {ClientID: "app", Type: model.OAuthClientPublic, RedirectURIs: []string{"https://app.example.com/callback"}, GrantTypes: grantTypes, Scopes: scopes, FirstParty: true},
+	}}, newFakeOAuthGrants(), testConsentURL)
+	sessions := newFakeSessionStore()
+	s.SetSessionRepo(sessions)
+	return s, sessions, Actor{UserUUID: user.UUID, TenantUUID: user.TenantID, Role: user.Role}
+}
+
+// authorize runs the authorization request through the consent page and
+// returns the code the client receives
+func authorize(t *testing.T, s *AuthService, actor Actor, params AuthorizationParams) string {
+	t.Helper()
+	consent, err := s.StartAuthorization(context.Background(), params)
+	if err != nil {
+		t.Fatalf("StartAuthorization: %v", err)
+	}
+	consentURL, err := url.Parse(consent)
+	if err != nil || !strings.HasPrefix(consent, testConsentURL) {
+		t.Fatalf("StartAuthorization redirected to %q, want the consent page", consent)
+	}
+
+	redirect, err := s.DecideAuthorization(context.Background(), actor, consentURL.Query().Get("request_id"), []string{AMRPassword}, time.Now(), true)
+	if err != nil {
+		t.Fatalf("DecideAuthorization: %v", err)
+	}
+	redirectURL, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatalf("DecideAuthorization returned %q: %v", redirect, err)
+	}
+	code := redirectURL.Query().Get("code")
+	if code == "" || redirectURL.Query().Get("state") != params.State {
+		t.Fatalf("DecideAuthorization redirected to %q, want a code and the state", redirect)
+	}
+	return code
+}
+
+func spaAuthorization(scope string) AuthorizationParams {
+	return AuthorizationParams{
+		ResponseType:        "code",
+		ClientID:            "spa",
+		RedirectURI:         oauthTestRedirect,
+		Scope:               scope,
+		State:               "xyz",
+		CodeChallenge:       codeChallengeFor(testVerifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func wantOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Fatalf("error = %v, want %s", err, code)
+	}
+}
+
+func TestAuthorizationCodePKCE(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		wantErr  string
+	}{
+		{"matching verifier", testVerifier, ""},
+		{"other verifier", strings.Repeat("w", 43), OAuthInvalidGrant},
+		{"verifier too short", strings.Repeat("v", 42), OAuthInvalidGrant},
+		{"challenge sent as verifier", codeChallengeFor(testVerifier), OAuthInvalidGrant},
+		{"missing verifier", "", OAuthInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, actor := newOAuthTestService(t)
+			code := authorize(t, s, actor, spaAuthorization("documents:read"))
+
+			response, err := s.ExchangeToken(context.Background(), TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "spa",
+				Code:         code,
+				RedirectURI:  oauthTestRedirect,
+				CodeVerifier: tt.verifier,
+			})
+			if tt.wantErr != "" {
+				wantOAuthError(t, err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExchangeToken: %v", err)
+			}
+			if response.Scope != "documents:read" || response.RefreshToken != "" {
+				t.Fatalf("unexpected response: %+v", response)
+			}
+		})
+	}
+
+	s, _, _ := newOAuthTestService(t)
+	for _, params := range []AuthorizationParams{
+		{ResponseType: "code", ClientID: "spa", RedirectURI: oauthTestRedirect},
+		{ResponseType: "code", ClientID: "spa", RedirectURI: oauthTestRedirect, CodeChallenge: codeChallengeFor(testVerifier), CodeChallengeMethod: "plain"},
+	} {
+		redirect, err := s.StartAuthorization(context.Background(), params)
+		if err != nil {
+			t.Fatalf("StartAuthorization: %v", err)
+		}
+		if !strings.HasPrefix(redirect, oauthTestRedirect) || !strings.Contains(redirect, "error="+OAuthInvalidRequest) {
+			t.Fatalf("request with method %q redirected to %q, want invalid_request", params.CodeChallengeMethod, redirect)
+		}
+	}
+}
+
+func TestThirdPartyTokenAudience(t *testing.T) {
+	s, _, actor := newOAuthTestService(t)
+	ctx := context.Background()
+
+	code := authorize(t, s, actor, spaAuthorization("documents:read"))
+	response, err := s.ExchangeToken(ctx, TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		RedirectURI:  oauthTestRedirect,
+		CodeVerifier: testVerifier,
+	})
+	if err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+
+	if _, _, valid := s.InspectToken(ctx, response.AccessToken, "spa", []string{"documents:read"}); !valid {
+		t.Fatal("token was rejected for its own audience")
+	}
+	for _, audience := range []string{"", "app"} {
+		if _, _, valid := s.InspectToken(ctx, response.AccessToken, audience, nil); valid {
+			t.Fatalf("token for spa was accepted for audience %q", audience)
+		}
+	}
+	if valid, _ := s.ValidateToken(ctx, response.AccessToken); valid {
+		t.Fatal("token for spa was accepted by this service")
+	}
+
+	// First-party tokens have no audience and are valid whatever the caller expects
+	params := spaAuthorization("documents:read")
+	params.ClientID, params.RedirectURI = "app", "https://app.example.com/callback"
+	code = authorize(t, s, actor, params)
+	response, err = s.ExchangeToken(ctx, TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "app",
+		Code:         code,
+		RedirectURI:  params.RedirectURI,
+		CodeVerifier: testVerifier,
+	})
+	if err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+	for _, audience := range []string{"", "spa"} {
+		if _, _, valid := s.InspectToken(ctx, response.AccessToken, audience, nil); !valid {
+			t.Fatalf("first-party token was rejected for audience %q", audience)
+		}
+	}
+}
+
+func TestAuthorizationRedirectURIExactMatch(t *testing.T) {
+	s, _, actor := newOAuthTestService(t)
+	ctx := context.Background()
+
+	for _, redirectURI := range []string{
+		oauthTestRedirect + "/",
+		oauthTestRedirect + "?next=/",
+		oauthTestRedirect + "#",
+		"https://SPA.example.com/callback",
+		"http://spa.example.com/callback",
+		"https://spa.example.com/callback/../callback",
+		"https://spa.example.com.evil.com/callback",
+	} {
+		params := spaAuthorization("documents:read")
+		params.RedirectURI = redirectURI
+		redirect, err := s.StartAuthorization(ctx, params)
+		if err == nil {
+			t.Fatalf("%q: redirected to %q, want an error shown to the user", redirectURI, redirect)
+		}
+		wantOAuthError(t, err, OAuthInvalidRequest)
+	}
+
+	// The only registered URI is used when the request leaves it out, and the
+	// exchange must then name it
+	params := spaAuthorization("documents:read")
+	params.RedirectURI = ""
+	code := authorize(t, s, actor, params)
+	_, err := s.ExchangeToken(ctx, TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		RedirectURI:  oauthTestRedirect + "/",
+		CodeVerifier: testVerifier,
+	})
+	wantOAuthError(t, err, OAuthInvalidGrant)
+
+	code = authorize(t, s, actor, params)
+	if _, err := s.ExchangeToken(ctx, TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		RedirectURI:  oauthTestRedirect,
+		CodeVerifier: testVerifier,
+	}); err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+}
+
+func TestAuthorizationCodeReplayRevokesSession(t *testing.T) {
+	s, sessions, actor := newOAuthTestService(t)
+	ctx := context.Background()
+
+	code := authorize(t, s, actor, spaAuthorization("documents:read offline_access"))
+	request := TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		RedirectURI:  oauthTestRedirect,
+		CodeVerifier: testVerifier,
+	}
+	response, err := s.ExchangeToken(ctx, request)
+	if err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+	active, _ := sessions.ListActiveSessions(ctx, actor.UserUUID)
+	if len(active) != 1 || active[0].ClientID != "spa" {
+		t.Fatalf("active sessions = %+v, want one for spa", active)
+	}
+
+	_, err = s.ExchangeToken(ctx, request)
+	wantOAuthError(t, err, OAuthInvalidGrant)
+
+	if active, _ := sessions.ListActiveSessions(ctx, actor.UserUUID); len(active) != 0 {
+		t.Fatalf("session survived the replay: %+v", active)
+	}
+	if _, _, valid := s.InspectToken(ctx, response.AccessToken, "spa", nil); valid {
+		t.Fatal("access token of the revoked session was accepted")
+	}
+	_, err = s.ExchangeToken(ctx, TokenRequest{GrantType: GrantTypeRefreshToken, ClientID: "spa", RefreshToken: response.RefreshToken})
+	wantOAuthError(t, err, OAuthInvalidGrant)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s, sessions, actor := newOAuthTestService(t)
+	ctx := context.Background()
+
+	code := authorize(t, s, actor, spaAuthorization("documents:read documents:write offline_access"))
+	first, err := s.ExchangeToken(ctx, TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		RedirectURI:  oauthTestRedirect,
+		CodeVerifier: testVerifier,
+	})
+	if err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+	if first.RefreshToken == "" {
+		t.Fatal("no refresh token issued for offline_access")
+	}
+
+	// A refresh token is only good for the client it was issued to
+	_, err = s.ExchangeToken(ctx, TokenRequest{GrantType: GrantTypeRefreshToken, ClientID: "app", RefreshToken: first.RefreshToken})
+	wantOAuthError(t, err, OAuthInvalidGrant)
+
+	code = authorize(t, s, actor, spaAuthorization("documents:read documents:write offline_access"))
+	first, err = s.ExchangeToken(ctx, TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		RedirectURI:  oauthTestRedirect,
+		CodeVerifier: testVerifier,
+	})
+	if err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+	// A narrower scope is allowed for the access token, a wider one is not
+	second, err := s.ExchangeToken(ctx, TokenRequest{GrantType: GrantTypeRefreshToken, ClientID: "spa", RefreshToken: first.RefreshToken, Scope: "documents:read"})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken || second.Scope != "documents:read" {
+		t.Fatalf("refresh response = %+v, want a new refresh token and the narrower scope", second)
+	}
+	if _, _, valid := s.InspectToken(ctx, second.AccessToken, "spa", []string{"documents:write"}); valid {
+		t.Fatal("narrowed access token carries documents:write")
+	}
+
+	// The rotated token keeps the original scope
+	third, err := s.ExchangeToken(ctx, TokenRequest{GrantType: GrantTypeRefreshToken, ClientID: "spa", RefreshToken: second.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh with the rotated token: %v", err)
+	}
+	if third.Scope != "documents:read documents:write offline_access" {
+		t.Fatalf("scope = %q, want the original scope", third.Scope)
+	}
+	_, err = s.ExchangeToken(ctx, TokenRequest{GrantType: GrantTypeRefreshToken, ClientID: "spa", RefreshToken: third.RefreshToken, Scope: "documents:delete"})
+	wantOAuthError(t, err, OAuthInvalidScope)
+
+	// Replaying a rotated token ends the session and every token of it
+	_, err = s.ExchangeToken(ctx, TokenRequest{GrantType: GrantTypeRefreshToken, ClientID: "spa", RefreshToken: first.RefreshToken})
+	wantOAuthError(t, err, OAuthInvalidGrant)
+	active, _ := sessions.ListActiveSessions(ctx, actor.UserUUID)
+	if len(active) != 1 {
+		t.Fatalf("active sessions = %d, want only the other authorization's session", len(active))
+	}
+	if _, _, valid := s.InspectToken(ctx, third.AccessToken, "spa", nil); valid {
+		t.Fatal("access token of the revoked session was accepted")
+	}
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+// OpenID Connect scopes (OIDC Core section 5.4)
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+
+	idTokenTTL = time.Hour
+)
+
+var (
+	ErrOIDCDisabled       = errors.New("openid connect is not configured")
+	ErrInvalidAccessToken = errors.New("invalid access token")
+	ErrInsufficientScope  = errors.New("insufficient scope")
+	ErrInvalidLogout      = errors.New("invalid logout request")
+)
+
+// JSONWebKey is the public half of a signing key as published in the JWKS (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string
+	Use       string
+	Algorithm string
+	KeyID     string
+	Modulus   string // n, base64url
+	Exponent  string // e, base64url
+}
+
+// EndSessionParams are the parameters of an RP-initiated logout request
+type EndSessionParams struct {
+	IDTokenHint           string
+	ClientID              string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+// LoadOIDCSigningKey reads the RSA private key that signs ID tokens from a PEM
+// file (PKCS #1 or PKCS #8). Without a path a key is generated, so ID tokens
+// stop verifying whenever the service restarts.
+func LoadOIDCSigningKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key must be an RSA key")
+	}
+	return key, nil
+}
+
+// SetOIDC enables OpenID Connect on top of the OAuth 2.0 authorization server.
+// issuer is the public base URL of this service.
+func (s *AuthService) SetOIDC(issuer string, key *rsa.PrivateKey) {
+	s.oidcIssuer = strings.TrimSuffix(issuer, "/")
+	s.oidcKey = key
+	s.oidcKeyID = jwkThumbprint(&key.PublicKey)
+}
+
+// OIDCIssuer returns the issuer identifier, or "" when OpenID Connect is disabled
+func (s *AuthService) OIDCIssuer() string {
+	return s.oidcIssuer
+}
+
+// SigningKeys returns the keys relying parties verify ID tokens with
+func (s *AuthService) SigningKeys() ([]JSONWebKey, error) {
+	if s.oidcKey == nil {
+		return nil, ErrOIDCDisabled
+	}
+	return []JSONWebKey{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     s.oidcKeyID,
+		Modulus:   base64.RawURLEncoding.EncodeToString(s.oidcKey.PublicKey.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.oidcKey.PublicKey.E)).Bytes()),
+	}}, nil
+}
+
+// issueIDToken signs the ID token of a grant. It carries the standard claims
+// of the granted scopes and expires with the access token or after an hour.
+func (s *AuthService) issueIDToken(client *model.OAuthClient, grant oauthGrant, accessTTL time.Duration) (string, error) {
+	ttl := idTokenTTL
+	if accessTTL < ttl {
+		ttl = accessTTL
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       s.oidcIssuer,
+		"sub":       grant.user.UUID,
+		"aud":       client.ClientID,
+		"azp":       client.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(ttl).Unix(),
+		"auth_time": grant.authTime.Unix(),
+		"amr":       withMFA(grant.amr),
+	}
+	if grant.nonce != "" {
+		claims["nonce"] = grant.nonce
+	}
+	if grant.session != nil {
+		claims["sid"] = grant.session.UUID
+	}
+	for name, value := range userClaims(grant.user, splitScope(grant.scope)) {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.oidcKeyID
+	return token.SignedString(s.oidcKey)
+}
+
+// UserInfo returns the claims about the user an access token with the openid
+// scope may read (OIDC Core section 5.3)
+func (s *AuthService) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	if s.oidcKey == nil {
+		return nil, ErrOIDCDisabled
+	}
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidAccessToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidAccessToken
+	}
+	if _, ok := claims["token_use"]; ok {
+		return nil, ErrInvalidAccessToken
+	}
+	userUUID, _ := claims["user_uuid"].(string)
+	sessionID, _ := claims["sid"].(string)
+	if userUUID == "" || !s.IsSessionActive(ctx, sessionID) {
+		return nil, ErrInvalidAccessToken
+	}
+	scope, _ := claims["scope"].(string)
+	scopes := splitScope(scope)
+	if !containsString(scopes, ScopeOpenID) {
+		return nil, ErrInsufficientScope
+	}
+
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	tenantID, _ := claims["tenant_id"].(string)
+	if _, _, err := s.scopeToTenant(ctx, user, tenantID); err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	info := userClaims(user, scopes)
+	info["sub"] = user.UUID
+	return info, nil
+}
+
+// EndSession implements RP-initiated logout. The session named by a current ID
+// token hint is revoked. Without one the request could be forged (logout CSRF),
+// so the browser is sent to the consent page for the user to confirm the logout
+// (ConfirmEndSession). The returned URL is where to send the browser next, or ""
+// when the client gave no registered post-logout redirect URI.
+func (s *AuthService) EndSession(ctx context.Context, params EndSessionParams) (string, error) {
+	if s.oidcKey == nil {
+		return "", ErrOIDCDisabled
+	}
+
+	hint, err := s.parseIDTokenHint(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	redirectTo, err := s.postLogoutRedirect(ctx, hint.clientID, params)
+	if err != nil {
+		return "", err
+	}
+
+	if hint.userUUID == "" || hint.expired {
+		values := url.Values{"logout": {"true"}}
+		if hint.clientID != "" {
+			values.Set("client_id", hint.clientID)
+		}
+		if params.PostLogoutRedirectURI != "" {
+			values.Set("post_logout_redirect_uri", params.PostLogoutRedirectURI)
+		}
+		if params.State != "" {
+			values.Set("state", params.State)
+		}
+		return withQuery(s.oauthConsentURL, values)
+	}
+
+	if hint.sessionID != "" && s.sessionRepo != nil {
+		if err := s.sessionRepo.RevokeSession(ctx, hint.userUUID, hint.sessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return "", err
+		}
+	}
+	return redirectTo, nil
+}
+
+// ConfirmEndSession finishes a logout the user confirmed on the consent page by
+// revoking the session of the confirming request
+func (s *AuthService) ConfirmEndSession(ctx context.Context, actor Actor, sessionID string, params EndSessionParams) (string, error) {
+	if s.oidcKey == nil {
+		return "", ErrOIDCDisabled
+	}
+
+	hint, err := s.parseIDTokenHint(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	if hint.userUUID != "" && hint.userUUID != actor.UserUUID {
+		return "", fmt.Errorf("%w: id_token_hint was issued to another user", ErrInvalidLogout)
+	}
+	redirectTo, err := s.postLogoutRedirect(ctx, hint.clientID, params)
+	if err != nil {
+		return "", err
+	}
+
+	if sessionID != "" && s.sessionRepo != nil {
+		if err := s.sessionRepo.RevokeSession(ctx, actor.UserUUID, sessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return "", err
+		}
+	}
+	return redirectTo, nil
+}
+
+// idTokenHint is what a logout request's id_token_hint says
+type idTokenHint struct {
+	clientID  string
+	userUUID  string
+	sessionID string
+	expired   bool
+}
+
+// parseIDTokenHint verifies the hint was issued by this provider to a registered
+// client, the one named by client_id if given. Expired hints still name the
+// client, but are not trusted to sign the user out without confirmation.
+func (s *AuthService) parseIDTokenHint(ctx context.Context, params EndSessionParams) (*idTokenHint, error) {
+	hint := &idTokenHint{clientID: params.ClientID}
+	if params.IDTokenHint == "" {
+		return hint, nil
+	}
+
+	// Claims are checked by hand so an expired hint is told apart from an invalid one
+	token, err := jwt.Parse(params.IDTokenHint, func(token *jwt.Token) (interface{}, error) {
+		return &s.oidcKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid id_token_hint", ErrInvalidLogout)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid id_token_hint", ErrInvalidLogout)
+	}
+	issuer, _ := claims.GetIssuer()
+	expiresAt, _ := claims.GetExpirationTime()
+	if issuer != s.oidcIssuer || expiresAt == nil {
+		return nil, fmt.Errorf("%w: invalid id_token_hint", ErrInvalidLogout)
+	}
+	hint.expired = !expiresAt.After(time.Now())
+
+	audience, _ := claims.GetAudience()
+	if len(audience) != 1 {
+		return nil, fmt.Errorf("%w: invalid id_token_hint", ErrInvalidLogout)
+	}
+	if params.ClientID != "" && params.ClientID != audience[0] {
+		return nil, fmt.Errorf("%w: id_token_hint was issued to another client", ErrInvalidLogout)
+	}
+	if _, err := s.oauthClientRepo.GetClient(ctx, audience[0]); err != nil {
+		return nil, fmt.Errorf("%w: id_token_hint was issued to an unknown client", ErrInvalidLogout)
+	}
+	hint.clientID = audience[0]
+	hint.userUUID, _ = claims["sub"].(string)
+	hint.sessionID, _ = claims["sid"].(string)
+	return hint, nil
+}
+
+// postLogoutRedirect builds the redirect back to the client, which must have
+// registered the requested post_logout_redirect_uri
+func (s *AuthService) postLogoutRedirect(ctx context.Context, clientID string, params EndSessionParams) (string, error) {
+	if params.PostLogoutRedirectURI == "" {
+		return "", nil
+	}
+	if clientID == "" {
+		return "", fmt.Errorf("%w: post_logout_redirect_uri requires client_id or id_token_hint", ErrInvalidLogout)
+	}
+	client, err := s.oauthClientRepo.GetClient(ctx, clientID)
+	if err != nil || !containsString(client.PostLogoutRedirectURIs, params.PostLogoutRedirectURI) {
+		return "", fmt.Errorf("%w: post_logout_redirect_uri is not registered for this client", ErrInvalidLogout)
+	}
+	values := url.Values{}
+	if params.State != "" {
+		values.Set("state", params.State)
+	}
+	return withQuery(params.PostLogoutRedirectURI, values)
+}
+
+// userClaims returns the standard claims (OIDC Core section 5.1) released by scopes
+func userClaims(user *model.User, scopes []string) map[string]interface{} {
+	claims := make(map[string]interface{})
+	if containsString(scopes, ScopeProfile) {
+		claims["preferred_username"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+		if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+			claims["name"] = name
+		}
+		if user.FirstName != "" {
+			claims["given_name"] = user.FirstName
+		}
+		if user.LastName != "" {
+			claims["family_name"] = user.LastName
+		}
+	}
+	if containsString(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerifiedAt != nil
+	}
+	if containsString(scopes, ScopePhone) && user.PhoneNumber != "" {
+		claims["phone_number"] = user.PhoneNumber
+		claims["phone_number_verified"] = user.PhoneVerifiedAt != nil
+	}
+	return claims
+}
+
+// jwkThumbprint is the RFC 7638 thumbprint of an RSA public key, used as its key ID
+func jwkThumbprint(key *rsa.PublicKey) string {
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	sum := sha256.Sum256([]byte(`{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
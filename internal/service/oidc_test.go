@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	testIssuer     = "https://auth.example.com"
+	testConsentURL = "https://app.example.com/consent"
+)
+
+type fakeOAuthClients struct {
+	repository.OAuthClientRepository
+	clients  map[string]*model.OAuthClient
+	consents []model.OAuthConsent
+}
+
+func (f *fakeOAuthClients) GetClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	client, ok := f.clients[clientID]
+	if !ok {
+		return nil, errors.New("oauth client not found")
+	}
+	return client, nil
+}
+
+func (f *fakeOAuthClients) GetConsent(ctx context.Context, userUUID, tenantUUID, clientID string) (*model.OAuthConsent, error) {
+	for i := range f.consents {
+		consent := &f.consents[i]
+		if consent.UserUUID == userUUID && consent.TenantUUID == tenantUUID && consent.ClientID == clientID {
+			return consent, nil
+		}
+	}
+	return nil, errors.New("consent not found")
+}
+
+func (f *fakeOAuthClients) SaveConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	if existing, err := f.GetConsent(ctx, consent.UserUUID, consent.TenantUUID, consent.ClientID); err == nil {
+		existing.Scopes = consent.Scopes
+		return nil
+	}
+	f.consents = append(f.consents, *consent)
+	return nil
+}
+
+// fakeSessions records revocations
+type fakeSessions struct {
+	repository.SessionRepository
+	revoked []string
+}
+
+func (f *fakeSessions) RevokeSession(ctx context.Context, userUUID, sessionUUID string) error {
+	f.revoked = append(f.revoked, userUUID+"/"+sessionUUID)
+	return nil
+}
+
+func newLogoutTestService(t *testing.T) (*AuthService, *rsa.PrivateKey, *fakeSessions) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	s := NewAuthService(newFakeUsers())
+	s.SetOAuth(&fakeOAuthClients{clients: map[string]*model.OAuthClient{
+		"app":   {ClientID: "app", PostLogoutRedirectURIs: []string{"https://app.example.com/bye"}},
+		"other": {ClientID: "other"},
+	}}, nil, testConsentURL)
+	s.SetOIDC(testIssuer, key)
+	sessions := &fakeSessions{}
+	s.SetSessionRepo(sessions)
+	return s, key, sessions
+}
+
+func signIDTokenHint(t *testing.T, key *rsa.PrivateKey, issuer, audience string, expiresAt time.Time) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": issuer,
+		"aud": audience,
+		"sub": "user-1",
+		"sid": "session-1",
+		"exp": expiresAt.Unix(),
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return token
+}
+
+func TestEndSessionWithCurrentHint(t *testing.T) {
+	s, key, sessions := newLogoutTestService(t)
+
+	redirectTo, err := s.EndSession(context.Background(), EndSessionParams{
+		IDTokenHint:           signIDTokenHint(t, key, testIssuer, "app", time.Now().Add(time.Hour)),
+		PostLogoutRedirectURI: "https://app.example.com/bye",
+		State:                 "xyz",
+	})
+	if err != nil {
+		t.Fatalf("EndSession: %v", err)
+	}
+	if redirectTo != "https://app.example.com/bye?state=xyz" {
+		t.Fatalf("redirect = %q", redirectTo)
+	}
+	if len(sessions.revoked) != 1 || sessions.revoked[0] != "user-1/session-1" {
+		t.Fatalf("revoked = %v, want the hinted session", sessions.revoked)
+	}
+}
+
+func TestEndSessionRejectsForeignHints(t *testing.T) {
+	s, key, sessions := newLogoutTestService(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	future := time.Now().Add(time.Hour)
+
+	tests := map[string]EndSessionParams{
+		"other client":   {IDTokenHint: signIDTokenHint(t, key, testIssuer, "other", future), ClientID: "app"},
+		"unknown client": {IDTokenHint: signIDTokenHint(t, key, testIssuer, "gone", future)},
+		"other issuer":   {IDTokenHint: signIDTokenHint(t, key, "https://evil.example.com", "app", future)},
+		"bad signature":  {IDTokenHint: signIDTokenHint(t, otherKey, testIssuer, "app", future)},
+		"expired, other issuer": {
+			IDTokenHint: signIDTokenHint(t, key, "https://evil.example.com", "app", time.Now().Add(-time.Hour)),
+		},
+	}
+	for name, params := range tests {
+		if _, err := s.EndSession(context.Background(), params); !errors.Is(err, ErrInvalidLogout) {
+			t.Errorf("%s: error = %v, want ErrInvalidLogout", name, err)
+		}
+	}
+	if len(sessions.revoked) != 0 {
+		t.Fatalf("revoked %v on invalid requests", sessions.revoked)
+	}
+}
+
+func TestEndSessionRequiresConfirmation(t *testing.T) {
+	s, key, sessions := newLogoutTestService(t)
+	ctx := context.Background()
+
+	tests := map[string]EndSessionParams{
+		"no hint": {ClientID: "app", PostLogoutRedirectURI: "https://app.example.com/bye"},
+		"expired hint": {
+			IDTokenHint:           signIDTokenHint(t, key, testIssuer, "app", time.Now().Add(-time.Hour)),
+			PostLogoutRedirectURI: "https://app.example.com/bye",
+		},
+	}
+	for name, params := range tests {
+		redirectTo, err := s.EndSession(ctx, params)
+		if err != nil {
+			t.Fatalf("%s: EndSession: %v", name, err)
+		}
+		parsed, _ := url.Parse(redirectTo)
+		query := parsed.Query()
+		if parsed.Host != "app.example.com" || parsed.Path != "/consent" || query.Get("logout") != "true" || query.Get("client_id") != "app" {
+			t.Fatalf("%s: redirect = %q, want the logout confirmation page", name, redirectTo)
+		}
+	}
+	if len(sessions.revoked) != 0 {
+		t.Fatalf("revoked %v without confirmation", sessions.revoked)
+	}
+
+	// The confirming user signs out their own session
+	actor := Actor{UserUUID: "user-1"}
+	redirectTo, err := s.ConfirmEndSession(ctx, actor, "session-2", EndSessionParams{ClientID: "app", PostLogoutRedirectURI: "https://app.example.com/bye"})
+	if err != nil {
+		t.Fatalf("ConfirmEndSession: %v", err)
+	}
+	if redirectTo != "https://app.example.com/bye" || len(sessions.revoked) != 1 || sessions.revoked[0] != "user-1/session-2" {
+		t.Fatalf("redirect = %q, revoked = %v", redirectTo, sessions.revoked)
+	}
+
+	// A hint naming someone else cannot be confirmed
+	_, err = s.ConfirmEndSession(ctx, Actor{UserUUID: "user-2"}, "session-3", EndSessionParams{
+		IDTokenHint: signIDTokenHint(t, key, testIssuer, "app", time.Now().Add(-time.Hour)),
+	})
+	if !errors.Is(err, ErrInvalidLogout) {
+		t.Fatalf("error = %v, want ErrInvalidLogout", err)
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+const (
+	phoneOTPTTL         = 10 * time.Minute
+	phoneOTPDigits      = 6
+	maxPhoneOTPAttempts = 5
+	maxSMSSends         = 3
+	smsSendWindow       = 15 * time.Minute
+)
+
+var (
+	ErrSMSDisabled        = errors.New("sms delivery is not configured")
+	ErrPhoneLoginDisabled = errors.New("phone login is not enabled")
+	ErrInvalidPhoneNumber = errors.New("invalid phone number: use international format, e.g. +14155550123")
+)
+
+// NormalizePhoneNumber converts a phone number to E.164 (+<country code><number>).
+// Common separators are stripped and a leading 00 international prefix becomes +.
+func NormalizePhoneNumber(raw string) (string, error) {
+	number := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "", "\t", "").Replace(strings.TrimSpace(raw))
+	if strings.HasPrefix(number, "00") {
+		number = "+" + number[2:]
+	}
+	if !strings.HasPrefix(number, "+") {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	digits := number[1:]
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalidPhoneNumber
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidPhoneNumber
+		}
+	}
+	return number, nil
+}
+
+// SendPhoneVerification texts a verification code to the user's phone number
+func (s *AuthService) SendPhoneVerification(ctx context.Context, userUUID string) error {
+	if s.smsSender == nil || s.phoneOTPRepo == nil {
+		return ErrSMSDisabled
+	}
+
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	if user.PhoneNumber == "" {
+		return errors.New("no phone number on account")
+	}
+	if user.PhoneVerifiedAt != nil {
+		return errors.New("phone number already verified")
+	}
+
+	return s.sendPhoneOTP(ctx, user, model.PhoneOTPPurposeVerify)
+}
+
+// ConfirmPhoneVerification checks the code and marks the phone number verified
+func (s *AuthService) ConfirmPhoneVerification(ctx context.Context, userUUID, code string) error {
+	if s.smsSender == nil || s.phoneOTPRepo == nil {
+		return ErrSMSDisabled
+	}
+
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+
+	otp, err := s.checkPhoneOTP(ctx, user, model.PhoneOTPPurposeVerify, code)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.MarkPhoneVerified(ctx, user.UUID, otp.PhoneNumber, time.Now())
+}
+
+// SendPhoneLoginCode texts a login code to a verified phone number. Unknown numbers
+// succeed silently so the endpoint cannot be used to discover accounts.
+func (s *AuthService) SendPhoneLoginCode(ctx context.Context, phoneNumber string) error {
+	if !s.phoneOTPLogin {
+		return ErrPhoneLoginDisabled
+	}
+	if s.smsSender == nil || s.phoneOTPRepo == nil {
+		return ErrSMSDisabled
+	}
+
+	phoneNumber, err := NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		return err
+	}
+	if !s.smsSends.Allowed(phoneNumber) {
+		return ErrTooManyAttempts
+	}
+
+	user, err := s.repo.GetByVerifiedPhone(ctx, phoneNumber)
+	if err != nil {
+		return nil
+	}
+	if !s.loginFailures.Allowed(user.Email) {
+		return ErrTooManyAttempts
+	}
+
+	return s.sendPhoneOTP(ctx, user, model.PhoneOTPPurposeLogin)
+}
+
+// LoginWithPhone exchanges a phone login code for the same token Login issues
+func (s *AuthService) LoginWithPhone(ctx context.Context, phoneNumber, code string) (string, *model.User, error) {
+	if !s.phoneOTPLogin {
+		return "", nil, ErrPhoneLoginDisabled
+	}
+	if s.smsSender == nil || s.phoneOTPRepo == nil {
+		return "", nil, ErrSMSDisabled
+	}
+
+	phoneNumber, err := NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		return "", nil, errors.New("invalid credentials")
+	}
+
+	user, err := s.repo.GetByVerifiedPhone(ctx, phoneNumber)
+	if err != nil {
+		return "", nil, errors.New("invalid credentials")
+	}
+	amr := []string{AMROTP}
+	if !s.loginFailures.Allowed(user.Email) {
+		s.recordLoginFailure(ctx, user, user.Email, amr, loginFailureLockedOut)
+		return "", nil, ErrTooManyAttempts
+	}
+
+	if _, err := s.checkPhoneOTP(ctx, user, model.PhoneOTPPurposeLogin, code); err != nil {
+		s.loginFailures.Record(user.Email)
+		s.recordLoginFailure(ctx, user, user.Email, amr, loginFailureBadCode)
+		return "", nil, errors.New("invalid credentials")
+	}
+	s.loginFailures.Reset(user.Email)
+
+	return s.completeLogin(ctx, user, amr)
+}
+
+func (s *AuthService) sendPhoneOTP(ctx context.Context, user *model.User, purpose string) error {
+	if !s.smsSends.Allowed(user.PhoneNumber) {
+		return ErrTooManyAttempts
+	}
+	s.smsSends.Record(user.PhoneNumber)
+
+	code, err := generateNumericCode(phoneOTPDigits)
+	if err != nil {
+		return err
+	}
+
+	err = s.phoneOTPRepo.CreateOTP(ctx, &model.PhoneOTP{
+		UserUUID:    user.UUID,
+		PhoneNumber: user.PhoneNumber,
+		Purpose:     purpose,
+		CodeHash:    hashPhoneOTP(user.UUID, code),
+		ExpiresAt:   time.Now().Add(phoneOTPTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(phoneOTPTTL.Minutes()))
+	if err := s.smsSender.SendSMS(ctx, user.PhoneNumber, message); err != nil {
+		return fmt.Errorf("failed to send sms: %w", err)
+	}
+	return nil
+}
+
+// checkPhoneOTP validates and consumes the active code for purpose, counting every attempt
+func (s *AuthService) checkPhoneOTP(ctx context.Context, user *model.User, purpose, code string) (*model.PhoneOTP, error) {
+	otp, err := s.phoneOTPRepo.GetActiveOTP(ctx, user.UUID, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every guess is counted before the comparison so parallel requests cannot exceed the limit
+	allowed, err := s.phoneOTPRepo.ClaimAttempt(ctx, otp.ID, maxPhoneOTPAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrTooManyAttempts
+	}
+	if !hmac.Equal([]byte(otp.CodeHash), []byte(hashPhoneOTP(user.UUID, code))) {
+		return nil, errors.New("invalid or expired code")
+	}
+
+	if err := s.phoneOTPRepo.ConsumeOTP(ctx, otp.ID); err != nil {
+		return nil, err
+	}
+	return otp, nil
+}
+
+// hashPhoneOTP keys the code hash with the JWT secret; a plain hash of a 6 digit code is trivially reversible
+func hashPhoneOTP(userUUID, code string) string {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte(userUUID + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateNumericCode(digits int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
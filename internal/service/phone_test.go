@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// fakeSMSSender records messages instead of sending them
+type fakeSMSSender struct {
+	mu   sync.Mutex
+	sent []sentSMS
+}
+
+type sentSMS struct {
+	PhoneNumber string
+	Message     string
+}
+
+func (f *fakeSMSSender) SendSMS(ctx context.Context, phoneNumber, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, sentSMS{PhoneNumber: phoneNumber, Message: message})
+	return nil
+}
+
+var smsCode = regexp.MustCompile(`\b\d{6}\b`)
+
+// lastCode returns the code in the most recent message
+func (f *fakeSMSSender) lastCode(t *testing.T) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sent) == 0 {
+		t.Fatal("no sms sent")
+	}
+	code := smsCode.FindString(f.sent[len(f.sent)-1].Message)
+	if code == "" {
+		t.Fatalf("no code in message %q", f.sent[len(f.sent)-1].Message)
+	}
+	return code
+}
+
+// fakePhoneOTPs mirrors PostgresPhoneOTPRepo: a new code replaces the previous
+// one and expired or consumed codes are never returned
+type fakePhoneOTPs struct {
+	mu     sync.Mutex
+	nextID uint
+	otps   []*model.PhoneOTP
+}
+
+var _ repository.PhoneOTPRepository = (*fakePhoneOTPs)(nil)
+
+func (f *fakePhoneOTPs) CreateOTP(ctx context.Context, otp *model.PhoneOTP) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := f.otps[:0]
+	for _, existing := range f.otps {
+		if existing.UserUUID != otp.UserUUID || existing.Purpose != otp.Purpose || existing.ConsumedAt != nil {
+			kept = append(kept, existing)
+		}
+	}
+	f.nextID++
+	otp.ID = f.nextID
+	f.otps = append(kept, otp)
+	return nil
+}
+
+func (f *fakePhoneOTPs) GetActiveOTP(ctx context.Context, userUUID, purpose string) (*model.PhoneOTP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, otp := range f.otps {
+		if otp.UserUUID == userUUID && otp.Purpose == purpose && otp.ConsumedAt == nil && otp.ExpiresAt.After(time.Now()) {
+			copied := *otp
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("invalid or expired code")
+}
+
+func (f *fakePhoneOTPs) ClaimAttempt(ctx context.Context, id uint, maxAttempts int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, otp := range f.otps {
+		if otp.ID == id && otp.Attempts < maxAttempts {
+			otp.Attempts++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakePhoneOTPs) ConsumeOTP(ctx context.Context, id uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, otp := range f.otps {
+		if otp.ID == id && otp.ConsumedAt == nil {
+			now := time.Now()
+			otp.ConsumedAt = &now
+			return nil
+		}
+	}
+	return errors.New("invalid or expired code")
+}
+
+func (f *fakePhoneOTPs) expireAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, otp := range f.otps {
+		otp.ExpiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+func newPhoneTestService(user *model.User) (*AuthService, *fakeSMSSender, *fakePhoneOTPs) {
+	s := NewAuthService(newFakeUsers(user))
+	sms := &fakeSMSSender{}
+	otps := &fakePhoneOTPs{}
+	s.SetSMSSender(sms, otps, true)
+	return s, sms, otps
+}
+
+func verifiedPhoneUser() *model.User {
+	verifiedAt := time.Now().Add(-time.Hour)
+	return &model.User{
+		UUID:            "00000000-0000-0000-0000-000000000001",
+		Email:           "ada@example.com",
+		Role:            "user",
+		TenantID:        "00000000-0000-0000-0000-0000000000aa",
+		PhoneNumber:     "+14155550123",
+		PhoneVerifiedAt: &verifiedAt,
+	}
+}
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"+14155550123", "+14155550123"},
+		{" +1 (415) 555-0123 ", "+14155550123"},
+		{"+44 20.7946.0958", "+442079460958"},
+		{"0044 20 7946 0958", "+442079460958"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePhoneNumber(tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizePhoneNumber(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+		}
+	}
+
+	for _, raw := range []string{"", "4155550123", "+0123456789", "+1415555", "+1234567890123456", "+1415555012a", "++14155550123"} {
+		if _, err := NormalizePhoneNumber(raw); !errors.Is(err, ErrInvalidPhoneNumber) {
+			t.Errorf("NormalizePhoneNumber(%q) error = %v, want ErrInvalidPhoneNumber", raw, err)
+		}
+	}
+}
+
+func TestLoginWithPhone(t *testing.T) {
+	user := verifiedPhoneUser()
+	s, sms, _ := newPhoneTestService(user)
+	ctx := context.Background()
+
+	if err := s.SendPhoneLoginCode(ctx, "+1 415 555 0123"); err != nil {
+		t.Fatalf("SendPhoneLoginCode: %v", err)
+	}
+	if sms.sent[0].PhoneNumber != user.PhoneNumber {
+		t.Fatalf("code sent to %q, want %q", sms.sent[0].PhoneNumber, user.PhoneNumber)
+	}
+
+	token, loggedIn, err := s.LoginWithPhone(ctx, "+14155550123", sms.lastCode(t))
+	if err != nil {
+		t.Fatalf("LoginWithPhone: %v", err)
+	}
+	if loggedIn.UUID != user.UUID {
+		t.Fatalf("logged in as %q, want %q", loggedIn.UUID, user.UUID)
+	}
+	if valid, claims := s.ValidateToken(ctx, token); !valid || claims.UUID != user.UUID {
+		t.Fatalf("issued token does not validate for the user")
+	}
+
+	// Codes are single use
+	if _, _, err := s.LoginWithPhone(ctx, "+14155550123", sms.lastCode(t)); err == nil {
+		t.Fatal("code was accepted twice")
+	}
+}
+
+func TestSendPhoneLoginCodeUnknownNumber(t *testing.T) {
+	s, sms, _ := newPhoneTestService(verifiedPhoneUser())
+
+	// Unknown numbers succeed silently and send nothing
+	if err := s.SendPhoneLoginCode(context.Background(), "+442079460958"); err != nil {
+		t.Fatalf("SendPhoneLoginCode: %v", err)
+	}
+	if len(sms.sent) != 0 {
+		t.Fatalf("sent %d messages to an unknown number", len(sms.sent))
+	}
+}
+
+func TestLoginWithPhoneExpiredCode(t *testing.T) {
+	s, sms, otps := newPhoneTestService(verifiedPhoneUser())
+	ctx := context.Background()
+
+	if err := s.SendPhoneLoginCode(ctx, "+14155550123"); err != nil {
+		t.Fatalf("SendPhoneLoginCode: %v", err)
+	}
+	otps.expireAll()
+
+	if _, _, err := s.LoginWithPhone(ctx, "+14155550123", sms.lastCode(t)); err == nil {
+		t.Fatal("expired code was accepted")
+	}
+}
+
+func TestPhoneOTPAttemptLimit(t *testing.T) {
+	user := verifiedPhoneUser()
+	user.PhoneVerifiedAt = nil
+	s, sms, _ := newPhoneTestService(user)
+	ctx := context.Background()
+
+	if err := s.SendPhoneVerification(ctx, user.UUID); err != nil {
+		t.Fatalf("SendPhoneVerification: %v", err)
+	}
+	code := sms.lastCode(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < maxPhoneOTPAttempts; i++ {
+		if err := s.ConfirmPhoneVerification(ctx, user.UUID, wrong); err == nil || errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("attempt %d: error = %v, want invalid code", i+1, err)
+		}
+	}
+
+	// Once the attempts are used up even the right code is refused
+	if err := s.ConfirmPhoneVerification(ctx, user.UUID, code); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("error = %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestConfirmPhoneVerification(t *testing.T) {
+	user := verifiedPhoneUser()
+	user.PhoneVerifiedAt = nil
+	s, sms, _ := newPhoneTestService(user)
+	ctx := context.Background()
+
+	if err := s.SendPhoneVerification(ctx, user.UUID); err != nil {
+		t.Fatalf("SendPhoneVerification: %v", err)
+	}
+	if err := s.ConfirmPhoneVerification(ctx, user.UUID, sms.lastCode(t)); err != nil {
+		t.Fatalf("ConfirmPhoneVerification: %v", err)
+	}
+
+	verified, _ := s.repo.GetByID(ctx, user.UUID)
+	if verified.PhoneVerifiedAt == nil {
+		t.Fatal("phone number was not marked verified")
+	}
+}
+
+func TestTwilioSMSSender(t *testing.T) {
+	var gotPath, gotTo, gotFrom, gotUser string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUser, _, _ = r.BasicAuth()
+		r.ParseForm()
+		gotTo, gotFrom = r.PostForm.Get("To"), r.PostForm.Get("From")
+		if gotTo == "+15005550001" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":21211,"message":"Invalid 'To' Phone Number"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sender, err := NewTwilioSMSSender("AC123", "secret", "+15005550006")
+	if err != nil {
+		t.Fatalf("NewTwilioSMSSender: %v", err)
+	}
+	sender.apiBase = server.URL
+
+	if err := sender.SendSMS(context.Background(), "+14155550123", "hello"); err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if gotPath != "/Accounts/AC123/Messages.json" || gotUser != "AC123" || gotTo != "+14155550123" || gotFrom != "+15005550006" {
+		t.Fatalf("unexpected request: path=%q user=%q to=%q from=%q", gotPath, gotUser, gotTo, gotFrom)
+	}
+
+	if err := sender.SendSMS(context.Background(), "+15005550001", "hello"); err == nil {
+		t.Fatal("expected an error for a rejected message")
+	}
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// Permissions checked by this service's own admin routes
+const (
+	PermTenantsRead       = "tenants:read"  // platform: all tenants
+	PermTenantsWrite      = "tenants:write" // platform: all tenants
+	PermGlobalRolesWrite  = "global_roles:write"
+	PermOAuthClientsRead  = "oauth_clients:read"  // platform: OAuth client registry
+	PermOAuthClientsWrite = "oauth_clients:write" // platform: OAuth client registry
+	PermServiceKeysRead   = "service_keys:read"   // platform: gRPC service keys
+	PermServiceKeysWrite  = "service_keys:write"  // platform: gRPC service keys
+	PermUsersImpersonate  = "users:impersonate"   // platform: act as any user
+	PermAuditRead         = "audit:read"          // platform: audit log
+	PermSessionsRead      = "sessions:read"       // platform: any user's sessions
+	PermSessionsWrite     = "sessions:write"      // platform: any user's sessions
+	PermTenantRead        = "tenant:read"
+	PermTenantWrite       = "tenant:write"
+	PermMembersRead       = "members:read"
+	PermMembersWrite      = "members:write"
+	PermInvitationsRead   = "invitations:read"
+	PermInvitationsWrite  = "invitations:write"
+	PermDomainsRead       = "domains:read"
+	PermDomainsWrite      = "domains:write"
+	PermRolesRead         = "roles:read"
+	PermRolesWrite        = "roles:write"
+	PermPoliciesRead      = "policies:read"
+	PermPoliciesWrite     = "policies:write"
+	permissionCacheTTL    = 30 * time.Second
+	maxPermissionsPerRole = 200
+)
+
+// builtinRoles are created at startup when missing. Their permissions can be
+// edited afterwards but the roles cannot be deleted.
+var builtinRoles = []model.Role{
+	{Name: "superadmin", Description: "Platform administrator", Permissions: []string{"*"}},
+	{Name: "admin", Description: "Tenant administrator", Permissions: []string{
+		PermTenantRead, PermTenantWrite,
+		PermMembersRead, PermMembersWrite,
+		PermInvitationsRead, PermInvitationsWrite,
+		PermDomainsRead, PermDomainsWrite,
+		PermRolesRead, PermRolesWrite,
+		PermPoliciesRead, PermPoliciesWrite,
+	}},
+	{Name: "user", Description: "Tenant member", Permissions: []string{}},
+}
+
+var (
+	ErrRBACDisabled       = errors.New("roles are not configured")
+	ErrPermissionEscalate = errors.New("cannot grant permissions you do not have")
+	ErrInvalidPermission  = errors.New("invalid permission")
+	ErrRoleNotFound       = repository.ErrRoleNotFound
+
+	permissionPattern = regexp.MustCompile(`^(\*|[a-z][a-z0-9_.-]*(:([a-z][a-z0-9_.-]*|\*))+)$`)
+	roleNamePattern   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+)
+
+// Actor is the authenticated caller of an administrative operation
+type Actor struct {
+	UserUUID   string
+	TenantUUID string // tenant the caller's token is scoped to
+	Role       string // role claim of the caller's token
+}
+
+// permissionCache keeps resolved role permissions briefly so permission checks
+// on every request do not hit the database
+type permissionCache struct {
+	mu      sync.Mutex
+	entries map[string]permissionCacheEntry
+}
+
+type permissionCacheEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+func (c *permissionCache) get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.permissions, true
+}
+
+func (c *permissionCache) set(key string, permissions []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = permissionCacheEntry{permissions: permissions, expiresAt: time.Now().Add(permissionCacheTTL)}
+}
+
+// reset drops all entries; role changes can affect every tenant through global roles
+func (c *permissionCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]permissionCacheEntry)
+}
+
+// SetRoleRepo enables role-based access control
+func (s *AuthService) SetRoleRepo(repo repository.RoleRepository) {
+	s.roleRepo = repo
+	s.permissions = &permissionCache{entries: make(map[string]permissionCacheEntry)}
+}
+
+// EnsureBuiltinRoles creates the built-in global roles that do not exist yet
+func (s *AuthService) EnsureBuiltinRoles(ctx context.Context) error {
+	if s.roleRepo == nil {
+		return nil
+	}
+	for _, builtin := range builtinRoles {
+		role := builtin
+		role.BuiltIn = true
+		role.Permissions = append([]string{}, builtin.Permissions...)
+		if err := s.roleRepo.EnsureRole(ctx, &role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolvePermissions returns the permissions a role name grants inside a tenant.
+// Unknown roles grant nothing.
+func (s *AuthService) ResolvePermissions(ctx context.Context, tenantUUID, roleName string) ([]string, error) {
+	if s.roleRepo == nil {
+		return nil, ErrRBACDisabled
+	}
+
+	key := tenantUUID + "|" + roleName
+	if permissions, ok := s.permissions.get(key); ok {
+		return permissions, nil
+	}
+
+	var permissions []string
+	role, err := s.roleRepo.FindRole(ctx, tenantUUID, roleName)
+	if err == nil {
+		permissions = role.Permissions
+	} else if !errors.Is(err, ErrRoleNotFound) {
+		return nil, err
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+	s.permissions.set(key, permissions)
+	return permissions, nil
+}
+
+// RoleHasPermission reports whether a role grants permission inside a tenant.
+// It fails closed when roles cannot be resolved.
+func (s *AuthService) RoleHasPermission(ctx context.Context, tenantUUID, roleName, permission string) bool {
+	permissions, err := s.ResolvePermissions(ctx, tenantUUID, roleName)
+	if err != nil {
+		if !errors.Is(err, ErrRBACDisabled) {
+			log.Printf("Failed to resolve permissions of role %q: %v", roleName, err)
+		}
+		return false
+	}
+	return permissionGranted(permissions, permission)
+}
+
+// HasPermission reports whether a user holds permission in a tenant. The user's
+// current membership role is used, so role changes apply to tokens already issued;
+// tokenRole is only used when memberships are not configured.
+func (s *AuthService) HasPermission(ctx context.Context, userUUID, tenantUUID, tokenRole, permission string) bool {
+	role, err := s.currentRole(ctx, Actor{UserUUID: userUUID, TenantUUID: tenantUUID, Role: tokenRole})
+	if err != nil {
+		return false
+	}
+	return s.RoleHasPermission(ctx, tenantUUID, role, permission)
+}
+
+// UserPermissions returns the permissions a user currently holds in a tenant
+func (s *AuthService) UserPermissions(ctx context.Context, userUUID, tenantUUID, tokenRole string) ([]string, error) {
+	role, err := s.currentRole(ctx, Actor{UserUUID: userUUID, TenantUUID: tenantUUID, Role: tokenRole})
+	if err != nil {
+		return nil, err
+	}
+	return s.ResolvePermissions(ctx, tenantUUID, role)
+}
+
+// currentRole returns the actor's role from their active membership
+func (s *AuthService) currentRole(ctx context.Context, actor Actor) (string, error) {
+	if s.membershipRepo == nil {
+		return actor.Role, nil
+	}
+	membership, err := s.membershipRepo.GetMembership(ctx, actor.UserUUID, actor.TenantUUID)
+	if err != nil {
+		return "", err
+	}
+	if !membership.IsActive() {
+		return "", ErrMembershipInactive
+	}
+	return membership.Role, nil
+}
+
+// permissionGranted matches a permission against granted ones, honouring
+// "*" and "resource:*" wildcards
+func permissionGranted(granted []string, permission string) bool {
+	for _, g := range granted {
+		if g == "*" || g == permission {
+			return true
+		}
+		if strings.HasSuffix(g, ":*") && strings.HasPrefix(permission, strings.TrimSuffix(g, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGrant ensures the actor holds every permission they hand out, so
+// roles cannot be used to escalate privileges
+func (s *AuthService) checkGrant(ctx context.Context, actor Actor, permissions []string) error {
+	role, err := s.currentRole(ctx, actor)
+	if err != nil {
+		return err
+	}
+	granted, err := s.ResolvePermissions(ctx, actor.TenantUUID, role)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !permissionGranted(granted, permission) {
+			return ErrPermissionEscalate
+		}
+	}
+	return nil
+}
+
+// checkRoleGrant ensures a role exists in the actor's tenant and the actor may hand it out
+func (s *AuthService) checkRoleGrant(ctx context.Context, actor Actor, roleName string) error {
+	if s.roleRepo == nil {
+		return ErrRBACDisabled
+	}
+	role, err := s.roleRepo.FindRole(ctx, actor.TenantUUID, roleName)
+	if err != nil {
+		return err
+	}
+	return s.checkGrant(ctx, actor, role.Permissions)
+}
+
+func normalizePermissions(permissions []string) ([]string, error) {
+	if len(permissions) > maxPermissionsPerRole {
+		return nil, errors.New("too many permissions")
+	}
+	seen := make(map[string]bool, len(permissions))
+	result := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+		if !permissionPattern.MatchString(permission) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPermission, permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// ListRoles lists the roles usable in a tenant (global roles only when tenantUUID is empty)
+func (s *AuthService) ListRoles(ctx context.Context, tenantUUID string) ([]model.Role, error) {
+	if s.roleRepo == nil {
+		return nil, ErrRBACDisabled
+	}
+	return s.roleRepo.ListRoles(ctx, tenantUUID)
+}
+
+// CreateRole defines a role in a tenant, or a global role when tenantUUID is empty
+func (s *AuthService) CreateRole(ctx context.Context, actor Actor, tenantUUID, name, description string, permissions []string) (*model.Role, error) {
+	if s.roleRepo == nil {
+		return nil, ErrRBACDisabled
+	}
+	if !roleNamePattern.MatchString(name) {
+		return nil, errors.New("invalid role name")
+	}
+	permissions, err := normalizePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkGrant(ctx, actor, permissions); err != nil {
+		return nil, err
+	}
+
+	role := &model.Role{
+		TenantUUID:  tenantUUID,
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+	}
+	if err := s.roleRepo.CreateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	s.permissions.reset()
+	return role, nil
+}
+
+// UpdateRole changes a role's description and/or permissions; nil leaves a field untouched
+func (s *AuthService) UpdateRole(ctx context.Context, actor Actor, tenantUUID, roleUUID string, description *string, permissions []string) (*model.Role, error) {
+	if s.roleRepo == nil {
+		return nil, ErrRBACDisabled
+	}
+	role, err := s.roleRepo.GetRole(ctx, tenantUUID, roleUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if description != nil {
+		role.Description = *description
+	}
+	if permissions != nil {
+		permissions, err = normalizePermissions(permissions)
+		if err != nil {
+			return nil, err
+		}
+		// Both the old and new permission sets must be within the actor's own
+		affected := append(append([]string{}, permissions...), role.Permissions...)
+		if err := s.checkGrant(ctx, actor, affected); err != nil {
+			return nil, err
+		}
+		role.Permissions = permissions
+	}
+
+	if err := s.roleRepo.UpdateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	s.permissions.reset()
+	return role, nil
+}
+
+// DeleteRole removes an unused custom role
+func (s *AuthService) DeleteRole(ctx context.Context, actor Actor, tenantUUID, roleUUID string) error {
+	if s.roleRepo == nil {
+		return ErrRBACDisabled
+	}
+	role, err := s.roleRepo.GetRole(ctx, tenantUUID, roleUUID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkGrant(ctx, actor, role.Permissions); err != nil {
+		return err
+	}
+	if err := s.roleRepo.DeleteRole(ctx, tenantUUID, roleUUID); err != nil {
+		return err
+	}
+	s.permissions.reset()
+	return nil
+}
+
+// ListMembers lists the memberships of a tenant
+func (s *AuthService) ListMembers(ctx context.Context, tenantUUID string) ([]model.TenantMembership, error) {
+	if s.membershipRepo == nil {
+		return nil, errors.New("tenants not configured")
+	}
+	return s.membershipRepo.ListTenantMembers(ctx, tenantUUID)
+}
+
+// AssignMemberRole gives a member of the actor's tenant another role. The actor
+// must hold every permission of both the new role and the member's current role.
+func (s *AuthService) AssignMemberRole(ctx context.Context, actor Actor, userUUID, roleName string) error {
+	if s.membershipRepo == nil {
+		return errors.New("tenants not configured")
+	}
+	if userUUID == actor.UserUUID {
+		return errors.New("cannot change your own role")
+	}
+
+	membership, err := s.membershipRepo.GetMembership(ctx, userUUID, actor.TenantUUID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkRoleGrant(ctx, actor, roleName); err != nil {
+		return err
+	}
+	current, err := s.ResolvePermissions(ctx, actor.TenantUUID, membership.Role)
+	if err != nil {
+		return err
+	}
+	if err := s.checkGrant(ctx, actor, current); err != nil {
+		return err
+	}
+
+	return s.membershipRepo.UpdateMembershipRole(ctx, userUUID, actor.TenantUUID, roleName)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+const rbacTestTenant = "00000000-0000-0000-0000-0000000000aa"
+
+// newRBACTestService returns a service with the built-in roles, a tenant role
+// "support" holding users:impersonate, a tenant admin and a plain member
+func newRBACTestService(t *testing.T) (*AuthService, *fakeMemberships, Actor, string) {
+	t.Helper()
+	admin := Actor{UserUUID: "00000000-0000-0000-0000-000000000001", TenantUUID: rbacTestTenant, Role: "admin"}
+	member := "00000000-0000-0000-0000-000000000002"
+
+	memberships := newFakeMemberships(
+		&model.TenantMembership{UserUUID: admin.UserUUID, TenantUUID: rbacTestTenant, Role: "admin", Status: model.MembershipStatusActive},
+		&model.TenantMembership{UserUUID: member, TenantUUID: rbacTestTenant, Role: "user", Status: model.MembershipStatusActive},
+	)
+	roles := newFakeRoles(&model.Role{
+		UUID:        "00000000-0000-0000-0000-0000000000f1",
+		TenantUUID:  rbacTestTenant,
+		Name:        "support",
+		Permissions: []string{PermMembersRead, PermUsersImpersonate},
+	})
+
+	s := NewAuthService(newFakeUsers())
+	s.SetMembershipRepo(memberships)
+	s.SetRoleRepo(roles)
+	if err := s.EnsureBuiltinRoles(context.Background()); err != nil {
+		t.Fatalf("EnsureBuiltinRoles: %v", err)
+	}
+	return s, memberships, admin, member
+}
+
+func TestCheckGrantStopsEscalation(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(s *AuthService, admin Actor, member string) error
+		wantErr error
+	}{
+		{"create role within own permissions", func(s *AuthService, admin Actor, member string) error {
+			_, err := s.CreateRole(context.Background(), admin, rbacTestTenant, "auditor", "", []string{PermMembersRead})
+			return err
+		}, nil},
+		{"create role with impersonation", func(s *AuthService, admin Actor, member string) error {
+			_, err := s.CreateRole(context.Background(), admin, rbacTestTenant, "helpdesk", "", []string{PermMembersRead, PermUsersImpersonate})
+			return err
+		}, ErrPermissionEscalate},
+		{"create role with wildcard", func(s *AuthService, admin Actor, member string) error {
+			_, err := s.CreateRole(context.Background(), admin, rbacTestTenant, "root", "", []string{"*"})
+			return err
+		}, ErrPermissionEscalate},
+		{"create role with resource wildcard", func(s *AuthService, admin Actor, member string) error {
+			_, err := s.CreateRole(context.Background(), admin, rbacTestTenant, "people", "", []string{"users:*"})
+			return err
+		}, ErrPermissionEscalate},
+		{"assign role within own permissions", func(s *AuthService, admin Actor, member string) error {
+			return s.AssignMemberRole(context.Background(), admin, member, "admin")
+		}, nil},
+		{"assign role with impersonation", func(s *AuthService, admin Actor, member string) error {
+			return s.AssignMemberRole(context.Background(), admin, member, "support")
+		}, ErrPermissionEscalate},
+		{"assign wildcard role", func(s *AuthService, admin Actor, member string) error {
+			return s.AssignMemberRole(context.Background(), admin, member, "superadmin")
+		}, ErrPermissionEscalate},
+		{"assign unknown role", func(s *AuthService, admin Actor, member string) error {
+			return s.AssignMemberRole(context.Background(), admin, member, "nobody")
+		}, ErrRoleNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, memberships, admin, member := newRBACTestService(t)
+
+			err := tt.run(s, admin, member)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				membership, _ := memberships.GetMembership(context.Background(), member, rbacTestTenant)
+				if membership.Role != "user" {
+					t.Fatalf("member role changed to %q", membership.Role)
+				}
+			}
+		})
+	}
+}
+
+func TestResolvePermissionsUnknownRole(t *testing.T) {
+	s, _, _, _ := newRBACTestService(t)
+
+	permissions, err := s.ResolvePermissions(context.Background(), rbacTestTenant, "nobody")
+	if err != nil {
+		t.Fatalf("ResolvePermissions: %v", err)
+	}
+	if len(permissions) != 0 {
+		t.Fatalf("unknown role grants %v", permissions)
+	}
+	if !s.RoleHasPermission(context.Background(), rbacTestTenant, "support", PermUsersImpersonate) {
+		t.Fatal("support role does not grant impersonation")
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	serviceKeyPrefix   = "sk_"
+	serviceKeyCacheTTL = 30 * time.Second
+	maxAllowedMethods  = 50
+)
+
+var (
+	ErrServiceKeysDisabled = errors.New("service keys are not configured")
+	ErrInvalidServiceKey   = errors.New("invalid service key")
+	ErrServiceKeyNotFound  = repository.ErrServiceKeyNotFound
+
+	serviceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,99}$`)
+	// A gRPC service ("auth.v1.AuthService") or one of its methods ("auth.v1.AuthService/CheckPermission")
+	rpcMethodPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*(/[A-Za-z_][A-Za-z0-9_]*)?$`)
+)
+
+// ServiceKeyInput creates a service key
+type ServiceKeyInput struct {
+	ServiceName    string
+	Description    string
+	AllowedMethods []string
+	ExpiresAt      *time.Time
+}
+
+// serviceKeyCache remembers verified keys briefly so every gRPC call does not hit the database
+type serviceKeyCache struct {
+	mu      sync.Mutex
+	entries map[string]serviceKeyCacheEntry
+}
+
+type serviceKeyCacheEntry struct {
+	key       *model.ServiceKey
+	expiresAt time.Time
+}
+
+func (c *serviceKeyCache) get(keyHash string, now time.Time) (*model.ServiceKey, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[keyHash]
+	if !ok || now.After(entry.expiresAt) || !entry.key.IsActive(now) {
+		return nil, false
+	}
+	return entry.key, true
+}
+
+func (c *serviceKeyCache) set(keyHash string, key *model.ServiceKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[keyHash] = serviceKeyCacheEntry{key: key, expiresAt: time.Now().Add(serviceKeyCacheTTL)}
+}
+
+func (c *serviceKeyCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]serviceKeyCacheEntry)
+}
+
+// SetServiceKeyRepo enables per-service keys for the gRPC API
+func (s *AuthService) SetServiceKeyRepo(repo repository.ServiceKeyRepository) {
+	s.serviceKeyRepo = repo
+	s.serviceKeys = &serviceKeyCache{entries: make(map[string]serviceKeyCacheEntry)}
+}
+
+// CreateServiceKey issues a key for a backend service. The key is returned only here.
+func (s *AuthService) CreateServiceKey(ctx context.Context, createdBy string, input ServiceKeyInput) (*model.ServiceKey, string, error) {
+	if s.serviceKeyRepo == nil {
+		return nil, "", ErrServiceKeysDisabled
+	}
+	if !serviceNamePattern.MatchString(input.ServiceName) {
+		return nil, "", fmt.Errorf("%w: invalid service name %q", ErrInvalidServiceKey, input.ServiceName)
+	}
+	if len(input.Description) > 255 {
+		return nil, "", fmt.Errorf("%w: description is too long", ErrInvalidServiceKey)
+	}
+	if len(input.AllowedMethods) == 0 || len(input.AllowedMethods) > maxAllowedMethods {
+		return nil, "", fmt.Errorf("%w: 1-%d allowed methods are required", ErrInvalidServiceKey, maxAllowedMethods)
+	}
+	for _, method := range input.AllowedMethods {
+		if !rpcMethodPattern.MatchString(method) {
+			return nil, "", fmt.Errorf("%w: invalid method %q", ErrInvalidServiceKey, method)
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiry must be in the future", ErrInvalidServiceKey)
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	rawKey := serviceKeyPrefix + token
+	key := &model.ServiceKey{
+		ServiceName:    input.ServiceName,
+		Description:    input.Description,
+		KeyHash:        hashOpaqueToken(rawKey),
+		KeyPrefix:      rawKey[:len(serviceKeyPrefix)+8],
+		AllowedMethods: dedupe(input.AllowedMethods),
+		ExpiresAt:      input.ExpiresAt,
+		CreatedBy:      createdBy,
+	}
+	if err := s.serviceKeyRepo.CreateKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, rawKey, nil
+}
+
+func (s *AuthService) ListServiceKeys(ctx context.Context, serviceName string) ([]model.ServiceKey, error) {
+	if s.serviceKeyRepo == nil {
+		return nil, ErrServiceKeysDisabled
+	}
+	return s.serviceKeyRepo.ListKeys(ctx, serviceName)
+}
+
+// RevokeServiceKey stops a key from authenticating; the change applies immediately on this instance
+func (s *AuthService) RevokeServiceKey(ctx context.Context, keyUUID string) error {
+	if s.serviceKeyRepo == nil {
+		return ErrServiceKeysDisabled
+	}
+	if err := s.serviceKeyRepo.RevokeKey(ctx, keyUUID); err != nil {
+		return err
+	}
+	s.serviceKeys.reset()
+	return nil
+}
+
+// VerifyServiceKey resolves service-key metadata to the calling service and the
+// gRPC methods it may call
+func (s *AuthService) VerifyServiceKey(ctx context.Context, rawKey string) (string, []string, error) {
+	if s.serviceKeyRepo == nil || !strings.HasPrefix(rawKey, serviceKeyPrefix) {
+		return "", nil, ErrInvalidServiceKey
+	}
+
+	now := time.Now()
+	keyHash := hashOpaqueToken(rawKey)
+	if key, ok := s.serviceKeys.get(keyHash, now); ok {
+		return key.ServiceName, key.AllowedMethods, nil
+	}
+	key, err := s.serviceKeyRepo.GetActiveKey(ctx, keyHash, now)
+	if err != nil {
+		if !errors.Is(err, ErrServiceKeyNotFound) {
+			log.Printf("Failed to verify service key: %v", err)
+		}
+		return "", nil, ErrInvalidServiceKey
+	}
+	if err := s.serviceKeyRepo.MarkUsed(ctx, key.ID, now); err != nil {
+		log.Printf("Failed to record service key use: %v", err)
+	}
+	s.serviceKeys.set(keyHash, key)
+	return key.ServiceName, key.AllowedMethods, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func newServiceKeyTestService(t *testing.T) (*AuthService, *fakeServiceKeys) {
+	t.Helper()
+	keys := &fakeServiceKeys{}
+	s := NewAuthService(newFakeUsers())
+	s.SetServiceKeyRepo(keys)
+	return s, keys
+}
+
+func createServiceKey(t *testing.T, s *AuthService, expiresAt *time.Time) (*model.ServiceKey, string) {
+	t.Helper()
+	key, rawKey, err := s.CreateServiceKey(context.Background(), "00000000-0000-0000-0000-000000000001", ServiceKeyInput{
+		ServiceName:    "billing",
+		AllowedMethods: []string{"auth.v1.AuthService/CheckPermission", "auth.v1.RelationService"},
+		ExpiresAt:      expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateServiceKey: %v", err)
+	}
+	return key, rawKey
+}
+
+func TestVerifyServiceKey(t *testing.T) {
+	s, keys := newServiceKeyTestService(t)
+	ctx := context.Background()
+	_, rawKey := createServiceKey(t, s, nil)
+
+	name, methods, err := s.VerifyServiceKey(ctx, rawKey)
+	if err != nil {
+		t.Fatalf("VerifyServiceKey: %v", err)
+	}
+	if name != "billing" || len(methods) != 2 {
+		t.Fatalf("verified %q with %v", name, methods)
+	}
+	if _, _, err := s.VerifyServiceKey(ctx, rawKey); err != nil {
+		t.Fatalf("VerifyServiceKey from cache: %v", err)
+	}
+	if keys.lookups != 1 {
+		t.Fatalf("store looked up %d times, want the second call served from the cache", keys.lookups)
+	}
+	if keys.keys[0].LastUsedAt == nil {
+		t.Fatal("key use was not recorded")
+	}
+
+	for _, candidate := range []string{"", "billing", rawKey[len(serviceKeyPrefix):], rawKey + "x", serviceKeyPrefix + "unknown"} {
+		if _, _, err := s.VerifyServiceKey(ctx, candidate); !errors.Is(err, ErrInvalidServiceKey) {
+			t.Fatalf("%q: error = %v, want ErrInvalidServiceKey", candidate, err)
+		}
+	}
+}
+
+func TestVerifyServiceKeyExpiry(t *testing.T) {
+	s, keys := newServiceKeyTestService(t)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	if _, _, err := s.CreateServiceKey(ctx, "", ServiceKeyInput{ServiceName: "billing", AllowedMethods: []string{"auth.v1.AuthService"}, ExpiresAt: &past}); !errors.Is(err, ErrInvalidServiceKey) {
+		t.Fatalf("key expiring in the past: error = %v, want ErrInvalidServiceKey", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	_, rawKey := createServiceKey(t, s, &expiresAt)
+	if _, _, err := s.VerifyServiceKey(ctx, rawKey); err != nil {
+		t.Fatalf("VerifyServiceKey: %v", err)
+	}
+
+	// Move the expiry of the stored and the cached key into the past, as if the hour went by
+	keys.keys[0].ExpiresAt = &past
+	cached, ok := s.serviceKeys.get(hashOpaqueToken(rawKey), time.Now())
+	if !ok {
+		t.Fatal("verified key was not cached")
+	}
+	cached.ExpiresAt = &past
+
+	if _, _, err := s.VerifyServiceKey(ctx, rawKey); !errors.Is(err, ErrInvalidServiceKey) {
+		t.Fatalf("expired key: error = %v, want ErrInvalidServiceKey", err)
+	}
+	if keys.lookups != 2 {
+		t.Fatalf("store looked up %d times, want the expired key not served from the cache", keys.lookups)
+	}
+}
+
+func TestRevokeServiceKey(t *testing.T) {
+	s, keys := newServiceKeyTestService(t)
+	ctx := context.Background()
+	key, rawKey := createServiceKey(t, s, nil)
+	if _, _, err := s.VerifyServiceKey(ctx, rawKey); err != nil {
+		t.Fatalf("VerifyServiceKey: %v", err)
+	}
+
+	if err := s.RevokeServiceKey(ctx, key.UUID); err != nil {
+		t.Fatalf("RevokeServiceKey: %v", err)
+	}
+	if _, _, err := s.VerifyServiceKey(ctx, rawKey); !errors.Is(err, ErrInvalidServiceKey) {
+		t.Fatalf("key revoked on this instance: error = %v, want ErrInvalidServiceKey", err)
+	}
+	if err := s.RevokeServiceKey(ctx, key.UUID); !errors.Is(err, ErrServiceKeyNotFound) {
+		t.Fatalf("second revocation: error = %v, want ErrServiceKeyNotFound", err)
+	}
+
+	// A key revoked through another instance stays usable here until its cache entry expires
+	other, otherKey := createServiceKey(t, s, nil)
+	if _, _, err := s.VerifyServiceKey(ctx, otherKey); err != nil {
+		t.Fatalf("VerifyServiceKey: %v", err)
+	}
+	if err := keys.RevokeKey(ctx, other.UUID); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+	if _, _, err := s.VerifyServiceKey(ctx, otherKey); err != nil {
+		t.Fatalf("key revoked elsewhere was rejected within the cache window: %v", err)
+	}
+	if _, ok := s.serviceKeys.get(hashOpaqueToken(otherKey), time.Now().Add(serviceKeyCacheTTL+time.Second)); ok {
+		t.Fatalf("cache entry outlived %v", serviceKeyCacheTTL)
+	}
+
+	s.serviceKeys.mu.Lock()
+	entry := s.serviceKeys.entries[hashOpaqueToken(otherKey)]
+	entry.expiresAt = time.Now().Add(-time.Second)
+	s.serviceKeys.entries[hashOpaqueToken(otherKey)] = entry
+	s.serviceKeys.mu.Unlock()
+	if _, _, err := s.VerifyServiceKey(ctx, otherKey); !errors.Is(err, ErrInvalidServiceKey) {
+		t.Fatalf("key revoked elsewhere after the cache window: error = %v, want ErrInvalidServiceKey", err)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// Session store outcomes callers handle
+var (
+	ErrSessionNotFound     = repository.ErrSessionNotFound
+	ErrSessionLimitReached = repository.ErrSessionLimitReached
+)
+
+// ClientInfo describes the device a request came from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+	DeviceID  string // optional stable identifier sent by the client (X-Device-ID)
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo attaches the caller's device details to ctx for session and audit records
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func clientInfoFrom(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
+
+// SetSessionRepo enables server-side sessions: every login is recorded and can be revoked
+func (s *AuthService) SetSessionRepo(repo repository.SessionRepository) {
+	s.sessionRepo = repo
+}
+
+// issueLoginToken records a new session for a completed login and issues its access token.
+// The login must satisfy the tenant's policy. The token never outlives its session.
+// When the tenant requires MFA the user has not enrolled, an MFA enrollment token is
+// returned with ErrMFAEnrollmentRequired.
+func (s *AuthService) issueLoginToken(ctx context.Context, user *model.User, amr []string) (string, error) {
+	return s.issueSessionToken(ctx, user, amr, true)
+}
+
+// issueSignupToken signs a new user in. Mandatory MFA and login method rules are
+// not applied so the user can enroll the factors their tenant requires.
+func (s *AuthService) issueSignupToken(ctx context.Context, user *model.User, amr []string) (string, error) {
+	return s.issueSessionToken(ctx, user, amr, false)
+}
+
+func (s *AuthService) issueSessionToken(ctx context.Context, user *model.User, amr []string, enforceAuthPolicy bool) (string, error) {
+	scoped, tenant, err := s.scopeToTenant(ctx, user, user.TenantID)
+	if err != nil {
+		s.recordLoginFailure(ctx, user, user.Email, amr, loginFailureTenantDenied)
+		return "", err
+	}
+	// Report the role held in the default tenant back to the caller
+	user.Role = scoped.Role
+
+	var policy TenantPolicy
+	if tenant != nil {
+		policy = tenantPolicyFromSettings(tenant.UUID, tenant.Settings)
+	}
+	err = policy.CheckEmail(user.Email)
+	if err == nil && enforceAuthPolicy {
+		err = policy.CheckAuthentication(amr)
+	}
+	if err != nil {
+		s.recordLoginFailure(ctx, user, user.Email, amr, loginFailurePolicy)
+		if errors.Is(err, ErrMFAEnrollmentRequired) {
+			// Let the user enroll a second factor instead of locking them out
+			enrollmentToken, tokenErr := generateMFAEnrollmentToken(user)
+			if tokenErr != nil {
+				return "", tokenErr
+			}
+			return enrollmentToken, err
+		}
+		return "", err
+	}
+
+	session, err := s.startSession(ctx, user, amr, policy.SessionLifetime(), "")
+	if err != nil {
+		if errors.Is(err, ErrSessionLimitReached) {
+			s.recordLoginFailure(ctx, user, user.Email, amr, loginFailureSessionLimit)
+		}
+		return "", err
+	}
+	s.recordLoginSuccess(ctx, user, amr)
+
+	ttl := sessionTokenTTL(policy, session)
+	if session == nil {
+		return issueAccessToken(user, tenant, amr, ttl, "")
+	}
+	return issueAccessToken(user, tenant, amr, ttl, session.UUID)
+}
+
+// sessionTokenTTL is the lifetime of an access token for session, capped by the
+// tenant's session lifetime and by when the session ends
+func sessionTokenTTL(policy TenantPolicy, session *model.Session) time.Duration {
+	ttl := accessTokenTTL
+	if lifetime := policy.SessionLifetime(); lifetime > 0 && lifetime < ttl {
+		ttl = lifetime
+	}
+	if session != nil {
+		if remaining := time.Until(session.ExpiresAt); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl
+}
+
+// startSession stores the session of a login. lifetime, when set, caps how long
+// the session lasts (the store may shorten it further). clientID names the OAuth
+// client the session was created for, if any.
+func (s *AuthService) startSession(ctx context.Context, user *model.User, amr []string, lifetime time.Duration, clientID string) (*model.Session, error) {
+	if s.sessionRepo == nil {
+		return nil, nil
+	}
+
+	amr = withMFA(amr)
+	info := clientInfoFrom(ctx)
+	now := time.Now()
+	session := &model.Session{
+		UserUUID:   user.UUID,
+		UserAgent:  truncate(info.UserAgent, 512),
+		IPAddress:  info.IPAddress,
+		AMR:        strings.Join(amr, ","),
+		MFA:        len(amr) > 1,
+		ClientID:   clientID,
+		LastSeenAt: now,
+	}
+	if lifetime > 0 {
+		session.ExpiresAt = now.Add(lifetime)
+	}
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// IsSessionActive reports whether the session behind a token may still be used
+// (not revoked, expired or idle), recording activity as a side effect so the idle
+// timeout slides. Tokens minted before sessions existed carry no sid and are
+// accepted until they expire.
+func (s *AuthService) IsSessionActive(ctx context.Context, sessionID string) bool {
+	if s.sessionRepo == nil || sessionID == "" {
+		return true
+	}
+
+	if _, err := s.sessionRepo.ValidateSession(ctx, sessionID, time.Now()); err != nil {
+		if !errors.Is(err, ErrSessionNotFound) {
+			log.Printf("Failed to validate session: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func (s *AuthService) ListSessions(ctx context.Context, userUUID string) ([]model.Session, error) {
+	if s.sessionRepo == nil {
+		return nil, nil
+	}
+	return s.sessionRepo.ListActiveSessions(ctx, userUUID)
+}
+
+// RevokeSession signs one of the user's devices out
+func (s *AuthService) RevokeSession(ctx context.Context, userUUID, sessionID string) error {
+	if s.sessionRepo == nil {
+		return ErrSessionNotFound
+	}
+	return s.sessionRepo.RevokeSession(ctx, userUUID, sessionID)
+}
+
+// RevokeUserSession signs a user out of one device on behalf of support staff
+func (s *AuthService) RevokeUserSession(ctx context.Context, actor Actor, userUUID, sessionID string) error {
+	if err := s.RevokeSession(ctx, userUUID, sessionID); err != nil {
+		return err
+	}
+
+	err := s.recordAudit(ctx, &model.AuditEvent{
+		Action:      AuditSessionRevoke,
+		ActorUUID:   actor.UserUUID,
+		SubjectUUID: userUUID,
+		TenantUUID:  actor.TenantUUID,
+		Details:     map[string]string{"session_id": sessionID},
+	})
+	if err != nil && !errors.Is(err, ErrAuditDisabled) {
+		log.Printf("Failed to audit session revocation: %v", err)
+	}
+	return nil
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func TestRevokeUserSession(t *testing.T) {
+	sessions := newFakeSessionStore()
+	audit := &fakeAudit{}
+	s := NewAuthService(newFakeUsers())
+	s.SetSessionRepo(sessions)
+	s.SetAuditRepo(audit)
+	ctx := context.Background()
+
+	session := &model.Session{UserUUID: "user-1"}
+	if err := sessions.CreateSession(ctx, session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	support := Actor{UserUUID: "support-1", TenantUUID: "tenant-1"}
+
+	if err := s.RevokeUserSession(ctx, support, "user-2", session.UUID); err == nil {
+		t.Fatal("revoked a session through another user")
+	}
+	if err := s.RevokeUserSession(ctx, support, "user-1", session.UUID); err != nil {
+		t.Fatalf("RevokeUserSession: %v", err)
+	}
+	if active, _ := s.ListSessions(ctx, "user-1"); len(active) != 0 {
+		t.Fatalf("sessions still active: %+v", active)
+	}
+
+	if len(audit.events) != 1 {
+		t.Fatalf("recorded %d audit events, want 1", len(audit.events))
+	}
+	event := audit.events[0]
+	if event.Action != AuditSessionRevoke || event.ActorUUID != "support-1" || event.SubjectUUID != "user-1" || event.Details["session_id"] != session.UUID {
+		t.Fatalf("unexpected audit event %+v", event)
+	}
+}
+
+func TestLoginAtSessionLimit(t *testing.T) {
+	s, _, _, sessions, user := newMagicLinkTestService(t)
+	sessions.max = 1
+	ctx := context.Background()
+
+	token, _, err := s.Login(ctx, user.Email, "correct horse")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, _, err := s.Login(ctx, user.Email, "correct horse"); !errors.Is(err, ErrSessionLimitReached) {
+		t.Fatalf("error = %v, want ErrSessionLimitReached", err)
+	}
+
+	// Signing out of a device makes room for another
+	sessionID, _ := tokenClaims(t, token)["sid"].(string)
+	if err := s.RevokeSession(ctx, user.UUID, sessionID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, _, err := s.Login(ctx, user.Email, "correct horse"); err != nil {
+		t.Fatalf("Login after revoking: %v", err)
+	}
+	if err := s.RevokeSession(ctx, user.UUID, sessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking twice: error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestExpiredSessionInvalidatesToken(t *testing.T) {
+	s, _, _, sessions, user := newMagicLinkTestService(t)
+	ctx := context.Background()
+
+	token, _, err := s.Login(ctx, user.Email, "correct horse")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	sessionID, _ := tokenClaims(t, token)["sid"].(string)
+	if !s.IsSessionActive(ctx, sessionID) {
+		t.Fatal("new session is not active")
+	}
+
+	sessions.sessions[sessionID].ExpiresAt = time.Now().Add(-time.Second)
+	if s.IsSessionActive(ctx, sessionID) {
+		t.Fatal("expired session is active")
+	}
+	if valid, _ := s.ValidateToken(ctx, token); valid {
+		t.Fatal("token of an expired session validated")
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+// SMSSender delivers text messages to E.164 phone numbers. Messages carry
+// one-time login codes and must never be logged.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phoneNumber, message string) error
+}
+
+const twilioAPIBase = "https://api.twilio.com/2010-04-01"
+
+// TwilioSMSSender delivers text messages through the Twilio Messaging API
+type TwilioSMSSender struct {
+	accountSID string
+	authToken  string
+	from       string // E.164 number or messaging service SID
+	apiBase    string
+	client     *http.Client
+}
+
+func NewTwilioSMSSender(accountSID, authToken, from string) (*TwilioSMSSender, error) {
+	if accountSID == "" || authToken == "" || from == "" {
+		return nil, errors.New("twilio account sid, auth token and sender are required")
+	}
+	return &TwilioSMSSender{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		apiBase:    twilioAPIBase,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (t *TwilioSMSSender) SendSMS(ctx context.Context, phoneNumber, message string) error {
+	form := url.Values{"To": {phoneNumber}, "Body": {message}}
+	if strings.HasPrefix(t.from, "MG") {
+		form.Set("MessagingServiceSid", t.from)
+	} else {
+		form.Set("From", t.from)
+	}
+
+	endpoint := t.apiBase + "/Accounts/" + url.PathEscape(t.accountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.accountSID, t.authToken)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var body struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+		return fmt.Errorf("twilio returned %s: %d %s", resp.Status, body.Code, body.Message)
+	}
+	return nil
+}
+
+// SetSMSSender enables phone verification and, when otpLogin is true, login by phone number
+func (s *AuthService) SetSMSSender(sender SMSSender, otpRepo repository.PhoneOTPRepository, otpLogin bool) {
+	s.smsSender = sender
+	s.phoneOTPRepo = otpRepo
+	s.phoneOTPLogin = otpLogin
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BeginWebAuthnReauth starts a passkey assertion for an already signed-in user
+func (s *AuthService) BeginWebAuthnReauth(ctx context.Context, userUUID string) (string, *protocol.CredentialAssertion, error) {
+	if s.webauthn == nil {
+		return "", nil, ErrWebAuthnDisabled
+	}
+	return s.beginWebAuthnUserAssertion(ctx, userUUID, nil)
+}
+
+// Reauthenticate re-verifies the signed-in user with their password and/or a passkey
+// assertion and issues a short-lived elevated token for the same session whose
+// auth_time is now and whose amr lists exactly the factors verified here.
+func (s *AuthService) Reauthenticate(ctx context.Context, userUUID, tenantUUID, sessionID, password, ceremonyID string, assertion []byte) (string, *model.User, error) {
+	if password == "" && ceremonyID == "" {
+		return "", nil, errors.New("password or passkey assertion required")
+	}
+
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !s.loginFailures.Allowed(user.Email) {
+		return "", nil, ErrTooManyAttempts
+	}
+
+	var amr []string
+
+	if password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			s.loginFailures.Record(user.Email)
+			return "", nil, errors.New("invalid credentials")
+		}
+		amr = append(amr, AMRPassword)
+	}
+
+	if ceremonyID != "" {
+		if s.webauthn == nil {
+			return "", nil, ErrWebAuthnDisabled
+		}
+		assertedUser, challenge, err := s.verifyWebAuthnAssertion(ctx, ceremonyID, assertion)
+		if err != nil || challenge.UserUUID != user.UUID || assertedUser.UUID != user.UUID {
+			s.loginFailures.Record(user.Email)
+			return "", nil, errors.New("invalid credentials")
+		}
+		amr = append(amr, AMRWebAuthn)
+	}
+	s.loginFailures.Reset(user.Email)
+
+	// The elevated token stays in the tenant the user is currently working in
+	if tenantUUID == "" {
+		tenantUUID = user.TenantID
+	}
+	scoped, tenant, err := s.scopeToTenant(ctx, user, tenantUUID)
+	if err != nil {
+		return "", nil, err
+	}
+	if tenant != nil {
+		if err := tenantPolicyFromSettings(tenant.UUID, tenant.Settings).CheckMethods(amr); err != nil {
+			return "", nil, err
+		}
+	}
+
+	tokenString, err := issueAccessToken(scoped, tenant, amr, elevatedTokenTTL, sessionID)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, scoped, nil
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	maxTenantSlugLength = 63
+	maxTenantListLimit  = 200
+	// legacyTenantSlug receives users created before tenants existed when no default tenant is configured
+	legacyTenantSlug = "default"
+)
+
+var (
+	ErrTenantInactive     = errors.New("tenant is not active")
+	ErrInvalidTenantSlug  = errors.New("invalid tenant slug")
+	ErrMembershipInactive = errors.New("tenant membership is suspended")
+	ErrTenantNotFound     = repository.ErrTenantNotFound
+	ErrMembershipNotFound = repository.ErrMembershipNotFound
+
+	tenantSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	nonSlugChars      = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// SetTenantRepo enables tenants. defaultSlug names the tenant that self-service
+// signups join; when empty every signup provisions its own tenant.
+func (s *AuthService) SetTenantRepo(repo repository.TenantRepository, defaultSlug string) {
+	s.tenantRepo = repo
+	s.defaultTenantSlug = defaultSlug
+}
+
+// scopeToTenant returns a copy of user acting in tenantUUID, with the role of
+// their membership there. Suspended tenants and memberships are rejected.
+func (s *AuthService) scopeToTenant(ctx context.Context, user *model.User, tenantUUID string) (*model.User, *model.Tenant, error) {
+	scoped := *user
+	scoped.TenantID = tenantUUID
+
+	if s.membershipRepo != nil {
+		membership, err := s.membershipRepo.GetMembership(ctx, user.UUID, tenantUUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !membership.IsActive() {
+			return nil, nil, ErrMembershipInactive
+		}
+		scoped.Role = membership.Role
+	} else if tenantUUID != user.TenantID {
+		return nil, nil, ErrMembershipNotFound
+	}
+
+	if s.tenantRepo == nil {
+		return &scoped, nil, nil
+	}
+	tenant, err := s.tenantRepo.GetByUUID(ctx, tenantUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !tenant.IsActive() {
+		return nil, nil, ErrTenantInactive
+	}
+	return &scoped, tenant, nil
+}
+
+// findTenant looks a tenant up by UUID or slug
+func (s *AuthService) findTenant(ctx context.Context, tenantRef string) (*model.Tenant, error) {
+	if _, err := uuid.Parse(tenantRef); err == nil {
+		return s.tenantRepo.GetByUUID(ctx, tenantRef)
+	}
+	return s.tenantRepo.GetBySlug(ctx, tenantRef)
+}
+
+// tenantPolicy returns the auth policy of a tenant (no restrictions when tenants are disabled)
+func (s *AuthService) tenantPolicy(ctx context.Context, tenantUUID string) (TenantPolicy, error) {
+	if s.tenantRepo == nil {
+		return TenantPolicy{}, nil
+	}
+	tenant, err := s.tenantRepo.GetByUUID(ctx, tenantUUID)
+	if err != nil {
+		return TenantPolicy{}, err
+	}
+	return tenantPolicyFromSettings(tenant.UUID, tenant.Settings), nil
+}
+
+// CreateTenant provisions a tenant. The slug is derived from the name when empty.
+func (s *AuthService) CreateTenant(ctx context.Context, name, slug string) (*model.Tenant, error) {
+	if s.tenantRepo == nil {
+		return nil, errors.New("tenants not configured")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("tenant name is required")
+	}
+
+	if slug == "" {
+		generated, err := s.uniqueTenantSlug(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		slug = generated
+	} else if !validTenantSlug(slug) {
+		return nil, ErrInvalidTenantSlug
+	}
+
+	tenant := &model.Tenant{
+		Slug:   slug,
+		Name:   name,
+		Status: model.TenantStatusActive,
+	}
+	if err := s.tenantRepo.CreateTenant(ctx, tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *AuthService) GetTenant(ctx context.Context, tenantUUID string) (*model.Tenant, error) {
+	if s.tenantRepo == nil {
+		return nil, errors.New("tenants not configured")
+	}
+	return s.tenantRepo.GetByUUID(ctx, tenantUUID)
+}
+
+func (s *AuthService) ListTenants(ctx context.Context, limit, offset int) ([]model.Tenant, error) {
+	if s.tenantRepo == nil {
+		return nil, errors.New("tenants not configured")
+	}
+	if limit <= 0 || limit > maxTenantListLimit {
+		limit = maxTenantListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.tenantRepo.ListTenants(ctx, limit, offset)
+}
+
+// TenantUpdate lists the tenant fields to change; nil fields are left untouched
+type TenantUpdate struct {
+	Name     *string
+	Status   *string
+	Settings json.RawMessage
+}
+
+// UpdateTenant renames, suspends/reactivates or reconfigures a tenant.
+// Suspending a tenant blocks new logins and user creation for it.
+func (s *AuthService) UpdateTenant(ctx context.Context, tenantUUID string, update TenantUpdate) (*model.Tenant, error) {
+	tenant, err := s.GetTenant(ctx, tenantUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, errors.New("tenant name is required")
+		}
+		tenant.Name = name
+	}
+	if update.Status != nil {
+		if *update.Status != model.TenantStatusActive && *update.Status != model.TenantStatusSuspended {
+			return nil, errors.New("invalid tenant status")
+		}
+		tenant.Status = *update.Status
+	}
+	if update.Settings != nil {
+		var settings map[string]interface{}
+		if err := json.Unmarshal(update.Settings, &settings); err != nil || settings == nil {
+			return nil, errors.New("tenant settings must be a JSON object")
+		}
+		if _, err := parseTenantPolicy(string(update.Settings)); err != nil {
+			return nil, err
+		}
+		tenant.Settings = string(update.Settings)
+	}
+
+	if err := s.tenantRepo.UpdateTenant(ctx, tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// createSignupUser stores a self-service signup. The user joins the default tenant
+// when no tenant is named; otherwise a new tenant, named or personal, is created
+// with the user as its admin.
+func (s *AuthService) createSignupUser(ctx context.Context, user *model.User, password, tenantName string) error {
+	if s.tenantRepo == nil {
+		return errors.New("tenants not configured")
+	}
+
+	tenantName = strings.TrimSpace(tenantName)
+	if tenantName == "" && s.defaultTenantSlug != "" {
+		tenant, err := s.tenantRepo.GetBySlug(ctx, s.defaultTenantSlug)
+		if err != nil {
+			return err
+		}
+		policy := tenantPolicyFromSettings(tenant.UUID, tenant.Settings)
+		if err := policy.CheckEmail(user.Email); err != nil {
+			return err
+		}
+		if err := policy.CheckPassword(password); err != nil {
+			return err
+		}
+		user.TenantID = tenant.UUID
+		return s.repo.CreateUser(ctx, user)
+	}
+
+	if tenantName == "" {
+		tenantName = user.Username
+	}
+	user.Role = "admin"
+	slug, err := s.uniqueTenantSlug(ctx, tenantName)
+	if err != nil {
+		return err
+	}
+	tenant := &model.Tenant{
+		Slug:   slug,
+		Name:   tenantName,
+		Status: model.TenantStatusActive,
+	}
+	return s.repo.CreateUserWithTenant(ctx, user, tenant)
+}
+
+// AdoptUsersWithoutTenant assigns users created before tenants existed to the
+// default tenant, creating it when needed. It is safe to run on every startup.
+func (s *AuthService) AdoptUsersWithoutTenant(ctx context.Context) error {
+	if s.tenantRepo == nil {
+		return nil
+	}
+	count, err := s.tenantRepo.CountUsersWithoutTenant(ctx)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	slug := s.defaultTenantSlug
+	if slug == "" {
+		slug = legacyTenantSlug
+	}
+	tenant, err := s.tenantRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		tenant = &model.Tenant{Slug: slug, Name: slug, Status: model.TenantStatusActive}
+		if err := s.tenantRepo.CreateTenant(ctx, tenant); err != nil {
+			return err
+		}
+	}
+
+	adopted, err := s.tenantRepo.AdoptUsersWithoutTenant(ctx, tenant.UUID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Assigned %d users without a tenant to tenant %q", adopted, tenant.Slug)
+	return nil
+}
+
+// uniqueTenantSlug derives a slug from name, adding a numeric suffix when taken
+func (s *AuthService) uniqueTenantSlug(ctx context.Context, name string) (string, error) {
+	base := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if base == "" {
+		base = "tenant"
+	}
+	base = strings.TrimRight(truncate(base, maxTenantSlugLength-4), "-")
+
+	slug := base
+	for i := 2; ; i++ {
+		exists, err := s.tenantRepo.SlugExists(ctx, slug)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return slug, nil
+		}
+		if i > 999 {
+			return "", errors.New("could not generate a unique tenant slug")
+		}
+		slug = base + "-" + strconv.Itoa(i)
+	}
+}
+
+func validTenantSlug(slug string) bool {
+	return len(slug) <= maxTenantSlugLength && tenantSlugPattern.MatchString(slug)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	// domainVerificationLabel is prepended to a domain to find its verification TXT record
+	domainVerificationLabel = "_auth-service-verification"
+	// domainVerificationPrefix starts the expected TXT record value
+	domainVerificationPrefix = "auth-service-verification="
+)
+
+var (
+	ErrTenantDomainsDisabled = errors.New("tenant domains are not configured")
+
+	domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// TenantDiscovery tells a login page which tenant handles an email address
+type TenantDiscovery struct {
+	Tenant       *model.Tenant
+	LoginMethods []string // amr values the tenant accepts
+}
+
+// SetTenantDomains enables verified tenant domains for home-realm discovery and
+// email-domain based joining
+func (s *AuthService) SetTenantDomains(domainRepo repository.TenantDomainRepository, joinRequestRepo repository.JoinRequestRepository) {
+	s.tenantDomainRepo = domainRepo
+	s.joinRequestRepo = joinRequestRepo
+	s.lookupTXT = net.DefaultResolver.LookupTXT
+}
+
+// AddTenantDomain claims an email domain for a tenant. It has no effect until
+// VerifyTenantDomain finds the verification TXT record.
+func (s *AuthService) AddTenantDomain(ctx context.Context, tenantUUID, domain, joinPolicy string) (*model.TenantDomain, error) {
+	if s.tenantDomainRepo == nil {
+		return nil, ErrTenantDomainsDisabled
+	}
+
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) > 253 || !domainPattern.MatchString(domain) {
+		return nil, errors.New("invalid domain")
+	}
+	if joinPolicy == "" {
+		joinPolicy = model.DomainJoinNone
+	}
+	if !validDomainJoinPolicy(joinPolicy) {
+		return nil, errors.New("invalid join policy")
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	record := &model.TenantDomain{
+		TenantUUID:        tenantUUID,
+		Domain:            domain,
+		JoinPolicy:        joinPolicy,
+		VerificationToken: token,
+	}
+	if err := s.tenantDomainRepo.CreateDomain(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// DomainVerificationRecord returns the DNS TXT record (name and value) that proves ownership of a domain
+func DomainVerificationRecord(domain *model.TenantDomain) (string, string) {
+	return domainVerificationLabel + "." + domain.Domain, domainVerificationPrefix + domain.VerificationToken
+}
+
+// VerifyTenantDomain checks DNS for the domain's verification TXT record
+func (s *AuthService) VerifyTenantDomain(ctx context.Context, tenantUUID, domainUUID string) (*model.TenantDomain, error) {
+	if s.tenantDomainRepo == nil {
+		return nil, ErrTenantDomainsDisabled
+	}
+
+	domain, err := s.tenantDomainRepo.GetDomain(ctx, tenantUUID, domainUUID)
+	if err != nil {
+		return nil, err
+	}
+	if domain.VerifiedAt != nil {
+		return domain, nil
+	}
+
+	name, expected := DomainVerificationRecord(domain)
+	records, err := s.lookupTXT(ctx, name)
+	if err != nil {
+		log.Printf("Domain verification lookup for %s failed: %v", name, err)
+		return nil, errors.New("verification record not found")
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == expected {
+			if err := s.tenantDomainRepo.MarkVerified(ctx, domain, time.Now()); err != nil {
+				return nil, err
+			}
+			return domain, nil
+		}
+	}
+	return nil, errors.New("verification record not found")
+}
+
+func (s *AuthService) ListTenantDomains(ctx context.Context, tenantUUID string) ([]model.TenantDomain, error) {
+	if s.tenantDomainRepo == nil {
+		return nil, ErrTenantDomainsDisabled
+	}
+	return s.tenantDomainRepo.ListDomains(ctx, tenantUUID)
+}
+
+// UpdateTenantDomain changes what happens when people with the domain sign up
+func (s *AuthService) UpdateTenantDomain(ctx context.Context, tenantUUID, domainUUID, joinPolicy string) (*model.TenantDomain, error) {
+	if s.tenantDomainRepo == nil {
+		return nil, ErrTenantDomainsDisabled
+	}
+	if !validDomainJoinPolicy(joinPolicy) {
+		return nil, errors.New("invalid join policy")
+	}
+	if err := s.tenantDomainRepo.UpdateJoinPolicy(ctx, tenantUUID, domainUUID, joinPolicy); err != nil {
+		return nil, err
+	}
+	return s.tenantDomainRepo.GetDomain(ctx, tenantUUID, domainUUID)
+}
+
+func (s *AuthService) DeleteTenantDomain(ctx context.Context, tenantUUID, domainUUID string) error {
+	if s.tenantDomainRepo == nil {
+		return ErrTenantDomainsDisabled
+	}
+	return s.tenantDomainRepo.DeleteDomain(ctx, tenantUUID, domainUUID)
+}
+
+// DiscoverTenant finds the tenant that verified the email's domain (home-realm discovery)
+func (s *AuthService) DiscoverTenant(ctx context.Context, email string) (*TenantDiscovery, error) {
+	if s.tenantDomainRepo == nil || s.tenantRepo == nil {
+		return nil, ErrTenantDomainsDisabled
+	}
+
+	notFound := errors.New("no tenant for this email domain")
+	domain, err := s.tenantDomainRepo.GetVerifiedDomain(ctx, emailDomain(email))
+	if err != nil {
+		return nil, notFound
+	}
+	tenant, err := s.tenantRepo.GetByUUID(ctx, domain.TenantUUID)
+	if err != nil || !tenant.IsActive() {
+		return nil, notFound
+	}
+
+	policy := tenantPolicyFromSettings(tenant.UUID, tenant.Settings)
+	methods := make([]string, 0, len(policyLoginMethods))
+	for _, method := range []string{AMRPassword, AMROTP, AMRWebAuthn, AMRFederated} {
+		if policy.AllowsMethod(method) {
+			methods = append(methods, method)
+		}
+	}
+	return &TenantDiscovery{Tenant: tenant, LoginMethods: methods}, nil
+}
+
+// joinTenantByDomain handles a new user whose email domain was verified by a
+// tenant. Signup does not prove email ownership, so auto-join sends an
+// invitation the user must accept; without invitations a join request is filed.
+// Failures are logged and never fail the signup.
+func (s *AuthService) joinTenantByDomain(ctx context.Context, user *model.User) {
+	if s.tenantDomainRepo == nil || s.tenantRepo == nil {
+		return
+	}
+
+	domain, err := s.tenantDomainRepo.GetVerifiedDomain(ctx, emailDomain(user.Email))
+	if err != nil || domain.TenantUUID == user.TenantID || domain.JoinPolicy == model.DomainJoinNone {
+		return
+	}
+	tenant, err := s.tenantRepo.GetByUUID(ctx, domain.TenantUUID)
+	if err != nil || !tenant.IsActive() {
+		return
+	}
+
+	if domain.JoinPolicy == model.DomainJoinAuto && s.invitationsEnabled() {
+		// The user invites themselves; accepting proves they own the address
+		_, err = s.sendInvitation(ctx, tenant, user.UUID, user.Email, "user",
+			"Confirm your email to join "+tenant.Name,
+			"Your email address lets you join "+tenant.Name+". Open the link below to confirm it.")
+	} else if s.joinRequestRepo != nil {
+		err = s.joinRequestRepo.CreateJoinRequest(ctx, &model.TenantJoinRequest{
+			TenantUUID: tenant.UUID,
+			UserUUID:   user.UUID,
+			Status:     model.JoinRequestPending,
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to join user %s to tenant %s by email domain: %v", user.UUID, tenant.Slug, err)
+	}
+}
+
+// ListJoinRequests lists a tenant's pending join requests
+func (s *AuthService) ListJoinRequests(ctx context.Context, tenantUUID string) ([]model.TenantJoinRequest, error) {
+	if s.joinRequestRepo == nil {
+		return nil, ErrTenantDomainsDisabled
+	}
+	return s.joinRequestRepo.ListPendingJoinRequests(ctx, tenantUUID)
+}
+
+// DecideJoinRequest approves (adding the user as a "user" member) or rejects a join request
+func (s *AuthService) DecideJoinRequest(ctx context.Context, tenantUUID, requestUUID, deciderUUID string, approve bool) (*model.TenantJoinRequest, error) {
+	if s.joinRequestRepo == nil {
+		return nil, ErrTenantDomainsDisabled
+	}
+	return s.joinRequestRepo.DecideJoinRequest(ctx, tenantUUID, requestUUID, deciderUUID, approve, "user")
+}
+
+func validDomainJoinPolicy(joinPolicy string) bool {
+	switch joinPolicy {
+	case model.DomainJoinNone, model.DomainJoinAuto, model.DomainJoinRequest:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	minPasswordLength = 6  // same as the signup request validation
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+var (
+	ErrMFAEnrollmentRequired = errors.New("tenant requires multi-factor authentication")
+	ErrLoginMethodNotAllowed = errors.New("login method not allowed for this tenant")
+	ErrEmailDomainNotAllowed = errors.New("email domain not allowed for this tenant")
+)
+
+// policyLoginMethods are the amr values a tenant may allow
+var policyLoginMethods = map[string]bool{
+	AMRPassword:  true,
+	AMROTP:       true,
+	AMRWebAuthn:  true,
+	AMRFederated: true,
+}
+
+// TenantPolicy is a tenant's authentication policy, stored under "auth_policy"
+// in the tenant settings. Zero values mean "no restriction".
+type TenantPolicy struct {
+	RequireMFA             bool     `json:"require_mfa"`
+	PasswordMinLength      int      `json:"password_min_length"`
+	PasswordRequireUpper   bool     `json:"password_require_upper"`
+	PasswordRequireLower   bool     `json:"password_require_lower"`
+	PasswordRequireDigit   bool     `json:"password_require_digit"`
+	PasswordRequireSymbol  bool     `json:"password_require_symbol"`
+	SessionLifetimeMinutes int      `json:"session_lifetime_minutes"`
+	AllowedLoginMethods    []string `json:"allowed_login_methods"` // amr values: "pwd", "otp", "webauthn", "fed"
+	AllowedEmailDomains    []string `json:"allowed_email_domains"`
+}
+
+type tenantSettings struct {
+	AuthPolicy *TenantPolicy `json:"auth_policy"`
+}
+
+// parseTenantPolicy reads and validates the auth policy from tenant settings JSON
+func parseTenantPolicy(settings string) (TenantPolicy, error) {
+	var parsed tenantSettings
+	if settings != "" {
+		if err := json.Unmarshal([]byte(settings), &parsed); err != nil {
+			return TenantPolicy{}, fmt.Errorf("invalid auth_policy: %w", err)
+		}
+	}
+	if parsed.AuthPolicy == nil {
+		return TenantPolicy{}, nil
+	}
+
+	policy := *parsed.AuthPolicy
+	if policy.PasswordMinLength != 0 && (policy.PasswordMinLength < minPasswordLength || policy.PasswordMinLength > maxPasswordLength) {
+		return TenantPolicy{}, fmt.Errorf("invalid auth_policy: password_min_length must be between %d and %d", minPasswordLength, maxPasswordLength)
+	}
+	if policy.SessionLifetimeMinutes < 0 {
+		return TenantPolicy{}, errors.New("invalid auth_policy: session_lifetime_minutes must not be negative")
+	}
+	if policy.AllowedLoginMethods != nil && len(policy.AllowedLoginMethods) == 0 {
+		return TenantPolicy{}, errors.New("invalid auth_policy: allowed_login_methods must not be empty")
+	}
+	for _, method := range policy.AllowedLoginMethods {
+		if !policyLoginMethods[method] {
+			return TenantPolicy{}, fmt.Errorf("invalid auth_policy: unknown login method %q", method)
+		}
+	}
+	for i, domain := range policy.AllowedEmailDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain == "" {
+			return TenantPolicy{}, errors.New("invalid auth_policy: empty email domain")
+		}
+		policy.AllowedEmailDomains[i] = domain
+	}
+	return policy, nil
+}
+
+// tenantPolicyFromSettings is parseTenantPolicy for stored settings. Settings are
+// validated when saved, so a parse failure only happens after manual edits; the
+// stored policy cannot be trusted then and the most restrictive one is returned.
+func tenantPolicyFromSettings(tenantUUID, settings string) TenantPolicy {
+	policy, err := parseTenantPolicy(settings)
+	if err != nil {
+		log.Printf("Tenant %s has an invalid auth policy, denying logins: %v", tenantUUID, err)
+		return TenantPolicy{AllowedLoginMethods: []string{}}
+	}
+	return policy
+}
+
+// CheckPassword enforces the tenant's password strength rules
+func (p TenantPolicy) CheckPassword(password string) error {
+	if p.PasswordMinLength > 0 && len(password) < p.PasswordMinLength {
+		return fmt.Errorf("password must be at least %d characters", p.PasswordMinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	switch {
+	case p.PasswordRequireUpper && !hasUpper:
+		return errors.New("password must contain an uppercase letter")
+	case p.PasswordRequireLower && !hasLower:
+		return errors.New("password must contain a lowercase letter")
+	case p.PasswordRequireDigit && !hasDigit:
+		return errors.New("password must contain a digit")
+	case p.PasswordRequireSymbol && !hasSymbol:
+		return errors.New("password must contain a symbol")
+	}
+	return nil
+}
+
+// CheckEmail enforces the tenant's allowed email domains
+func (p TenantPolicy) CheckEmail(email string) error {
+	if len(p.AllowedEmailDomains) == 0 {
+		return nil
+	}
+	domain := emailDomain(email)
+	if domain == "" {
+		return ErrEmailDomainNotAllowed
+	}
+	for _, allowed := range p.AllowedEmailDomains {
+		if domain == allowed {
+			return nil
+		}
+	}
+	return ErrEmailDomainNotAllowed
+}
+
+// emailDomain returns the lower-cased domain of an email address, or "" when it has none
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+// AllowsMethod reports whether the tenant accepts a login method (amr value)
+func (p TenantPolicy) AllowsMethod(method string) bool {
+	return p.AllowedLoginMethods == nil || containsString(p.AllowedLoginMethods, method)
+}
+
+// CheckMethods requires every factor (amr value) a login used to be allowed
+func (p TenantPolicy) CheckMethods(amr []string) error {
+	for _, method := range amr {
+		if method != AMRMFA && !p.AllowsMethod(method) {
+			return ErrLoginMethodNotAllowed
+		}
+	}
+	return nil
+}
+
+// CheckAuthentication enforces allowed login methods and mandatory MFA for a completed login
+func (p TenantPolicy) CheckAuthentication(amr []string) error {
+	if err := p.CheckMethods(amr); err != nil {
+		return err
+	}
+	// A user-verified passkey already combines possession with a PIN or biometric
+	if p.RequireMFA && len(withMFA(amr)) < 2 && !containsString(amr, AMRWebAuthn) {
+		return ErrMFAEnrollmentRequired
+	}
+	return nil
+}
+
+// SessionLifetime is the tenant's maximum session length, or 0 when unrestricted
+func (p TenantPolicy) SessionLifetime() time.Duration {
+	return time.Duration(p.SessionLifetimeMinutes) * time.Minute
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
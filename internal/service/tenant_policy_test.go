@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+func TestRequireMFAIssuesEnrollmentToken(t *testing.T) {
+	user := verifiedPhoneUser()
+	s, sms, _ := newPhoneTestService(user)
+	s.SetTenantRepo(newFakeTenants(&model.Tenant{
+		UUID:     user.TenantID,
+		Slug:     "acme",
+		Status:   model.TenantStatusActive,
+		Settings: `{"auth_policy":{"require_mfa":true}}`,
+	}), "")
+	ctx := context.Background()
+
+	if err := s.SendPhoneLoginCode(ctx, user.PhoneNumber); err != nil {
+		t.Fatalf("SendPhoneLoginCode: %v", err)
+	}
+	token, _, err := s.LoginWithPhone(ctx, user.PhoneNumber, sms.lastCode(t))
+	if !errors.Is(err, ErrMFAEnrollmentRequired) {
+		t.Fatalf("error = %v, want ErrMFAEnrollmentRequired", err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return jwtKey, nil }); err != nil {
+		t.Fatalf("enrollment token does not parse: %v", err)
+	}
+	if claims["token_use"] != tokenUseMFAEnrollment || claims["user_uuid"] != user.UUID {
+		t.Fatalf("unexpected enrollment token claims: %v", claims)
+	}
+
+	// The enrollment token is not a session
+	if valid, _ := s.ValidateToken(ctx, token); valid {
+		t.Fatal("enrollment token validated as an access token")
+	}
+}
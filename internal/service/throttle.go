@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many attempts, please try again later")
+
+const (
+	maxFailedLogins     = 5
+	failedLoginWindow   = 15 * time.Minute
+	maxMagicLinkSends   = 3
+	magicLinkSendWindow = 15 * time.Minute
+)
+
+// attemptLimiter counts attempts per key within a fixed window.
+// It backs both the failed-login lockout and per-address send limits.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	attempts map[string]*attemptWindow
+}
+
+type attemptWindow struct {
+	count int
+	start time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: make(map[string]*attemptWindow),
+	}
+}
+
+// Allowed reports whether key is still below the limit for the current window
+func (l *attemptLimiter) Allowed(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.attempts[key]
+	if !ok || time.Since(w.start) > l.window {
+		return true
+	}
+	return w.count < l.max
+}
+
+// Record counts one attempt for key
+func (l *attemptLimiter) Record(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, ok := l.attempts[key]
+	if !ok || now.Sub(w.start) > l.window {
+		l.purge(now)
+		l.attempts[key] = &attemptWindow{count: 1, start: now}
+		return
+	}
+	w.count++
+}
+
+// Reset clears the attempts for key, e.g. after a successful login
+func (l *attemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, key)
+}
+
+// purge drops expired windows so the map does not grow without bound. Callers hold the lock.
+func (l *attemptLimiter) purge(now time.Time) {
+	for key, w := range l.attempts {
+		if now.Sub(w.start) > l.window {
+			delete(l.attempts, key)
+		}
+	}
+}
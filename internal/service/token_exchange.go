@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/model"
+)
+
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+
+	// RFC 8693 section 2.2.2: the requested audience or resource is not accepted
+	OAuthInvalidTarget = "invalid_target"
+
+	// Tokens carrying an act claim are short-lived and never refreshed
+	exchangedTokenTTL           = 5 * time.Minute
+	impersonationTokenTTL       = 15 * time.Minute
+	maxImpersonationReasonChars = 500
+)
+
+var (
+	ErrInvalidImpersonation = errors.New("invalid impersonation request")
+	ErrImpersonationDenied  = errors.New("cannot impersonate this user")
+)
+
+// defaultImpersonationScopes let support staff see what a user sees in their
+// tenant without changing anything
+var defaultImpersonationScopes = []string{
+	PermTenantRead, PermMembersRead, PermInvitationsRead, PermDomainsRead, PermRolesRead, PermPoliciesRead,
+}
+
+// ImpersonationInput names the user support staff want to act as and why
+type ImpersonationInput struct {
+	UserUUID   string
+	TenantUUID string // defaults to the user's home tenant
+	Reason     string
+}
+
+// exchangeToken lets a confidential client call other services on behalf of
+// the user of a subject token (RFC 8693). The new token names the client in its
+// act claim, carries a subset of the subject's scopes and lives only a few minutes.
+func (s *AuthService) exchangeToken(ctx context.Context, client *model.OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if client.IsPublic() {
+		return nil, oauthError(OAuthUnauthorizedClient, "public clients cannot exchange tokens")
+	}
+	if req.SubjectToken == "" || req.SubjectTokenType == "" {
+		return nil, oauthError(OAuthInvalidRequest, "subject_token and subject_token_type are required")
+	}
+	if req.SubjectTokenType != TokenTypeAccessToken {
+		return nil, oauthError(OAuthInvalidRequest, "unsupported subject_token_type")
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		return nil, oauthError(OAuthInvalidRequest, "unsupported requested_token_type")
+	}
+	if req.ActorToken != "" || req.ActorTokenType != "" {
+		return nil, oauthError(OAuthInvalidRequest, "actor_token is not supported; the authenticated client is the actor")
+	}
+	if req.Audience != "" || req.Resource != "" {
+		return nil, oauthError(OAuthInvalidTarget, "audience and resource are not supported")
+	}
+	if s.auditRepo == nil {
+		return nil, ErrAuditDisabled
+	}
+
+	subject, err := s.parseSubjectToken(ctx, req.SubjectToken)
+	if err != nil {
+		return nil, oauthError(OAuthInvalidGrant, "invalid subject token")
+	}
+	// A third-party client's token may only be exchanged by that client
+	if audience, _ := subject.GetAudience(); len(audience) > 0 && !containsString(audience, client.ClientID) {
+		return nil, oauthError(OAuthInvalidGrant, "subject token was issued for another audience")
+	}
+
+	allowed := client.Scopes
+	if subjectScope, ok := subject["scope"].(string); ok {
+		allowed = intersectScopes(client.Scopes, splitScope(subjectScope))
+	}
+	scopes := allowed
+	if req.Scope != "" {
+		scopes = splitScope(req.Scope)
+		for _, requested := range scopes {
+			if !containsString(allowed, requested) {
+				return nil, oauthError(OAuthInvalidScope, fmt.Sprintf("scope %q exceeds the subject token or client", requested))
+			}
+		}
+	}
+
+	userUUID, _ := subject["user_uuid"].(string)
+	tenantUUID, _ := subject["tenant_id"].(string)
+	sessionID, _ := subject["sid"].(string)
+	amr := amrFromClaims(subject)
+	user, tenant, _, err := s.oauthGrantUser(ctx, userUUID, tenantUUID, amr)
+	if err != nil {
+		return nil, oauthError(OAuthInvalidGrant, err.Error())
+	}
+
+	// The new token never outlives the one it was exchanged for
+	ttl := exchangedTokenTTL
+	if expiresAt, err := subject.GetExpirationTime(); err == nil && expiresAt != nil {
+		if remaining := time.Until(expiresAt.Time); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	authTime := time.Now()
+	if value, ok := subject["auth_time"].(float64); ok {
+		authTime = time.Unix(int64(value), 0)
+	}
+
+	claims := accessTokenClaims(user, tenant, amr, authTime, ttl, sessionID)
+	claims["client_id"] = client.ClientID
+	claims["scope"] = strings.Join(scopes, " ")
+	if !client.FirstParty {
+		claims["aud"] = client.ClientID
+	}
+	act := map[string]interface{}{"sub": client.ClientID, "client_id": client.ClientID}
+	if prior, ok := subject["act"]; ok {
+		// Delegation chains keep earlier actors nested (RFC 8693 section 4.1)
+		act["act"] = prior
+	}
+	claims["act"] = act
+
+	details := map[string]string{
+		"scope":      strings.Join(scopes, " "),
+		"expires_at": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+	}
+	if subjectClient, ok := subject["client_id"].(string); ok {
+		details["subject_client_id"] = subjectClient
+	}
+	err = s.recordAudit(ctx, &model.AuditEvent{
+		Action:        AuditTokenExchange,
+		ActorClientID: client.ClientID,
+		SubjectUUID:   user.UUID,
+		TenantUUID:    user.TenantID,
+		Details:       details,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := signAccessToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken:     accessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(ttl.Seconds()),
+		Scope:           strings.Join(scopes, " "),
+		IssuedTokenType: TokenTypeAccessToken,
+	}, nil
+}
+
+// SetImpersonationScopes replaces the permissions impersonation tokens are
+// limited to. Wildcards and users:impersonate are not allowed.
+func (s *AuthService) SetImpersonationScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one impersonation scope is required", ErrInvalidPermission)
+	}
+	for _, scope := range scopes {
+		if strings.Contains(scope, "*") || scope == PermUsersImpersonate || !permissionPattern.MatchString(scope) {
+			return fmt.Errorf("%w: %q cannot be an impersonation scope", ErrInvalidPermission, scope)
+		}
+	}
+	s.impersonationScopes = dedupe(scopes)
+	return nil
+}
+
+// Impersonate issues support staff a short-lived access token for another user.
+// The token's act claim names the staff member, its scope limits it to the
+// impersonation scopes, it ends with the staff member's session, and it is
+// recorded in the audit log before it is issued. Users who may impersonate
+// others cannot be impersonated.
+func (s *AuthService) Impersonate(ctx context.Context, actor Actor, sessionID string, amr []string, authTime time.Time, input ImpersonationInput) (string, time.Time, error) {
+	if s.auditRepo == nil {
+		return "", time.Time{}, ErrAuditDisabled
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" || utf8.RuneCountInString(reason) > maxImpersonationReasonChars {
+		return "", time.Time{}, fmt.Errorf("%w: a reason of at most %d characters is required", ErrInvalidImpersonation, maxImpersonationReasonChars)
+	}
+	if input.UserUUID == "" {
+		return "", time.Time{}, fmt.Errorf("%w: user_id is required", ErrInvalidImpersonation)
+	}
+	if input.UserUUID == actor.UserUUID {
+		return "", time.Time{}, fmt.Errorf("%w: cannot impersonate yourself", ErrInvalidImpersonation)
+	}
+
+	user, err := s.repo.GetByID(ctx, input.UserUUID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	tenantUUID := input.TenantUUID
+	if tenantUUID == "" {
+		tenantUUID = user.TenantID
+	}
+	scoped, tenant, err := s.scopeToTenant(ctx, user, tenantUUID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	permissions, err := s.ResolvePermissions(ctx, tenantUUID, scoped.Role)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if permissionGranted(permissions, PermUsersImpersonate) {
+		return "", time.Time{}, ErrImpersonationDenied
+	}
+
+	claims := accessTokenClaims(scoped, tenant, amr, authTime, impersonationTokenTTL, sessionID)
+	claims["act"] = map[string]interface{}{"sub": actor.UserUUID, "tenant_id": actor.TenantUUID}
+	claims["scope"] = strings.Join(s.impersonationScopes, " ")
+	expiresAt := time.Unix(claims["exp"].(int64), 0)
+
+	err = s.recordAudit(ctx, &model.AuditEvent{
+		Action:      AuditImpersonationStart,
+		ActorUUID:   actor.UserUUID,
+		SubjectUUID: scoped.UUID,
+		TenantUUID:  tenantUUID,
+		Details: map[string]string{
+			"reason":       reason,
+			"actor_tenant": actor.TenantUUID,
+			"scope":        claims["scope"].(string),
+			"expires_at":   expiresAt.UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := signAccessToken(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// parseSubjectToken validates an access token of this service presented for exchange
+func (s *AuthService) parseSubjectToken(ctx context.Context, tokenStr string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidAccessToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidAccessToken
+	}
+	if _, ok := claims["token_use"]; ok {
+		return nil, ErrInvalidAccessToken
+	}
+	userUUID, _ := claims["user_uuid"].(string)
+	sessionID, _ := claims["sid"].(string)
+	if userUUID == "" || !s.IsSessionActive(ctx, sessionID) {
+		return nil, ErrInvalidAccessToken
+	}
+	return claims, nil
+}
+
+// intersectScopes returns the scopes present in both lists, in the order of a
+func intersectScopes(a, b []string) []string {
+	scopes := make([]string, 0, len(a))
+	for _, scope := range a {
+		if containsString(b, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
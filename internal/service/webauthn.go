@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/johnroshan2255/auth-service/internal/config"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+)
+
+const (
+	webAuthnChallengeTTL = 5 * time.Minute
+	mfaTokenTTL          = 5 * time.Minute
+	tokenUseMFA          = "mfa"
+
+	// Enrollment tokens let users whose tenant requires MFA register their first passkey
+	mfaEnrollmentTokenTTL = 15 * time.Minute
+	tokenUseMFAEnrollment = "mfa_enrollment"
+)
+
+var ErrWebAuthnDisabled = errors.New("webauthn is not configured")
+
+// NewWebAuthn builds the relying party from config. It returns nil when WEBAUTHN_RP_ID is not set.
+func NewWebAuthn(cfg *config.Config) (*webauthn.WebAuthn, error) {
+	if cfg.WebAuthnRPID == "" {
+		return nil, nil
+	}
+
+	displayName := cfg.WebAuthnRPDisplayName
+	if displayName == "" {
+		displayName = cfg.WebAuthnRPID
+	}
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: displayName,
+		RPOrigins:     cfg.WebAuthnRPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnChallengeTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnChallengeTTL},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize webauthn: %w", err)
+	}
+	return wa, nil
+}
+
+// SetWebAuthn enables passkey registration and login
+func (s *AuthService) SetWebAuthn(wa *webauthn.WebAuthn, repo repository.WebAuthnRepository) {
+	s.webauthn = wa
+	s.webauthnRepo = repo
+}
+
+// hasSecondFactor reports whether a password login must be completed with a second factor
+func (s *AuthService) hasSecondFactor(ctx context.Context, user *model.User) bool {
+	if s.webauthn == nil || s.webauthnRepo == nil {
+		return false
+	}
+	count, err := s.webauthnRepo.CountCredentialsByUser(ctx, user.UUID)
+	if err != nil {
+		// Fail closed: never skip the second factor because the lookup failed
+		return true
+	}
+	return count > 0
+}
+
+// BeginWebAuthnRegistration starts registering a new passkey for an authenticated user
+func (s *AuthService) BeginWebAuthnRegistration(ctx context.Context, userUUID string) (string, *protocol.CredentialCreation, error) {
+	if s.webauthn == nil {
+		return "", nil, ErrWebAuthnDisabled
+	}
+
+	waUser, err := s.loadWebAuthnUser(ctx, userUUID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	creation, session, err := s.webauthn.BeginRegistration(
+		waUser,
+		webauthn.WithExclusions(webauthn.Credentials(waUser.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin registration: %w", err)
+	}
+
+	ceremonyID, err := s.saveWebAuthnChallenge(ctx, userUUID, nil, model.WebAuthnCeremonyRegistration, session)
+	if err != nil {
+		return "", nil, err
+	}
+	return ceremonyID, creation, nil
+}
+
+// FinishWebAuthnRegistration verifies the attestation response and stores the new credential
+func (s *AuthService) FinishWebAuthnRegistration(ctx context.Context, userUUID, ceremonyID, name string, response []byte) (*model.WebAuthnCredential, error) {
+	if s.webauthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+
+	challenge, session, err := s.consumeWebAuthnChallenge(ctx, ceremonyID, model.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserUUID != userUUID {
+		return nil, errors.New("challenge not found")
+	}
+
+	waUser, err := s.loadWebAuthnUser(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential: %w", err)
+	}
+
+	credential, err := s.webauthn.CreateCredential(waUser, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential: %w", err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+
+	record := &model.WebAuthnCredential{
+		UserUUID:        userUUID,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+	}
+	if err := s.webauthnRepo.CreateCredential(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// BeginWebAuthnLogin starts an assertion ceremony. With an MFA token from Login the
+// ceremony is bound to that user (second factor); without one it is a discoverable
+// passkey login (passwordless).
+func (s *AuthService) BeginWebAuthnLogin(ctx context.Context, mfaToken string) (string, *protocol.CredentialAssertion, error) {
+	if s.webauthn == nil {
+		return "", nil, ErrWebAuthnDisabled
+	}
+
+	if mfaToken != "" {
+		userUUID, amr, err := parseMFAToken(mfaToken)
+		if err != nil {
+			return "", nil, err
+		}
+		return s.beginWebAuthnUserAssertion(ctx, userUUID, amr)
+	}
+
+	assertion, session, err := s.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin login: %w", err)
+	}
+
+	ceremonyID, err := s.saveWebAuthnChallenge(ctx, "", nil, model.WebAuthnCeremonyLogin, session)
+	if err != nil {
+		return "", nil, err
+	}
+	return ceremonyID, assertion, nil
+}
+
+// FinishWebAuthnLogin verifies the assertion and issues the same token as Login
+func (s *AuthService) FinishWebAuthnLogin(ctx context.Context, ceremonyID string, response []byte) (string, *model.User, error) {
+	if s.webauthn == nil {
+		return "", nil, ErrWebAuthnDisabled
+	}
+
+	user, challenge, err := s.verifyWebAuthnAssertion(ctx, ceremonyID, response)
+	if err != nil {
+		return "", nil, err
+	}
+
+	amr := append(splitAMR(challenge.AMR), AMRWebAuthn)
+	tokenString, err := s.issueLoginToken(ctx, user, amr)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, user, nil
+}
+
+// beginWebAuthnUserAssertion starts an assertion limited to the user's own passkeys.
+// amr lists the factors the user has already passed in this login, if any.
+func (s *AuthService) beginWebAuthnUserAssertion(ctx context.Context, userUUID string, amr []string) (string, *protocol.CredentialAssertion, error) {
+	waUser, err := s.loadWebAuthnUser(ctx, userUUID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(waUser.credentials) == 0 {
+		return "", nil, errors.New("no passkeys registered")
+	}
+
+	assertion, session, err := s.webauthn.BeginLogin(waUser)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin login: %w", err)
+	}
+
+	ceremonyID, err := s.saveWebAuthnChallenge(ctx, userUUID, amr, model.WebAuthnCeremonyLogin, session)
+	if err != nil {
+		return "", nil, err
+	}
+	return ceremonyID, assertion, nil
+}
+
+// verifyWebAuthnAssertion consumes the ceremony, checks the assertion and records the credential use
+func (s *AuthService) verifyWebAuthnAssertion(ctx context.Context, ceremonyID string, response []byte) (*model.User, *model.WebAuthnChallenge, error) {
+	challenge, session, err := s.consumeWebAuthnChallenge(ctx, ceremonyID, model.WebAuthnCeremonyLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	var (
+		waUser     *webAuthnUser
+		credential *webauthn.Credential
+	)
+
+	if challenge.UserUUID != "" {
+		waUser, err = s.loadWebAuthnUser(ctx, challenge.UserUUID)
+		if err != nil {
+			return nil, nil, errors.New("invalid credentials")
+		}
+		credential, err = s.webauthn.ValidateLogin(waUser, *session, parsed)
+	} else {
+		handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+			waUser, err = s.loadWebAuthnUser(ctx, string(userHandle))
+			return waUser, err
+		}
+		credential, err = s.webauthn.ValidateDiscoverableLogin(handler, *session, parsed)
+	}
+	if err != nil || waUser == nil {
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	if err := s.recordWebAuthnUse(ctx, waUser, credential); err != nil {
+		return nil, nil, err
+	}
+	return waUser.user, challenge, nil
+}
+
+// ListWebAuthnCredentials returns the passkeys registered by a user
+func (s *AuthService) ListWebAuthnCredentials(ctx context.Context, userUUID string) ([]model.WebAuthnCredential, error) {
+	if s.webauthnRepo == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+	return s.webauthnRepo.ListCredentialsByUser(ctx, userUUID)
+}
+
+// DeleteWebAuthnCredential removes one of the user's passkeys
+func (s *AuthService) DeleteWebAuthnCredential(ctx context.Context, userUUID, credentialUUID string) error {
+	if s.webauthnRepo == nil {
+		return ErrWebAuthnDisabled
+	}
+	return s.webauthnRepo.DeleteCredential(ctx, userUUID, credentialUUID)
+}
+
+// recordWebAuthnUse stores the new sign counter. A counter that did not advance means
+// the authenticator may have been cloned, so the credential is flagged and the login refused.
+func (s *AuthService) recordWebAuthnUse(ctx context.Context, waUser *webAuthnUser, credential *webauthn.Credential) error {
+	record, ok := waUser.records[base64.RawURLEncoding.EncodeToString(credential.ID)]
+	if !ok {
+		return errors.New("invalid credentials")
+	}
+
+	if credential.Authenticator.CloneWarning {
+		if !record.CloneWarning {
+			record.CloneWarning = true
+			if err := s.webauthnRepo.UpdateCredentialUsage(ctx, record); err != nil {
+				log.Printf("Failed to flag cloned passkey %s: %v", record.UUID, err)
+			}
+		}
+		return errors.New("invalid credentials")
+	}
+
+	now := time.Now()
+	record.SignCount = credential.Authenticator.SignCount
+	record.BackupState = credential.Flags.BackupState
+	record.LastUsedAt = &now
+
+	return s.webauthnRepo.UpdateCredentialUsage(ctx, record)
+}
+
+func (s *AuthService) saveWebAuthnChallenge(ctx context.Context, userUUID string, amr []string, ceremony string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode challenge: %w", err)
+	}
+
+	challenge := &model.WebAuthnChallenge{
+		UserUUID:    userUUID,
+		AMR:         strings.Join(amr, ","),
+		Ceremony:    ceremony,
+		SessionData: string(data),
+		ExpiresAt:   time.Now().Add(webAuthnChallengeTTL),
+	}
+	if err := s.webauthnRepo.SaveChallenge(ctx, challenge); err != nil {
+		return "", err
+	}
+	return challenge.UUID, nil
+}
+
+func (s *AuthService) consumeWebAuthnChallenge(ctx context.Context, ceremonyID, ceremony string) (*model.WebAuthnChallenge, *webauthn.SessionData, error) {
+	challenge, err := s.webauthnRepo.ConsumeChallenge(ctx, ceremonyID, ceremony)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session := &webauthn.SessionData{}
+	if err := json.Unmarshal([]byte(challenge.SessionData), session); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode challenge: %w", err)
+	}
+	return challenge, session, nil
+}
+
+func (s *AuthService) loadWebAuthnUser(ctx context.Context, userUUID string) (*webAuthnUser, error) {
+	user, err := s.repo.GetByID(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.webauthnRepo.ListCredentialsByUser(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	waUser := &webAuthnUser{
+		user:    user,
+		records: make(map[string]*model.WebAuthnCredential, len(records)),
+	}
+	for i := range records {
+		record := &records[i]
+		credential, err := toWebAuthnCredential(record)
+		if err != nil {
+			return nil, err
+		}
+		waUser.credentials = append(waUser.credentials, credential)
+		waUser.records[record.CredentialID] = record
+	}
+	return waUser, nil
+}
+
+func toWebAuthnCredential(record *model.WebAuthnCredential) (webauthn.Credential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(record.CredentialID)
+	if err != nil {
+		return webauthn.Credential{}, fmt.Errorf("invalid stored credential id: %w", err)
+	}
+
+	var transports []protocol.AuthenticatorTransport
+	for _, transport := range strings.Split(record.Transports, ",") {
+		if transport != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       record.PublicKey,
+		AttestationType: record.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: record.BackupEligible,
+			BackupState:    record.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       record.AAGUID,
+			SignCount:    record.SignCount,
+			CloneWarning: record.CloneWarning,
+		},
+	}, nil
+}
+
+// webAuthnUser adapts model.User to the webauthn.User interface.
+// The user handle is the user's UUID so discoverable logins can resolve the account.
+type webAuthnUser struct {
+	user        *model.User
+	credentials []webauthn.Credential
+	records     map[string]*model.WebAuthnCredential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.UUID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if name := strings.TrimSpace(u.user.FirstName + " " + u.user.LastName); name != "" {
+		return name
+	}
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// generateMFAToken issues a short-lived token proving the first factor succeeded.
+// amr records which factor that was so the final token can list every method used.
+func generateMFAToken(user *model.User, amr []string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_uuid": user.UUID,
+		"token_use": tokenUseMFA,
+		"amr":       amr,
+		"exp":       time.Now().Add(mfaTokenTTL).Unix(),
+	})
+	return token.SignedString(jwtKey)
+}
+
+// generateMFAEnrollmentToken issues a short-lived token that only grants access to
+// passkey registration, for a login refused because no second factor is enrolled
+func generateMFAEnrollmentToken(user *model.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_uuid": user.UUID,
+		"tenant_id": user.TenantID,
+		"token_use": tokenUseMFAEnrollment,
+		"exp":       time.Now().Add(mfaEnrollmentTokenTTL).Unix(),
+	})
+	return token.SignedString(jwtKey)
+}
+
+func parseMFAToken(tokenStr string) (string, []string, error) {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return "", nil, errors.New("invalid mfa token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["token_use"] != tokenUseMFA {
+		return "", nil, errors.New("invalid mfa token")
+	}
+
+	userUUID, ok := claims["user_uuid"].(string)
+	if !ok || userUUID == "" {
+		return "", nil, errors.New("invalid mfa token")
+	}
+	return userUUID, amrFromClaims(claims), nil
+}
+
+// splitAMR parses the comma separated amr stored with a challenge
+func splitAMR(value string) []string {
+	var amr []string
+	for _, method := range strings.Split(value, ",") {
+		if method != "" {
+			amr = append(amr, method)
+		}
+	}
+	return amr
+}
@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/johnroshan2255/auth-service/internal/config"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testRPID     = "example.com"
+	testRPOrigin = "https://app.example.com"
+)
+
+// softAuthenticator is a passkey held in memory. It answers registration with a
+// "none" attestation and signs assertions with an ES256 key, counting each use.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	counter      uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// authenticatorData encodes the RP ID hash, flags (user present and verified) and
+// counter, followed by attested credential data when attested is set
+func (a *softAuthenticator) authenticatorData(t *testing.T, attested bool) []byte {
+	t.Helper()
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, publicKey...)
+}
+
+func clientDataJSON(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    testRPOrigin,
+	})
+	if err != nil {
+		t.Fatalf("encode client data: %v", err)
+	}
+	return data
+}
+
+// register answers a credential creation request
+func (a *softAuthenticator) register(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	t.Helper()
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+	a.counter++
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(t, true),
+	})
+	if err != nil {
+		t.Fatalf("encode attestation: %v", err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientDataJSON(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// assert answers a credential request
+func (a *softAuthenticator) assert(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	a.counter++
+
+	authData := a.authenticatorData(t, false)
+	clientData := clientDataJSON(t, "webauthn.get", assertion.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) response(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+	return data
+}
+
+// fakeWebAuthn is an in-memory WebAuthnRepository
+type fakeWebAuthn struct {
+	mu          sync.Mutex
+	credentials []*model.WebAuthnCredential
+	challenges  map[string]*model.WebAuthnChallenge
+}
+
+var _ repository.WebAuthnRepository = (*fakeWebAuthn)(nil)
+
+func (f *fakeWebAuthn) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.credentials {
+		if existing.CredentialID == credential.CredentialID {
+			return errors.New("credential already registered")
+		}
+	}
+	credential.ID = uint(len(f.credentials) + 1)
+	credential.UUID = uuid.New().String()
+	stored := *credential
+	f.credentials = append(f.credentials, &stored)
+	return nil
+}
+
+func (f *fakeWebAuthn) GetCredentialByCredentialID(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, credential := range f.credentials {
+		if credential.CredentialID == credentialID {
+			found := *credential
+			return &found, nil
+		}
+	}
+	return nil, errors.New("credential not found")
+}
+
+func (f *fakeWebAuthn) ListCredentialsByUser(ctx context.Context, userUUID string) ([]model.WebAuthnCredential, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var credentials []model.WebAuthnCredential
+	for _, credential := range f.credentials {
+		if credential.UserUUID == userUUID {
+			credentials = append(credentials, *credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (f *fakeWebAuthn) UpdateCredentialUsage(ctx context.Context, credential *model.WebAuthnCredential) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.credentials {
+		if existing.ID == credential.ID {
+			existing.SignCount = credential.SignCount
+			existing.CloneWarning = credential.CloneWarning
+			existing.BackupState = credential.BackupState
+			existing.LastUsedAt = credential.LastUsedAt
+			return nil
+		}
+	}
+	return errors.New("credential not found")
+}
+
+func (f *fakeWebAuthn) DeleteCredential(ctx context.Context, userUUID, credentialUUID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, credential := range f.credentials {
+		if credential.UUID == credentialUUID && credential.UserUUID == userUUID {
+			f.credentials = append(f.credentials[:i], f.credentials[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("credential not found")
+}
+
+func (f *fakeWebAuthn) CountCredentialsByUser(ctx context.Context, userUUID string) (int64, error) {
+	credentials, err := f.ListCredentialsByUser(ctx, userUUID)
+	return int64(len(credentials)), err
+}
+
+func (f *fakeWebAuthn) SaveChallenge(ctx context.Context, challenge *model.WebAuthnChallenge) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	challenge.UUID = uuid.New().String()
+	f.challenges[challenge.UUID] = challenge
+	return nil
+}
+
+func (f *fakeWebAuthn) ConsumeChallenge(ctx context.Context, challengeUUID, ceremony string) (*model.WebAuthnChallenge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	challenge, ok := f.challenges[challengeUUID]
+	if !ok || challenge.Ceremony != ceremony {
+		return nil, errors.New("challenge not found")
+	}
+	delete(f.challenges, challengeUUID)
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, errors.New("challenge expired")
+	}
+	return challenge, nil
+}
+
+func newWebAuthnTestService(t *testing.T) (*AuthService, *fakeWebAuthn, *model.User) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	user := &model.User{
+		UUID:         "00000000-0000-0000-0000-000000000001",
+		Email:        "ada@example.com",
+		Username:     "ada",
+		FirstName:    "Ada",
+		PasswordHash: string(hash),
+		Role:         "user",
+		TenantID:     "00000000-0000-0000-0000-0000000000aa",
+	}
+
+	wa, err := NewWebAuthn(&config.Config{WebAuthnRPID: testRPID, WebAuthnRPOrigins: []string{testRPOrigin}})
+	if err != nil {
+		t.Fatalf("NewWebAuthn: %v", err)
+	}
+	repo := &fakeWebAuthn{challenges: map[string]*model.WebAuthnChallenge{}}
+	s := NewAuthService(newFakeUsers(user))
+	s.SetWebAuthn(wa, repo)
+	return s, repo, user
+}
+
+// registerPasskey runs a registration ceremony for user with authenticator
+func registerPasskey(t *testing.T, s *AuthService, user *model.User, authenticator *softAuthenticator) *model.WebAuthnCredential {
+	t.Helper()
+	ctx := context.Background()
+	ceremonyID, creation, err := s.BeginWebAuthnRegistration(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnRegistration: %v", err)
+	}
+	credential, err := s.FinishWebAuthnRegistration(ctx, user.UUID, ceremonyID, "Laptop", authenticator.register(t, creation))
+	if err != nil {
+		t.Fatalf("FinishWebAuthnRegistration: %v", err)
+	}
+	return credential
+}
+
+// loginWithPasskey runs an assertion ceremony, bound to a user when mfaToken is set
+func loginWithPasskey(t *testing.T, s *AuthService, mfaToken string, authenticator *softAuthenticator) (string, *model.User, error) {
+	t.Helper()
+	ctx := context.Background()
+	ceremonyID, assertion, err := s.BeginWebAuthnLogin(ctx, mfaToken)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnLogin: %v", err)
+	}
+	return s.FinishWebAuthnLogin(ctx, ceremonyID, authenticator.assert(t, assertion))
+}
+
+func tokenAMR(t *testing.T, token string) []string {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return jwtKey, nil }); err != nil {
+		t.Fatalf("token does not parse: %v", err)
+	}
+	values, _ := claims["amr"].([]interface{})
+	amr := make([]string, 0, len(values))
+	for _, value := range values {
+		method, _ := value.(string)
+		amr = append(amr, method)
+	}
+	return amr
+}
+
+func TestWebAuthnRegistration(t *testing.T) {
+	s, repo, user := newWebAuthnTestService(t)
+	authenticator := newSoftAuthenticator(t)
+
+	credential := registerPasskey(t, s, user, authenticator)
+	if credential.UserUUID != user.UUID || credential.Name != "Laptop" || credential.AttestationType != "none" {
+		t.Fatalf("unexpected credential %+v", credential)
+	}
+	if credential.CredentialID != base64.RawURLEncoding.EncodeToString(authenticator.credentialID) || credential.SignCount != 1 {
+		t.Fatalf("credential id or counter not stored: %+v", credential)
+	}
+	if len(repo.credentials) != 1 {
+		t.Fatalf("stored %d credentials, want 1", len(repo.credentials))
+	}
+
+	// The same authenticator cannot be registered twice
+	ctx := context.Background()
+	ceremonyID, creation, err := s.BeginWebAuthnRegistration(ctx, user.UUID)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnRegistration: %v", err)
+	}
+	if len(creation.Response.CredentialExcludeList) != 1 {
+		t.Fatalf("exclude list = %v, want the registered passkey", creation.Response.CredentialExcludeList)
+	}
+	if _, err := s.FinishWebAuthnRegistration(ctx, user.UUID, ceremonyID, "", authenticator.register(t, creation)); err == nil {
+		t.Fatal("registered the same credential twice")
+	}
+
+	// A ceremony only completes once
+	if _, err := s.FinishWebAuthnRegistration(ctx, user.UUID, ceremonyID, "", authenticator.register(t, creation)); err == nil {
+		t.Fatal("registration ceremony was reused")
+	}
+}
+
+func TestWebAuthnPasswordlessLogin(t *testing.T) {
+	s, repo, user := newWebAuthnTestService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, user, authenticator)
+
+	token, loggedIn, err := loginWithPasskey(t, s, "", authenticator)
+	if err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+	if loggedIn.UUID != user.UUID {
+		t.Fatalf("logged in as %s, want %s", loggedIn.UUID, user.UUID)
+	}
+	if valid, validated := s.ValidateToken(context.Background(), token); !valid || validated.UUID != user.UUID {
+		t.Fatal("passkey login token does not validate")
+	}
+	if amr := tokenAMR(t, token); len(amr) != 1 || amr[0] != AMRWebAuthn {
+		t.Fatalf("amr = %v, want [%s]", amr, AMRWebAuthn)
+	}
+	if stored := repo.credentials[0]; stored.SignCount != 2 || stored.LastUsedAt == nil {
+		t.Fatalf("credential use not recorded: %+v", stored)
+	}
+
+	// An authenticator the service has never seen is refused
+	if _, _, err := loginWithPasskey(t, s, "", newSoftAuthenticator(t)); err == nil {
+		t.Fatal("unregistered authenticator logged in")
+	}
+}
+
+func TestWebAuthnSecondFactor(t *testing.T) {
+	s, _, user := newWebAuthnTestService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, user, authenticator)
+	ctx := context.Background()
+
+	mfaToken, _, err := s.Login(ctx, user.Email, "correct horse")
+	if !errors.Is(err, ErrMFARequired) {
+		t.Fatalf("Login error = %v, want ErrMFARequired", err)
+	}
+	if valid, _ := s.ValidateToken(ctx, mfaToken); valid {
+		t.Fatal("MFA token validated as an access token")
+	}
+
+	// The ceremony is bound to the user who passed the first factor
+	ceremonyID, assertion, err := s.BeginWebAuthnLogin(ctx, mfaToken)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnLogin: %v", err)
+	}
+	if len(assertion.Response.AllowedCredentials) != 1 {
+		t.Fatalf("allowed credentials = %v, want the user's passkey", assertion.Response.AllowedCredentials)
+	}
+	token, loggedIn, err := s.FinishWebAuthnLogin(ctx, ceremonyID, authenticator.assert(t, assertion))
+	if err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+	if loggedIn.UUID != user.UUID {
+		t.Fatalf("logged in as %s, want %s", loggedIn.UUID, user.UUID)
+	}
+
+	amr := tokenAMR(t, token)
+	for _, method := range []string{AMRPassword, AMRWebAuthn, AMRMFA} {
+		if !slices.Contains(amr, method) {
+			t.Fatalf("amr = %v, want it to contain %s", amr, method)
+		}
+	}
+
+	// A passkey the user never registered does not satisfy the second factor
+	mfaToken, _, _ = s.Login(ctx, user.Email, "correct horse")
+	if _, _, err := loginWithPasskey(t, s, mfaToken, newSoftAuthenticator(t)); err == nil {
+		t.Fatal("unregistered authenticator passed the second factor")
+	}
+}
+
+func TestWebAuthnRejectsCounterRegression(t *testing.T) {
+	s, repo, user := newWebAuthnTestService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, user, authenticator)
+
+	if _, _, err := loginWithPasskey(t, s, "", authenticator); err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+
+	// A copy of the key replays an older counter
+	authenticator.counter = 1
+	if _, _, err := loginWithPasskey(t, s, "", authenticator); err == nil {
+		t.Fatal("assertion with a stale counter was accepted")
+	}
+	if stored := repo.credentials[0]; !stored.CloneWarning || stored.SignCount != 2 {
+		t.Fatalf("credential = %+v, want clone warning and counter 2", stored)
+	}
+
+	// The flagged passkey stays unusable even with a higher counter
+	authenticator.counter = 10
+	if _, _, err := loginWithPasskey(t, s, "", authenticator); err == nil {
+		t.Fatal("flagged passkey was accepted")
+	}
+}
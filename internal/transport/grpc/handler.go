@@ -5,6 +5,13 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	authv1 "github.com/johnroshan2255/auth-service/proto/auth/v1"
 	"github.com/johnroshan2255/auth-service/internal/service"
@@ -20,15 +27,89 @@ func NewAuthHandler(s *service.AuthService) *AuthHandler {
 }
 
 func (h *AuthHandler) ValidateToken(ctx context.Context, req *authv1.TokenRequest) (*authv1.TokenResponse, error) {
-	valid, user := h.service.ValidateToken(req.Token)
+	user, info, valid := h.service.InspectToken(ctx, req.Token, req.Audience, req.RequiredScopes)
 	if !valid {
 		return &authv1.TokenResponse{Valid: false}, nil
 	}
 
-	return &authv1.TokenResponse{
+	response := &authv1.TokenResponse{
 		Valid:    true,
 		UserId:   user.UUID,
 		TenantId: user.TenantID,
 		Role:     user.Role,
-	}, nil
+		Scope:    strings.Join(info.Scopes, " "),
+	}
+	if info.ActorUserUUID != "" || info.ActorClientID != "" {
+		response.Actor = &authv1.Actor{UserId: info.ActorUserUUID, ClientId: info.ActorClientID}
+	}
+	return response, nil
+}
+
+// CheckPermission evaluates a permission centrally so backends do not hard-code role checks
+func (h *AuthHandler) CheckPermission(ctx context.Context, req *authv1.CheckPermissionRequest) (*authv1.CheckPermissionResponse, error) {
+	decision, err := h.service.CheckPermission(ctx, req.Subject, req.Tenant, service.PermissionCheck{
+		Permission:         req.Permission,
+		Resource:           req.Resource,
+		ResourceAttributes: req.ResourceAttributes,
+	}, newRequestContext(req.Request))
+	if err != nil {
+		return nil, permissionCheckError(err)
+	}
+
+	return newCheckPermissionResponse(decision), nil
+}
+
+// CheckPermissions evaluates several permissions for one subject and tenant
+func (h *AuthHandler) CheckPermissions(ctx context.Context, req *authv1.CheckPermissionsRequest) (*authv1.CheckPermissionsResponse, error) {
+	checks := make([]service.PermissionCheck, 0, len(req.Checks))
+	for _, check := range req.Checks {
+		checks = append(checks, service.PermissionCheck{
+			Permission:         check.Permission,
+			Resource:           check.Resource,
+			ResourceAttributes: check.ResourceAttributes,
+		})
+	}
+
+	decisions, err := h.service.CheckPermissions(ctx, req.Subject, req.Tenant, checks, newRequestContext(req.Request))
+	if err != nil {
+		return nil, permissionCheckError(err)
+	}
+
+	results := make([]*authv1.CheckPermissionResponse, 0, len(decisions))
+	for i := range decisions {
+		results = append(results, newCheckPermissionResponse(&decisions[i]))
+	}
+	return &authv1.CheckPermissionsResponse{Results: results}, nil
+}
+
+func newCheckPermissionResponse(decision *service.PermissionDecision) *authv1.CheckPermissionResponse {
+	return &authv1.CheckPermissionResponse{
+		Allowed:  decision.Allowed,
+		Reason:   decision.Reason,
+		TenantId: decision.TenantID,
+		Role:     decision.Role,
+		Policy:   decision.Policy,
+	}
+}
+
+func newRequestContext(request *authv1.RequestContext) service.RequestContext {
+	if request == nil {
+		return service.RequestContext{}
+	}
+	result := service.RequestContext{IPAddress: request.IpAddress, Attributes: request.Attributes}
+	if request.Time != 0 {
+		result.Time = time.Unix(request.Time, 0)
+	}
+	return result
+}
+
+func permissionCheckError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrRBACDisabled):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, service.ErrInvalidPermission), errors.Is(err, service.ErrInvalidPermissionCheck):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	log.Printf("Permission check failed: %v", err)
+	return status.Error(codes.Internal, "permission check failed")
 }
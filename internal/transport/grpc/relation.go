@@ -0,0 +1,123 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/johnroshan2255/auth-service/internal/authz"
+	authv1 "github.com/johnroshan2255/auth-service/proto/auth/v1"
+)
+
+// RelationHandler serves relationship-based authorization to backend services
+type RelationHandler struct {
+	engine *authz.Engine
+	authv1.UnimplementedRelationServiceServer
+}
+
+func NewRelationHandler(engine *authz.Engine) *RelationHandler {
+	return &RelationHandler{engine: engine}
+}
+
+func (h *RelationHandler) WriteRelations(ctx context.Context, req *authv1.WriteRelationsRequest) (*authv1.WriteRelationsResponse, error) {
+	writes, err := parseTuples(req.Writes)
+	if err != nil {
+		return nil, relationError(err)
+	}
+	deletes, err := parseTuples(req.Deletes)
+	if err != nil {
+		return nil, relationError(err)
+	}
+
+	token, err := h.engine.Write(ctx, req.TenantId, writes, deletes)
+	if err != nil {
+		return nil, relationError(err)
+	}
+	return &authv1.WriteRelationsResponse{ConsistencyToken: token}, nil
+}
+
+func (h *RelationHandler) Check(ctx context.Context, req *authv1.CheckRequest) (*authv1.CheckResponse, error) {
+	object, err := authz.ParseObject(req.Object)
+	if err != nil {
+		return nil, relationError(err)
+	}
+	subject, err := authz.ParseSubject(req.Subject)
+	if err != nil {
+		return nil, relationError(err)
+	}
+
+	allowed, token, err := h.engine.Check(ctx, req.TenantId, object, req.Relation, subject, req.ConsistencyToken)
+	if err != nil {
+		return nil, relationError(err)
+	}
+	return &authv1.CheckResponse{Allowed: allowed, ConsistencyToken: token}, nil
+}
+
+func (h *RelationHandler) Expand(ctx context.Context, req *authv1.ExpandRequest) (*authv1.ExpandResponse, error) {
+	object, err := authz.ParseObject(req.Object)
+	if err != nil {
+		return nil, relationError(err)
+	}
+
+	tree, token, err := h.engine.Expand(ctx, req.TenantId, object, req.Relation, req.ConsistencyToken)
+	if err != nil {
+		return nil, relationError(err)
+	}
+	return &authv1.ExpandResponse{Tree: newRelationTree(tree), ConsistencyToken: token}, nil
+}
+
+func (h *RelationHandler) ListObjects(ctx context.Context, req *authv1.ListObjectsRequest) (*authv1.ListObjectsResponse, error) {
+	subject, err := authz.ParseSubject(req.Subject)
+	if err != nil {
+		return nil, relationError(err)
+	}
+
+	ids, token, err := h.engine.ListObjects(ctx, req.TenantId, req.ObjectType, req.Relation, subject, int(req.Limit), req.ConsistencyToken)
+	if err != nil {
+		return nil, relationError(err)
+	}
+	return &authv1.ListObjectsResponse{ObjectIds: ids, ConsistencyToken: token}, nil
+}
+
+func parseTuples(values []string) ([]authz.Tuple, error) {
+	tuples := make([]authz.Tuple, 0, len(values))
+	for _, value := range values {
+		tuple, err := authz.ParseTuple(value)
+		if err != nil {
+			return nil, err
+		}
+		tuples = append(tuples, tuple)
+	}
+	return tuples, nil
+}
+
+func newRelationTree(tree *authz.ExpandTree) *authv1.RelationTree {
+	result := &authv1.RelationTree{
+		Operation: tree.Operation,
+		Object:    tree.Object.String(),
+		Relation:  tree.Relation,
+	}
+	for _, subject := range tree.Subjects {
+		result.Subjects = append(result.Subjects, subject.String())
+	}
+	for _, child := range tree.Children {
+		result.Children = append(result.Children, newRelationTree(child))
+	}
+	return result
+}
+
+func relationError(err error) error {
+	switch {
+	case errors.Is(err, authz.ErrInvalidRequest), errors.Is(err, authz.ErrInvalidToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, authz.ErrTokenUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, authz.ErrDepthExceeded):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	log.Printf("Relation request failed: %v", err)
+	return status.Error(codes.Internal, "relation request failed")
+}
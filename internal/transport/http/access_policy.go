@@ -0,0 +1,239 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type AccessPolicyRequest struct {
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	Effect      *string  `json:"effect"` // "allow" or "deny"
+	Permissions []string `json:"permissions"`
+	Condition   *string  `json:"condition"` // CEL expression
+	Enabled     *bool    `json:"enabled"`
+}
+
+type AccessPolicyResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Effect      string    `json:"effect"`
+	Permissions []string  `json:"permissions"`
+	Condition   string    `json:"condition"`
+	Enabled     bool      `json:"enabled"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PolicyDryRunRequest is a permission check to explain, optionally with a draft
+// policy that is evaluated as if saved
+type PolicyDryRunRequest struct {
+	UserUUID           string               `json:"user_uuid" binding:"required"`
+	Permission         string               `json:"permission" binding:"required"`
+	Resource           string               `json:"resource"`
+	ResourceAttributes map[string]string    `json:"resource_attributes"`
+	IPAddress          string               `json:"ip_address"`
+	Time               *time.Time           `json:"time"`
+	Attributes         map[string]string    `json:"attributes"` // request attributes
+	Policy             *AccessPolicyRequest `json:"policy"`
+}
+
+type PolicyResultResponse struct {
+	Name      string `json:"name"`
+	Effect    string `json:"effect"`
+	Condition string `json:"condition"`
+	Applies   bool   `json:"applies"`
+	Matched   bool   `json:"matched"`
+	Error     string `json:"error,omitempty"`
+	Draft     bool   `json:"draft,omitempty"`
+}
+
+type PolicyDryRunResponse struct {
+	Allowed  bool                   `json:"allowed"`
+	Reason   string                 `json:"reason"`
+	Role     string                 `json:"role"`
+	Policy   string                 `json:"policy,omitempty"`
+	Policies []PolicyResultResponse `json:"policies"`
+}
+
+type MemberAttributesRequest struct {
+	Attributes map[string]string `json:"attributes" binding:"required"`
+}
+
+func newAccessPolicyResponse(policy *model.AccessPolicy) AccessPolicyResponse {
+	return AccessPolicyResponse{
+		ID:          policy.UUID,
+		Name:        policy.Name,
+		Description: policy.Description,
+		Effect:      policy.Effect,
+		Permissions: policy.Permissions,
+		Condition:   policy.Condition,
+		Enabled:     policy.Enabled,
+		CreatedBy:   policy.CreatedBy,
+		CreatedAt:   policy.CreatedAt,
+		UpdatedAt:   policy.UpdatedAt,
+	}
+}
+
+func (r *AccessPolicyRequest) input() service.AccessPolicyInput {
+	return service.AccessPolicyInput{
+		Name:        r.Name,
+		Description: r.Description,
+		Effect:      r.Effect,
+		Permissions: r.Permissions,
+		Condition:   r.Condition,
+		Enabled:     r.Enabled,
+	}
+}
+
+func accessPolicyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrPoliciesDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrInvalidPolicy), errors.Is(err, service.ErrInvalidPermission),
+		errors.Is(err, service.ErrInvalidPermissionCheck):
+		return http.StatusBadRequest
+	case err.Error() == "policy not found":
+		return http.StatusNotFound
+	case err.Error() == "policy already exists":
+		return http.StatusConflict
+	}
+	return rbacErrorStatus(err)
+}
+
+// ListAccessPolicies lists the current tenant's access policies
+func (h *AuthHandler) ListAccessPolicies(c *gin.Context) {
+	policies, err := h.service.ListAccessPolicies(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(accessPolicyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]AccessPolicyResponse, 0, len(policies))
+	for i := range policies {
+		response = append(response, newAccessPolicyResponse(&policies[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": response})
+}
+
+func (h *AuthHandler) CreateAccessPolicy(c *gin.Context) {
+	var req AccessPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.service.CreateAccessPolicy(c.Request.Context(), actorFromContext(c), req.input())
+	if err != nil {
+		c.JSON(accessPolicyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newAccessPolicyResponse(policy))
+}
+
+// UpdateAccessPolicy changes the fields present in the request; the name cannot change
+func (h *AuthHandler) UpdateAccessPolicy(c *gin.Context) {
+	var req AccessPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.service.UpdateAccessPolicy(c.Request.Context(), actorFromContext(c), c.Param("id"), req.input())
+	if err != nil {
+		c.JSON(accessPolicyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAccessPolicyResponse(policy))
+}
+
+func (h *AuthHandler) DeleteAccessPolicy(c *gin.Context) {
+	if err := h.service.DeleteAccessPolicy(c.Request.Context(), actorFromContext(c), c.Param("id")); err != nil {
+		c.JSON(accessPolicyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "policy deleted"})
+}
+
+// DryRunAccessPolicies evaluates a permission check in the current tenant and
+// explains which role and policies decided it
+func (h *AuthHandler) DryRunAccessPolicies(c *gin.Context) {
+	var req PolicyDryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := service.DryRunInput{
+		Subject: req.UserUUID,
+		Check: service.PermissionCheck{
+			Permission:         req.Permission,
+			Resource:           req.Resource,
+			ResourceAttributes: req.ResourceAttributes,
+		},
+		Request: service.RequestContext{IPAddress: req.IPAddress, Attributes: req.Attributes},
+	}
+	if req.Time != nil {
+		input.Request.Time = *req.Time
+	}
+	if req.Policy != nil {
+		draft := req.Policy.input()
+		input.Draft = &draft
+	}
+
+	decision, err := h.service.DryRunAccessPolicies(c.Request.Context(), actorFromContext(c), input)
+	if err != nil {
+		c.JSON(accessPolicyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]PolicyResultResponse, 0, len(decision.Policies))
+	for _, result := range decision.Policies {
+		results = append(results, PolicyResultResponse{
+			Name:      result.Name,
+			Effect:    result.Effect,
+			Condition: result.Condition,
+			Applies:   result.Applies,
+			Matched:   result.Matched,
+			Error:     result.Error,
+			Draft:     result.Draft,
+		})
+	}
+	c.JSON(http.StatusOK, PolicyDryRunResponse{
+		Allowed:  decision.Allowed,
+		Reason:   decision.Reason,
+		Role:     decision.Role,
+		Policy:   decision.Policy,
+		Policies: results,
+	})
+}
+
+// SetMemberAttributes replaces the attributes access policies see for a member
+func (h *AuthHandler) SetMemberAttributes(c *gin.Context) {
+	var req MemberAttributesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SetMemberAttributes(c.Request.Context(), actorFromContext(c), c.Param("user_id"), req.Attributes); err != nil {
+		statusCode := http.StatusBadRequest
+		if errors.Is(err, service.ErrMembershipNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "attributes updated"})
+}
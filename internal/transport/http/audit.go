@@ -0,0 +1,110 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type ImpersonationRequest struct {
+	UserID   string `json:"user_id" binding:"required"`
+	TenantID string `json:"tenant_id"` // defaults to the user's home tenant
+	Reason   string `json:"reason" binding:"required"`
+}
+
+type AuditEventResponse struct {
+	ID            uint              `json:"id"`
+	Action        string            `json:"action"`
+	ActorID       string            `json:"actor_id,omitempty"`
+	ActorClientID string            `json:"actor_client_id,omitempty"`
+	SubjectID     string            `json:"subject_id,omitempty"`
+	TenantID      string            `json:"tenant_id,omitempty"`
+	Details       map[string]string `json:"details"`
+	IPAddress     string            `json:"ip_address"`
+	UserAgent     string            `json:"user_agent"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+func impersonationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAuditDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrInvalidImpersonation):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrImpersonationDenied), isTenantAccessError(err):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrMembershipNotFound), errors.Is(err, service.ErrTenantNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// Impersonate issues support staff a short-lived token to act as a customer.
+// The token carries an act claim naming the caller and every use is audited.
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	var req ImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, expiresAt, err := h.service.Impersonate(
+		c.Request.Context(),
+		actorFromContext(c),
+		c.GetString("session_id"),
+		c.GetStringSlice("amr"),
+		time.Unix(c.GetInt64("auth_time"), 0),
+		service.ImpersonationInput{UserUUID: req.UserID, TenantUUID: req.TenantID, Reason: req.Reason},
+	)
+	if err != nil {
+		c.JSON(impersonationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(time.Until(expiresAt).Seconds()),
+		"expires_at":   expiresAt,
+	})
+}
+
+// ListAuditEvents returns recent audit events (?action=, actor_id=, subject_id=,
+// tenant_id=, limit= up to 500)
+func (h *AuthHandler) ListAuditEvents(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	filter := service.AuditFilter{
+		Action:      c.Query("action"),
+		ActorUUID:   c.Query("actor_id"),
+		SubjectUUID: c.Query("subject_id"),
+		TenantUUID:  c.Query("tenant_id"),
+	}
+
+	events, err := h.service.ListAuditEvents(c.Request.Context(), filter, limit)
+	if err != nil {
+		c.JSON(impersonationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]AuditEventResponse, 0, len(events))
+	for _, event := range events {
+		response = append(response, AuditEventResponse{
+			ID:            event.ID,
+			Action:        event.Action,
+			ActorID:       event.ActorUUID,
+			ActorClientID: event.ActorClientID,
+			SubjectID:     event.SubjectUUID,
+			TenantID:      event.TenantUUID,
+			Details:       event.Details,
+			IPAddress:     event.IPAddress,
+			UserAgent:     event.UserAgent,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"events": response})
+}
@@ -0,0 +1,20 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+// clientInfoMiddleware stores the caller's IP and user agent in the request context
+// so the service can record them on sessions
+func clientInfoMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := service.WithClientInfo(c.Request.Context(), service.ClientInfo{
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			DeviceID:  c.GetHeader("X-Device-ID"),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
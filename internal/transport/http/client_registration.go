@@ -0,0 +1,173 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+// ClientRegistrationRequest is client metadata as defined by RFC 7591 section 2
+type ClientRegistrationRequest struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	ClientName              string   `json:"client_name"`
+	LogoURI                 string   `json:"logo_uri"`
+	Scope                   string   `json:"scope"`
+}
+
+type InitialAccessTokenRequest struct {
+	Description      string   `json:"description"`
+	Scopes           []string `json:"scopes" binding:"required"`
+	MaxUses          int      `json:"max_uses"`           // 0 for unlimited
+	ExpiresInSeconds int64    `json:"expires_in_seconds"` // defaults to a week
+}
+
+type InitialAccessTokenResponse struct {
+	ID          string     `json:"id"`
+	Token       string     `json:"token,omitempty"` // only returned when created
+	Description string     `json:"description"`
+	Scopes      []string   `json:"scopes"`
+	MaxUses     int        `json:"max_uses"`
+	UseCount    int        `json:"use_count"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func newInitialAccessTokenResponse(token *model.OAuthInitialAccessToken) InitialAccessTokenResponse {
+	return InitialAccessTokenResponse{
+		ID:          token.UUID,
+		Description: token.Description,
+		Scopes:      token.Scopes,
+		MaxUses:     token.MaxUses,
+		UseCount:    token.UseCount,
+		ExpiresAt:   token.ExpiresAt,
+		RevokedAt:   token.RevokedAt,
+		CreatedAt:   token.CreatedAt,
+	}
+}
+
+// RegisterClient is the dynamic client registration endpoint (RFC 7591). The
+// caller presents an initial access token as a bearer token.
+func (h *AuthHandler) RegisterClient(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req ClientRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeOAuthError(c, http.StatusBadRequest, service.OAuthInvalidClientMetadata, err.Error())
+		return
+	}
+
+	initialAccessToken, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	client, secret, err := h.service.RegisterClient(c.Request.Context(), initialAccessToken, service.ClientMetadata{
+		RedirectURIs:            req.RedirectURIs,
+		PostLogoutRedirectURIs:  req.PostLogoutRedirectURIs,
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		GrantTypes:              req.GrantTypes,
+		ResponseTypes:           req.ResponseTypes,
+		ClientName:              req.ClientName,
+		LogoURI:                 req.LogoURI,
+		Scope:                   req.Scope,
+	})
+	if err != nil {
+		var oauthErr *service.OAuthError
+		switch {
+		case errors.As(err, &oauthErr):
+			writeOAuthError(c, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		case errors.Is(err, service.ErrInvalidInitialAccessToken):
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_token", err.Error())
+		case errors.Is(err, service.ErrOAuthDisabled):
+			writeOAuthError(c, http.StatusNotImplemented, service.OAuthServerError, err.Error())
+		default:
+			writeOAuthError(c, http.StatusInternalServerError, service.OAuthServerError, "failed to register client")
+		}
+		return
+	}
+
+	authMethod := req.TokenEndpointAuthMethod
+	if authMethod == "" {
+		authMethod = "client_secret_basic"
+	}
+	responseTypes := []string{}
+	for _, grantType := range client.GrantTypes {
+		if grantType == service.GrantTypeAuthorizationCode {
+			responseTypes = []string{"code"}
+		}
+	}
+	body := gin.H{
+		"client_id":                  client.ClientID,
+		"client_id_issued_at":        client.CreatedAt.Unix(),
+		"client_name":                client.Name,
+		"redirect_uris":              client.RedirectURIs,
+		"post_logout_redirect_uris":  client.PostLogoutRedirectURIs,
+		"grant_types":                client.GrantTypes,
+		"response_types":             responseTypes,
+		"token_endpoint_auth_method": authMethod,
+		"scope":                      strings.Join(client.Scopes, " "),
+	}
+	if client.LogoURI != "" {
+		body["logo_uri"] = client.LogoURI
+	}
+	if secret != "" {
+		body["client_secret"] = secret
+		body["client_secret_expires_at"] = 0 // secrets do not expire
+	}
+	c.JSON(http.StatusCreated, body)
+}
+
+func (h *AuthHandler) ListInitialAccessTokens(c *gin.Context) {
+	tokens, err := h.service.ListInitialAccessTokens(c.Request.Context())
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]InitialAccessTokenResponse, 0, len(tokens))
+	for i := range tokens {
+		response = append(response, newInitialAccessTokenResponse(&tokens[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": response})
+}
+
+// CreateInitialAccessToken issues a token for dynamic client registration; it is only shown in this response
+func (h *AuthHandler) CreateInitialAccessToken(c *gin.Context) {
+	var req InitialAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, raw, err := h.service.CreateInitialAccessToken(c.Request.Context(), c.GetString("user_id"), service.InitialAccessTokenInput{
+		Description: req.Description,
+		Scopes:      req.Scopes,
+		MaxUses:     req.MaxUses,
+		ExpiresIn:   time.Duration(req.ExpiresInSeconds) * time.Second,
+	})
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := newInitialAccessTokenResponse(token)
+	response.Token = raw
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *AuthHandler) RevokeInitialAccessToken(c *gin.Context) {
+	if err := h.service.RevokeInitialAccessToken(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "initial access token revoked"})
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceAuthorization is the device authorization endpoint (RFC 8628 section
+// 3.1). Clients authenticate like at the token endpoint.
+func (h *AuthHandler) DeviceAuthorization(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	clientID, secret, basicAuth, ok := oauthClientAuth(c)
+	if !ok {
+		return
+	}
+
+	authorization, err := h.service.StartDeviceAuthorization(c.Request.Context(), clientID, secret, c.PostForm("scope"))
+	if err != nil {
+		writeTokenEndpointError(c, err, basicAuth)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_code":               authorization.DeviceCode,
+		"user_code":                 authorization.UserCode,
+		"verification_uri":          authorization.VerificationURI,
+		"verification_uri_complete": authorization.VerificationURIComplete,
+		"expires_in":                authorization.ExpiresIn,
+		"interval":                  authorization.Interval,
+	})
+}
+
+// GetDeviceAuthorization shows the verification page what the device entered by
+// the user asks for
+func (h *AuthHandler) GetDeviceAuthorization(c *gin.Context) {
+	prompt, err := h.service.GetDeviceAuthorization(c.Request.Context(), actorFromContext(c), c.Param("user_code"))
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthorizationRequestResponse{
+		RequestID:       prompt.RequestID,
+		ClientID:        prompt.Client.ClientID,
+		ClientName:      prompt.Client.Name,
+		ClientLogoURI:   prompt.Client.LogoURI,
+		Scopes:          prompt.Scopes,
+		ConsentRequired: prompt.ConsentRequired,
+	})
+}
+
+// DecideDeviceAuthorization approves or denies a device for the current user and tenant
+func (h *AuthHandler) DecideDeviceAuthorization(c *gin.Context) {
+	var req AuthorizationDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.DecideDeviceAuthorization(
+		c.Request.Context(),
+		actorFromContext(c),
+		c.Param("user_code"),
+		c.GetStringSlice("amr"),
+		time.Unix(c.GetInt64("auth_time"), 0),
+		req.Approve,
+	)
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "device authorization answered"})
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+// FederatedCallbackRequest carries the query parameters the provider redirected back with
+type FederatedCallbackRequest struct {
+	State string `json:"state" binding:"required"`
+	Code  string `json:"code" binding:"required"`
+}
+
+type IdentityProviderResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type LinkedIdentityResponse struct {
+	ID          string     `json:"id"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func newLinkedIdentityResponse(identity *model.LinkedIdentity) LinkedIdentityResponse {
+	return LinkedIdentityResponse{
+		ID:          identity.UUID,
+		Provider:    identity.Provider,
+		Email:       identity.Email,
+		LastLoginAt: identity.LastLoginAt,
+		CreatedAt:   identity.CreatedAt,
+	}
+}
+
+// ListIdentityProviders lists the upstream providers offered on the login page
+func (h *AuthHandler) ListIdentityProviders(c *gin.Context) {
+	providers := h.service.ListIdentityProviders()
+	response := make([]IdentityProviderResponse, 0, len(providers))
+	for _, provider := range providers {
+		response = append(response, IdentityProviderResponse{ID: provider.ID, Name: provider.Name})
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": response})
+}
+
+// BeginFederatedLogin returns the provider URL the browser should be sent to
+func (h *AuthHandler) BeginFederatedLogin(c *gin.Context) {
+	authorizationURL, err := h.service.BeginFederatedLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		c.JSON(federationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authorizationURL})
+}
+
+// CompleteFederatedLogin exchanges the provider's code for an access token
+func (h *AuthHandler) CompleteFederatedLogin(c *gin.Context) {
+	var req FederatedCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.service.CompleteFederatedLogin(c.Request.Context(), req.State, req.Code)
+	if errors.Is(err, service.ErrMFARequired) {
+		c.JSON(http.StatusOK, MFARequiredResponse{
+			MFARequired: true,
+			MFAToken:    token,
+		})
+		return
+	}
+	if errors.Is(err, service.ErrMFAEnrollmentRequired) {
+		c.JSON(http.StatusForbidden, MFAEnrollmentRequiredResponse{
+			Error:              err.Error(),
+			MFAEnrollmentToken: token,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(federationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:    token,
+		UserUUID: user.UUID,
+		TenantID: user.TenantID,
+		Role:     user.Role,
+	})
+}
+
+func (h *AuthHandler) ListLinkedIdentities(c *gin.Context) {
+	identities, err := h.service.ListLinkedIdentities(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(federationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]LinkedIdentityResponse, 0, len(identities))
+	for i := range identities {
+		response = append(response, newLinkedIdentityResponse(&identities[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"identities": response})
+}
+
+// BeginIdentityLink starts a provider login whose identity is linked to the current user
+func (h *AuthHandler) BeginIdentityLink(c *gin.Context) {
+	authorizationURL, err := h.service.BeginIdentityLink(c.Request.Context(), c.GetString("user_id"), c.Param("provider"))
+	if err != nil {
+		c.JSON(federationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authorizationURL})
+}
+
+// CompleteIdentityLink finishes a link started with BeginIdentityLink
+func (h *AuthHandler) CompleteIdentityLink(c *gin.Context) {
+	var req FederatedCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identity, err := h.service.LinkFederatedIdentity(c.Request.Context(), c.GetString("user_id"), req.State, req.Code)
+	if err != nil {
+		c.JSON(federationErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, newLinkedIdentityResponse(identity))
+}
+
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	if err := h.service.UnlinkIdentity(c.Request.Context(), c.GetString("user_id"), c.Param("id")); err != nil {
+		statusCode := federationErrorStatus(err, http.StatusInternalServerError)
+		if err.Error() == "linked identity not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func federationErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, service.ErrFederationDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrUnknownIdentityProvider):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidFederatedLogin):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrIdentityProviderUnavailable):
+		return http.StatusBadGateway
+	case errors.Is(err, service.ErrFederatedAccountExists),
+		errors.Is(err, service.ErrIdentityAlreadyLinked),
+		errors.Is(err, service.ErrLastIdentity):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrFederatedSignupDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrFederatedEmailRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case isTenantAccessError(err):
+		return http.StatusForbidden
+	default:
+		return fallback
+	}
+}
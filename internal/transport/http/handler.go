@@ -1,7 +1,9 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/johnroshan2255/auth-service/internal/service"
@@ -27,6 +29,19 @@ type LoginResponse struct {
 	Role     string `json:"role"`
 }
 
+// MFARequiredResponse is returned by Login when a second factor must be completed
+type MFARequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// MFAEnrollmentRequiredResponse is returned (403) when the tenant requires MFA the user
+// has not set up. The token only grants access to passkey registration.
+type MFAEnrollmentRequiredResponse struct {
+	Error              string `json:"error"`
+	MFAEnrollmentToken string `json:"mfa_enrollment_token"`
+}
+
 type SignupRequest struct {
 	Email       string `json:"email" binding:"required,email"`
 	Username    string `json:"username" binding:"required,min=3,max=50"`
@@ -34,6 +49,8 @@ type SignupRequest struct {
 	PhoneNumber string `json:"phone_number" binding:"required"`
 	FirstName   string `json:"first_name" binding:"required"`
 	LastName    string `json:"last_name" binding:"required"`
+	TenantName  string `json:"tenant_name" binding:"max=255"` // optional: create a new tenant (organization)
+	InvitationToken string `json:"invitation_token"` // optional: join the tenant that sent the invitation
 }
 
 type SignupResponse struct {
@@ -46,7 +63,9 @@ type SignupResponse struct {
 }
 
 type ValidateTokenRequest struct {
-	Token string `json:"token" binding:"required"`
+	Token    string `json:"token" binding:"required"`
+	Scope    string `json:"scope"`    // optional: space separated scopes an OAuth token must carry
+	Audience string `json:"audience"` // optional: client ID a third-party client's token must be issued to
 }
 
 type ValidateTokenResponse struct {
@@ -54,6 +73,15 @@ type ValidateTokenResponse struct {
 	UserUUID   string `json:"user_uuid"`
 	TenantID string `json:"tenant_id,omitempty"`
 	Role     string `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Scope    string `json:"scope,omitempty"` // set for tokens issued through OAuth
+	Actor    *TokenActorResponse `json:"actor,omitempty"`
+}
+
+// TokenActorResponse names who is acting for the token's user
+type TokenActorResponse struct {
+	UserUUID string `json:"user_uuid,omitempty"` // impersonating support user
+	ClientID string `json:"client_id,omitempty"` // client that exchanged the token
 }
 
 // Login handles user login
@@ -65,6 +93,32 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	token, user, err := h.service.Login(c.Request.Context(), req.Email, req.Password)
+	if errors.Is(err, service.ErrMFARequired) {
+		c.JSON(http.StatusOK, MFARequiredResponse{
+			MFARequired: true,
+			MFAToken:    token,
+		})
+		return
+	}
+	if errors.Is(err, service.ErrMFAEnrollmentRequired) {
+		c.JSON(http.StatusForbidden, MFAEnrollmentRequiredResponse{
+			Error:              err.Error(),
+			MFAEnrollmentToken: token,
+		})
+		return
+	}
+	if errors.Is(err, service.ErrTooManyAttempts) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if isTenantAccessError(err) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrSessionLimitReached) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -86,18 +140,31 @@ func (h *AuthHandler) ValidateToken(c *gin.Context) {
 		return
 	}
 
-	valid, user := h.service.ValidateToken(req.Token)
+	user, info, valid := h.service.InspectToken(c.Request.Context(), req.Token, req.Audience, strings.Fields(req.Scope))
 	if !valid {
 		c.JSON(http.StatusOK, ValidateTokenResponse{Valid: false})
 		return
 	}
 
-	c.JSON(http.StatusOK, ValidateTokenResponse{
+	// The role is the user's current membership role, so these are the current permissions
+	permissions, err := h.service.ResolvePermissions(c.Request.Context(), user.TenantID, user.Role)
+	if err != nil && !errors.Is(err, service.ErrRBACDisabled) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve permissions"})
+		return
+	}
+
+	response := ValidateTokenResponse{
 		Valid:    true,
 		UserUUID:   user.UUID,
 		TenantID: user.TenantID,
 		Role:     user.Role,
-	})
+		Permissions: permissions,
+		Scope:    strings.Join(info.Scopes, " "),
+	}
+	if info.ActorUserUUID != "" || info.ActorClientID != "" {
+		response.Actor = &TokenActorResponse{UserUUID: info.ActorUserUUID, ClientID: info.ActorClientID}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // GetCurrentUser returns the current authenticated user info
@@ -134,10 +201,12 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		req.PhoneNumber,
 		req.FirstName,
 		req.LastName,
+		req.TenantName,
+		req.InvitationToken,
 	)
 	if err != nil {
 		statusCode := http.StatusBadRequest
-		if err.Error() == "email already exists" || err.Error() == "username already exists" {
+		if err.Error() == "email already exists" || err.Error() == "username already exists" || err.Error() == "tenant slug already exists" {
 			statusCode = http.StatusConflict
 		}
 		c.JSON(statusCode, gin.H{"error": err.Error()})
@@ -154,6 +223,16 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 	})
 }
 
+// isTenantAccessError reports whether a login was refused by the user's tenant
+// (suspended tenant or membership, or the tenant's authentication policy)
+func isTenantAccessError(err error) bool {
+	return errors.Is(err, service.ErrTenantInactive) ||
+		errors.Is(err, service.ErrMembershipInactive) ||
+		errors.Is(err, service.ErrMFAEnrollmentRequired) ||
+		errors.Is(err, service.ErrLoginMethodNotAllowed) ||
+		errors.Is(err, service.ErrEmailDomainNotAllowed)
+}
+
 // HealthCheck checks the health of the auth service
 func (h *AuthHandler) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "Auth service is running"})
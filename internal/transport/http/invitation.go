@@ -0,0 +1,157 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role"` // defaults to "user"
+}
+
+type InvitationTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type InvitationResponse struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InvitationLookupResponse is shown to the invitee before they accept
+type InvitationLookupResponse struct {
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	TenantID     string    `json:"tenant_id"`
+	TenantSlug   string    `json:"tenant_slug"`
+	TenantName   string    `json:"tenant_name"`
+	ExistingUser bool      `json:"existing_user"` // sign in and accept, otherwise sign up with invitation_token
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+func newInvitationResponse(invitation *model.TenantInvitation) InvitationResponse {
+	return InvitationResponse{
+		ID:        invitation.UUID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		InvitedBy: invitation.InvitedBy,
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
+
+func invitationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvitationsDisabled):
+		return http.StatusNotImplemented
+	case isTenantAccessError(err), errors.Is(err, service.ErrPermissionEscalate):
+		return http.StatusForbidden
+	case err.Error() == "invitation not found", err.Error() == "invalid or expired invitation":
+		return http.StatusNotFound
+	case err.Error() == "user is already a member", err.Error() == "membership already exists":
+		return http.StatusConflict
+	case err.Error() == "invitation was sent to a different email":
+		return http.StatusForbidden
+	case err.Error() == "invalid role":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// CreateInvitation invites someone by email to the admin's current tenant
+func (h *AuthHandler) CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := h.service.InviteToTenant(c.Request.Context(), actorFromContext(c), req.Email, req.Role)
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newInvitationResponse(invitation))
+}
+
+// ListInvitations lists the pending invitations of the admin's current tenant
+func (h *AuthHandler) ListInvitations(c *gin.Context) {
+	invitations, err := h.service.ListInvitations(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]InvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		response = append(response, newInvitationResponse(&invitations[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": response})
+}
+
+// RevokeInvitation cancels a pending invitation of the admin's current tenant
+func (h *AuthHandler) RevokeInvitation(c *gin.Context) {
+	if err := h.service.RevokeInvitation(c.Request.Context(), c.GetString("tenant_id"), c.Param("id")); err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invitation revoked"})
+}
+
+// LookupInvitation shows a pending invitation to the person holding its token
+func (h *AuthHandler) LookupInvitation(c *gin.Context) {
+	var req InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	details, err := h.service.GetInvitation(c.Request.Context(), req.Token)
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, InvitationLookupResponse{
+		Email:        details.Invitation.Email,
+		Role:         details.Invitation.Role,
+		TenantID:     details.Tenant.UUID,
+		TenantSlug:   details.Tenant.Slug,
+		TenantName:   details.Tenant.Name,
+		ExistingUser: details.ExistingUser,
+		ExpiresAt:    details.Invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitation adds the signed-in user to the inviting tenant.
+// Use /auth/switch-tenant afterwards to work in it.
+func (h *AuthHandler) AcceptInvitation(c *gin.Context) {
+	var req InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, err := h.service.AcceptInvitation(c.Request.Context(), c.GetString("user_id"), req.Token)
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tenant_id":   tenant.UUID,
+		"tenant_slug": tenant.Slug,
+	})
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginEventResponse struct {
+	Method    string    `json:"method"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Device    string    `json:"device_fingerprint"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListLoginEvents returns the current user's recent login attempts (?limit=, max 200)
+func (h *AuthHandler) ListLoginEvents(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	events, err := h.service.ListLoginEvents(c.Request.Context(), c.GetString("user_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]LoginEventResponse, 0, len(events))
+	for _, event := range events {
+		response = append(response, LoginEventResponse{
+			Method:    event.Method,
+			Success:   event.Success,
+			Reason:    event.Reason,
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			Device:    event.DeviceFingerprint,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"logins": response})
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RequestMagicLink emails a single-use sign-in link
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.RequestMagicLink(c.Request.Context(), req.Email); err != nil {
+		c.JSON(magicLinkErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same response whether or not the email belongs to an account
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a sign-in link has been sent"})
+}
+
+// ConsumeMagicLink exchanges a magic link token for an access token
+func (h *AuthHandler) ConsumeMagicLink(c *gin.Context) {
+	var req ConsumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.service.ConsumeMagicLink(c.Request.Context(), req.Token)
+	if errors.Is(err, service.ErrMFARequired) {
+		c.JSON(http.StatusOK, MFARequiredResponse{
+			MFARequired: true,
+			MFAToken:    token,
+		})
+		return
+	}
+	if errors.Is(err, service.ErrMFAEnrollmentRequired) {
+		c.JSON(http.StatusForbidden, MFAEnrollmentRequiredResponse{
+			Error:              err.Error(),
+			MFAEnrollmentToken: token,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(magicLinkErrorStatus(err, http.StatusUnauthorized), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:    token,
+		UserUUID: user.UUID,
+		TenantID: user.TenantID,
+		Role:     user.Role,
+	})
+}
+
+func magicLinkErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, service.ErrMagicLinkDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case isTenantAccessError(err):
+		return http.StatusForbidden
+	default:
+		return fallback
+	}
+}
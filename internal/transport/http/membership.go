@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type UserTenantResponse struct {
+	TenantID   string    `json:"tenant_id"`
+	TenantSlug string    `json:"tenant_slug"`
+	Name       string    `json:"name"`
+	Role       string    `json:"role"`
+	Status     string    `json:"status"` // membership status
+	Active     bool      `json:"active"` // false when the membership or tenant is suspended
+	Current    bool      `json:"current"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+type SwitchTenantRequest struct {
+	Tenant string `json:"tenant" binding:"required"` // tenant ID or slug
+}
+
+type SwitchTenantResponse struct {
+	Token      string `json:"token"`
+	UserUUID   string `json:"user_uuid"`
+	TenantID   string `json:"tenant_id"`
+	TenantSlug string `json:"tenant_slug"`
+	Role       string `json:"role"`
+}
+
+// ListUserTenants lists the tenants the current user belongs to
+func (h *AuthHandler) ListUserTenants(c *gin.Context) {
+	tenants, err := h.service.ListUserTenants(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentID := c.GetString("tenant_id")
+	response := make([]UserTenantResponse, 0, len(tenants))
+	for _, t := range tenants {
+		response = append(response, UserTenantResponse{
+			TenantID:   t.Tenant.UUID,
+			TenantSlug: t.Tenant.Slug,
+			Name:       t.Tenant.Name,
+			Role:       t.Membership.Role,
+			Status:     t.Membership.Status,
+			Active:     t.Membership.IsActive() && t.Tenant.IsActive(),
+			Current:    t.Tenant.UUID == currentID,
+			JoinedAt:   t.Membership.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tenants": response})
+}
+
+// SwitchTenant exchanges the current access token for one scoped to another of the user's tenants
+func (h *AuthHandler) SwitchTenant(c *gin.Context) {
+	var req SwitchTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, tenant, err := h.service.SwitchTenant(
+		c.Request.Context(),
+		c.GetString("user_id"),
+		c.GetString("session_id"),
+		req.Tenant,
+		time.Unix(c.GetInt64("auth_time"), 0),
+		c.GetStringSlice("amr"),
+	)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrTenantNotFound):
+			statusCode = http.StatusNotFound
+		case isTenantAccessError(err):
+			statusCode = http.StatusForbidden
+		case errors.Is(err, service.ErrSessionNotFound):
+			statusCode = http.StatusUnauthorized
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SwitchTenantResponse{
+		Token:      token,
+		UserUUID:   user.UUID,
+		TenantID:   tenant.UUID,
+		TenantSlug: tenant.Slug,
+		Role:       user.Role,
+	})
+}
@@ -0,0 +1,356 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type OAuthClientRequest struct {
+	Name                   *string  `json:"name"`
+	Type                   string   `json:"type"` // "public" or "confidential", set at registration
+	RedirectURIs           []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	Scopes                 []string `json:"scopes"`
+	GrantTypes             []string `json:"grant_types"`
+	LogoURI                *string  `json:"logo_uri"`
+	FirstParty             *bool    `json:"first_party"`
+	Disabled               *bool    `json:"disabled"`
+}
+
+type OAuthClientResponse struct {
+	ClientID               string    `json:"client_id"`
+	ClientSecret           string    `json:"client_secret,omitempty"` // only returned when created or rotated
+	Name                   string    `json:"name"`
+	Type                   string    `json:"type"`
+	RedirectURIs           []string  `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string  `json:"post_logout_redirect_uris"`
+	Scopes                 []string  `json:"scopes"`
+	GrantTypes             []string  `json:"grant_types"`
+	LogoURI                string    `json:"logo_uri,omitempty"`
+	FirstParty             bool      `json:"first_party"`
+	Disabled               bool      `json:"disabled"`
+	RegistrationTokenID    string    `json:"registration_token_id,omitempty"` // set for dynamically registered clients
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+type AuthorizationRequestResponse struct {
+	RequestID       string   `json:"request_id"`
+	ClientID        string   `json:"client_id"`
+	ClientName      string   `json:"client_name"`
+	ClientLogoURI   string   `json:"client_logo_uri,omitempty"`
+	Scopes          []string `json:"scopes"`
+	ConsentRequired bool     `json:"consent_required"`
+}
+
+type AuthorizationDecisionRequest struct {
+	Approve bool `json:"approve"`
+}
+
+func newOAuthClientResponse(client *model.OAuthClient) OAuthClientResponse {
+	return OAuthClientResponse{
+		ClientID:               client.ClientID,
+		Name:                   client.Name,
+		Type:                   client.Type,
+		RedirectURIs:           client.RedirectURIs,
+		PostLogoutRedirectURIs: client.PostLogoutRedirectURIs,
+		Scopes:                 client.Scopes,
+		GrantTypes:             client.GrantTypes,
+		LogoURI:                client.LogoURI,
+		FirstParty:             client.FirstParty,
+		Disabled:               client.DisabledAt != nil,
+		RegistrationTokenID:    client.RegistrationTokenUUID,
+		CreatedAt:              client.CreatedAt,
+		UpdatedAt:              client.UpdatedAt,
+	}
+}
+
+func (r *OAuthClientRequest) input() service.OAuthClientInput {
+	return service.OAuthClientInput{
+		Name:                   r.Name,
+		Type:                   r.Type,
+		RedirectURIs:           r.RedirectURIs,
+		PostLogoutRedirectURIs: r.PostLogoutRedirectURIs,
+		Scopes:                 r.Scopes,
+		GrantTypes:             r.GrantTypes,
+		LogoURI:                r.LogoURI,
+		FirstParty:             r.FirstParty,
+		Disabled:               r.Disabled,
+	}
+}
+
+func oauthErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrOAuthDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrInvalidOAuthClient), errors.Is(err, service.ErrInvalidInitialAccessTokenInput):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrDeviceFlowDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrDeviceCodeNotFound), errors.Is(err, service.ErrInitialAccessTokenNotFound):
+		return http.StatusNotFound
+	case err.Error() == "oauth client not found", err.Error() == "authorization request not found":
+		return http.StatusNotFound
+	case isTenantAccessError(err):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// Authorize starts the authorization code flow (RFC 6749 section 4.1) and
+// sends the browser to the consent page
+func (h *AuthHandler) Authorize(c *gin.Context) {
+	redirectTo, err := h.service.StartAuthorization(c.Request.Context(), service.AuthorizationParams{
+		ResponseType:        c.Query("response_type"),
+		ClientID:            c.Query("client_id"),
+		RedirectURI:         c.Query("redirect_uri"),
+		Scope:               c.Query("scope"),
+		State:               c.Query("state"),
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+		Nonce:               c.Query("nonce"),
+	})
+	if err != nil {
+		// The client could not be verified, so the error is not sent back to it
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+			return
+		}
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirectTo)
+}
+
+// Token is the OAuth 2.0 token endpoint. Clients authenticate with HTTP Basic
+// or client_id and client_secret form parameters.
+func (h *AuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	req := service.TokenRequest{
+		GrantType:    c.PostForm("grant_type"),
+		Code:         c.PostForm("code"),
+		RedirectURI:  c.PostForm("redirect_uri"),
+		CodeVerifier: c.PostForm("code_verifier"),
+		RefreshToken: c.PostForm("refresh_token"),
+		Scope:        c.PostForm("scope"),
+		DeviceCode:   c.PostForm("device_code"),
+		// Token exchange (RFC 8693)
+		SubjectToken:       c.PostForm("subject_token"),
+		SubjectTokenType:   c.PostForm("subject_token_type"),
+		ActorToken:         c.PostForm("actor_token"),
+		ActorTokenType:     c.PostForm("actor_token_type"),
+		RequestedTokenType: c.PostForm("requested_token_type"),
+		Audience:           c.PostForm("audience"),
+		Resource:           c.PostForm("resource"),
+	}
+	var basicAuth, ok bool
+	if req.ClientID, req.ClientSecret, basicAuth, ok = oauthClientAuth(c); !ok {
+		return
+	}
+
+	response, err := h.service.ExchangeToken(c.Request.Context(), req)
+	if err != nil {
+		writeTokenEndpointError(c, err, basicAuth)
+		return
+	}
+
+	body := gin.H{
+		"access_token": response.AccessToken,
+		"token_type":   response.TokenType,
+		"expires_in":   response.ExpiresIn,
+		"scope":        response.Scope,
+	}
+	if response.RefreshToken != "" {
+		body["refresh_token"] = response.RefreshToken
+	}
+	if response.IDToken != "" {
+		body["id_token"] = response.IDToken
+	}
+	if response.IssuedTokenType != "" {
+		body["issued_token_type"] = response.IssuedTokenType
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+func writeOAuthError(c *gin.Context, statusCode int, code, description string) {
+	c.JSON(statusCode, gin.H{"error": code, "error_description": description})
+}
+
+// oauthClientAuth reads client credentials from HTTP Basic or the client_id and
+// client_secret form parameters. It writes an error response and returns false
+// when they are malformed.
+func oauthClientAuth(c *gin.Context) (clientID, secret string, basicAuth, ok bool) {
+	clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	username, password, basicAuth := c.Request.BasicAuth()
+	if !basicAuth {
+		return clientID, secret, false, true
+	}
+	if secret != "" {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "use only one client authentication method")
+		return "", "", true, false
+	}
+	// RFC 6749 section 2.3.1: credentials are form-encoded before base64
+	basicID, idErr := url.QueryUnescape(username)
+	basicSecret, secretErr := url.QueryUnescape(password)
+	if idErr != nil || secretErr != nil || (clientID != "" && clientID != basicID) {
+		writeOAuthError(c, http.StatusUnauthorized, service.OAuthInvalidClient, "client authentication failed")
+		return "", "", true, false
+	}
+	return basicID, basicSecret, true, true
+}
+
+// writeTokenEndpointError writes an error from the token or device authorization endpoint
+func writeTokenEndpointError(c *gin.Context, err error, basicAuth bool) {
+	var oauthErr *service.OAuthError
+	switch {
+	case errors.As(err, &oauthErr):
+		statusCode := http.StatusBadRequest
+		if oauthErr.Code == service.OAuthInvalidClient {
+			statusCode = http.StatusUnauthorized
+			if basicAuth {
+				c.Header("WWW-Authenticate", `Basic realm="oauth2"`)
+			}
+		}
+		writeOAuthError(c, statusCode, oauthErr.Code, oauthErr.Description)
+	case errors.Is(err, service.ErrOAuthDisabled), errors.Is(err, service.ErrDeviceFlowDisabled), errors.Is(err, service.ErrAuditDisabled):
+		writeOAuthError(c, http.StatusNotImplemented, service.OAuthServerError, err.Error())
+	default:
+		writeOAuthError(c, http.StatusInternalServerError, service.OAuthServerError, "failed to issue tokens")
+	}
+}
+
+// GetAuthorizationRequest shows the consent page what a pending authorization request asks for
+func (h *AuthHandler) GetAuthorizationRequest(c *gin.Context) {
+	prompt, err := h.service.GetAuthorizationRequest(c.Request.Context(), actorFromContext(c), c.Param("id"))
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthorizationRequestResponse{
+		RequestID:       prompt.RequestID,
+		ClientID:        prompt.Client.ClientID,
+		ClientName:      prompt.Client.Name,
+		ClientLogoURI:   prompt.Client.LogoURI,
+		Scopes:          prompt.Scopes,
+		ConsentRequired: prompt.ConsentRequired,
+	})
+}
+
+// DecideAuthorization approves or denies a pending authorization request for
+// the current user and tenant. The consent page sends the browser to redirect_to.
+func (h *AuthHandler) DecideAuthorization(c *gin.Context) {
+	var req AuthorizationDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redirectTo, err := h.service.DecideAuthorization(
+		c.Request.Context(),
+		actorFromContext(c),
+		c.Param("id"),
+		c.GetStringSlice("amr"),
+		time.Unix(c.GetInt64("auth_time"), 0),
+		req.Approve,
+	)
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectTo})
+}
+
+func (h *AuthHandler) ListOAuthClients(c *gin.Context) {
+	clients, err := h.service.ListOAuthClients(c.Request.Context())
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]OAuthClientResponse, 0, len(clients))
+	for i := range clients {
+		response = append(response, newOAuthClientResponse(&clients[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": response})
+}
+
+// CreateOAuthClient registers a client; a confidential client's secret is only shown in this response
+func (h *AuthHandler) CreateOAuthClient(c *gin.Context) {
+	var req OAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, secret, err := h.service.RegisterOAuthClient(c.Request.Context(), c.GetString("user_id"), req.input())
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := newOAuthClientResponse(client)
+	response.ClientSecret = secret
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *AuthHandler) GetOAuthClient(c *gin.Context) {
+	client, err := h.service.GetOAuthClient(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newOAuthClientResponse(client))
+}
+
+// UpdateOAuthClient changes the fields present in the request
+func (h *AuthHandler) UpdateOAuthClient(c *gin.Context) {
+	var req OAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.service.UpdateOAuthClient(c.Request.Context(), c.Param("id"), req.input())
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newOAuthClientResponse(client))
+}
+
+// DeleteOAuthClient removes a client together with its consents and refresh tokens
+func (h *AuthHandler) DeleteOAuthClient(c *gin.Context) {
+	if err := h.service.DeleteOAuthClient(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "client deleted"})
+}
+
+// RotateOAuthClientSecret issues a new secret for a confidential client
+func (h *AuthHandler) RotateOAuthClientSecret(c *gin.Context) {
+	secret, err := h.service.RotateOAuthClientSecret(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(oauthErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"client_secret": secret})
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+// ConfirmEndSessionRequest repeats the parameters the logout endpoint passed to the consent page
+type ConfirmEndSessionRequest struct {
+	IDTokenHint           string `json:"id_token_hint"`
+	ClientID              string `json:"client_id"`
+	PostLogoutRedirectURI string `json:"post_logout_redirect_uri"`
+	State                 string `json:"state"`
+}
+
+// OpenIDConfiguration is the discovery document (OpenID Connect Discovery 1.0)
+func (h *AuthHandler) OpenIDConfiguration(c *gin.Context) {
+	issuer := h.service.OIDCIssuer()
+	if issuer == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	document := gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth2/authorize",
+		"token_endpoint":                        issuer + "/oauth2/token",
+		"userinfo_endpoint":                     issuer + "/oauth2/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"end_session_endpoint":                  issuer + "/oauth2/logout",
+		"scopes_supported":                      []string{service.ScopeOpenID, service.ScopeProfile, service.ScopeEmail, service.ScopePhone, service.ScopeOfflineAccess},
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 service.SupportedGrantTypes(),
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "sid",
+			"name", "given_name", "family_name", "preferred_username", "updated_at",
+			"email", "email_verified", "phone_number", "phone_number_verified",
+		},
+	}
+	if h.service.DeviceAuthorizationEnabled() {
+		document["device_authorization_endpoint"] = issuer + "/oauth2/device_authorization"
+	}
+	if h.service.ClientRegistrationEnabled() {
+		document["registration_endpoint"] = issuer + "/oauth2/register"
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+// JWKS publishes the keys ID tokens are signed with
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	keys, err := h.service.SigningKeys()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, gin.H{
+			"kty": key.KeyType,
+			"use": key.Use,
+			"alg": key.Algorithm,
+			"kid": key.KeyID,
+			"n":   key.Modulus,
+			"e":   key.Exponent,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": response})
+}
+
+// UserInfo returns claims about the user for an access token with the openid scope
+func (h *AuthHandler) UserInfo(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	info, err := h.service.UserInfo(c.Request.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOIDCDisabled):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInsufficientScope):
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+		default:
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
+
+// EndSession is the RP-initiated logout endpoint (OpenID Connect RP-Initiated Logout 1.0)
+func (h *AuthHandler) EndSession(c *gin.Context) {
+	redirectTo, err := h.service.EndSession(c.Request.Context(), service.EndSessionParams{
+		IDTokenHint:           c.Request.FormValue("id_token_hint"),
+		ClientID:              c.Request.FormValue("client_id"),
+		PostLogoutRedirectURI: c.Request.FormValue("post_logout_redirect_uri"),
+		State:                 c.Request.FormValue("state"),
+	})
+	if err != nil {
+		c.JSON(endSessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if redirectTo != "" {
+		c.Redirect(http.StatusFound, redirectTo)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// ConfirmEndSession signs the user out after they confirmed a logout on the consent page
+func (h *AuthHandler) ConfirmEndSession(c *gin.Context) {
+	var req ConfirmEndSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redirectTo, err := h.service.ConfirmEndSession(c.Request.Context(), actorFromContext(c), c.GetString("session_id"), service.EndSessionParams{
+		IDTokenHint:           req.IDTokenHint,
+		ClientID:              req.ClientID,
+		PostLogoutRedirectURI: req.PostLogoutRedirectURI,
+		State:                 req.State,
+	})
+	if err != nil {
+		c.JSON(endSessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectTo})
+}
+
+func endSessionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrOIDCDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrInvalidLogout):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
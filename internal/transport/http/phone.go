@@ -0,0 +1,120 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type ConfirmPhoneRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type PhoneLoginCodeRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+}
+
+type PhoneLoginRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+	Code        string `json:"code" binding:"required"`
+}
+
+// SendPhoneVerification texts a verification code to the current user's phone number
+func (h *AuthHandler) SendPhoneVerification(c *gin.Context) {
+	if err := h.service.SendPhoneVerification(c.Request.Context(), c.GetString("user_id")); err != nil {
+		c.JSON(phoneErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification code sent"})
+}
+
+// ConfirmPhoneVerification marks the current user's phone number as verified
+func (h *AuthHandler) ConfirmPhoneVerification(c *gin.Context) {
+	var req ConfirmPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ConfirmPhoneVerification(c.Request.Context(), c.GetString("user_id"), req.Code); err != nil {
+		statusCode := phoneErrorStatus(err, http.StatusBadRequest)
+		if err.Error() == "phone number already in use" {
+			statusCode = http.StatusConflict
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"phone_verified": true})
+}
+
+// SendPhoneLoginCode texts a login code to a verified phone number
+func (h *AuthHandler) SendPhoneLoginCode(c *gin.Context) {
+	var req PhoneLoginCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SendPhoneLoginCode(c.Request.Context(), req.PhoneNumber); err != nil {
+		c.JSON(phoneErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same response whether or not the number belongs to an account
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the number is registered, a code has been sent"})
+}
+
+// LoginWithPhone exchanges a phone login code for an access token
+func (h *AuthHandler) LoginWithPhone(c *gin.Context) {
+	var req PhoneLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.service.LoginWithPhone(c.Request.Context(), req.PhoneNumber, req.Code)
+	if errors.Is(err, service.ErrMFARequired) {
+		c.JSON(http.StatusOK, MFARequiredResponse{
+			MFARequired: true,
+			MFAToken:    token,
+		})
+		return
+	}
+	if errors.Is(err, service.ErrMFAEnrollmentRequired) {
+		c.JSON(http.StatusForbidden, MFAEnrollmentRequiredResponse{
+			Error:              err.Error(),
+			MFAEnrollmentToken: token,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(phoneErrorStatus(err, http.StatusUnauthorized), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:    token,
+		UserUUID: user.UUID,
+		TenantID: user.TenantID,
+		Role:     user.Role,
+	})
+}
+
+func phoneErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, service.ErrSMSDisabled), errors.Is(err, service.ErrPhoneLoginDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrInvalidPhoneNumber):
+		return http.StatusBadRequest
+	case isTenantAccessError(err):
+		return http.StatusForbidden
+	default:
+		return fallback
+	}
+}
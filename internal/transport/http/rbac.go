@@ -0,0 +1,228 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest changes only the fields that are present
+type UpdateRoleRequest struct {
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type RoleResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	Global      bool      `json:"global"`
+	BuiltIn     bool      `json:"built_in"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type MemberResponse struct {
+	UserUUID string    `json:"user_uuid"`
+	Role     string    `json:"role"`
+	Status   string    `json:"status"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type PermissionsResponse struct {
+	TenantID    string   `json:"tenant_id"`
+	Permissions []string `json:"permissions"`
+}
+
+func newRoleResponse(role *model.Role) RoleResponse {
+	return RoleResponse{
+		ID:          role.UUID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		Global:      role.TenantUUID == "",
+		BuiltIn:     role.BuiltIn,
+		CreatedAt:   role.CreatedAt,
+	}
+}
+
+// actorFromContext describes the authenticated caller for administrative operations
+func actorFromContext(c *gin.Context) service.Actor {
+	return service.Actor{
+		UserUUID:   c.GetString("user_id"),
+		TenantUUID: c.GetString("tenant_id"),
+		Role:       c.GetString("role"),
+	}
+}
+
+func rbacErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrRBACDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrPermissionEscalate), isTenantAccessError(err):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrRoleNotFound), errors.Is(err, service.ErrMembershipNotFound):
+		return http.StatusNotFound
+	case err.Error() == "role already exists", err.Error() == "role is in use":
+		return http.StatusConflict
+	case err.Error() == "built-in roles cannot be deleted", err.Error() == "cannot change your own role":
+		return http.StatusForbidden
+	case err.Error() == "invalid role name", err.Error() == "too many permissions":
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, service.ErrInvalidPermission) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ListMyPermissions returns the permissions the current user holds in their current tenant
+func (h *AuthHandler) ListMyPermissions(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	permissions, err := h.service.UserPermissions(c.Request.Context(), c.GetString("user_id"), tenantID, c.GetString("role"))
+	if err != nil {
+		c.JSON(rbacErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, PermissionsResponse{TenantID: tenantID, Permissions: permissions})
+}
+
+// ListTenantRoles lists the global and custom roles usable in the current tenant
+func (h *AuthHandler) ListTenantRoles(c *gin.Context) {
+	h.listRoles(c, c.GetString("tenant_id"))
+}
+
+func (h *AuthHandler) CreateTenantRole(c *gin.Context) {
+	h.createRole(c, c.GetString("tenant_id"))
+}
+
+func (h *AuthHandler) UpdateTenantRole(c *gin.Context) {
+	h.updateRole(c, c.GetString("tenant_id"))
+}
+
+func (h *AuthHandler) DeleteTenantRole(c *gin.Context) {
+	h.deleteRole(c, c.GetString("tenant_id"))
+}
+
+// ListGlobalRoles lists the roles shared by all tenants
+func (h *AuthHandler) ListGlobalRoles(c *gin.Context) {
+	h.listRoles(c, "")
+}
+
+func (h *AuthHandler) CreateGlobalRole(c *gin.Context) {
+	h.createRole(c, "")
+}
+
+func (h *AuthHandler) UpdateGlobalRole(c *gin.Context) {
+	h.updateRole(c, "")
+}
+
+func (h *AuthHandler) DeleteGlobalRole(c *gin.Context) {
+	h.deleteRole(c, "")
+}
+
+func (h *AuthHandler) listRoles(c *gin.Context, tenantUUID string) {
+	roles, err := h.service.ListRoles(c.Request.Context(), tenantUUID)
+	if err != nil {
+		c.JSON(rbacErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]RoleResponse, 0, len(roles))
+	for i := range roles {
+		response = append(response, newRoleResponse(&roles[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": response})
+}
+
+func (h *AuthHandler) createRole(c *gin.Context, tenantUUID string) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.service.CreateRole(c.Request.Context(), actorFromContext(c), tenantUUID, req.Name, req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(rbacErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newRoleResponse(role))
+}
+
+func (h *AuthHandler) updateRole(c *gin.Context, tenantUUID string) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.service.UpdateRole(c.Request.Context(), actorFromContext(c), tenantUUID, c.Param("id"), req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(rbacErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newRoleResponse(role))
+}
+
+func (h *AuthHandler) deleteRole(c *gin.Context, tenantUUID string) {
+	if err := h.service.DeleteRole(c.Request.Context(), actorFromContext(c), tenantUUID, c.Param("id")); err != nil {
+		c.JSON(rbacErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role deleted"})
+}
+
+// ListMembers lists the members of the current tenant with their roles
+func (h *AuthHandler) ListMembers(c *gin.Context) {
+	members, err := h.service.ListMembers(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(rbacErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]MemberResponse, 0, len(members))
+	for _, member := range members {
+		response = append(response, MemberResponse{
+			UserUUID: member.UserUUID,
+			Role:     member.Role,
+			Status:   member.Status,
+			JoinedAt: member.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"members": response})
+}
+
+// AssignMemberRole changes the role of a member of the current tenant
+func (h *AuthHandler) AssignMemberRole(c *gin.Context) {
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.AssignMemberRole(c.Request.Context(), actorFromContext(c), c.Param("user_id"), req.Role); err != nil {
+		c.JSON(rbacErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated"})
+}
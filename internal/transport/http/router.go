@@ -2,12 +2,16 @@ package http
 
 import (
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/johnroshan2255/auth-service/internal/middleware"
 	"github.com/johnroshan2255/auth-service/internal/service"
 )
 
+// sensitiveActionMaxAge is how recently a user must have authenticated to perform sensitive actions
+const sensitiveActionMaxAge = 10 * time.Minute
+
 func SetupRouter(authService *service.AuthService) *gin.Engine {
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
@@ -21,9 +25,27 @@ func SetupRouter(authService *service.AuthService) *gin.Engine {
 
 	// Add CORS middleware for frontend
 	router.Use(middleware.CORSMiddleware())
+	router.Use(clientInfoMiddleware())
 
 	authHandler := NewAuthHandler(authService)
 
+	// OAuth 2.0 authorization server
+	oauth2 := router.Group("/oauth2")
+	{
+		oauth2.GET("/authorize", authHandler.Authorize)
+		oauth2.POST("/token", authHandler.Token)
+		oauth2.POST("/device_authorization", authHandler.DeviceAuthorization)
+		oauth2.POST("/register", authHandler.RegisterClient)
+		oauth2.GET("/userinfo", authHandler.UserInfo)
+		oauth2.POST("/userinfo", authHandler.UserInfo)
+		oauth2.GET("/logout", authHandler.EndSession)
+		oauth2.POST("/logout", authHandler.EndSession)
+	}
+
+	// OpenID Connect discovery
+	router.GET("/.well-known/openid-configuration", authHandler.OpenIDConfiguration)
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	api := router.Group("/api/v1")
 	{
 		api.GET("/health", authHandler.HealthCheck)
@@ -34,6 +56,141 @@ func SetupRouter(authService *service.AuthService) *gin.Engine {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/validate", authHandler.ValidateToken)
 			auth.GET("/me", middleware.AuthMiddleware(), authHandler.GetCurrentUser)
+			auth.POST("/magic-link", authHandler.RequestMagicLink)
+			auth.POST("/magic-link/consume", authHandler.ConsumeMagicLink)
+
+			// Login through upstream OpenID Connect providers
+			federated := auth.Group("/federated")
+			{
+				federated.GET("/providers", authHandler.ListIdentityProviders)
+				federated.POST("/:provider/start", authHandler.BeginFederatedLogin)
+				federated.POST("/callback", authHandler.CompleteFederatedLogin)
+			}
+
+			phone := auth.Group("/phone")
+			{
+				phone.POST("/verify/send", middleware.AuthMiddleware(), middleware.RejectDelegated(), authHandler.SendPhoneVerification)
+				phone.POST("/verify/confirm", middleware.AuthMiddleware(), middleware.RejectDelegated(), authHandler.ConfirmPhoneVerification)
+				phone.POST("/login/send", authHandler.SendPhoneLoginCode)
+				phone.POST("/login", authHandler.LoginWithPhone)
+			}
+
+			webauthn := auth.Group("/webauthn")
+			{
+				webauthn.POST("/login/begin", authHandler.BeginWebAuthnLogin)
+				webauthn.POST("/login/finish", authHandler.FinishWebAuthnLogin)
+				webauthn.POST("/register/begin", middleware.MFAEnrollmentAuthMiddleware(), middleware.RejectDelegated(), authHandler.BeginWebAuthnRegistration)
+				webauthn.POST("/register/finish", middleware.MFAEnrollmentAuthMiddleware(), middleware.RejectDelegated(), authHandler.FinishWebAuthnRegistration)
+				webauthn.GET("/credentials", middleware.AuthMiddleware(), authHandler.ListWebAuthnCredentials)
+				webauthn.DELETE("/credentials/:id", middleware.AuthMiddleware(), middleware.RequireRecentAuth(sensitiveActionMaxAge), authHandler.DeleteWebAuthnCredential)
+			}
+
+			auth.POST("/reauth", middleware.AuthMiddleware(), middleware.RejectDelegated(), authHandler.Reauthenticate)
+			auth.POST("/reauth/webauthn/begin", middleware.AuthMiddleware(), middleware.RejectDelegated(), authHandler.BeginWebAuthnReauth)
+			auth.POST("/switch-tenant", middleware.AuthMiddleware(), middleware.RejectDelegated(), authHandler.SwitchTenant)
+			auth.POST("/invitations/lookup", authHandler.LookupInvitation)
+			auth.POST("/discover", authHandler.DiscoverTenant)
+			auth.POST("/invitations/accept", middleware.AuthMiddleware(), middleware.RejectDelegated(), authHandler.AcceptInvitation)
+		}
+
+		// Logout confirmation from the consent page when a logout request had no current ID token
+		api.POST("/oauth2/logout/confirm", middleware.AuthMiddleware(), middleware.RejectDelegated(), authHandler.ConfirmEndSession)
+
+		// Consent page API for pending OAuth authorization requests
+		oauthRequests := api.Group("/oauth2/requests", middleware.AuthMiddleware())
+		{
+			oauthRequests.GET("/:id", authHandler.GetAuthorizationRequest)
+			oauthRequests.POST("/:id/decision", middleware.RejectDelegated(), authHandler.DecideAuthorization)
+		}
+
+		// Verification page API for the device authorization grant
+		oauthDevices := api.Group("/oauth2/devices", middleware.AuthMiddleware())
+		{
+			oauthDevices.GET("/:user_code", authHandler.GetDeviceAuthorization)
+			oauthDevices.POST("/:user_code/decision", middleware.RejectDelegated(), authHandler.DecideDeviceAuthorization)
+		}
+
+		me := api.Group("/me", middleware.AuthMiddleware())
+		{
+			me.GET("/sessions", authHandler.ListSessions)
+			me.DELETE("/sessions/:id", middleware.RejectDelegated(), authHandler.RevokeSession)
+			me.GET("/logins", authHandler.ListLoginEvents)
+			me.GET("/tenants", authHandler.ListUserTenants)
+			me.GET("/permissions", authHandler.ListMyPermissions)
+			me.GET("/identities", authHandler.ListLinkedIdentities)
+			me.POST("/identities/:provider/start", middleware.RejectDelegated(), middleware.RequireRecentAuth(sensitiveActionMaxAge), authHandler.BeginIdentityLink)
+			me.POST("/identities/callback", middleware.RejectDelegated(), authHandler.CompleteIdentityLink)
+			me.DELETE("/identities/:id", middleware.RejectDelegated(), middleware.RequireRecentAuth(sensitiveActionMaxAge), authHandler.UnlinkIdentity)
+		}
+
+		// Administration of the tenant the caller's token is scoped to
+		tenant := api.Group("/tenant", middleware.AuthMiddleware())
+		{
+			tenant.GET("/settings", middleware.RequirePermission(service.PermTenantRead), authHandler.GetTenantSettings)
+			tenant.PUT("/settings", middleware.RequirePermission(service.PermTenantWrite), authHandler.UpdateTenantSettings)
+
+			tenant.POST("/invitations", middleware.RequirePermission(service.PermInvitationsWrite), authHandler.CreateInvitation)
+			tenant.GET("/invitations", middleware.RequirePermission(service.PermInvitationsRead), authHandler.ListInvitations)
+			tenant.DELETE("/invitations/:id", middleware.RequirePermission(service.PermInvitationsWrite), authHandler.RevokeInvitation)
+
+			tenant.POST("/domains", middleware.RequirePermission(service.PermDomainsWrite), authHandler.CreateTenantDomain)
+			tenant.GET("/domains", middleware.RequirePermission(service.PermDomainsRead), authHandler.ListTenantDomains)
+			tenant.POST("/domains/:id/verify", middleware.RequirePermission(service.PermDomainsWrite), authHandler.VerifyTenantDomain)
+			tenant.PATCH("/domains/:id", middleware.RequirePermission(service.PermDomainsWrite), authHandler.UpdateTenantDomain)
+			tenant.DELETE("/domains/:id", middleware.RequirePermission(service.PermDomainsWrite), authHandler.DeleteTenantDomain)
+
+			tenant.GET("/join-requests", middleware.RequirePermission(service.PermMembersRead), authHandler.ListJoinRequests)
+			tenant.POST("/join-requests/:id/approve", middleware.RequirePermission(service.PermMembersWrite), authHandler.ApproveJoinRequest)
+			tenant.POST("/join-requests/:id/reject", middleware.RequirePermission(service.PermMembersWrite), authHandler.RejectJoinRequest)
+
+			tenant.GET("/members", middleware.RequirePermission(service.PermMembersRead), authHandler.ListMembers)
+			tenant.PUT("/members/:user_id/role", middleware.RequirePermission(service.PermMembersWrite), authHandler.AssignMemberRole)
+			tenant.PUT("/members/:user_id/attributes", middleware.RequirePermission(service.PermMembersWrite), authHandler.SetMemberAttributes)
+
+			tenant.GET("/roles", middleware.RequirePermission(service.PermRolesRead), authHandler.ListTenantRoles)
+			tenant.POST("/roles", middleware.RequirePermission(service.PermRolesWrite), authHandler.CreateTenantRole)
+			tenant.PATCH("/roles/:id", middleware.RequirePermission(service.PermRolesWrite), authHandler.UpdateTenantRole)
+			tenant.DELETE("/roles/:id", middleware.RequirePermission(service.PermRolesWrite), authHandler.DeleteTenantRole)
+
+			tenant.GET("/policies", middleware.RequirePermission(service.PermPoliciesRead), authHandler.ListAccessPolicies)
+			tenant.POST("/policies", middleware.RequirePermission(service.PermPoliciesWrite), authHandler.CreateAccessPolicy)
+			tenant.POST("/policies/dry-run", middleware.RequirePermission(service.PermPoliciesRead), authHandler.DryRunAccessPolicies)
+			tenant.PATCH("/policies/:id", middleware.RequirePermission(service.PermPoliciesWrite), authHandler.UpdateAccessPolicy)
+			tenant.DELETE("/policies/:id", middleware.RequirePermission(service.PermPoliciesWrite), authHandler.DeleteAccessPolicy)
+		}
+
+		// Platform administration
+		admin := api.Group("/admin", middleware.AuthMiddleware())
+		{
+			admin.POST("/tenants", middleware.RequirePermission(service.PermTenantsWrite), authHandler.CreateTenant)
+			admin.GET("/tenants", middleware.RequirePermission(service.PermTenantsRead), authHandler.ListTenants)
+			admin.GET("/tenants/:id", middleware.RequirePermission(service.PermTenantsRead), authHandler.GetTenant)
+			admin.PATCH("/tenants/:id", middleware.RequirePermission(service.PermTenantsWrite), authHandler.UpdateTenant)
+
+			admin.GET("/roles", middleware.RequirePermission(service.PermGlobalRolesWrite), authHandler.ListGlobalRoles)
+			admin.POST("/roles", middleware.RequirePermission(service.PermGlobalRolesWrite), authHandler.CreateGlobalRole)
+			admin.PATCH("/roles/:id", middleware.RequirePermission(service.PermGlobalRolesWrite), authHandler.UpdateGlobalRole)
+			admin.DELETE("/roles/:id", middleware.RequirePermission(service.PermGlobalRolesWrite), authHandler.DeleteGlobalRole)
+
+			admin.GET("/oauth-clients", middleware.RequirePermission(service.PermOAuthClientsRead), authHandler.ListOAuthClients)
+			admin.POST("/oauth-clients", middleware.RequirePermission(service.PermOAuthClientsWrite), authHandler.CreateOAuthClient)
+			admin.GET("/oauth-clients/:id", middleware.RequirePermission(service.PermOAuthClientsRead), authHandler.GetOAuthClient)
+			admin.PATCH("/oauth-clients/:id", middleware.RequirePermission(service.PermOAuthClientsWrite), authHandler.UpdateOAuthClient)
+			admin.DELETE("/oauth-clients/:id", middleware.RequirePermission(service.PermOAuthClientsWrite), authHandler.DeleteOAuthClient)
+			admin.POST("/oauth-clients/:id/secret", middleware.RequirePermission(service.PermOAuthClientsWrite), authHandler.RotateOAuthClientSecret)
+			admin.GET("/oauth-registration-tokens", middleware.RequirePermission(service.PermOAuthClientsRead), authHandler.ListInitialAccessTokens)
+			admin.POST("/oauth-registration-tokens", middleware.RequirePermission(service.PermOAuthClientsWrite), authHandler.CreateInitialAccessToken)
+			admin.DELETE("/oauth-registration-tokens/:id", middleware.RequirePermission(service.PermOAuthClientsWrite), authHandler.RevokeInitialAccessToken)
+
+			admin.GET("/service-keys", middleware.RequirePermission(service.PermServiceKeysRead), authHandler.ListServiceKeys)
+			admin.POST("/service-keys", middleware.RequirePermission(service.PermServiceKeysWrite), authHandler.CreateServiceKey)
+			admin.DELETE("/service-keys/:id", middleware.RequirePermission(service.PermServiceKeysWrite), authHandler.RevokeServiceKey)
+
+			admin.POST("/impersonation", middleware.RequirePermission(service.PermUsersImpersonate), middleware.RequireRecentAuth(sensitiveActionMaxAge), authHandler.Impersonate)
+			admin.GET("/audit-events", middleware.RequirePermission(service.PermAuditRead), authHandler.ListAuditEvents)
+
+			admin.GET("/users/:user_id/sessions", middleware.RequirePermission(service.PermSessionsRead), authHandler.ListUserSessions)
+			admin.DELETE("/users/:user_id/sessions/:id", middleware.RequirePermission(service.PermSessionsWrite), middleware.RejectDelegated(), authHandler.RevokeUserSession)
 		}
 	}
 
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type ServiceKeyRequest struct {
+	ServiceName    string     `json:"service_name" binding:"required"`
+	Description    string     `json:"description"`
+	AllowedMethods []string   `json:"allowed_methods" binding:"required"` // e.g. "auth.v1.AuthService" or "auth.v1.AuthService/ValidateToken"
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+type ServiceKeyResponse struct {
+	ID             string     `json:"id"`
+	Key            string     `json:"key,omitempty"` // only returned when created
+	KeyPrefix      string     `json:"key_prefix"`
+	ServiceName    string     `json:"service_name"`
+	Description    string     `json:"description"`
+	AllowedMethods []string   `json:"allowed_methods"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy      string     `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newServiceKeyResponse(key *model.ServiceKey) ServiceKeyResponse {
+	return ServiceKeyResponse{
+		ID:             key.UUID,
+		KeyPrefix:      key.KeyPrefix,
+		ServiceName:    key.ServiceName,
+		Description:    key.Description,
+		AllowedMethods: key.AllowedMethods,
+		ExpiresAt:      key.ExpiresAt,
+		LastUsedAt:     key.LastUsedAt,
+		RevokedAt:      key.RevokedAt,
+		CreatedBy:      key.CreatedBy,
+		CreatedAt:      key.CreatedAt,
+	}
+}
+
+func serviceKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrServiceKeysDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, service.ErrInvalidServiceKey):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrServiceKeyNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// ListServiceKeys lists gRPC service keys, optionally for one ?service=
+func (h *AuthHandler) ListServiceKeys(c *gin.Context) {
+	keys, err := h.service.ListServiceKeys(c.Request.Context(), c.Query("service"))
+	if err != nil {
+		c.JSON(serviceKeyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]ServiceKeyResponse, 0, len(keys))
+	for i := range keys {
+		response = append(response, newServiceKeyResponse(&keys[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"service_keys": response})
+}
+
+// CreateServiceKey issues a key; rotate by creating a new key for the service
+// and revoking the old one once callers have switched
+func (h *AuthHandler) CreateServiceKey(c *gin.Context) {
+	var req ServiceKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, rawKey, err := h.service.CreateServiceKey(c.Request.Context(), c.GetString("user_id"), service.ServiceKeyInput{
+		ServiceName:    req.ServiceName,
+		Description:    req.Description,
+		AllowedMethods: req.AllowedMethods,
+		ExpiresAt:      req.ExpiresAt,
+	})
+	if err != nil {
+		c.JSON(serviceKeyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := newServiceKeyResponse(key)
+	response.Key = rawKey
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *AuthHandler) RevokeServiceKey(c *gin.Context) {
+	if err := h.service.RevokeServiceKey(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(serviceKeyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "service key revoked"})
+}
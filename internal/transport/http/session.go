@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	AMR        []string  `json:"amr"`
+	MFA        bool      `json:"mfa"`
+	ClientID   string    `json:"client_id,omitempty"` // OAuth client signed in with this session
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ListSessions lists the devices the current user is signed in on
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.service.ListSessions(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessionResponses(sessions, c.GetString("session_id"))})
+}
+
+// RevokeSession signs the current user out of one device
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	if err := h.service.RevokeSession(c.Request.Context(), c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUserSessions lists the devices any user is signed in on, for support staff
+func (h *AuthHandler) ListUserSessions(c *gin.Context) {
+	sessions, err := h.service.ListSessions(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessionResponses(sessions, "")})
+}
+
+// RevokeUserSession signs any user out of one device, for support staff
+func (h *AuthHandler) RevokeUserSession(c *gin.Context) {
+	err := h.service.RevokeUserSession(c.Request.Context(), actorFromContext(c), c.Param("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// sessionResponses describes sessions; currentID marks the one making the request
+func sessionResponses(sessions []model.Session, currentID string) []SessionResponse {
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.UUID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			AMR:        strings.Split(session.AMR, ","),
+			MFA:        session.MFA,
+			ClientID:   session.ClientID,
+			Current:    session.UUID == currentID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+	return response
+}
+
+func sessionErrorStatus(err error) int {
+	if errors.Is(err, service.ErrSessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
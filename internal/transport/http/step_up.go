@@ -0,0 +1,60 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type ReauthRequest struct {
+	Password   string          `json:"password"`
+	CeremonyID string          `json:"ceremony_id"` // from /reauth/webauthn/begin
+	Credential json.RawMessage `json:"credential"`
+}
+
+// BeginWebAuthnReauth returns assertion options to re-verify the current user with a passkey
+func (h *AuthHandler) BeginWebAuthnReauth(c *gin.Context) {
+	ceremonyID, options, err := h.service.BeginWebAuthnReauth(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnBeginResponse{CeremonyID: ceremonyID, Options: options})
+}
+
+// Reauthenticate issues a short-lived elevated token for sensitive actions
+func (h *AuthHandler) Reauthenticate(c *gin.Context) {
+	var req ReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.service.Reauthenticate(c.Request.Context(), c.GetString("user_id"), c.GetString("tenant_id"), c.GetString("session_id"), req.Password, req.CeremonyID, req.Credential)
+	if err != nil {
+		statusCode := http.StatusUnauthorized
+		switch {
+		case errors.Is(err, service.ErrTooManyAttempts):
+			statusCode = http.StatusTooManyRequests
+		case errors.Is(err, service.ErrWebAuthnDisabled):
+			statusCode = http.StatusNotImplemented
+		case isTenantAccessError(err):
+			statusCode = http.StatusForbidden
+		case err.Error() == "password or passkey assertion required":
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:    token,
+		UserUUID: user.UUID,
+		TenantID: user.TenantID,
+		Role:     user.Role,
+	})
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type CreateTenantRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Slug string `json:"slug"` // optional, derived from the name when empty
+}
+
+type UpdateTenantRequest struct {
+	Name     *string         `json:"name"`
+	Status   *string         `json:"status"` // "active" or "suspended"
+	Settings json.RawMessage `json:"settings"`
+}
+
+// UpdateTenantSettingsRequest lets tenant admins rename their tenant and replace its
+// settings (including auth_policy). Suspension stays with platform admins.
+type UpdateTenantSettingsRequest struct {
+	Name     *string         `json:"name"`
+	Settings json.RawMessage `json:"settings" binding:"required"`
+}
+
+type TenantResponse struct {
+	ID        string          `json:"id"`
+	Slug      string          `json:"slug"`
+	Name      string          `json:"name"`
+	Status    string          `json:"status"`
+	Settings  json.RawMessage `json:"settings"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func newTenantResponse(tenant *model.Tenant) TenantResponse {
+	return TenantResponse{
+		ID:        tenant.UUID,
+		Slug:      tenant.Slug,
+		Name:      tenant.Name,
+		Status:    tenant.Status,
+		Settings:  json.RawMessage(tenant.Settings),
+		CreatedAt: tenant.CreatedAt,
+		UpdatedAt: tenant.UpdatedAt,
+	}
+}
+
+// CreateTenant provisions a tenant (admin only)
+func (h *AuthHandler) CreateTenant(c *gin.Context) {
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, err := h.service.CreateTenant(c.Request.Context(), req.Name, req.Slug)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if err.Error() == "tenant slug already exists" {
+			statusCode = http.StatusConflict
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newTenantResponse(tenant))
+}
+
+// ListTenants lists tenants (admin only)
+func (h *AuthHandler) ListTenants(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	tenants, err := h.service.ListTenants(c.Request.Context(), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]TenantResponse, 0, len(tenants))
+	for i := range tenants {
+		response = append(response, newTenantResponse(&tenants[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": response})
+}
+
+// GetTenant returns one tenant (admin only)
+func (h *AuthHandler) GetTenant(c *gin.Context) {
+	tenant, err := h.service.GetTenant(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrTenantNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTenantResponse(tenant))
+}
+
+// UpdateTenant renames, suspends or reconfigures a tenant (admin only)
+func (h *AuthHandler) UpdateTenant(c *gin.Context) {
+	var req UpdateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, err := h.service.UpdateTenant(c.Request.Context(), c.Param("id"), service.TenantUpdate{
+		Name:     req.Name,
+		Status:   req.Status,
+		Settings: req.Settings,
+	})
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if errors.Is(err, service.ErrTenantNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTenantResponse(tenant))
+}
+
+// GetTenantSettings returns the tenant the caller's token is scoped to
+func (h *AuthHandler) GetTenantSettings(c *gin.Context) {
+	tenant, err := h.service.GetTenant(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrTenantNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTenantResponse(tenant))
+}
+
+// UpdateTenantSettings renames or reconfigures the caller's tenant
+func (h *AuthHandler) UpdateTenantSettings(c *gin.Context) {
+	var req UpdateTenantSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, err := h.service.UpdateTenant(c.Request.Context(), c.GetString("tenant_id"), service.TenantUpdate{
+		Name:     req.Name,
+		Settings: req.Settings,
+	})
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		if errors.Is(err, service.ErrTenantNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTenantResponse(tenant))
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/model"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type CreateTenantDomainRequest struct {
+	Domain     string `json:"domain" binding:"required"`
+	JoinPolicy string `json:"join_policy"` // "none" (default), "auto_join" or "request"
+}
+
+type UpdateTenantDomainRequest struct {
+	JoinPolicy string `json:"join_policy" binding:"required"`
+}
+
+type TenantDomainResponse struct {
+	ID         string     `json:"id"`
+	Domain     string     `json:"domain"`
+	JoinPolicy string     `json:"join_policy"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	// DNS TXT record to publish to prove ownership of the domain
+	VerificationRecordName  string    `json:"verification_record_name"`
+	VerificationRecordValue string    `json:"verification_record_value"`
+	CreatedAt               time.Time `json:"created_at"`
+}
+
+type JoinRequestResponse struct {
+	ID        string    `json:"id"`
+	UserUUID  string    `json:"user_uuid"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DiscoverTenantRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type DiscoverTenantResponse struct {
+	TenantID     string   `json:"tenant_id"`
+	TenantSlug   string   `json:"tenant_slug"`
+	TenantName   string   `json:"tenant_name"`
+	LoginMethods []string `json:"login_methods"`
+}
+
+func newTenantDomainResponse(domain *model.TenantDomain) TenantDomainResponse {
+	recordName, recordValue := service.DomainVerificationRecord(domain)
+	return TenantDomainResponse{
+		ID:                      domain.UUID,
+		Domain:                  domain.Domain,
+		JoinPolicy:              domain.JoinPolicy,
+		Verified:                domain.VerifiedAt != nil,
+		VerifiedAt:              domain.VerifiedAt,
+		VerificationRecordName:  recordName,
+		VerificationRecordValue: recordValue,
+		CreatedAt:               domain.CreatedAt,
+	}
+}
+
+func tenantDomainErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrTenantDomainsDisabled):
+		return http.StatusNotImplemented
+	case err.Error() == "domain not found", err.Error() == "join request not found":
+		return http.StatusNotFound
+	case err.Error() == "domain already exists", err.Error() == "domain is verified by another tenant",
+		err.Error() == "membership already exists":
+		return http.StatusConflict
+	case err.Error() == "verification record not found":
+		return http.StatusUnprocessableEntity
+	case err.Error() == "invalid domain", err.Error() == "invalid join policy":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// DiscoverTenant tells the login page which tenant handles an email address
+func (h *AuthHandler) DiscoverTenant(c *gin.Context) {
+	var req DiscoverTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	discovery, err := h.service.DiscoverTenant(c.Request.Context(), req.Email)
+	if err != nil {
+		statusCode := http.StatusNotFound
+		if errors.Is(err, service.ErrTenantDomainsDisabled) {
+			statusCode = http.StatusNotImplemented
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, DiscoverTenantResponse{
+		TenantID:     discovery.Tenant.UUID,
+		TenantSlug:   discovery.Tenant.Slug,
+		TenantName:   discovery.Tenant.Name,
+		LoginMethods: discovery.LoginMethods,
+	})
+}
+
+// CreateTenantDomain claims an email domain for the admin's current tenant
+func (h *AuthHandler) CreateTenantDomain(c *gin.Context) {
+	var req CreateTenantDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.service.AddTenantDomain(c.Request.Context(), c.GetString("tenant_id"), req.Domain, req.JoinPolicy)
+	if err != nil {
+		c.JSON(tenantDomainErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newTenantDomainResponse(domain))
+}
+
+// ListTenantDomains lists the domains of the admin's current tenant
+func (h *AuthHandler) ListTenantDomains(c *gin.Context) {
+	domains, err := h.service.ListTenantDomains(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(tenantDomainErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]TenantDomainResponse, 0, len(domains))
+	for i := range domains {
+		response = append(response, newTenantDomainResponse(&domains[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"domains": response})
+}
+
+// VerifyTenantDomain checks the domain's DNS verification record
+func (h *AuthHandler) VerifyTenantDomain(c *gin.Context) {
+	domain, err := h.service.VerifyTenantDomain(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(tenantDomainErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTenantDomainResponse(domain))
+}
+
+// UpdateTenantDomain changes the domain's join policy
+func (h *AuthHandler) UpdateTenantDomain(c *gin.Context) {
+	var req UpdateTenantDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.service.UpdateTenantDomain(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"), req.JoinPolicy)
+	if err != nil {
+		c.JSON(tenantDomainErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTenantDomainResponse(domain))
+}
+
+// DeleteTenantDomain releases a domain
+func (h *AuthHandler) DeleteTenantDomain(c *gin.Context) {
+	if err := h.service.DeleteTenantDomain(c.Request.Context(), c.GetString("tenant_id"), c.Param("id")); err != nil {
+		c.JSON(tenantDomainErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListJoinRequests lists pending requests to join the admin's current tenant
+func (h *AuthHandler) ListJoinRequests(c *gin.Context) {
+	requests, err := h.service.ListJoinRequests(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(tenantDomainErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]JoinRequestResponse, 0, len(requests))
+	for _, request := range requests {
+		response = append(response, JoinRequestResponse{
+			ID:        request.UUID,
+			UserUUID:  request.UserUUID,
+			Status:    request.Status,
+			CreatedAt: request.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"join_requests": response})
+}
+
+// ApproveJoinRequest adds the requesting user to the tenant
+func (h *AuthHandler) ApproveJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, true)
+}
+
+// RejectJoinRequest declines a join request
+func (h *AuthHandler) RejectJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, false)
+}
+
+func (h *AuthHandler) decideJoinRequest(c *gin.Context, approve bool) {
+	request, err := h.service.DecideJoinRequest(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"), c.GetString("user_id"), approve)
+	if err != nil {
+		c.JSON(tenantDomainErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, JoinRequestResponse{
+		ID:        request.UUID,
+		UserUUID:  request.UserUUID,
+		Status:    request.Status,
+		CreatedAt: request.CreatedAt,
+	})
+}
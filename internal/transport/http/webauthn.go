@@ -0,0 +1,163 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnroshan2255/auth-service/internal/service"
+)
+
+type WebAuthnBeginLoginRequest struct {
+	MFAToken string `json:"mfa_token"` // optional: present when WebAuthn is used as a second factor
+}
+
+type WebAuthnFinishRequest struct {
+	CeremonyID string          `json:"ceremony_id" binding:"required"`
+	Name       string          `json:"name"` // registration only
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type WebAuthnBeginResponse struct {
+	CeremonyID string      `json:"ceremony_id"`
+	Options    interface{} `json:"options"`
+}
+
+type WebAuthnCredentialResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Transports string     `json:"transports,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// BeginWebAuthnRegistration returns credential creation options for the current user
+func (h *AuthHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	ceremonyID, options, err := h.service.BeginWebAuthnRegistration(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnBeginResponse{CeremonyID: ceremonyID, Options: options})
+}
+
+// FinishWebAuthnRegistration verifies the authenticator response and stores the passkey
+func (h *AuthHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	var req WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.service.FinishWebAuthnRegistration(c.Request.Context(), c.GetString("user_id"), req.CeremonyID, req.Name, req.Credential)
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebAuthnCredentialResponse{
+		ID:         credential.UUID,
+		Name:       credential.Name,
+		Transports: credential.Transports,
+		CreatedAt:  credential.CreatedAt,
+	})
+}
+
+// BeginWebAuthnLogin returns assertion options for a passkey login or second factor
+func (h *AuthHandler) BeginWebAuthnLogin(c *gin.Context) {
+	var req WebAuthnBeginLoginRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ceremonyID, options, err := h.service.BeginWebAuthnLogin(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		status := webAuthnErrorStatus(err)
+		if status == http.StatusBadRequest {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnBeginResponse{CeremonyID: ceremonyID, Options: options})
+}
+
+// FinishWebAuthnLogin verifies the assertion and returns the same response as Login
+func (h *AuthHandler) FinishWebAuthnLogin(c *gin.Context) {
+	var req WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.service.FinishWebAuthnLogin(c.Request.Context(), req.CeremonyID, req.Credential)
+	if err != nil {
+		status := webAuthnErrorStatus(err)
+		if status == http.StatusBadRequest {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:    token,
+		UserUUID: user.UUID,
+		TenantID: user.TenantID,
+		Role:     user.Role,
+	})
+}
+
+// ListWebAuthnCredentials lists the current user's passkeys
+func (h *AuthHandler) ListWebAuthnCredentials(c *gin.Context) {
+	credentials, err := h.service.ListWebAuthnCredentials(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(webAuthnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]WebAuthnCredentialResponse, 0, len(credentials))
+	for _, credential := range credentials {
+		response = append(response, WebAuthnCredentialResponse{
+			ID:         credential.UUID,
+			Name:       credential.Name,
+			Transports: credential.Transports,
+			CreatedAt:  credential.CreatedAt,
+			LastUsedAt: credential.LastUsedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": response})
+}
+
+// DeleteWebAuthnCredential removes one of the current user's passkeys
+func (h *AuthHandler) DeleteWebAuthnCredential(c *gin.Context) {
+	err := h.service.DeleteWebAuthnCredential(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		statusCode := webAuthnErrorStatus(err)
+		if err.Error() == "credential not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func webAuthnErrorStatus(err error) int {
+	if errors.Is(err, service.ErrWebAuthnDisabled) {
+		return http.StatusNotImplemented
+	}
+	if isTenantAccessError(err) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}